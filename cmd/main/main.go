@@ -79,15 +79,17 @@ func main() {
 	app.Use(compress.New(compress.Config{
 		Level: compress.Level(router.GZipLevel),
 		Next: func(c *fiber.Ctx) bool {
-			return strings.Contains(c.Path(), "docs")
+			// Event streams must reach the client as they are written
+			return strings.Contains(c.Path(), "docs") || strings.Contains(c.Path(), "/events/")
 		},
 	}))
 
 	// Router CORS
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: router.CORSOrigin,
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Admin-Secret, X-Request-ID",
-		AllowMethods: "GET,POST,PUT,PATCH,DELETE",
+		AllowOrigins:  router.CORSOrigin,
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Admin-Secret, X-Request-ID, Idempotency-Key, Upload-Offset, Upload-Length",
+		AllowMethods:  "GET,HEAD,POST,PUT,PATCH,DELETE",
+		ExposeHeaders: "Location, Idempotent-Replayed, Upload-Offset, Upload-Length, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After",
 	}))
 
 	// Router Security
@@ -853,6 +853,46 @@ func GetWebhookStats(c *fiber.Ctx) error {
 	return router.ResponseSuccessWithData(c, "Webhook statistics retrieved successfully", stats)
 }
 
+// @Summary     Get Webhook Alerts
+// @Description List recent webhook circuit breaker alerts across all devices (Admin only)
+// @Tags        Admin
+// @Produce     json
+// @Param       X-Admin-Secret header string true "Admin secret key"
+// @Param       limit query int false "Maximum number of alerts (default 100)"
+// @Success     200 {object} router.ResSuccess
+// @Failure     401 {object} router.ResError
+// @Failure     500 {object} router.ResError
+// @Router      /admin/webhooks/alerts [get]
+func GetWebhookAlerts(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	log.AdminOp(c, "GetWebhookAlerts").Info("Getting webhook alerts")
+
+	engine := pkgWhatsApp.GetWebhookEngine()
+	if engine == nil {
+		log.AdminOp(c, "GetWebhookAlerts").Error("Webhook engine not initialized")
+		return router.ResponseInternalError(c, "Webhook engine not initialized")
+	}
+
+	alerts, err := engine.Store().GetAlerts(ctx, limit)
+	if err != nil {
+		log.AdminOp(c, "GetWebhookAlerts").WithError(err).Error("Failed to get webhook alerts")
+		return router.ResponseInternalError(c, "Failed to get webhook alerts: "+err.Error())
+	}
+
+	log.AdminOp(c, "GetWebhookAlerts").WithField("count", len(alerts)).Info("Webhook alerts retrieved successfully")
+
+	return router.ResponseSuccessWithData(c, "Webhook alerts retrieved successfully", map[string]interface{}{"alerts": alerts})
+}
+
 // @Summary     Reconnect All Devices
 // @Description Attempt to reconnect all disconnected devices (Admin only)
 // @Tags        Admin
@@ -0,0 +1,69 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/env"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+)
+
+// ErrInvalidKey is returned for storage keys that are empty or try to escape the store root
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Store is a backend that archived media is written to
+type Store interface {
+	// Name identifies the backend ("local", "s3")
+	Name() string
+	// Put writes an object under the given key, replacing any existing object
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// SignedURL returns a time-limited URL that downloads the object without further authentication
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// NewFromEnv builds the store selected by WHATSAPP_MEDIA_STORAGE_BACKEND (local or s3)
+func NewFromEnv() (Store, error) {
+	backend := strings.ToLower(env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_BACKEND", "local"))
+	switch backend {
+	case "local":
+		// signed URLs must include HTTP_BASE_URL, which the /media/files/* route is mounted under
+		publicURL := strings.TrimRight(env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_PUBLIC_URL", ""), "/") + router.BaseURL
+		signingKey := env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_SIGNING_KEY", env.GetEnvStringOrDefault("JWT_SECRET_KEY", ""))
+		if signingKey == "" {
+			return nil, errors.New("WHATSAPP_MEDIA_STORAGE_SIGNING_KEY or JWT_SECRET_KEY is required for local media storage")
+		}
+		return NewLocalStore(
+			env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_LOCAL_DIR", "./data/media"),
+			publicURL,
+			[]byte(signingKey),
+		)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:        env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_S3_REGION", "us-east-1"),
+			Bucket:          env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_S3_BUCKET", ""),
+			AccessKeyID:     env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_S3_ACCESS_KEY", ""),
+			SecretAccessKey: env.GetEnvStringOrDefault("WHATSAPP_MEDIA_STORAGE_S3_SECRET_KEY", ""),
+			PathStyle:       env.GetEnvBoolOrDefault("WHATSAPP_MEDIA_STORAGE_S3_PATH_STYLE", true),
+		})
+	}
+	return nil, fmt.Errorf("unknown media storage backend: %s", backend)
+}
+
+// cleanKey normalizes a storage key and rejects keys that would escape the store root
+func cleanKey(key string) (string, error) {
+	key = strings.TrimLeft(strings.ReplaceAll(key, "\\", "/"), "/")
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	return path.Clean(key), nil
+}
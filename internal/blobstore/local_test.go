@@ -0,0 +1,136 @@
+package blobstore
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+)
+
+func newTestLocalStore(t *testing.T, publicURL string) *LocalStore {
+	t.Helper()
+	store, err := NewLocalStore(t.TempDir(), publicURL, []byte("test-signing-key"))
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	return store
+}
+
+// signedParams extracts the key, expires and signature a signed URL carries
+func signedParams(t *testing.T, signedURL string) (string, string, string) {
+	t.Helper()
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatalf("parse signed URL: %v", err)
+	}
+	idx := strings.Index(u.Path, LocalFilesPath)
+	if idx < 0 {
+		t.Fatalf("signed URL %q does not point at %s", signedURL, LocalFilesPath)
+	}
+	return u.Path[idx+len(LocalFilesPath):], u.Query().Get("expires"), u.Query().Get("signature")
+}
+
+func TestLocalStoreSignedURLVerifies(t *testing.T) {
+	store := newTestLocalStore(t, "https://api.example.com")
+
+	signedURL, err := store.SignedURL(context.Background(), "device-1/2026/01/02/msg 1.jpg", time.Hour)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	if !strings.HasPrefix(signedURL, "https://api.example.com/media/files/device-1/2026/01/02/msg%201.jpg?") {
+		t.Fatalf("unexpected signed URL %q", signedURL)
+	}
+
+	key, expires, signature := signedParams(t, signedURL)
+	if err := store.Verify(key, expires, signature); err != nil {
+		t.Fatalf("Verify rejected a fresh URL: %v", err)
+	}
+}
+
+func TestLocalStoreVerifyRejects(t *testing.T) {
+	store := newTestLocalStore(t, "")
+	signedURL, err := store.SignedURL(context.Background(), "device-1/file.jpg", time.Hour)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	key, expires, signature := signedParams(t, signedURL)
+
+	expired, err := store.SignedURL(context.Background(), "device-1/file.jpg", -time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	_, expiredAt, expiredSig := signedParams(t, expired)
+
+	other, err := NewLocalStore(t.TempDir(), "", []byte("another-signing-key"))
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	otherURL, _ := other.SignedURL(context.Background(), "device-1/file.jpg", time.Hour)
+	_, otherExpires, otherSig := signedParams(t, otherURL)
+
+	cases := map[string][3]string{
+		"other key":          {"device-1/other.jpg", expires, signature},
+		"extended expiry":    {key, expires + "0", signature},
+		"tampered signature": {key, expires, strings.Repeat("0", len(signature))},
+		"expired":            {key, expiredAt, expiredSig},
+		"foreign signature":  {key, otherExpires, otherSig},
+		"path traversal":     {"../" + key, expires, signature},
+		"missing expiry":     {key, "", signature},
+	}
+	for name, c := range cases {
+		if err := store.Verify(c[0], c[1], c[2]); err == nil {
+			t.Errorf("%s: Verify accepted an invalid URL", name)
+		}
+	}
+}
+
+func TestNewFromEnvSignedURLIncludesBasePath(t *testing.T) {
+	previous := router.BaseURL
+	router.BaseURL = "/api/v1"
+	t.Cleanup(func() { router.BaseURL = previous })
+
+	t.Setenv("WHATSAPP_MEDIA_STORAGE_BACKEND", "local")
+	t.Setenv("WHATSAPP_MEDIA_STORAGE_LOCAL_DIR", t.TempDir())
+	t.Setenv("WHATSAPP_MEDIA_STORAGE_PUBLIC_URL", "https://api.example.com/")
+	t.Setenv("WHATSAPP_MEDIA_STORAGE_SIGNING_KEY", "test-signing-key")
+
+	store, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("NewFromEnv: %v", err)
+	}
+	signedURL, err := store.SignedURL(context.Background(), "device-1/file.jpg", time.Hour)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	if want := "https://api.example.com/api/v1/media/files/device-1/file.jpg?"; !strings.HasPrefix(signedURL, want) {
+		t.Fatalf("signed URL %q does not start with %q", signedURL, want)
+	}
+}
+
+func TestLocalStorePutAndPath(t *testing.T) {
+	store := newTestLocalStore(t, "")
+	if err := store.Put(context.Background(), "device-1/a/b.txt", []byte("hello"), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	path, err := store.Path("device-1/a/b.txt")
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("read back %q, %v", data, err)
+	}
+	if filepath.Dir(filepath.Dir(path)) != filepath.Join(store.root, "device-1") {
+		t.Fatalf("object written outside its key directory: %s", path)
+	}
+	for _, key := range []string{"", "../escape", "a/../../b", "a//b"} {
+		if _, err := store.Path(key); err == nil {
+			t.Errorf("Path(%q) accepted an invalid key", key)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package campaign
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/validation"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// getDeviceContext extracts device context from auth middleware
+func getDeviceContext(c *fiber.Ctx) (deviceID string, jid string) {
+	deviceID = c.Locals("device_id").(string)
+	jidVal := c.Locals("device_jid")
+	if jidVal != nil {
+		jid = jidVal.(string)
+	}
+	return
+}
+
+type createCampaignRequest struct {
+	Name               string   `json:"name"`
+	Message            string   `json:"message"`
+	Recipients         []string `json:"recipients"`
+	IntervalMS         int64    `json:"interval_ms"`
+	TypingSimulation   *bool    `json:"typing_simulation"`
+	PresenceSimulation *bool    `json:"presence_simulation"`
+}
+
+func campaignID(c *fiber.Ctx) (int64, error) {
+	id, err := c.ParamsInt("campaign_id")
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid campaign_id")
+	}
+	return int64(id), nil
+}
+
+func campaignErrorResponse(c *fiber.Ctx, operation string, id int64, err error) error {
+	switch {
+	case errors.Is(err, pkgWhatsApp.ErrCampaignNotFound):
+		log.DeviceOpCtx(c, operation).WithField("campaign_id", id).Warn("Campaign not found")
+		return router.ResponseNotFound(c, "Campaign not found")
+	case errors.Is(err, pkgWhatsApp.ErrCampaignStateConflict):
+		log.DeviceOpCtx(c, operation).WithField("campaign_id", id).Warn("Campaign status does not allow this action")
+		return router.ResponseBadRequest(c, "Campaign status does not allow this action")
+	}
+	log.DeviceOpCtx(c, operation).WithField("campaign_id", id).WithError(err).Error("Campaign operation failed")
+	return router.ResponseInternalError(c, err.Error())
+}
+
+// CreateCampaign queues one text message for a list of recipients and starts sending in the background
+func CreateCampaign(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+
+	var req createCampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.DeviceOpCtx(c, "CreateCampaign").Warn("Failed to parse body request")
+		return router.ResponseBadRequest(c, "Failed parse body request")
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		log.DeviceOpCtx(c, "CreateCampaign").Warn("Message is required")
+		return router.ResponseBadRequest(c, "message is required")
+	}
+
+	seen := make(map[string]struct{}, len(req.Recipients))
+	recipients := make([]string, 0, len(req.Recipients))
+	for _, raw := range req.Recipients {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		r, err := validation.NormalizeRecipientJID(raw)
+		if err != nil {
+			log.DeviceOpCtx(c, "CreateCampaign").WithField("recipient", raw).Warn("Invalid recipient")
+			return router.ResponseBadRequest(c, fmt.Sprintf("invalid recipient %q: %s", raw, err.Error()))
+		}
+		if _, ok := seen[r]; ok {
+			continue
+		}
+		seen[r] = struct{}{}
+		recipients = append(recipients, r)
+	}
+	if len(recipients) == 0 {
+		log.DeviceOpCtx(c, "CreateCampaign").Warn("Recipients are required")
+		return router.ResponseBadRequest(c, "recipients is required")
+	}
+	if max := pkgWhatsApp.CampaignMaxRecipients(); len(recipients) > max {
+		log.DeviceOpCtx(c, "CreateCampaign").WithField("recipients", len(recipients)).Warn("Too many recipients")
+		return router.ResponseBadRequest(c, fmt.Sprintf("maximum %d recipients per campaign", max))
+	}
+	if req.IntervalMS < 0 {
+		return router.ResponseBadRequest(c, "interval_ms must be >= 0")
+	}
+
+	log.DeviceOpCtx(c, "CreateCampaign").WithField("recipients", len(recipients)).WithField("interval_ms", req.IntervalMS).Info("Creating campaign")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	campaign, err := pkgWhatsApp.CreateCampaign(ctx, jid, deviceID, pkgWhatsApp.CampaignInput{
+		Name:               strings.TrimSpace(req.Name),
+		Message:            req.Message,
+		Recipients:         recipients,
+		Interval:           time.Duration(req.IntervalMS) * time.Millisecond,
+		TypingSimulation:   req.TypingSimulation,
+		PresenceSimulation: req.PresenceSimulation,
+	})
+	if err != nil {
+		log.DeviceOpCtx(c, "CreateCampaign").WithError(err).Error("Failed to create campaign")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "CreateCampaign").WithField("campaign_id", campaign.ID).Info("Campaign created successfully")
+
+	return router.ResponseCreatedWithData(c, "Success create campaign", campaign)
+}
+
+// ListCampaigns lists the device's campaigns with their progress counters
+func ListCampaigns(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	campaigns, err := pkgWhatsApp.GetCampaigns(ctx, deviceID, limit, offset)
+	if err != nil {
+		log.DeviceOpCtx(c, "ListCampaigns").WithError(err).Error("Failed to list campaigns")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "ListCampaigns").WithField("count", len(campaigns)).Info("Campaigns listed successfully")
+
+	return router.ResponseSuccessWithData(c, "Success list campaigns", map[string]interface{}{
+		"campaigns": campaigns,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GetCampaign returns a campaign with its progress counters
+func GetCampaign(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := campaignID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "GetCampaign").Warn("Invalid campaign_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	campaign, err := pkgWhatsApp.GetCampaign(ctx, deviceID, id)
+	if err != nil {
+		return campaignErrorResponse(c, "GetCampaign", id, err)
+	}
+
+	return router.ResponseSuccessWithData(c, "Success get campaign", campaign)
+}
+
+// ListCampaignRecipients pages through the per-recipient status of a campaign
+func ListCampaignRecipients(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := campaignID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "ListCampaignRecipients").Warn("Invalid campaign_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", pkgWhatsApp.RecipientQueued, pkgWhatsApp.RecipientSending, pkgWhatsApp.RecipientSent, pkgWhatsApp.RecipientDelivered, pkgWhatsApp.RecipientRead, pkgWhatsApp.RecipientFailed:
+	default:
+		log.DeviceOpCtx(c, "ListCampaignRecipients").WithField("status", status).Warn("Invalid status filter")
+		return router.ResponseBadRequest(c, "status must be one of queued, sending, sent, delivered, read, failed")
+	}
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	recipients, err := pkgWhatsApp.GetCampaignRecipients(ctx, deviceID, id, status, limit, offset)
+	if err != nil {
+		return campaignErrorResponse(c, "ListCampaignRecipients", id, err)
+	}
+
+	return router.ResponseSuccessWithData(c, "Success list campaign recipients", map[string]interface{}{
+		"recipients": recipients,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// ExportCampaign returns the per-recipient results of a campaign as CSV
+func ExportCampaign(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := campaignID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "ExportCampaign").Warn("Invalid campaign_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	recipients, err := pkgWhatsApp.GetCampaignRecipients(ctx, deviceID, id, "", 0, 0)
+	if err != nil {
+		return campaignErrorResponse(c, "ExportCampaign", id, err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"chat_jid", "status", "message_id", "error", "sent_at", "delivered_at", "read_at"})
+	for _, r := range recipients {
+		_ = w.Write([]string{r.ChatJID, r.Status, r.MessageID, r.Error, formatCSVTime(r.SentAt), formatCSVTime(r.DeliveredAt), formatCSVTime(r.ReadAt)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.DeviceOpCtx(c, "ExportCampaign").WithField("campaign_id", id).WithError(err).Error("Failed to write CSV")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "ExportCampaign").WithField("campaign_id", id).WithField("rows", len(recipients)).Info("Campaign exported successfully")
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="campaign-`+strconv.FormatInt(id, 10)+`.csv"`)
+	return c.Send(buf.Bytes())
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// PauseCampaign stops sending until the campaign is resumed
+func PauseCampaign(c *fiber.Ctx) error {
+	return changeCampaignStatus(c, "PauseCampaign", "Success pause campaign", pkgWhatsApp.PauseCampaign)
+}
+
+// ResumeCampaign continues sending a paused campaign
+func ResumeCampaign(c *fiber.Ctx) error {
+	return changeCampaignStatus(c, "ResumeCampaign", "Success resume campaign", pkgWhatsApp.ResumeCampaign)
+}
+
+// CancelCampaign stops a campaign for good; unsent recipients are marked failed
+func CancelCampaign(c *fiber.Ctx) error {
+	return changeCampaignStatus(c, "CancelCampaign", "Success cancel campaign", pkgWhatsApp.CancelCampaign)
+}
+
+func changeCampaignStatus(c *fiber.Ctx, operation string, message string, change func(context.Context, string, int64) (*pkgWhatsApp.Campaign, error)) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := campaignID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, operation).Warn("Invalid campaign_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, operation).WithField("campaign_id", id).Info("Changing campaign status")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	campaign, err := change(ctx, deviceID, id)
+	if err != nil {
+		return campaignErrorResponse(c, operation, id, err)
+	}
+
+	log.DeviceOpCtx(c, operation).WithField("campaign_id", id).WithField("status", campaign.Status).Info("Campaign status changed successfully")
+
+	return router.ResponseSuccessWithData(c, message, campaign)
+}
@@ -3,6 +3,7 @@ package device
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"strconv"
 	"strings"
 
@@ -405,6 +406,61 @@ func GetProxy(c *fiber.Ctx) error {
 	})
 }
 
+// SetMediaAutoDownload enables or disables archiving of incoming media to the storage backend
+func SetMediaAutoDownload(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deviceID, _ := getDeviceContext(c)
+
+	var req typWhatsApp.RequestSetMediaAutoDownload
+	if err := c.BodyParser(&req); err != nil {
+		log.DeviceOpCtx(c, "SetMediaAutoDownload").Warn("Failed to parse body request")
+		return router.ResponseBadRequest(c, "Failed to parse body request")
+	}
+
+	log.DeviceOpCtx(c, "SetMediaAutoDownload").WithField("enabled", req.Enabled).Info("Setting media auto-download")
+
+	err := pkgWhatsApp.WhatsAppSetMediaAutoDownload(ctx, deviceID, req.Enabled)
+	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrMediaStorageNotConfigured) {
+			log.DeviceOpCtx(c, "SetMediaAutoDownload").Warn("Media storage backend not configured")
+			return router.ResponseBadRequest(c, err.Error())
+		}
+		log.DeviceOpCtx(c, "SetMediaAutoDownload").WithError(err).Error("Failed to set media auto-download")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "SetMediaAutoDownload").WithField("enabled", req.Enabled).Info("Media auto-download configured successfully")
+
+	return router.ResponseSuccess(c, "Media auto-download configured successfully")
+}
+
+// GetMediaAutoDownload gets the media auto-download configuration of the device
+func GetMediaAutoDownload(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	deviceID, _ := getDeviceContext(c)
+
+	log.DeviceOpCtx(c, "GetMediaAutoDownload").Info("Retrieving media auto-download configuration")
+
+	enabled, backend, err := pkgWhatsApp.WhatsAppGetMediaAutoDownload(ctx, deviceID)
+	if err != nil {
+		log.DeviceOpCtx(c, "GetMediaAutoDownload").WithError(err).Error("Failed to get media auto-download configuration")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	return router.ResponseSuccessWithData(c, "Media auto-download configuration", typWhatsApp.ResponseGetMediaAutoDownload{
+		Enabled:        enabled,
+		StorageBackend: backend,
+	})
+}
+
 // RegisterPushNotification registers device for push notifications
 func RegisterPushNotification(c *fiber.Ctx) error {
 	ctx := c.UserContext()
@@ -0,0 +1,161 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/eventstream"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhook"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+const (
+	streamBatchSize = 100
+	// streamFallbackPoll is a safety net: streams are woken by the hub when events are written,
+	// on this instance or (through LISTEN/NOTIFY) another one
+	streamFallbackPoll = 30 * time.Second
+	streamHeartbeat    = 15 * time.Second
+	streamQueryTimeout = 10 * time.Second
+)
+
+// getDeviceContext extracts device context from auth middleware
+func getDeviceContext(c *fiber.Ctx) (deviceID string, jid string) {
+	deviceID = c.Locals("device_id").(string)
+	jidVal := c.Locals("device_jid")
+	if jidVal != nil {
+		jid = jidVal.(string)
+	}
+	return
+}
+
+type streamOptions struct {
+	deviceID   string
+	cursor     int64
+	eventTypes []webhook.EventType
+}
+
+// parseStreamOptions reads the event filter (?events=a,b) and the resume cursor
+// (Last-Event-ID header or ?last_event_id=). Without a cursor the stream starts at the newest event.
+func parseStreamOptions(c *fiber.Ctx, hub *eventstream.Hub) (streamOptions, error) {
+	deviceID, _ := getDeviceContext(c)
+	opts := streamOptions{deviceID: deviceID}
+
+	for _, evt := range strings.Split(c.Query("events"), ",") {
+		if evt = strings.TrimSpace(evt); evt != "" {
+			opts.eventTypes = append(opts.eventTypes, webhook.EventType(evt))
+		}
+	}
+
+	rawCursor := c.Get("Last-Event-ID")
+	if rawCursor == "" {
+		rawCursor = c.Query("last_event_id")
+	}
+	if rawCursor != "" {
+		cursor, err := strconv.ParseInt(rawCursor, 10, 64)
+		if err != nil || cursor < 0 {
+			return opts, fmt.Errorf("invalid last event id: %s", rawCursor)
+		}
+		opts.cursor = cursor
+		return opts, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), streamQueryTimeout)
+	defer cancel()
+	latest, err := hub.LatestID(ctx, deviceID)
+	if err != nil {
+		return opts, err
+	}
+	opts.cursor = latest
+	return opts, nil
+}
+
+func fetchEvents(hub *eventstream.Hub, opts *streamOptions) ([]eventstream.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), streamQueryTimeout)
+	defer cancel()
+	events, err := hub.Fetch(ctx, opts.deviceID, opts.cursor, opts.eventTypes, streamBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		opts.cursor = events[len(events)-1].ID
+	}
+	return events, nil
+}
+
+// Stream sends the device's events as Server-Sent Events
+func Stream(c *fiber.Ctx) error {
+	hub := pkgWhatsApp.GetEventHub()
+	if !hub.Enabled() {
+		log.DeviceOpCtx(c, "EventStream").Warn("Event stream is disabled")
+		return router.ResponseBadRequest(c, "Event stream is disabled")
+	}
+
+	opts, err := parseStreamOptions(c, hub)
+	if err != nil {
+		log.DeviceOpCtx(c, "EventStream").WithError(err).Warn("Invalid stream parameters")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "EventStream").WithField("cursor", opts.cursor).WithField("event_count", len(opts.eventTypes)).Info("Event stream opened")
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	wake, unsubscribe := hub.Subscribe(opts.deviceID)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		poll := time.NewTicker(streamFallbackPoll)
+		defer poll.Stop()
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		fmt.Fprintf(w, "retry: 3000\n\n")
+		if w.Flush() != nil {
+			return
+		}
+
+		for {
+			events, err := fetchEvents(hub, &opts)
+			if err != nil {
+				log.EvtErr("stream", "sse-fetch", opts.deviceID, err)
+				fmt.Fprintf(w, "event: error\ndata: {\"error\":%q}\n\n", err.Error())
+				_ = w.Flush()
+				return
+			}
+			for _, evt := range events {
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evt.ID, evt.EventType, evt.Payload)
+			}
+			if len(events) > 0 {
+				if w.Flush() != nil {
+					return
+				}
+			}
+			if len(events) == streamBatchSize {
+				continue
+			}
+
+			select {
+			case <-wake:
+			case <-poll.C:
+			case <-heartbeat.C:
+				fmt.Fprintf(w, ": ping\n\n")
+				if w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
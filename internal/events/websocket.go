@@ -0,0 +1,127 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+const (
+	wsMaxClientMessage = 64 << 10
+	wsWriteTimeout     = 10 * time.Second
+)
+
+// The stream authenticates with the device token, never with cookies, so a page on another
+// origin gains nothing it could not do with the token itself and browsers may connect from anywhere
+var wsUpgrader = websocket.FastHTTPUpgrader{
+	CheckOrigin: func(ctx *fasthttp.RequestCtx) bool { return true },
+}
+
+// withEventID prefixes the stored event JSON object with its cursor so WebSocket clients can resume
+func withEventID(id int64, payload []byte) []byte {
+	if len(payload) < 2 || payload[0] != '{' {
+		return payload
+	}
+	msg := []byte(`{"id":` + strconv.FormatInt(id, 10))
+	if strings.TrimSpace(string(payload[1:])) != "}" {
+		msg = append(msg, ',')
+	}
+	return append(msg, payload[1:]...)
+}
+
+// readLoop drains client messages so pings get answered and a close is noticed; done is closed
+// when the client goes away. Client messages themselves are ignored.
+func readLoop(conn *websocket.Conn, done chan<- struct{}) {
+	defer close(done)
+	conn.SetReadLimit(wsMaxClientMessage)
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(conn *websocket.Conn, payload []byte) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteMessage(websocket.TextMessage, payload)
+}
+
+// WebSocket streams the device's events over a WebSocket connection. Each text message is the
+// webhook event JSON with an extra "id" field to pass back as last_event_id when reconnecting.
+func WebSocket(c *fiber.Ctx) error {
+	hub := pkgWhatsApp.GetEventHub()
+	if !hub.Enabled() {
+		log.DeviceOpCtx(c, "EventWebSocket").Warn("Event stream is disabled")
+		return router.ResponseBadRequest(c, "Event stream is disabled")
+	}
+
+	if !websocket.FastHTTPIsWebSocketUpgrade(c.Context()) {
+		log.DeviceOpCtx(c, "EventWebSocket").Warn("Request is not a WebSocket upgrade")
+		return router.ResponseBadRequest(c, "WebSocket upgrade required")
+	}
+
+	opts, err := parseStreamOptions(c, hub)
+	if err != nil {
+		log.DeviceOpCtx(c, "EventWebSocket").WithError(err).Warn("Invalid stream parameters")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "EventWebSocket").WithField("cursor", opts.cursor).WithField("event_count", len(opts.eventTypes)).Info("Event WebSocket opened")
+
+	err = wsUpgrader.Upgrade(c.Context(), func(conn *websocket.Conn) {
+		defer conn.Close()
+		wake, unsubscribe := hub.Subscribe(opts.deviceID)
+		defer unsubscribe()
+
+		done := make(chan struct{})
+		go readLoop(conn, done)
+
+		poll := time.NewTicker(streamFallbackPoll)
+		defer poll.Stop()
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			events, err := fetchEvents(hub, &opts)
+			if err != nil {
+				log.EvtErr("stream", "ws-fetch", opts.deviceID, err)
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "internal error"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			for _, evt := range events {
+				if writeEvent(conn, withEventID(evt.ID, evt.Payload)) != nil {
+					return
+				}
+			}
+			if len(events) == streamBatchSize {
+				continue
+			}
+
+			select {
+			case <-done:
+				return
+			case <-wake:
+			case <-poll.C:
+			case <-heartbeat.C:
+				if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)) != nil {
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		// The upgrader has already answered the client with the handshake error
+		log.DeviceOpCtx(c, "EventWebSocket").WithError(err).Warn("WebSocket handshake failed")
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package eventstream
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhook"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/env"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+const (
+	publishBatchSize     = 100
+	publishFlushInterval = 250 * time.Millisecond
+
+	// notifyChannel is the Postgres NOTIFY channel the writer announces new events on,
+	// with the device ID as payload
+	notifyChannel = "wa_event_log"
+	// listenRetryDelay is the wait before the listener reconnects after losing its connection
+	listenRetryDelay = 5 * time.Second
+)
+
+// errListenUnsupported is returned when the database driver cannot LISTEN; subscribers then
+// only pick up events written by this instance, and the rest with their fallback poll
+var errListenUnsupported = errors.New("database driver does not support LISTEN")
+
+// ErrQueueFull is returned by Publish when the background writer is too far behind to take the event
+var ErrQueueFull = errors.New("event log queue is full")
+
+// Event is one entry of a device's event log; ID is the cursor clients resume from
+type Event struct {
+	ID        int64
+	EventType webhook.EventType
+	Payload   json.RawMessage
+}
+
+// Hub appends every dispatched event to wa_event_log and wakes local stream subscribers.
+// Subscribers read the log by cursor, so a reconnect (or another instance) picks up where it left off.
+// Events are written by one background writer in batches, so publishing never waits on the database.
+//
+// Every batch announces its devices with NOTIFY, and each instance keeps one LISTEN connection
+// that wakes its own subscribers, so streams on every instance see new events without polling.
+// Batches of all instances are serialized by an advisory lock, so event IDs become visible in
+// increasing order and a cursor never skips an event committed late.
+type Hub struct {
+	db          *sql.DB
+	enabled     bool
+	queue       chan pendingEvent
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+type pendingEvent struct {
+	deviceID  string
+	eventType webhook.EventType
+	payload   []byte
+}
+
+func NewHub(db *sql.DB) *Hub {
+	h := &Hub{
+		db:          db,
+		enabled:     env.GetEnvBoolOrDefault("EVENT_STREAM_ENABLED", false),
+		subscribers: make(map[string]map[chan struct{}]struct{}),
+	}
+	if h.enabled {
+		// EVENT_STREAM_QUEUE_SIZE: default 10000 events waiting for the writer
+		size := env.GetEnvIntOrDefault("EVENT_STREAM_QUEUE_SIZE", 10000)
+		if size < 100 {
+			size = 100
+		}
+		h.queue = make(chan pendingEvent, size)
+		go h.runWriter()
+		go h.runListener()
+	}
+	return h
+}
+
+func (h *Hub) Enabled() bool {
+	return h != nil && h.enabled
+}
+
+// Publish queues the event, in the same JSON shape webhooks receive, for the background writer,
+// which stores it and then wakes the device's subscribers
+func (h *Hub) Publish(ctx context.Context, event webhook.WebhookEvent) error {
+	if !h.Enabled() {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	select {
+	case h.queue <- pendingEvent{deviceID: event.DeviceID, eventType: event.EventType, payload: payload}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return ErrQueueFull
+	}
+}
+
+// runWriter collects queued events until a batch is full or the flush interval passes
+func (h *Hub) runWriter() {
+	ticker := time.NewTicker(publishFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]pendingEvent, 0, publishBatchSize)
+	for {
+		select {
+		case evt := <-h.queue:
+			batch = append(batch, evt)
+			if len(batch) < publishBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		h.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush writes a batch in one transaction and wakes the subscribers of every device in it
+func (h *Hub) flush(batch []pendingEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.insert(ctx, batch); err != nil {
+		log.SysErr("stream-write", err)
+		return
+	}
+	notified := make(map[string]bool, len(batch))
+	for _, evt := range batch {
+		if !notified[evt.deviceID] {
+			notified[evt.deviceID] = true
+			h.notify(evt.deviceID)
+		}
+	}
+}
+
+func (h *Hub) insert(ctx context.Context, batch []pendingEvent) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// IDs are taken from the sequence while the lock is held and it is released on commit, so no
+	// transaction can commit a lower ID after a higher one is visible
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, notifyChannel); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO wa_event_log (device_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3::jsonb, CURRENT_TIMESTAMP)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	devices := make(map[string]bool, len(batch))
+	for _, evt := range batch {
+		if _, err := stmt.ExecContext(ctx, evt.deviceID, evt.eventType, string(evt.payload)); err != nil {
+			return err
+		}
+		devices[evt.deviceID] = true
+	}
+	// delivered to the listeners of every instance when the transaction commits
+	for deviceID := range devices {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, deviceID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// runListener keeps a LISTEN connection open and wakes the subscribers of every device another
+// instance (or this one) writes events for. After a reconnect all subscribers are woken, since
+// notifications sent while the connection was down are lost.
+func (h *Hub) runListener() {
+	for {
+		err := h.listen(context.Background())
+		if errors.Is(err, errListenUnsupported) {
+			log.SysErr("stream-listen", err)
+			return
+		}
+		log.SysErr("stream-listen", err)
+		h.notifyAll()
+		time.Sleep(listenRetryDelay)
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) error {
+	conn, err := h.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		sc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errListenUnsupported
+		}
+		pgConn := sc.Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+			return err
+		}
+		// events written before LISTEN took effect would otherwise wait for the fallback poll
+		h.notifyAll()
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			h.notify(n.Payload)
+		}
+	})
+}
+
+// Subscribe returns a channel that is signalled whenever the device gets a new event
+func (h *Hub) Subscribe(deviceID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	if h.subscribers[deviceID] == nil {
+		h.subscribers[deviceID] = make(map[chan struct{}]struct{})
+	}
+	h.subscribers[deviceID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers[deviceID], ch)
+		if len(h.subscribers[deviceID]) == 0 {
+			delete(h.subscribers, deviceID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// notifyAll wakes every local subscriber
+func (h *Hub) notifyAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subscribers := range h.subscribers {
+		for ch := range subscribers {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (h *Hub) notify(deviceID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[deviceID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Fetch returns up to limit events of the device after the cursor, optionally restricted to event types
+func (h *Hub) Fetch(ctx context.Context, deviceID string, afterID int64, eventTypes []webhook.EventType, limit int) ([]Event, error) {
+	if eventTypes == nil {
+		eventTypes = []webhook.EventType{}
+	}
+	filter, err := json.Marshal(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, event_type, payload
+		FROM wa_event_log
+		WHERE device_id = $1 AND id > $2
+			AND ($3::jsonb = '[]'::jsonb OR event_type IN (SELECT jsonb_array_elements_text($3::jsonb)))
+		ORDER BY id
+		LIMIT $4
+	`, deviceID, afterID, string(filter), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.EventType, &payload); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// LatestID is the cursor of the newest event of the device, used when a client starts without one
+func (h *Hub) LatestID(ctx context.Context, deviceID string) (int64, error) {
+	var id sql.NullInt64
+	err := h.db.QueryRowContext(ctx, `SELECT MAX(id) FROM wa_event_log WHERE device_id = $1`, deviceID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id.Int64, nil
+}
+
+// Cleanup removes events older than the retention period; clients can only resume within it
+func (h *Hub) Cleanup(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+	result, err := h.db.ExecContext(ctx, `DELETE FROM wa_event_log WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
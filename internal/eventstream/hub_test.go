@@ -0,0 +1,40 @@
+package eventstream
+
+import "testing"
+
+func woken(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestNotifyWakesOnlyTheDevicesSubscribers(t *testing.T) {
+	h := &Hub{subscribers: make(map[string]map[chan struct{}]struct{})}
+	a1, unsubscribeA1 := h.Subscribe("device-a")
+	a2, _ := h.Subscribe("device-a")
+	b, _ := h.Subscribe("device-b")
+
+	h.notify("device-a")
+	h.notify("device-a") // a pending wake-up is not queued twice
+	if !woken(a1) || !woken(a2) {
+		t.Error("subscribers of device-a were not woken")
+	}
+	if woken(a1) {
+		t.Error("two notifications queued two wake-ups")
+	}
+	if woken(b) {
+		t.Error("device-b was woken by an event of device-a")
+	}
+
+	unsubscribeA1()
+	h.notifyAll()
+	if woken(a1) {
+		t.Error("an unsubscribed channel was woken")
+	}
+	if !woken(a2) || !woken(b) {
+		t.Error("notifyAll did not wake every subscriber")
+	}
+}
@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// getDeviceContext extracts device context from auth middleware
+func getDeviceContext(c *fiber.Ctx) (deviceID string, jid string) {
+	deviceID = c.Locals("device_id").(string)
+	jidVal := c.Locals("device_jid")
+	if jidVal != nil {
+		jid = jidVal.(string)
+	}
+	return
+}
+
+// GetJob returns the status of a send accepted with async=true
+func GetJob(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := c.ParamsInt("job_id")
+	if err != nil || id <= 0 {
+		log.DeviceOpCtx(c, "GetJob").Warn("Invalid job_id")
+		return router.ResponseBadRequest(c, "invalid job_id")
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	job, err := pkgWhatsApp.GetSendJob(ctx, deviceID, int64(id))
+	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrSendJobNotFound) {
+			log.DeviceOpCtx(c, "GetJob").WithField("job_id", id).Warn("Job not found")
+			return router.ResponseNotFound(c, "Job not found")
+		}
+		log.DeviceOpCtx(c, "GetJob").WithField("job_id", id).WithError(err).Error("Failed to get job")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	return router.ResponseSuccessWithData(c, "Success get job", job)
+}
@@ -0,0 +1,200 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// getDeviceContext extracts device context from auth middleware
+func getDeviceContext(c *fiber.Ctx) (deviceID string, jid string) {
+	deviceID = c.Locals("device_id").(string)
+	jidVal := c.Locals("device_jid")
+	if jidVal != nil {
+		jid = jidVal.(string)
+	}
+	return
+}
+
+func mediaID(c *fiber.Ctx) (int64, error) {
+	id, err := c.ParamsInt("media_id")
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid media_id")
+	}
+	return int64(id), nil
+}
+
+func libraryErrorResponse(c *fiber.Ctx, operation string, id int64, err error) error {
+	switch {
+	case errors.Is(err, pkgWhatsApp.ErrLibraryMediaNotFound):
+		log.DeviceOpCtx(c, operation).WithField("media_id", id).Warn("Media not found")
+		return router.ResponseNotFound(c, "Media not found")
+	case errors.Is(err, pkgWhatsApp.ErrLibraryMediaInvalid):
+		log.DeviceOpCtx(c, operation).WithError(err).Warn("Invalid media")
+		return router.ResponseBadRequest(c, err.Error())
+	case errors.Is(err, pkgWhatsApp.ErrMediaFetchFailed):
+		log.DeviceOpCtx(c, operation).WithError(err).Warn("Failed to fetch media_url")
+		return router.ResponseBadGateway(c, err.Error())
+	}
+	log.DeviceOpCtx(c, operation).WithField("media_id", id).WithError(err).Error("Media library operation failed")
+	return router.ResponseInternalError(c, err.Error())
+}
+
+// readLibraryInput takes the media to add from the multipart file, a media_url or media_base64
+func readLibraryInput(ctx context.Context, c *fiber.Ctx, kind string) (*pkgWhatsApp.MediaInput, error) {
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		buf := make([]byte, fileHeader.Size)
+		if _, err := io.ReadFull(file, buf); err != nil {
+			return nil, err
+		}
+		return &pkgWhatsApp.MediaInput{Data: buf, MimeType: fileHeader.Header.Get(fiber.HeaderContentType), FileName: fileHeader.Filename, Source: "file", Size: fileHeader.Size}, nil
+	}
+	if !pkgWhatsApp.IsValidMediaKind(kind) {
+		// FetchMediaURL and DecodeMediaBase64 treat unknown kinds as documents
+		return nil, errors.New("media_type must be one of image, video, audio, document, sticker")
+	}
+	if mediaURL := strings.TrimSpace(c.FormValue("media_url")); mediaURL != "" {
+		return pkgWhatsApp.FetchMediaURL(ctx, mediaURL, kind)
+	}
+	if raw := c.FormValue("media_base64"); raw != "" {
+		return pkgWhatsApp.DecodeMediaBase64(raw, kind)
+	}
+	return nil, errors.New("file, media_url or media_base64 is required")
+}
+
+// AddMedia stores media in the device's library and uploads it to WhatsApp once. Adding
+// bytes that are already in the library returns the existing media_id.
+func AddMedia(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+	kind := strings.ToLower(strings.TrimSpace(c.FormValue("media_type")))
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	input, err := readLibraryInput(ctx, c, kind)
+	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrMediaFetchFailed) {
+			return libraryErrorResponse(c, "AddMedia", 0, err)
+		}
+		log.DeviceOpCtx(c, "AddMedia").WithError(err).Warn("Invalid media input")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	fileName := strings.TrimSpace(c.FormValue("filename"))
+	if fileName == "" {
+		fileName = input.FileName
+	}
+
+	log.DeviceOpCtx(c, "AddMedia").WithField("media_type", kind).WithField("source", input.Source).WithField("size", input.Size).Info("Adding media to library")
+
+	media, err := pkgWhatsApp.AddLibraryMedia(ctx, jid, deviceID, kind, input.Data, input.MimeType, fileName)
+	if err != nil {
+		return libraryErrorResponse(c, "AddMedia", 0, err)
+	}
+
+	if media.Deduplicated {
+		log.DeviceOpCtx(c, "AddMedia").WithField("media_id", media.ID).Info("Media already in library")
+		return router.ResponseSuccessWithData(c, "Success add media", media)
+	}
+
+	log.DeviceOpCtx(c, "AddMedia").WithField("media_id", media.ID).Info("Media added successfully")
+
+	return router.ResponseCreatedWithData(c, "Success add media", media)
+}
+
+// ListMedia lists the device's media library, newest first
+func ListMedia(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+
+	kind := c.Query("media_type")
+	if kind != "" && !pkgWhatsApp.IsValidMediaKind(kind) {
+		log.DeviceOpCtx(c, "ListMedia").WithField("media_type", kind).Warn("Invalid media_type filter")
+		return router.ResponseBadRequest(c, "media_type must be one of image, video, audio, document, sticker")
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	media, err := pkgWhatsApp.GetLibraryMediaList(ctx, deviceID, kind, limit, offset)
+	if err != nil {
+		log.DeviceOpCtx(c, "ListMedia").WithError(err).Error("Failed to list media")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "ListMedia").WithField("count", len(media)).Info("Media listed successfully")
+
+	return router.ResponseSuccessWithData(c, "Success list media", map[string]interface{}{
+		"media":  media,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetMedia returns one library entry
+func GetMedia(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := mediaID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "GetMedia").Warn("Invalid media_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	media, err := pkgWhatsApp.GetLibraryMedia(ctx, deviceID, id)
+	if err != nil {
+		return libraryErrorResponse(c, "GetMedia", id, err)
+	}
+
+	return router.ResponseSuccessWithData(c, "Success get media", media)
+}
+
+// DeleteMedia removes a library entry
+func DeleteMedia(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := mediaID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "DeleteMedia").Warn("Invalid media_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := pkgWhatsApp.DeleteLibraryMedia(ctx, deviceID, id); err != nil {
+		return libraryErrorResponse(c, "DeleteMedia", id, err)
+	}
+
+	log.DeviceOpCtx(c, "DeleteMedia").WithField("media_id", id).Info("Media deleted successfully")
+
+	return router.ResponseSuccess(c, "Success delete media")
+}
@@ -0,0 +1,41 @@
+package media
+
+import (
+	"errors"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/blobstore"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// ServeFile serves archived media from the local storage backend to holders of a signed URL
+func ServeFile(c *fiber.Ctx) error {
+	key := c.Params("*")
+
+	store, ok := pkgWhatsApp.GetMediaStorage().(*blobstore.LocalStore)
+	if !ok {
+		return router.ResponseNotFound(c, "Local media storage is not enabled")
+	}
+
+	if err := store.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+		log.Print(c).WithField("storage_key", key).Warn("Rejected media file request with invalid signature")
+		return router.ResponseUnauthorized(c, err.Error())
+	}
+
+	path, err := store.Path(key)
+	if err != nil {
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return router.ResponseNotFound(c, "Media file not found")
+		}
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	return c.SendFile(path)
+}
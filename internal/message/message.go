@@ -3,6 +3,8 @@ package message
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"mime"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -188,20 +190,55 @@ func Reply(c *fiber.Ctx) error {
 	}
 
 	reqReply.MessageID = messageID
-
-	log.MessageOpCtx(c, "Reply", reqReply.ChatJID).WithField("reply_to_message_id", messageID).Info("Replying to message")
+	if reqReply.Text == "" {
+		reqReply.Text = reqReply.Message
+	}
+	if strings.TrimSpace(reqReply.Text) == "" {
+		log.MessageOpCtx(c, "Reply", reqReply.ChatJID).Warn("Text is required")
+		return router.ResponseBadRequest(c, "text is required")
+	}
+	if err := pkgWhatsApp.ValidateClientReference(reqReply.ClientReference, reqReply.Metadata); err != nil {
+		log.MessageOpCtx(c, "Reply", reqReply.ChatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
 
 	ctx := c.UserContext()
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	if reqReply.ChatJID == "" {
+		chatJID, err := pkgWhatsApp.WhatsAppGetReplyChat(ctx, deviceID, messageID)
+		if err != nil {
+			if errors.Is(err, pkgWhatsApp.ErrStoredMessageNotFound) {
+				log.MessageOpCtx(c, "Reply", "").WithField("reply_to_message_id", messageID).Warn("Missing chat_jid and message not found in message store")
+				return router.ResponseBadRequest(c, "chat_jid is required")
+			}
+			log.MessageOpCtx(c, "Reply", "").WithField("reply_to_message_id", messageID).WithError(err).Error("Failed to resolve reply chat")
+			return router.ResponseInternalError(c, err.Error())
+		}
+		reqReply.ChatJID = chatJID
+	}
+
+	log.MessageOpCtx(c, "Reply", reqReply.ChatJID).WithField("reply_to_message_id", messageID).Info("Replying to message")
+
 	opts := &pkgWhatsApp.SendOptions{
 		TypingSimulation:   reqReply.TypingSimulation,
 		PresenceSimulation: reqReply.PresenceSimulation,
+		ClientReference:    reqReply.ClientReference,
+		Metadata:           reqReply.Metadata,
+		ReplyTo: &pkgWhatsApp.ReplyTarget{
+			MessageID:   messageID,
+			Participant: reqReply.Participant,
+			Message:     reqReply.QuotedMessage,
+		},
 	}
 	msgID, err := pkgWhatsApp.WhatsAppSendText(ctx, jid, deviceID, reqReply.ChatJID, reqReply.Text, opts)
 	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrStoredMessageNotFound) {
+			log.MessageOpCtx(c, "Reply", reqReply.ChatJID).WithField("reply_to_message_id", messageID).Warn("Quoted message not found")
+			return router.ResponseNotFound(c, err.Error())
+		}
 		log.MessageOpCtx(c, "Reply", reqReply.ChatJID).WithField("reply_to_message_id", messageID).WithError(err).Error("Failed to reply to message")
 		return router.ResponseInternalError(c, err.Error())
 	}
@@ -229,6 +266,10 @@ func Forward(c *fiber.Ctx) error {
 		log.MessageOpCtx(c, "Forward", "").Warn("Missing to_chat_jid")
 		return router.ResponseBadRequest(c, "to_chat_jid is required")
 	}
+	if err := pkgWhatsApp.ValidateClientReference(reqForward.ClientReference, reqForward.Metadata); err != nil {
+		log.MessageOpCtx(c, "Forward", reqForward.ToChatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
 
 	log.MessageOpCtx(c, "Forward", reqForward.ToChatJID).WithField("message_id", messageID).Info("Forwarding message")
 
@@ -239,8 +280,17 @@ func Forward(c *fiber.Ctx) error {
 
 	toChatJID := pkgWhatsApp.WhatsAppGetJID(ctx, jid, deviceID, reqForward.ToChatJID)
 
-	newMsgID, err := pkgWhatsApp.WhatsAppForwardMessage(jid, deviceID, messageID, toChatJID)
+	opts := &pkgWhatsApp.SendOptions{ClientReference: reqForward.ClientReference, Metadata: reqForward.Metadata}
+	newMsgID, err := pkgWhatsApp.WhatsAppForwardMessage(ctx, jid, deviceID, messageID, toChatJID, opts)
 	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrStoredMessageNotFound) {
+			log.MessageOpCtx(c, "Forward", reqForward.ToChatJID).WithField("message_id", messageID).Warn("Message not found in message store")
+			return router.ResponseNotFound(c, err.Error())
+		}
+		if errors.Is(err, pkgWhatsApp.ErrMessageStoreDisabled) {
+			log.MessageOpCtx(c, "Forward", reqForward.ToChatJID).Warn("Message store is disabled")
+			return router.ResponseConflict(c, err.Error())
+		}
 		log.MessageOpCtx(c, "Forward", reqForward.ToChatJID).WithField("message_id", messageID).WithError(err).Error("Failed to forward message")
 		return router.ResponseInternalError(c, err.Error())
 	}
@@ -333,3 +383,91 @@ func StarMessage(c *fiber.Ctx) error {
 
 	return router.ResponseSuccess(c, "Message "+action+" successfully")
 }
+
+// DownloadMedia streams the decrypted media of a stored message
+func DownloadMedia(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+	messageID := c.Params("message_id")
+
+	log.MessageOpCtx(c, "DownloadMedia", "").WithField("message_id", messageID).Info("Downloading message media")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	media, err := pkgWhatsApp.WhatsAppDownloadStoredMedia(ctx, jid, deviceID, messageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pkgWhatsApp.ErrStoredMessageNotFound):
+			log.MessageOpCtx(c, "DownloadMedia", "").WithField("message_id", messageID).Warn("Message not found in message store")
+			return router.ResponseNotFound(c, err.Error())
+		case errors.Is(err, pkgWhatsApp.ErrMessageHasNoMedia):
+			log.MessageOpCtx(c, "DownloadMedia", "").WithField("message_id", messageID).Warn("Message has no media")
+			return router.ResponseBadRequest(c, err.Error())
+		case errors.Is(err, pkgWhatsApp.ErrMediaUnavailable):
+			log.MessageOpCtx(c, "DownloadMedia", "").WithField("message_id", messageID).WithError(err).Warn("Media no longer available")
+			return router.ResponseBadGateway(c, err.Error())
+		}
+		log.MessageOpCtx(c, "DownloadMedia", "").WithField("message_id", messageID).WithError(err).Error("Failed to download media")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.MessageOpCtx(c, "DownloadMedia", "").WithField("message_id", messageID).WithField("size", media.Size).Info("Media downloaded successfully")
+
+	c.Set(fiber.HeaderContentType, media.Mimetype)
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": media.FileName}))
+	// the body is closed, and its temporary file removed, once it has been sent
+	return c.SendStream(media.Body, int(media.Size))
+}
+
+// DownloadThumbnail returns the thumbnail image of a stored message
+func DownloadThumbnail(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+	messageID := c.Params("message_id")
+
+	log.MessageOpCtx(c, "DownloadThumbnail", "").WithField("message_id", messageID).Info("Downloading message thumbnail")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	thumbnail, mimeType, err := pkgWhatsApp.WhatsAppGetMessageThumbnail(ctx, jid, deviceID, messageID)
+	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrStoredMessageNotFound) || errors.Is(err, pkgWhatsApp.ErrNoThumbnail) {
+			log.MessageOpCtx(c, "DownloadThumbnail", "").WithField("message_id", messageID).Warn("Thumbnail not found")
+			return router.ResponseNotFound(c, err.Error())
+		}
+		log.MessageOpCtx(c, "DownloadThumbnail", "").WithField("message_id", messageID).WithError(err).Error("Failed to download thumbnail")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.MessageOpCtx(c, "DownloadThumbnail", "").WithField("message_id", messageID).Info("Thumbnail downloaded successfully")
+
+	c.Set(fiber.HeaderContentType, mimeType)
+	return c.Send(thumbnail)
+}
+
+// GetStatus returns the delivery status and timeline of a message sent through the API
+func GetStatus(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	messageID := c.Params("message_id")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	status, err := pkgWhatsApp.GetMessageStatus(ctx, deviceID, messageID)
+	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrMessageStatusNotFound) {
+			log.MessageOpCtx(c, "GetStatus", "").WithField("message_id", messageID).Warn("Message status not found")
+			return router.ResponseNotFound(c, err.Error())
+		}
+		log.MessageOpCtx(c, "GetStatus", "").WithField("message_id", messageID).WithError(err).Error("Failed to get message status")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	return router.ResponseSuccessWithData(c, "Success get message status", status)
+}
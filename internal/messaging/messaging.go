@@ -3,13 +3,20 @@ package messaging
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/forPelevin/gomoji"
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/scheduled"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/transcode"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/validation"
@@ -52,6 +59,149 @@ func parseOptionalBool(val string) *bool {
 	}
 }
 
+// replyTarget builds a quoted-reply target from an optional reply_to_message_id value
+func replyTarget(messageID string) *pkgWhatsApp.ReplyTarget {
+	messageID = strings.TrimSpace(messageID)
+	if messageID == "" {
+		return nil
+	}
+	return &pkgWhatsApp.ReplyTarget{MessageID: messageID}
+}
+
+// formMentions reads the mentions of a multipart send, given as repeated fields or a comma-separated list
+func formMentions(c *fiber.Ctx) []string {
+	values := []string{c.FormValue("mentions")}
+	if form, err := c.MultipartForm(); err == nil && len(form.Value["mentions"]) > 0 {
+		values = form.Value["mentions"]
+	}
+	var mentions []string
+	for _, v := range values {
+		for _, m := range strings.Split(v, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				mentions = append(mentions, m)
+			}
+		}
+	}
+	return mentions
+}
+
+// mentionTokens reports whether the @<number> tokens of a group text or caption are mentioned;
+// they are only when the request lists no mentions of its own
+func mentionTokens(mentions []string, mentionAll bool) bool {
+	return len(mentions) == 0 && !mentionAll
+}
+
+// formClientReference reads client_reference and metadata of a multipart send; metadata is a
+// JSON object of string values
+func formClientReference(c *fiber.Ctx) (string, map[string]string, error) {
+	clientReference := c.FormValue("client_reference")
+	var metadata map[string]string
+	if raw := strings.TrimSpace(c.FormValue("metadata")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			return "", nil, errors.New("metadata must be a JSON object of string values")
+		}
+	}
+	if err := pkgWhatsApp.ValidateClientReference(clientReference, metadata); err != nil {
+		return "", nil, err
+	}
+	return clientReference, metadata, nil
+}
+
+// stickerPack reads the sticker pack metadata of a sticker send; emojis is a comma-separated list
+func stickerPack(c *fiber.Ctx) (transcode.StickerMetadata, error) {
+	pack := transcode.StickerMetadata{
+		PackName:  strings.TrimSpace(c.FormValue("pack_name")),
+		Publisher: strings.TrimSpace(c.FormValue("publisher")),
+	}
+	for _, e := range strings.Split(c.FormValue("emojis"), ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		if !gomoji.ContainsEmoji(e) {
+			return pack, fmt.Errorf("%q is not an emoji", e)
+		}
+		pack.Emojis = append(pack.Emojis, e)
+	}
+	if len(pack.Emojis) > 3 {
+		return pack, errors.New("a sticker can have at most 3 emojis")
+	}
+	return pack, nil
+}
+
+// scheduledStickerPack keeps empty pack metadata out of scheduled payloads
+func scheduledStickerPack(pack transcode.StickerMetadata) *transcode.StickerMetadata {
+	if pack.IsZero() {
+		return nil
+	}
+	return &pack
+}
+
+var errMediaInputMissing = errors.New("file, media_url, media_base64, upload_id or media_id is required")
+
+// readMediaInput takes the media of a send request from the multipart file, a media_url
+// fetched by the server, inline media_base64, a finished resumable upload_id, or a media_id from
+// the media library, in that order of precedence. Uploads and library media are sent without
+// re-reading the file, so their bytes are only loaded when buffered is set (scheduled and async
+// sends store the media with the message).
+func readMediaInput(c *fiber.Ctx, kind string, buffered bool) (*pkgWhatsApp.MediaInput, error) {
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		fileBytes, err := convertFileToBytes(file)
+		if err != nil {
+			return nil, err
+		}
+		return &pkgWhatsApp.MediaInput{Data: fileBytes, FileName: fileHeader.Filename, Source: "file", Size: int64(len(fileBytes))}, nil
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if mediaURL := strings.TrimSpace(c.FormValue("media_url")); mediaURL != "" {
+		return pkgWhatsApp.FetchMediaURL(ctx, mediaURL, kind)
+	}
+	if raw := c.FormValue("media_base64"); raw != "" {
+		return pkgWhatsApp.DecodeMediaBase64(raw, kind)
+	}
+	if uploadID := strings.TrimSpace(c.FormValue("upload_id")); uploadID != "" {
+		deviceID, _ := getDeviceContext(c)
+		return pkgWhatsApp.MediaUploadInput(ctx, deviceID, uploadID, kind, buffered)
+	}
+	if raw := strings.TrimSpace(c.FormValue("media_id")); raw != "" {
+		mediaID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || mediaID <= 0 {
+			return nil, errors.New("invalid media_id")
+		}
+		deviceID, _ := getDeviceContext(c)
+		return pkgWhatsApp.LibraryMediaInput(ctx, deviceID, mediaID, kind, buffered)
+	}
+	return nil, errMediaInputMissing
+}
+
+// mediaInputErrorResponse maps readMediaInput errors: remote fetch failures are a bad gateway,
+// an unknown upload_id or media_id is not found, everything else is a problem with the request
+func mediaInputErrorResponse(c *fiber.Ctx, operation string, chatJID string, err error) error {
+	if errors.Is(err, pkgWhatsApp.ErrMediaFetchFailed) {
+		log.MessageOpCtx(c, operation, chatJID).WithError(err).Warn("Failed to fetch media_url")
+		return router.ResponseBadGateway(c, err.Error())
+	}
+	if errors.Is(err, pkgWhatsApp.ErrMediaUploadNotFound) {
+		log.MessageOpCtx(c, operation, chatJID).Warn("Upload not found")
+		return router.ResponseNotFound(c, "Upload not found")
+	}
+	if errors.Is(err, pkgWhatsApp.ErrLibraryMediaNotFound) {
+		log.MessageOpCtx(c, operation, chatJID).Warn("Media not found")
+		return router.ResponseNotFound(c, "Media not found")
+	}
+	log.MessageOpCtx(c, operation, chatJID).WithError(err).Warn("Invalid media input")
+	return router.ResponseBadRequest(c, err.Error())
+}
+
 func SendText(c *fiber.Ctx) error {
 	deviceID, jid := getDeviceContext(c)
 	chatJID := c.Params("chat_jid")
@@ -71,6 +221,31 @@ func SendText(c *fiber.Ctx) error {
 		log.MessageOpCtx(c, "SendText", chatJID).Warn("Text is required")
 		return router.ResponseBadRequest(c, "text is required")
 	}
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendText", chatJID, reqSendMessage.SendAt, reqSendMessage.Async || c.Query("async") == "true")
+	if handled {
+		return err
+	}
+	if err := validation.ValidateMentions(chatJID, reqSendMessage.Mentions, reqSendMessage.MentionAll); err != nil {
+		log.MessageOpCtx(c, "SendText", chatJID).Warn("Invalid mentions")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	if err := pkgWhatsApp.ValidateClientReference(reqSendMessage.ClientReference, reqSendMessage.Metadata); err != nil {
+		log.MessageOpCtx(c, "SendText", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledText, pkgWhatsApp.ScheduledPayload{
+		Text:               reqSendMessage.Text,
+		ReplyToMessageID:   strings.TrimSpace(reqSendMessage.ReplyMessageID),
+		Mentions:           reqSendMessage.Mentions,
+		MentionAll:         reqSendMessage.MentionAll,
+		MentionTokens:      mentionTokens(reqSendMessage.Mentions, reqSendMessage.MentionAll),
+		TypingSimulation:   reqSendMessage.TypingSimulation,
+		PresenceSimulation: reqSendMessage.PresenceSimulation,
+		ClientReference:    reqSendMessage.ClientReference,
+		Metadata:           reqSendMessage.Metadata,
+	}, nil); handled {
+		return err
+	}
 
 	log.MessageOpCtx(c, "SendText", chatJID).WithField("text_length", len(reqSendMessage.Text)).Info("Sending text message")
 
@@ -82,9 +257,19 @@ func SendText(c *fiber.Ctx) error {
 	opts := &pkgWhatsApp.SendOptions{
 		TypingSimulation:   reqSendMessage.TypingSimulation,
 		PresenceSimulation: reqSendMessage.PresenceSimulation,
+		ReplyTo:            replyTarget(reqSendMessage.ReplyMessageID),
+		Mentions:           reqSendMessage.Mentions,
+		MentionAll:         reqSendMessage.MentionAll,
+		MentionTokens:      mentionTokens(reqSendMessage.Mentions, reqSendMessage.MentionAll),
+		ClientReference:    reqSendMessage.ClientReference,
+		Metadata:           reqSendMessage.Metadata,
 	}
 	msgID, err := pkgWhatsApp.WhatsAppSendText(ctx, jid, deviceID, chatJID, reqSendMessage.Text, opts)
 	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrStoredMessageNotFound) {
+			log.MessageOpCtx(c, "SendText", chatJID).WithField("reply_to_message_id", reqSendMessage.ReplyMessageID).Warn("Quoted message not found")
+			return router.ResponseNotFound(c, err.Error())
+		}
 		log.MessageOpCtx(c, "SendText", chatJID).WithError(err).Error("Failed to send text message")
 		return router.ResponseInternalError(c, err.Error())
 	}
@@ -108,26 +293,48 @@ func SendImage(c *fiber.Ctx) error {
 	viewOnce := c.FormValue("view_once") == "true"
 	typingSimulation := parseOptionalBool(c.FormValue("typing_simulation"))
 	presenceSimulation := parseOptionalBool(c.FormValue("presence_simulation"))
-
-	fileHeader, err := c.FormFile("file")
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendImage", chatJID, c.FormValue("send_at"), c.FormValue("async") == "true")
+	if handled {
+		return err
+	}
+	clientReference, metadata, err := formClientReference(c)
 	if err != nil {
-		log.MessageOpCtx(c, "SendImage", chatJID).Warn("No file provided")
-		return router.ResponseBadRequest(c, "file is required")
+		log.MessageOpCtx(c, "SendImage", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	mentions := formMentions(c)
+	mentionAll := c.FormValue("mention_all") == "true"
+	if err := validation.ValidateMentions(chatJID, mentions, mentionAll); err != nil {
+		log.MessageOpCtx(c, "SendImage", chatJID).Warn("Invalid mentions")
+		return router.ResponseBadRequest(c, err.Error())
 	}
 
-	log.MessageOpCtx(c, "SendImage", chatJID).WithField("filename", fileHeader.Filename).WithField("size", fileHeader.Size).WithField("view_once", viewOnce).Info("Sending image")
-
-	file, err := fileHeader.Open()
+	media, err := readMediaInput(c, pkgWhatsApp.MediaKindImage, deferral.Deferred())
 	if err != nil {
-		log.MessageOpCtx(c, "SendImage", chatJID).WithError(err).Error("Failed to open file")
-		return router.ResponseInternalError(c, err.Error())
+		return mediaInputErrorResponse(c, "SendImage", chatJID, err)
+	}
+	fileBytes := media.Data
+	mimeType := media.MimeType
+	if mimeType == "" {
+		mimeType = "image/jpeg"
 	}
-	defer file.Close()
 
-	fileBytes, err := convertFileToBytes(file)
-	if err != nil {
-		log.MessageOpCtx(c, "SendImage", chatJID).WithError(err).Error("Failed to convert file to bytes")
-		return router.ResponseInternalError(c, err.Error())
+	log.MessageOpCtx(c, "SendImage", chatJID).WithField("source", media.Source).WithField("filename", media.FileName).WithField("size", media.Size).WithField("view_once", viewOnce).Info("Sending image")
+
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledImage, pkgWhatsApp.ScheduledPayload{
+		Caption:            caption,
+		MimeType:           mimeType,
+		ViewOnce:           viewOnce,
+		ReplyToMessageID:   strings.TrimSpace(c.FormValue("reply_to_message_id")),
+		Mentions:           mentions,
+		MentionAll:         mentionAll,
+		MentionTokens:      mentionTokens(mentions, mentionAll),
+		TypingSimulation:   typingSimulation,
+		PresenceSimulation: presenceSimulation,
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+	}, fileBytes); handled {
+		return err
 	}
 
 	ctx := c.UserContext()
@@ -138,8 +345,19 @@ func SendImage(c *fiber.Ctx) error {
 	opts := &pkgWhatsApp.SendOptions{
 		TypingSimulation:   typingSimulation,
 		PresenceSimulation: presenceSimulation,
+		ReplyTo:            replyTarget(c.FormValue("reply_to_message_id")),
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+		Mentions:           mentions,
+		MentionAll:         mentionAll,
+		MentionTokens:      mentionTokens(mentions, mentionAll),
+	}
+	var msgID string
+	if media.Stored() {
+		msgID, err = pkgWhatsApp.WhatsAppSendStoredMedia(ctx, jid, deviceID, chatJID, media, pkgWhatsApp.StoredMediaOptions{Caption: caption, ViewOnce: viewOnce}, opts)
+	} else {
+		msgID, err = pkgWhatsApp.WhatsAppSendImage(ctx, jid, deviceID, chatJID, fileBytes, mimeType, caption, viewOnce, opts)
 	}
-	msgID, err := pkgWhatsApp.WhatsAppSendImage(ctx, jid, deviceID, chatJID, fileBytes, "image/jpeg", caption, viewOnce, opts)
 	if err != nil {
 		log.MessageOpCtx(c, "SendImage", chatJID).WithError(err).Error("Failed to send image")
 		return router.ResponseInternalError(c, err.Error())
@@ -163,30 +381,55 @@ func SendDocument(c *fiber.Ctx) error {
 	caption := c.FormValue("caption")
 	typingSimulation := parseOptionalBool(c.FormValue("typing_simulation"))
 	presenceSimulation := parseOptionalBool(c.FormValue("presence_simulation"))
-
-	fileHeader, err := c.FormFile("file")
-	if err != nil {
-		log.MessageOpCtx(c, "SendDocument", chatJID).Warn("No file provided")
-		return router.ResponseBadRequest(c, "file is required")
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendDocument", chatJID, c.FormValue("send_at"), c.FormValue("async") == "true")
+	if handled {
+		return err
 	}
-
-	log.MessageOpCtx(c, "SendDocument", chatJID).WithField("filename", fileHeader.Filename).WithField("size", fileHeader.Size).Info("Sending document")
-
-	file, err := fileHeader.Open()
+	clientReference, metadata, err := formClientReference(c)
 	if err != nil {
-		log.MessageOpCtx(c, "SendDocument", chatJID).WithError(err).Error("Failed to open file")
-		return router.ResponseInternalError(c, err.Error())
+		log.MessageOpCtx(c, "SendDocument", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	mentions := formMentions(c)
+	mentionAll := c.FormValue("mention_all") == "true"
+	if err := validation.ValidateMentions(chatJID, mentions, mentionAll); err != nil {
+		log.MessageOpCtx(c, "SendDocument", chatJID).Warn("Invalid mentions")
+		return router.ResponseBadRequest(c, err.Error())
 	}
-	defer file.Close()
 
-	fileBytes, err := convertFileToBytes(file)
+	media, err := readMediaInput(c, pkgWhatsApp.MediaKindDocument, deferral.Deferred())
 	if err != nil {
-		log.MessageOpCtx(c, "SendDocument", chatJID).WithError(err).Error("Failed to convert file to bytes")
-		return router.ResponseInternalError(c, err.Error())
+		return mediaInputErrorResponse(c, "SendDocument", chatJID, err)
+	}
+	fileBytes := media.Data
+	mimeType := media.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
 	}
 
+	log.MessageOpCtx(c, "SendDocument", chatJID).WithField("source", media.Source).WithField("filename", media.FileName).WithField("size", media.Size).Info("Sending document")
+
+	if fileName == "" {
+		fileName = media.FileName
+	}
 	if fileName == "" {
-		fileName = fileHeader.Filename
+		fileName = "document"
+	}
+
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledDocument, pkgWhatsApp.ScheduledPayload{
+		Caption:            caption,
+		MimeType:           mimeType,
+		FileName:           fileName,
+		ReplyToMessageID:   strings.TrimSpace(c.FormValue("reply_to_message_id")),
+		Mentions:           mentions,
+		MentionAll:         mentionAll,
+		MentionTokens:      mentionTokens(mentions, mentionAll),
+		TypingSimulation:   typingSimulation,
+		PresenceSimulation: presenceSimulation,
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+	}, fileBytes); handled {
+		return err
 	}
 
 	ctx := c.UserContext()
@@ -197,8 +440,19 @@ func SendDocument(c *fiber.Ctx) error {
 	opts := &pkgWhatsApp.SendOptions{
 		TypingSimulation:   typingSimulation,
 		PresenceSimulation: presenceSimulation,
+		ReplyTo:            replyTarget(c.FormValue("reply_to_message_id")),
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+		Mentions:           mentions,
+		MentionAll:         mentionAll,
+		MentionTokens:      mentionTokens(mentions, mentionAll),
+	}
+	var msgID string
+	if media.Stored() {
+		msgID, err = pkgWhatsApp.WhatsAppSendStoredMedia(ctx, jid, deviceID, chatJID, media, pkgWhatsApp.StoredMediaOptions{Caption: caption, FileName: fileName}, opts)
+	} else {
+		msgID, err = pkgWhatsApp.WhatsAppSendDocument(ctx, jid, deviceID, chatJID, fileBytes, mimeType, fileName, caption, opts)
 	}
-	msgID, err := pkgWhatsApp.WhatsAppSendDocument(ctx, jid, deviceID, chatJID, fileBytes, "application/octet-stream", fileName, caption, opts)
 	if err != nil {
 		log.MessageOpCtx(c, "SendDocument", chatJID).WithError(err).Error("Failed to send document")
 		return router.ResponseInternalError(c, err.Error())
@@ -231,8 +485,16 @@ func GetMessages(c *fiber.Ctx) error {
 
 	chatID := pkgWhatsApp.WhatsAppGetJID(ctx, jid, deviceID, chatJID)
 
-	messages, err := pkgWhatsApp.WhatsAppGetChatHistory(jid, deviceID, chatID, limit, before, after)
+	messages, err := pkgWhatsApp.WhatsAppGetChatHistory(ctx, jid, deviceID, chatID, limit, before, after)
 	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrStoredMessageNotFound) {
+			log.MessageOpCtx(c, "GetMessages", chatJID).WithField("before", before).WithField("after", after).Warn("Cursor message not found")
+			return router.ResponseNotFound(c, err.Error())
+		}
+		if errors.Is(err, pkgWhatsApp.ErrMessageStoreDisabled) {
+			log.MessageOpCtx(c, "GetMessages", chatJID).Warn("Message store is disabled")
+			return router.ResponseConflict(c, err.Error())
+		}
 		log.MessageOpCtx(c, "GetMessages", chatJID).WithError(err).Error("Failed to get chat messages")
 		return router.ResponseInternalError(c, err.Error())
 	}
@@ -445,26 +707,48 @@ func SendVideo(c *fiber.Ctx) error {
 	viewOnce := c.FormValue("view_once") == "true"
 	typingSimulation := parseOptionalBool(c.FormValue("typing_simulation"))
 	presenceSimulation := parseOptionalBool(c.FormValue("presence_simulation"))
-
-	fileHeader, err := c.FormFile("file")
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendVideo", chatJID, c.FormValue("send_at"), c.FormValue("async") == "true")
+	if handled {
+		return err
+	}
+	clientReference, metadata, err := formClientReference(c)
 	if err != nil {
-		log.MessageOpCtx(c, "SendVideo", chatJID).Warn("No file provided")
-		return router.ResponseBadRequest(c, "file is required")
+		log.MessageOpCtx(c, "SendVideo", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	mentions := formMentions(c)
+	mentionAll := c.FormValue("mention_all") == "true"
+	if err := validation.ValidateMentions(chatJID, mentions, mentionAll); err != nil {
+		log.MessageOpCtx(c, "SendVideo", chatJID).Warn("Invalid mentions")
+		return router.ResponseBadRequest(c, err.Error())
 	}
 
-	log.MessageOpCtx(c, "SendVideo", chatJID).WithField("filename", fileHeader.Filename).WithField("size", fileHeader.Size).WithField("view_once", viewOnce).Info("Sending video")
-
-	file, err := fileHeader.Open()
+	media, err := readMediaInput(c, pkgWhatsApp.MediaKindVideo, deferral.Deferred())
 	if err != nil {
-		log.MessageOpCtx(c, "SendVideo", chatJID).WithError(err).Error("Failed to open file")
-		return router.ResponseInternalError(c, err.Error())
+		return mediaInputErrorResponse(c, "SendVideo", chatJID, err)
+	}
+	fileBytes := media.Data
+	mimeType := media.MimeType
+	if mimeType == "" {
+		mimeType = "video/mp4"
 	}
-	defer file.Close()
 
-	fileBytes, err := convertFileToBytes(file)
-	if err != nil {
-		log.MessageOpCtx(c, "SendVideo", chatJID).WithError(err).Error("Failed to convert file to bytes")
-		return router.ResponseInternalError(c, err.Error())
+	log.MessageOpCtx(c, "SendVideo", chatJID).WithField("source", media.Source).WithField("filename", media.FileName).WithField("size", media.Size).WithField("view_once", viewOnce).Info("Sending video")
+
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledVideo, pkgWhatsApp.ScheduledPayload{
+		Caption:            caption,
+		MimeType:           mimeType,
+		ViewOnce:           viewOnce,
+		ReplyToMessageID:   strings.TrimSpace(c.FormValue("reply_to_message_id")),
+		Mentions:           mentions,
+		MentionAll:         mentionAll,
+		MentionTokens:      mentionTokens(mentions, mentionAll),
+		TypingSimulation:   typingSimulation,
+		PresenceSimulation: presenceSimulation,
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+	}, fileBytes); handled {
+		return err
 	}
 
 	ctx := c.UserContext()
@@ -475,8 +759,19 @@ func SendVideo(c *fiber.Ctx) error {
 	opts := &pkgWhatsApp.SendOptions{
 		TypingSimulation:   typingSimulation,
 		PresenceSimulation: presenceSimulation,
+		ReplyTo:            replyTarget(c.FormValue("reply_to_message_id")),
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+		Mentions:           mentions,
+		MentionAll:         mentionAll,
+		MentionTokens:      mentionTokens(mentions, mentionAll),
+	}
+	var msgID string
+	if media.Stored() {
+		msgID, err = pkgWhatsApp.WhatsAppSendStoredMedia(ctx, jid, deviceID, chatJID, media, pkgWhatsApp.StoredMediaOptions{Caption: caption, ViewOnce: viewOnce}, opts)
+	} else {
+		msgID, err = pkgWhatsApp.WhatsAppSendVideo(ctx, jid, deviceID, chatJID, fileBytes, mimeType, caption, viewOnce, opts)
 	}
-	msgID, err := pkgWhatsApp.WhatsAppSendVideo(ctx, jid, deviceID, chatJID, fileBytes, "video/mp4", caption, viewOnce, opts)
 	if err != nil {
 		log.MessageOpCtx(c, "SendVideo", chatJID).WithError(err).Error("Failed to send video")
 		return router.ResponseInternalError(c, err.Error())
@@ -499,26 +794,40 @@ func SendAudio(c *fiber.Ctx) error {
 	isVoiceNote := c.FormValue("voice_note") == "true" || c.FormValue("ptt") == "true"
 	typingSimulation := parseOptionalBool(c.FormValue("typing_simulation"))
 	presenceSimulation := parseOptionalBool(c.FormValue("presence_simulation"))
-
-	fileHeader, err := c.FormFile("file")
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendAudio", chatJID, c.FormValue("send_at"), c.FormValue("async") == "true")
+	if handled {
+		return err
+	}
+	clientReference, metadata, err := formClientReference(c)
 	if err != nil {
-		log.MessageOpCtx(c, "SendAudio", chatJID).Warn("No file provided")
-		return router.ResponseBadRequest(c, "file is required")
+		log.MessageOpCtx(c, "SendAudio", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
 	}
 
-	log.MessageOpCtx(c, "SendAudio", chatJID).WithField("filename", fileHeader.Filename).WithField("size", fileHeader.Size).WithField("voice_note", isVoiceNote).Info("Sending audio")
-
-	file, err := fileHeader.Open()
+	// Voice notes are converted to OGG/Opus before upload, so stored media is read back
+	// and sent as bytes instead of reusing its existing upload
+	media, err := readMediaInput(c, pkgWhatsApp.MediaKindAudio, deferral.Deferred() || isVoiceNote)
 	if err != nil {
-		log.MessageOpCtx(c, "SendAudio", chatJID).WithError(err).Error("Failed to open file")
-		return router.ResponseInternalError(c, err.Error())
+		return mediaInputErrorResponse(c, "SendAudio", chatJID, err)
+	}
+	fileBytes := media.Data
+	mimeType := media.MimeType
+	if mimeType == "" {
+		mimeType = "audio/mpeg"
 	}
-	defer file.Close()
 
-	fileBytes, err := convertFileToBytes(file)
-	if err != nil {
-		log.MessageOpCtx(c, "SendAudio", chatJID).WithError(err).Error("Failed to convert file to bytes")
-		return router.ResponseInternalError(c, err.Error())
+	log.MessageOpCtx(c, "SendAudio", chatJID).WithField("source", media.Source).WithField("filename", media.FileName).WithField("size", media.Size).WithField("voice_note", isVoiceNote).Info("Sending audio")
+
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledAudio, pkgWhatsApp.ScheduledPayload{
+		MimeType:           mimeType,
+		VoiceNote:          isVoiceNote,
+		ReplyToMessageID:   strings.TrimSpace(c.FormValue("reply_to_message_id")),
+		TypingSimulation:   typingSimulation,
+		PresenceSimulation: presenceSimulation,
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+	}, fileBytes); handled {
+		return err
 	}
 
 	ctx := c.UserContext()
@@ -529,9 +838,21 @@ func SendAudio(c *fiber.Ctx) error {
 	opts := &pkgWhatsApp.SendOptions{
 		TypingSimulation:   typingSimulation,
 		PresenceSimulation: presenceSimulation,
+		ReplyTo:            replyTarget(c.FormValue("reply_to_message_id")),
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+	}
+	var msgID string
+	if media.Stored() && !isVoiceNote {
+		msgID, err = pkgWhatsApp.WhatsAppSendStoredMedia(ctx, jid, deviceID, chatJID, media, pkgWhatsApp.StoredMediaOptions{}, opts)
+	} else {
+		msgID, err = pkgWhatsApp.WhatsAppSendAudio(ctx, jid, deviceID, chatJID, fileBytes, mimeType, isVoiceNote, opts)
 	}
-	msgID, err := pkgWhatsApp.WhatsAppSendAudio(ctx, jid, deviceID, chatJID, fileBytes, "audio/mpeg", isVoiceNote, opts)
 	if err != nil {
+		if errors.Is(err, transcode.ErrUnsupported) {
+			log.MessageOpCtx(c, "SendAudio", chatJID).WithError(err).Warn("Voice note format not supported")
+			return router.ResponseBadRequest(c, err.Error())
+		}
 		log.MessageOpCtx(c, "SendAudio", chatJID).WithError(err).Error("Failed to send audio")
 		return router.ResponseInternalError(c, err.Error())
 	}
@@ -552,26 +873,40 @@ func SendSticker(c *fiber.Ctx) error {
 
 	typingSimulation := parseOptionalBool(c.FormValue("typing_simulation"))
 	presenceSimulation := parseOptionalBool(c.FormValue("presence_simulation"))
-
-	fileHeader, err := c.FormFile("file")
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendSticker", chatJID, c.FormValue("send_at"), c.FormValue("async") == "true")
+	if handled {
+		return err
+	}
+	clientReference, metadata, err := formClientReference(c)
 	if err != nil {
-		log.MessageOpCtx(c, "SendSticker", chatJID).Warn("No file provided")
-		return router.ResponseBadRequest(c, "file is required (must be WebP format)")
+		log.MessageOpCtx(c, "SendSticker", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
 	}
 
-	log.MessageOpCtx(c, "SendSticker", chatJID).WithField("filename", fileHeader.Filename).WithField("size", fileHeader.Size).Info("Sending sticker")
-
-	file, err := fileHeader.Open()
+	pack, err := stickerPack(c)
 	if err != nil {
-		log.MessageOpCtx(c, "SendSticker", chatJID).WithError(err).Error("Failed to open file")
-		return router.ResponseInternalError(c, err.Error())
+		log.MessageOpCtx(c, "SendSticker", chatJID).Warn("Invalid sticker pack metadata")
+		return router.ResponseBadRequest(c, err.Error())
 	}
-	defer file.Close()
 
-	fileBytes, err := convertFileToBytes(file)
+	// Stored stickers are already WebP; they are only re-read when pack metadata has to be embedded
+	media, err := readMediaInput(c, pkgWhatsApp.MediaKindSticker, deferral.Deferred() || !pack.IsZero())
 	if err != nil {
-		log.MessageOpCtx(c, "SendSticker", chatJID).WithError(err).Error("Failed to convert file to bytes")
-		return router.ResponseInternalError(c, err.Error())
+		return mediaInputErrorResponse(c, "SendSticker", chatJID, err)
+	}
+	fileBytes := media.Data
+
+	log.MessageOpCtx(c, "SendSticker", chatJID).WithField("source", media.Source).WithField("filename", media.FileName).WithField("size", media.Size).Info("Sending sticker")
+
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledSticker, pkgWhatsApp.ScheduledPayload{
+		ReplyToMessageID:   strings.TrimSpace(c.FormValue("reply_to_message_id")),
+		StickerPack:        scheduledStickerPack(pack),
+		TypingSimulation:   typingSimulation,
+		PresenceSimulation: presenceSimulation,
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+	}, fileBytes); handled {
+		return err
 	}
 
 	ctx := c.UserContext()
@@ -582,9 +917,21 @@ func SendSticker(c *fiber.Ctx) error {
 	opts := &pkgWhatsApp.SendOptions{
 		TypingSimulation:   typingSimulation,
 		PresenceSimulation: presenceSimulation,
+		ReplyTo:            replyTarget(c.FormValue("reply_to_message_id")),
+		ClientReference:    clientReference,
+		Metadata:           metadata,
+	}
+	var msgID string
+	if media.Stored() && pack.IsZero() {
+		msgID, err = pkgWhatsApp.WhatsAppSendStoredMedia(ctx, jid, deviceID, chatJID, media, pkgWhatsApp.StoredMediaOptions{}, opts)
+	} else {
+		msgID, err = pkgWhatsApp.WhatsAppSendSticker(ctx, jid, deviceID, chatJID, fileBytes, pack, opts)
 	}
-	msgID, err := pkgWhatsApp.WhatsAppSendSticker(ctx, jid, deviceID, chatJID, fileBytes, opts)
 	if err != nil {
+		if errors.Is(err, transcode.ErrUnsupported) {
+			log.MessageOpCtx(c, "SendSticker", chatJID).WithError(err).Warn("Sticker format not supported")
+			return router.ResponseBadRequest(c, err.Error())
+		}
 		log.MessageOpCtx(c, "SendSticker", chatJID).WithError(err).Error("Failed to send sticker")
 		return router.ResponseInternalError(c, err.Error())
 	}
@@ -614,6 +961,24 @@ func SendLocation(c *fiber.Ctx) error {
 		log.MessageOpCtx(c, "SendLocation", chatJID).Warn("Invalid coordinates")
 		return router.ResponseBadRequest(c, "latitude and longitude are required")
 	}
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendLocation", chatJID, req.SendAt, req.Async || c.Query("async") == "true")
+	if handled {
+		return err
+	}
+	if err := pkgWhatsApp.ValidateClientReference(req.ClientReference, req.Metadata); err != nil {
+		log.MessageOpCtx(c, "SendLocation", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledLocation, pkgWhatsApp.ScheduledPayload{
+		Latitude:        req.Latitude,
+		Longitude:       req.Longitude,
+		Name:            req.Name,
+		Address:         req.Address,
+		ClientReference: req.ClientReference,
+		Metadata:        req.Metadata,
+	}, nil); handled {
+		return err
+	}
 
 	log.MessageOpCtx(c, "SendLocation", chatJID).WithField("latitude", req.Latitude).WithField("longitude", req.Longitude).Info("Sending location")
 
@@ -622,7 +987,7 @@ func SendLocation(c *fiber.Ctx) error {
 		ctx = context.Background()
 	}
 
-	opts := &pkgWhatsApp.SendOptions{}
+	opts := &pkgWhatsApp.SendOptions{ClientReference: req.ClientReference, Metadata: req.Metadata}
 	msgID, err := pkgWhatsApp.WhatsAppSendLocation(ctx, jid, deviceID, chatJID, req.Latitude, req.Longitude, req.Name, req.Address, opts)
 	if err != nil {
 		log.MessageOpCtx(c, "SendLocation", chatJID).WithError(err).Error("Failed to send location")
@@ -658,6 +1023,22 @@ func SendContact(c *fiber.Ctx) error {
 		log.MessageOpCtx(c, "SendContact", chatJID).Warn("Contact phone is required")
 		return router.ResponseBadRequest(c, "phone is required")
 	}
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendContact", chatJID, req.SendAt, req.Async || c.Query("async") == "true")
+	if handled {
+		return err
+	}
+	if err := pkgWhatsApp.ValidateClientReference(req.ClientReference, req.Metadata); err != nil {
+		log.MessageOpCtx(c, "SendContact", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledContact, pkgWhatsApp.ScheduledPayload{
+		Name:            req.Name,
+		Phone:           req.Phone,
+		ClientReference: req.ClientReference,
+		Metadata:        req.Metadata,
+	}, nil); handled {
+		return err
+	}
 
 	log.MessageOpCtx(c, "SendContact", chatJID).WithField("name", req.Name).WithField("phone", req.Phone).Info("Sending contact")
 
@@ -666,7 +1047,7 @@ func SendContact(c *fiber.Ctx) error {
 		ctx = context.Background()
 	}
 
-	opts := &pkgWhatsApp.SendOptions{}
+	opts := &pkgWhatsApp.SendOptions{ClientReference: req.ClientReference, Metadata: req.Metadata}
 	msgID, err := pkgWhatsApp.WhatsAppSendContact(ctx, jid, deviceID, chatJID, req.Name, req.Phone, opts)
 	if err != nil {
 		log.MessageOpCtx(c, "SendContact", chatJID).WithError(err).Error("Failed to send contact")
@@ -703,6 +1084,10 @@ func SendLinkPreview(c *fiber.Ctx) error {
 		log.MessageOpCtx(c, "SendLinkPreview", chatJID).Warn("URL is required")
 		return router.ResponseBadRequest(c, "url is required")
 	}
+	if err := pkgWhatsApp.ValidateClientReference(req.ClientReference, req.Metadata); err != nil {
+		log.MessageOpCtx(c, "SendLinkPreview", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
 
 	log.MessageOpCtx(c, "SendLinkPreview", chatJID).WithField("url", req.URL).Info("Sending message with link preview")
 
@@ -711,7 +1096,7 @@ func SendLinkPreview(c *fiber.Ctx) error {
 		ctx = context.Background()
 	}
 
-	opts := &pkgWhatsApp.SendOptions{}
+	opts := &pkgWhatsApp.SendOptions{ClientReference: req.ClientReference, Metadata: req.Metadata}
 	msgID, err := pkgWhatsApp.WhatsAppSendTextWithLinkPreview(ctx, jid, deviceID, chatJID, req.Text, req.URL, req.Title, req.Description, req.Thumbnail, opts)
 	if err != nil {
 		log.MessageOpCtx(c, "SendLinkPreview", chatJID).WithError(err).Error("Failed to send link preview")
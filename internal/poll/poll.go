@@ -2,9 +2,11 @@ package poll
 
 import (
 	"context"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/scheduled"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/validation"
@@ -52,6 +54,15 @@ func CreatePoll(c *fiber.Ctx) error {
 		return router.ResponseBadRequest(c, "maximum 12 options allowed")
 	}
 
+	deferral, handled, err := scheduled.ParseDeferral(c, "CreatePoll", chatJID, req.SendAt, req.Async || c.Query("async") == "true")
+	if handled {
+		return err
+	}
+	if err := pkgWhatsApp.ValidateClientReference(req.ClientReference, req.Metadata); err != nil {
+		log.MessageOpCtx(c, "CreatePoll", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
 	log.MessageOpCtx(c, "CreatePoll", chatJID).WithField("question", req.Question).WithField("options_count", len(req.Options)).Info("Creating poll")
 
 	ctx := c.UserContext()
@@ -59,7 +70,13 @@ func CreatePoll(c *fiber.Ctx) error {
 		ctx = context.Background()
 	}
 
-	msgID, err := pkgWhatsApp.WhatsAppCreatePoll(ctx, jid, deviceID, chatJID, req.Question, req.Options, req.MultiAnswer)
+	payload := pkgWhatsApp.ScheduledPayload{Question: req.Question, Options: req.Options, MultiAnswer: req.MultiAnswer, ClientReference: req.ClientReference, Metadata: req.Metadata}
+	if handled, err := deferral.MaybeSchedule(c, pkgWhatsApp.ScheduledPoll, payload, nil); handled {
+		return err
+	}
+
+	opts := &pkgWhatsApp.SendOptions{ClientReference: req.ClientReference, Metadata: req.Metadata}
+	msgID, err := pkgWhatsApp.WhatsAppCreatePoll(ctx, jid, deviceID, chatJID, req.Question, req.Options, req.MultiAnswer, opts)
 	if err != nil {
 		log.MessageOpCtx(c, "CreatePoll", chatJID).WithError(err).Error("Failed to create poll")
 		return router.ResponseInternalError(c, err.Error())
@@ -112,18 +129,31 @@ func VotePoll(c *fiber.Ctx) error {
 	return router.ResponseSuccess(c, "Success vote on poll")
 }
 
-// GetPollResults gets the results of a poll (placeholder - requires message history)
+// GetPollResults returns the aggregated votes of a stored poll
 func GetPollResults(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
 	pollID := c.Params("poll_id")
 
-	// Poll results are typically tracked via webhook events when votes come in
-	// This endpoint returns the poll message ID for reference
 	log.Poll(c, "GetPollResults", pollID).Info("Getting poll results")
 
-	return router.ResponseSuccessWithData(c, "Poll results are delivered via webhook events", map[string]interface{}{
-		"poll_id": pollID,
-		"note":    "Subscribe to poll.vote webhook events to receive real-time vote updates",
-	})
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	results, err := pkgWhatsApp.GetPollResults(ctx, deviceID, pollID)
+	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrPollNotFound) {
+			log.Poll(c, "GetPollResults", pollID).Warn("Poll not found")
+			return router.ResponseNotFound(c, "Poll not found")
+		}
+		log.Poll(c, "GetPollResults", pollID).WithError(err).Error("Failed to get poll results")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.Poll(c, "GetPollResults", pollID).WithField("total_voters", results.TotalVoters).Info("Poll results retrieved successfully")
+
+	return router.ResponseSuccessWithData(c, "Success get poll results", results)
 }
 
 // DeletePoll deletes a poll message
@@ -0,0 +1,53 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PostgresStore keeps one counter row per API key and window in wa_api_key_usage
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the counter table if it does not exist yet
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	if db == nil {
+		return nil, errors.New("postgres rate limit store needs a database")
+	}
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_api_key_usage (
+		api_key_id BIGINT NOT NULL,
+		window_start TIMESTAMP NOT NULL,
+		requests BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (api_key_id, window_start)
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Name() string {
+	return "postgres"
+}
+
+func (s *PostgresStore) Incr(ctx context.Context, apiKeyID int64, windowStart time.Time, window time.Duration) (int64, error) {
+	var requests int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO wa_api_key_usage (api_key_id, window_start, requests)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (api_key_id, window_start) DO UPDATE SET requests = wa_api_key_usage.requests + 1
+		RETURNING requests
+	`, apiKeyID, windowStart.UTC()).Scan(&requests)
+	return requests, err
+}
+
+func (s *PostgresStore) Cleanup(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM wa_api_key_usage WHERE window_start < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,65 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/env"
+)
+
+// Store counts the requests of an API key in fixed windows. Counters live outside the process,
+// so quotas survive restarts and are shared by every instance using the same store.
+type Store interface {
+	// Name identifies the backend ("postgres", "redis")
+	Name() string
+	// Incr adds one request to the window of the API key that starts at windowStart and returns
+	// the number of requests counted in that window so far
+	Incr(ctx context.Context, apiKeyID int64, windowStart time.Time, window time.Duration) (int64, error)
+	// Cleanup removes counters of windows that started before the given time
+	Cleanup(ctx context.Context, before time.Time) (int64, error)
+}
+
+// NewFromEnv builds the store selected by API_KEY_RATE_LIMIT_BACKEND (postgres or redis).
+// postgres keeps the counters in db; redis connects to REDIS_URL.
+func NewFromEnv(db *sql.DB) (Store, error) {
+	backend := strings.ToLower(env.GetEnvStringOrDefault("API_KEY_RATE_LIMIT_BACKEND", "postgres"))
+	switch backend {
+	case "postgres":
+		return NewPostgresStore(db)
+	case "redis":
+		return NewRedisStore(env.GetEnvStringOrDefault("REDIS_URL", "redis://localhost:6379/0"))
+	}
+	return nil, fmt.Errorf("unknown rate limit backend: %s", backend)
+}
+
+// Usage is the state of an API key's quota after counting a request
+type Usage struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Exceeded  bool
+}
+
+// Count adds one request of the API key to the fixed window that contains now and reports the
+// key's usage against limit. Windows are aligned to UTC multiples of window, so every instance
+// sharing the store counts into the same one.
+func Count(ctx context.Context, store Store, apiKeyID int64, limit int, window time.Duration, now time.Time) (Usage, error) {
+	windowStart := now.UTC().Truncate(window)
+	count, err := store.Incr(ctx, apiKeyID, windowStart, window)
+	if err != nil {
+		return Usage{}, err
+	}
+	u := Usage{
+		Limit: limit,
+		Reset: windowStart.Add(window),
+	}
+	if count > int64(limit) {
+		u.Exceeded = true
+	} else {
+		u.Remaining = limit - int(count)
+	}
+	return u, nil
+}
@@ -0,0 +1,146 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store that counts in a map keyed like the Redis store
+type memoryStore struct {
+	counts map[string]int64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{counts: make(map[string]int64)}
+}
+
+func (s *memoryStore) Name() string {
+	return "memory"
+}
+
+func (s *memoryStore) Incr(_ context.Context, apiKeyID int64, windowStart time.Time, _ time.Duration) (int64, error) {
+	key := fmt.Sprintf("%d:%d", apiKeyID, windowStart.Unix())
+	s.counts[key]++
+	return s.counts[key], nil
+}
+
+func (s *memoryStore) Cleanup(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestCountExceedsAfterLimit(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2026, 10, 17, 9, 15, 0, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		u, err := Count(context.Background(), store, 1, 3, time.Hour, now)
+		if err != nil {
+			t.Fatalf("Count: %v", err)
+		}
+		if u.Exceeded || u.Remaining != 3-i {
+			t.Fatalf("request %d: exceeded=%v remaining=%d, want false and %d", i, u.Exceeded, u.Remaining, 3-i)
+		}
+	}
+	u, err := Count(context.Background(), store, 1, 3, time.Hour, now)
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if !u.Exceeded || u.Remaining != 0 {
+		t.Fatalf("request over the limit: exceeded=%v remaining=%d, want true and 0", u.Exceeded, u.Remaining)
+	}
+
+	// other keys have their own quota
+	if u, _ := Count(context.Background(), store, 2, 3, time.Hour, now); u.Exceeded || u.Remaining != 2 {
+		t.Errorf("another key: exceeded=%v remaining=%d, want false and 2", u.Exceeded, u.Remaining)
+	}
+}
+
+func TestCountWindowBoundaries(t *testing.T) {
+	store := newMemoryStore()
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+
+	// every moment of the hour shares the window that resets at its end
+	for _, now := range []time.Time{start, start.Add(30 * time.Minute), start.Add(time.Hour - time.Nanosecond)} {
+		u, err := Count(context.Background(), store, 1, 10, time.Hour, now)
+		if err != nil {
+			t.Fatalf("Count: %v", err)
+		}
+		if !u.Reset.Equal(start.Add(time.Hour)) {
+			t.Errorf("at %s: reset %s, want %s", now.Format(time.TimeOnly), u.Reset, start.Add(time.Hour))
+		}
+	}
+	if u, _ := Count(context.Background(), store, 1, 10, time.Hour, start.Add(59*time.Minute)); u.Remaining != 6 {
+		t.Errorf("fourth request of the window: remaining %d, want 6", u.Remaining)
+	}
+
+	// the next window starts over
+	u, _ := Count(context.Background(), store, 1, 10, time.Hour, start.Add(time.Hour))
+	if u.Remaining != 9 || !u.Reset.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("next window: remaining %d reset %s, want 9 and %s", u.Remaining, u.Reset, start.Add(2*time.Hour))
+	}
+}
+
+func TestCountAlignsWindowsToUTC(t *testing.T) {
+	store := newMemoryStore()
+	// 09:15 in UTC-03:30 and 12:45 in UTC are the same instant, so they share a window
+	zone := time.FixedZone("UTC-03:30", -(3*3600 + 1800))
+	Count(context.Background(), store, 1, 10, time.Hour, time.Date(2026, 10, 17, 9, 15, 0, 0, zone))
+	u, _ := Count(context.Background(), store, 1, 10, time.Hour, time.Date(2026, 10, 17, 12, 50, 0, 0, time.UTC))
+	if u.Remaining != 8 {
+		t.Fatalf("remaining %d, want 8: the same instant in another zone counted in a different window", u.Remaining)
+	}
+	if want := time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC); !u.Reset.Equal(want) {
+		t.Errorf("reset %s, want %s", u.Reset, want)
+	}
+}
+
+// The Redis test runs against a real server:
+//
+//	REDIS_TEST_URL=redis://localhost:6379/15 go test ./internal/quota -run Redis
+//
+// It is skipped when REDIS_TEST_URL is not set.
+func TestRedisStoreCountsPerWindow(t *testing.T) {
+	rawURL := os.Getenv("REDIS_TEST_URL")
+	if rawURL == "" {
+		t.Skip("REDIS_TEST_URL not set")
+	}
+	store, err := NewRedisStore(rawURL)
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	ctx := context.Background()
+	apiKeyID := time.Now().UnixNano() // fresh keys on every run
+	start := time.Now().UTC().Truncate(time.Hour)
+	defer store.client.Del(ctx,
+		fmt.Sprintf("wa:quota:%d:%d", apiKeyID, start.Unix()),
+		fmt.Sprintf("wa:quota:%d:%d", apiKeyID, start.Add(time.Hour).Unix()))
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := store.Incr(ctx, apiKeyID, start, time.Hour)
+		if err != nil {
+			t.Fatalf("Incr: %v", err)
+		}
+		if got != want {
+			t.Fatalf("Incr = %d, want %d", got, want)
+		}
+	}
+	if got, _ := store.Incr(ctx, apiKeyID, start.Add(time.Hour), time.Hour); got != 1 {
+		t.Errorf("next window Incr = %d, want 1", got)
+	}
+
+	ttl, err := store.client.TTL(ctx, fmt.Sprintf("wa:quota:%d:%d", apiKeyID, start.Unix())).Result()
+	if err != nil {
+		t.Fatalf("TTL: %v", err)
+	}
+	if ttl <= time.Hour || ttl > 2*time.Hour {
+		t.Errorf("counter TTL %s, want between one and two windows", ttl)
+	}
+}
+
+func TestNewRedisStoreRejectsBadURL(t *testing.T) {
+	if _, err := NewRedisStore("http://localhost:6379"); err == nil {
+		t.Error("NewRedisStore accepted an http:// URL")
+	}
+}
@@ -0,0 +1,57 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisPingTimeout = 5 * time.Second
+
+// RedisStore keeps counters in Redis with INCR, letting each key expire after its window
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore parses a redis:// or rediss:// URL and checks the server answers
+func NewRedisStore(rawURL string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisPingTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis %s: %w", opts.Addr, err)
+	}
+	return &RedisStore{client: client}, nil
+}
+
+func (s *RedisStore) Name() string {
+	return "redis"
+}
+
+func (s *RedisStore) Incr(ctx context.Context, apiKeyID int64, windowStart time.Time, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("wa:quota:%d:%d", apiKeyID, windowStart.Unix())
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		// keep the counter one extra window so a slow clock on another instance still sees it
+		pipe.Expire(ctx, key, 2*window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Cleanup is a no-op: Redis expires the counters itself
+func (s *RedisStore) Cleanup(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
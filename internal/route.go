@@ -16,16 +16,24 @@ import (
 	ctlBot "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/bot"
 	ctlBusiness "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/business"
 	ctlCall "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/call"
+	ctlCampaign "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/campaign"
 	ctlDevice "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/device"
+	ctlEvents "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/events"
 	ctlGroups "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/groups"
 	ctlHistory "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/history"
 	ctlIndex "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/index"
+	ctlJobs "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/jobs"
+	ctlLibrary "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/library"
+	ctlMedia "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/media"
 	ctlMessage "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/message"
 	ctlMessaging "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/messaging"
 	ctlNewsletter "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/newsletter"
 	ctlPoll "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/poll"
 	ctlPresence "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/presence"
+	ctlScheduled "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/scheduled"
 	ctlStatus "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/status"
+	ctlTemplate "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/template"
+	ctlUpload "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/upload"
 	ctlUser "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/user"
 	ctlWebhooks "github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhooks"
 )
@@ -70,6 +78,7 @@ func Routes(app *fiber.App) {
 	app.Get(router.BaseURL+"/admin/devices/status", adminMiddleware, ctlAdmin.GetAllDevicesStatus)
 	app.Post(router.BaseURL+"/admin/devices/reconnect", adminMiddleware, ctlAdmin.ReconnectAllDevices)
 	app.Get(router.BaseURL+"/admin/webhooks/stats", adminMiddleware, ctlAdmin.GetWebhookStats)
+	app.Get(router.BaseURL+"/admin/webhooks/alerts", adminMiddleware, ctlAdmin.GetWebhookAlerts)
 
 	// API Key Management
 	app.Post(router.BaseURL+"/admin/api-keys", adminMiddleware, ctlAdmin.CreateAPIKey)
@@ -108,11 +117,16 @@ func Routes(app *fiber.App) {
 	})
 	app.Post(router.BaseURL+"/devices/token", tokenLimiter, ctlAuth.RegenerateToken)
 
+	// Archived media files (local storage backend, authorized by signed URL)
+	app.Get(router.BaseURL+"/media/files/*", ctlMedia.ServeFile)
+
 	// ============================================================
 	// DEVICE OPERATIONS (JWT Bearer token authentication)
 	// All WhatsApp operations require valid JWT token
 	// ============================================================
 	deviceAuthMiddleware := auth.DeviceAuth()
+	// Sends (POST under /chats, /messages, /status and /newsletters) honor an Idempotency-Key header
+	idempotencyMiddleware := auth.Idempotency()
 
 	// Device management
 	app.Get(router.BaseURL+"/devices/me", deviceAuthMiddleware, ctlDevice.GetDeviceMe)
@@ -127,6 +141,10 @@ func Routes(app *fiber.App) {
 	app.Get(router.BaseURL+"/devices/me/proxy", deviceAuthMiddleware, ctlDevice.GetProxy)
 	app.Post(router.BaseURL+"/devices/me/proxy", deviceAuthMiddleware, ctlDevice.SetProxy)
 
+	// Media Auto-Download (archive incoming media to the storage backend)
+	app.Get(router.BaseURL+"/devices/me/media-auto-download", deviceAuthMiddleware, ctlDevice.GetMediaAutoDownload)
+	app.Post(router.BaseURL+"/devices/me/media-auto-download", deviceAuthMiddleware, ctlDevice.SetMediaAutoDownload)
+
 	// Push Notification Registration
 	app.Post(router.BaseURL+"/devices/me/push-notifications", deviceAuthMiddleware, ctlDevice.RegisterPushNotification)
 	app.Get(router.BaseURL+"/devices/me/server-push-config", deviceAuthMiddleware, ctlDevice.GetServerPushConfig)
@@ -152,63 +170,106 @@ func Routes(app *fiber.App) {
 	app.Get(router.BaseURL+"/users/me/blocklist", deviceAuthMiddleware, ctlUser.GetBlocklist)
 
 	// Chat/Messaging routes
-	app.Post(router.BaseURL+"/chats/:chat_jid/messages", deviceAuthMiddleware, ctlMessaging.SendText)
-	app.Post(router.BaseURL+"/chats/:chat_jid/images", deviceAuthMiddleware, ctlMessaging.SendImage)
-	app.Post(router.BaseURL+"/chats/:chat_jid/videos", deviceAuthMiddleware, ctlMessaging.SendVideo)
-	app.Post(router.BaseURL+"/chats/:chat_jid/audio", deviceAuthMiddleware, ctlMessaging.SendAudio)
-	app.Post(router.BaseURL+"/chats/:chat_jid/stickers", deviceAuthMiddleware, ctlMessaging.SendSticker)
-	app.Post(router.BaseURL+"/chats/:chat_jid/locations", deviceAuthMiddleware, ctlMessaging.SendLocation)
-	app.Post(router.BaseURL+"/chats/:chat_jid/contacts", deviceAuthMiddleware, ctlMessaging.SendContact)
-	app.Post(router.BaseURL+"/chats/:chat_jid/documents", deviceAuthMiddleware, ctlMessaging.SendDocument)
-	app.Post(router.BaseURL+"/chats/:chat_jid/link-preview", deviceAuthMiddleware, ctlMessaging.SendLinkPreview)
+	app.Post(router.BaseURL+"/chats/:chat_jid/messages", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendText)
+	app.Post(router.BaseURL+"/chats/:chat_jid/images", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendImage)
+	app.Post(router.BaseURL+"/chats/:chat_jid/videos", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendVideo)
+	app.Post(router.BaseURL+"/chats/:chat_jid/audio", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendAudio)
+	app.Post(router.BaseURL+"/chats/:chat_jid/stickers", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendSticker)
+	app.Post(router.BaseURL+"/chats/:chat_jid/locations", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendLocation)
+	app.Post(router.BaseURL+"/chats/:chat_jid/contacts", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendContact)
+	app.Post(router.BaseURL+"/chats/:chat_jid/documents", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendDocument)
+	app.Post(router.BaseURL+"/chats/:chat_jid/link-preview", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.SendLinkPreview)
 	app.Get(router.BaseURL+"/chats/:chat_jid/messages", deviceAuthMiddleware, ctlMessaging.GetMessages)
-	app.Post(router.BaseURL+"/chats/:chat_jid/archive", deviceAuthMiddleware, ctlMessaging.ArchiveChat)
-	app.Post(router.BaseURL+"/chats/:chat_jid/pin", deviceAuthMiddleware, ctlMessaging.PinChat)
-	app.Post(router.BaseURL+"/chats/:chat_jid/mute", deviceAuthMiddleware, ctlMessaging.MuteChat)
-	app.Post(router.BaseURL+"/chats/:chat_jid/mark-read", deviceAuthMiddleware, ctlMessaging.MarkChatRead)
+	app.Post(router.BaseURL+"/chats/:chat_jid/archive", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.ArchiveChat)
+	app.Post(router.BaseURL+"/chats/:chat_jid/pin", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.PinChat)
+	app.Post(router.BaseURL+"/chats/:chat_jid/mute", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.MuteChat)
+	app.Post(router.BaseURL+"/chats/:chat_jid/mark-read", deviceAuthMiddleware, idempotencyMiddleware, ctlMessaging.MarkChatRead)
 	app.Delete(router.BaseURL+"/chats/:chat_jid", deviceAuthMiddleware, ctlMessaging.DeleteChat)
 
+	// Scheduled messages (queued by send_at on the send endpoints)
+	app.Get(router.BaseURL+"/messages/scheduled", deviceAuthMiddleware, ctlScheduled.ListScheduledMessages)
+	app.Get(router.BaseURL+"/messages/scheduled/:scheduled_id", deviceAuthMiddleware, ctlScheduled.GetScheduledMessage)
+	app.Patch(router.BaseURL+"/messages/scheduled/:scheduled_id", deviceAuthMiddleware, ctlScheduled.RescheduleMessage)
+	app.Delete(router.BaseURL+"/messages/scheduled/:scheduled_id", deviceAuthMiddleware, ctlScheduled.CancelScheduledMessage)
+
+	// Send jobs (queued by async=true on the send endpoints)
+	app.Get(router.BaseURL+"/jobs/:job_id", deviceAuthMiddleware, ctlJobs.GetJob)
+
 	// Message routes
-	app.Post(router.BaseURL+"/messages/:message_id/read", deviceAuthMiddleware, ctlMessage.MarkRead)
-	app.Post(router.BaseURL+"/messages/:message_id/reaction", deviceAuthMiddleware, ctlMessage.React)
+	app.Post(router.BaseURL+"/messages/:message_id/read", deviceAuthMiddleware, idempotencyMiddleware, ctlMessage.MarkRead)
+	app.Post(router.BaseURL+"/messages/:message_id/reaction", deviceAuthMiddleware, idempotencyMiddleware, ctlMessage.React)
 	app.Patch(router.BaseURL+"/messages/:message_id", deviceAuthMiddleware, ctlMessage.Edit)
 	app.Delete(router.BaseURL+"/messages/:message_id", deviceAuthMiddleware, ctlMessage.Delete)
-	app.Post(router.BaseURL+"/messages/:message_id/reply", deviceAuthMiddleware, ctlMessage.Reply)
-	app.Post(router.BaseURL+"/messages/:message_id/forward", deviceAuthMiddleware, ctlMessage.Forward)
+	app.Post(router.BaseURL+"/messages/:message_id/reply", deviceAuthMiddleware, idempotencyMiddleware, ctlMessage.Reply)
+	app.Post(router.BaseURL+"/messages/:message_id/forward", deviceAuthMiddleware, idempotencyMiddleware, ctlMessage.Forward)
+	app.Get(router.BaseURL+"/messages/:message_id/media", deviceAuthMiddleware, ctlMessage.DownloadMedia)
+	app.Get(router.BaseURL+"/messages/:message_id/thumbnail", deviceAuthMiddleware, ctlMessage.DownloadThumbnail)
+	app.Get(router.BaseURL+"/messages/:message_id/status", deviceAuthMiddleware, ctlMessage.GetStatus)
 
 	// Star/Unstar Messages
-	app.Post(router.BaseURL+"/messages/:message_id/star", deviceAuthMiddleware, ctlMessage.StarMessage)
+	app.Post(router.BaseURL+"/messages/:message_id/star", deviceAuthMiddleware, idempotencyMiddleware, ctlMessage.StarMessage)
 
 	// Media Retry
-	app.Post(router.BaseURL+"/messages/media/retry-receipt", deviceAuthMiddleware, ctlMessage.SendMediaRetryReceipt)
+	app.Post(router.BaseURL+"/messages/media/retry-receipt", deviceAuthMiddleware, idempotencyMiddleware, ctlMessage.SendMediaRetryReceipt)
+
+	// Broadcast campaigns
+	app.Post(router.BaseURL+"/campaigns", deviceAuthMiddleware, ctlCampaign.CreateCampaign)
+	app.Get(router.BaseURL+"/campaigns", deviceAuthMiddleware, ctlCampaign.ListCampaigns)
+	app.Get(router.BaseURL+"/campaigns/:campaign_id", deviceAuthMiddleware, ctlCampaign.GetCampaign)
+	app.Get(router.BaseURL+"/campaigns/:campaign_id/recipients", deviceAuthMiddleware, ctlCampaign.ListCampaignRecipients)
+	app.Get(router.BaseURL+"/campaigns/:campaign_id/export", deviceAuthMiddleware, ctlCampaign.ExportCampaign)
+	app.Post(router.BaseURL+"/campaigns/:campaign_id/pause", deviceAuthMiddleware, ctlCampaign.PauseCampaign)
+	app.Post(router.BaseURL+"/campaigns/:campaign_id/resume", deviceAuthMiddleware, ctlCampaign.ResumeCampaign)
+	app.Post(router.BaseURL+"/campaigns/:campaign_id/cancel", deviceAuthMiddleware, ctlCampaign.CancelCampaign)
+
+	// Message templates
+	app.Post(router.BaseURL+"/templates", deviceAuthMiddleware, ctlTemplate.CreateTemplate)
+	app.Get(router.BaseURL+"/templates", deviceAuthMiddleware, ctlTemplate.ListTemplates)
+	app.Get(router.BaseURL+"/templates/:template_id", deviceAuthMiddleware, ctlTemplate.GetTemplate)
+	app.Patch(router.BaseURL+"/templates/:template_id", deviceAuthMiddleware, ctlTemplate.UpdateTemplate)
+	app.Delete(router.BaseURL+"/templates/:template_id", deviceAuthMiddleware, ctlTemplate.DeleteTemplate)
+	app.Put(router.BaseURL+"/templates/:template_id/media", deviceAuthMiddleware, ctlTemplate.UploadTemplateMedia)
+	app.Post(router.BaseURL+"/chats/:chat_jid/templates/:template_id", deviceAuthMiddleware, idempotencyMiddleware, ctlTemplate.SendTemplate)
+
+	// Resumable media uploads (tus-style offsets); GET also answers HEAD
+	app.Post(router.BaseURL+"/uploads", deviceAuthMiddleware, ctlUpload.CreateUpload)
+	app.Get(router.BaseURL+"/uploads/:upload_id", deviceAuthMiddleware, ctlUpload.GetUpload)
+	app.Patch(router.BaseURL+"/uploads/:upload_id", deviceAuthMiddleware, ctlUpload.AppendUpload)
+	app.Delete(router.BaseURL+"/uploads/:upload_id", deviceAuthMiddleware, ctlUpload.DeleteUpload)
+
+	// Media library (send by media_id)
+	app.Post(router.BaseURL+"/media/library", deviceAuthMiddleware, ctlLibrary.AddMedia)
+	app.Get(router.BaseURL+"/media/library", deviceAuthMiddleware, ctlLibrary.ListMedia)
+	app.Get(router.BaseURL+"/media/library/:media_id", deviceAuthMiddleware, ctlLibrary.GetMedia)
+	app.Delete(router.BaseURL+"/media/library/:media_id", deviceAuthMiddleware, ctlLibrary.DeleteMedia)
 
 	// Poll routes
-	app.Post(router.BaseURL+"/chats/:chat_jid/polls", deviceAuthMiddleware, ctlPoll.CreatePoll)
+	app.Post(router.BaseURL+"/chats/:chat_jid/polls", deviceAuthMiddleware, idempotencyMiddleware, ctlPoll.CreatePoll)
 	app.Post(router.BaseURL+"/polls/:poll_id/vote", deviceAuthMiddleware, ctlPoll.VotePoll)
 	app.Get(router.BaseURL+"/polls/:poll_id/results", deviceAuthMiddleware, ctlPoll.GetPollResults)
 	app.Delete(router.BaseURL+"/polls/:poll_id", deviceAuthMiddleware, ctlPoll.DeletePoll)
 
 	// Newsletter/Channel routes
 	app.Get(router.BaseURL+"/newsletters", deviceAuthMiddleware, ctlNewsletter.ListNewsletters)
-	app.Post(router.BaseURL+"/newsletters", deviceAuthMiddleware, ctlNewsletter.CreateNewsletter)
+	app.Post(router.BaseURL+"/newsletters", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.CreateNewsletter)
 	app.Get(router.BaseURL+"/newsletters/:jid", deviceAuthMiddleware, ctlNewsletter.GetNewsletterInfo)
-	app.Post(router.BaseURL+"/newsletters/:jid/follow", deviceAuthMiddleware, ctlNewsletter.FollowNewsletter)
+	app.Post(router.BaseURL+"/newsletters/:jid/follow", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.FollowNewsletter)
 	app.Delete(router.BaseURL+"/newsletters/:jid/follow", deviceAuthMiddleware, ctlNewsletter.UnfollowNewsletter)
 	app.Get(router.BaseURL+"/newsletters/:jid/messages", deviceAuthMiddleware, ctlNewsletter.GetNewsletterMessages)
-	app.Post(router.BaseURL+"/newsletters/:jid/messages", deviceAuthMiddleware, ctlNewsletter.SendNewsletterMessage)
-	app.Post(router.BaseURL+"/newsletters/:jid/images", deviceAuthMiddleware, ctlNewsletter.SendNewsletterImage)
-	app.Post(router.BaseURL+"/newsletters/:jid/videos", deviceAuthMiddleware, ctlNewsletter.SendNewsletterVideo)
-	app.Post(router.BaseURL+"/newsletters/:jid/documents", deviceAuthMiddleware, ctlNewsletter.SendNewsletterDocument)
-	app.Post(router.BaseURL+"/newsletters/:jid/reaction", deviceAuthMiddleware, ctlNewsletter.ReactToNewsletterMessage)
-	app.Post(router.BaseURL+"/newsletters/:jid/comments", deviceAuthMiddleware, ctlNewsletter.SendNewsletterComment)
-	app.Post(router.BaseURL+"/newsletters/:jid/mute", deviceAuthMiddleware, ctlNewsletter.ToggleNewsletterMute)
-	app.Post(router.BaseURL+"/newsletters/:jid/viewed", deviceAuthMiddleware, ctlNewsletter.MarkNewsletterViewed)
+	app.Post(router.BaseURL+"/newsletters/:jid/messages", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.SendNewsletterMessage)
+	app.Post(router.BaseURL+"/newsletters/:jid/images", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.SendNewsletterImage)
+	app.Post(router.BaseURL+"/newsletters/:jid/videos", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.SendNewsletterVideo)
+	app.Post(router.BaseURL+"/newsletters/:jid/documents", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.SendNewsletterDocument)
+	app.Post(router.BaseURL+"/newsletters/:jid/reaction", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.ReactToNewsletterMessage)
+	app.Post(router.BaseURL+"/newsletters/:jid/comments", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.SendNewsletterComment)
+	app.Post(router.BaseURL+"/newsletters/:jid/mute", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.ToggleNewsletterMute)
+	app.Post(router.BaseURL+"/newsletters/:jid/viewed", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.MarkNewsletterViewed)
 	app.Get(router.BaseURL+"/newsletters/invite/:code", deviceAuthMiddleware, ctlNewsletter.GetNewsletterInfoFromInvite)
-	app.Post(router.BaseURL+"/newsletters/:jid/live", deviceAuthMiddleware, ctlNewsletter.SubscribeLiveUpdates)
-	app.Post(router.BaseURL+"/newsletters/:jid/photo", deviceAuthMiddleware, ctlNewsletter.UpdateNewsletterPhoto)
+	app.Post(router.BaseURL+"/newsletters/:jid/live", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.SubscribeLiveUpdates)
+	app.Post(router.BaseURL+"/newsletters/:jid/photo", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.UpdateNewsletterPhoto)
 
 	// Status/Stories routes
-	app.Post(router.BaseURL+"/status", deviceAuthMiddleware, ctlStatus.PostStatus)
+	app.Post(router.BaseURL+"/status", deviceAuthMiddleware, idempotencyMiddleware, ctlStatus.PostStatus)
 	app.Get(router.BaseURL+"/status", deviceAuthMiddleware, ctlStatus.GetStatusUpdates)
 	app.Delete(router.BaseURL+"/status/:status_id", deviceAuthMiddleware, ctlStatus.DeleteStatus)
 	app.Get(router.BaseURL+"/status/:user_jid", deviceAuthMiddleware, ctlStatus.GetUserStatus)
@@ -240,7 +301,7 @@ func Routes(app *fiber.App) {
 	app.Delete(router.BaseURL+"/groups/:group_jid/admins", deviceAuthMiddleware, ctlGroups.DemoteAdmins)
 
 	// Presence routes
-	app.Post(router.BaseURL+"/chats/:chat_jid/presence", deviceAuthMiddleware, ctlPresence.SendChatPresence)
+	app.Post(router.BaseURL+"/chats/:chat_jid/presence", deviceAuthMiddleware, idempotencyMiddleware, ctlPresence.SendChatPresence)
 	app.Post(router.BaseURL+"/presence/status", deviceAuthMiddleware, ctlPresence.UpdateStatus)
 	app.Patch(router.BaseURL+"/chats/:chat_jid/disappearing-timer", deviceAuthMiddleware, ctlPresence.SetDisappearingTimer)
 	app.Patch(router.BaseURL+"/users/me/disappearing-timer", deviceAuthMiddleware, ctlPresence.SetDefaultDisappearingTimer)
@@ -258,6 +319,14 @@ func Routes(app *fiber.App) {
 	app.Delete(router.BaseURL+"/webhooks/:webhook_id", deviceAuthMiddleware, ctlWebhooks.DeleteWebhook)
 	app.Get(router.BaseURL+"/webhooks/:webhook_id/logs", deviceAuthMiddleware, ctlWebhooks.GetWebhookLogs)
 	app.Post(router.BaseURL+"/webhooks/:webhook_id/test", deviceAuthMiddleware, ctlWebhooks.TestWebhook)
+	app.Post(router.BaseURL+"/webhooks/:webhook_id/deliveries/replay", deviceAuthMiddleware, ctlWebhooks.ReplayFailedDeliveries)
+	app.Post(router.BaseURL+"/webhooks/:webhook_id/deliveries/:delivery_id/replay", deviceAuthMiddleware, ctlWebhooks.ReplayDelivery)
+	app.Get(router.BaseURL+"/webhooks/:webhook_id/dead-letters", deviceAuthMiddleware, ctlWebhooks.ListDeadLetters)
+	app.Post(router.BaseURL+"/webhooks/:webhook_id/dead-letters/requeue", deviceAuthMiddleware, ctlWebhooks.RequeueDeadLetters)
+
+	// Event stream routes (alternative to webhooks)
+	app.Get(router.BaseURL+"/events/stream", deviceAuthMiddleware, ctlEvents.Stream)
+	app.Get(router.BaseURL+"/events/ws", auth.StreamAuth(), ctlEvents.WebSocket)
 
 	// ============================================================
 	// NEW WHATSMEOW FEATURE ROUTES
@@ -286,7 +355,7 @@ func Routes(app *fiber.App) {
 
 	// Newsletter updates routes
 	app.Get(router.BaseURL+"/newsletters/:jid/updates", deviceAuthMiddleware, ctlNewsletter.GetNewsletterMessageUpdates)
-	app.Post(router.BaseURL+"/newsletters/tos/accept", deviceAuthMiddleware, ctlNewsletter.AcceptTOSNotice)
+	app.Post(router.BaseURL+"/newsletters/tos/accept", deviceAuthMiddleware, idempotencyMiddleware, ctlNewsletter.AcceptTOSNotice)
 
 	// Community/Group unlinking route
 	app.Delete(router.BaseURL+"/groups/:parent_jid/link/:child_jid", deviceAuthMiddleware, ctlGroups.UnlinkGroup)
@@ -192,7 +192,7 @@ func Routines(cron *cron.Cron) {
 	}
 
 	// Webhook delivery log cleanup cron — prevents unbounded wa_webhook_deliveries table growth (#5)
-	// Runs daily at 04:00, deletes deliveries older than 7 days by default
+	// Runs daily at 04:00, deletes finished deliveries older than 7 days by default
 	if whe := pkgWhatsApp.GetWebhookEngine(); whe != nil {
 		retentionDays := 7
 		if raw, ok := os.LookupEnv("WEBHOOK_DELIVERY_RETENTION_DAYS"); ok {
@@ -218,11 +218,338 @@ func Routines(cron *cron.Cron) {
 		} else {
 			log.Print(nil).WithField("retention_days", retentionDays).Info("Webhook delivery cleanup cron enabled")
 		}
+
+		// Dead letters wait for an operator to inspect and requeue them, so they are kept longer
+		// (30 days by default, 0 keeps them until they are requeued)
+		deadLetterDays := 30
+		if raw, ok := os.LookupEnv("WEBHOOK_DEAD_LETTER_RETENTION_DAYS"); ok {
+			if v, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && v >= 0 {
+				deadLetterDays = v
+			}
+		}
+		if deadLetterDays > 0 {
+			deadLetterRetention := time.Duration(deadLetterDays) * 24 * time.Hour
+			_, err := cron.AddFunc("0 5 4 * * *", func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				deleted, err := whe.Store().CleanupDeadLetters(ctx, deadLetterRetention)
+				if err != nil {
+					log.Print(nil).WithField("error", err.Error()).Error("Failed to cleanup old webhook dead letters")
+					return
+				}
+				if deleted > 0 {
+					log.Print(nil).WithField("deleted", deleted).WithField("retention_days", deadLetterDays).Info("Webhook dead letter cleanup completed")
+				}
+			})
+			if err != nil {
+				log.Print(nil).WithField("error", err.Error()).Error("Failed to add webhook dead letter cleanup cron job")
+			} else {
+				log.Print(nil).WithField("retention_days", deadLetterDays).Info("Webhook dead letter cleanup cron enabled")
+			}
+		}
+	}
+
+	// Event log cleanup cron — the SSE/WebSocket streams can only resume within the retention window
+	if hub := pkgWhatsApp.GetEventHub(); hub.Enabled() {
+		retentionHours := 24
+		if raw, ok := os.LookupEnv("EVENT_STREAM_RETENTION_HOURS"); ok {
+			if v, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && v > 0 {
+				retentionHours = v
+			}
+		}
+		retention := time.Duration(retentionHours) * time.Hour
+		_, err := cron.AddFunc("0 15 * * * *", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			deleted, err := hub.Cleanup(ctx, retention)
+			if err != nil {
+				log.Print(nil).WithField("error", err.Error()).Error("Failed to cleanup old stream events")
+				return
+			}
+			if deleted > 0 {
+				log.Print(nil).WithField("deleted", deleted).WithField("retention_hours", retentionHours).Info("Event log cleanup completed")
+			}
+		})
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to add event log cleanup cron job")
+		} else {
+			log.Print(nil).WithField("retention_hours", retentionHours).Info("Event log cleanup cron enabled")
+		}
+	}
+
+	// Scheduled message cron — sends messages queued with send_at. The queue lives in Postgres,
+	// so messages scheduled before a restart are still sent; rows for disconnected devices wait for the next tick
+	if isScheduledMessagesCronEnabled() {
+		spec := getScheduledMessagesCronSpec()
+		_, err := cron.AddFunc(spec, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			sent, failed, err := pkgWhatsApp.RunDueScheduledMessages(ctx)
+			if err != nil {
+				log.Print(nil).WithField("error", err.Error()).Error("Failed to run scheduled messages")
+				return
+			}
+			if sent > 0 || failed > 0 {
+				log.Print(nil).WithField("sent", sent).WithField("failed", failed).Info("Scheduled messages processed")
+			}
+		})
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to add scheduled message cron job")
+		} else {
+			log.Print(nil).WithField("spec", spec).Info("Scheduled message cron enabled")
+		}
+	}
+
+	// Delivery SLA cron — alerts once per sent message still undelivered after WHATSAPP_MESSAGE_DELIVERY_SLA
+	if sla := pkgWhatsApp.MessageDeliverySLA(); sla > 0 {
+		_, err := cron.AddFunc("45 * * * * *", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			alerted, err := pkgWhatsApp.RunMessageDeliverySLA(ctx)
+			if err != nil {
+				log.Print(nil).WithField("error", err.Error()).Error("Failed to check message delivery SLA")
+				return
+			}
+			if alerted > 0 {
+				log.Print(nil).WithField("alerted", alerted).Warn("Messages not delivered within SLA")
+			}
+		})
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to add delivery SLA cron job")
+		} else {
+			log.Print(nil).WithField("sla", sla.String()).Info("Delivery SLA cron enabled")
+		}
+	}
+
+	// Campaign resume cron — campaign workers run in-process, so running campaigns
+	// are picked up again within a minute after a restart
+	_, err := cron.AddFunc("30 * * * * *", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		started, err := pkgWhatsApp.ResumeRunningCampaigns(ctx)
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to resume running campaigns")
+			return
+		}
+		if started > 0 {
+			log.Print(nil).WithField("started", started).Info("Resumed running campaigns")
+		}
+	})
+	if err != nil {
+		log.Print(nil).WithField("error", err.Error()).Error("Failed to add campaign resume cron job")
+	}
+
+	// Outbox resume cron — outbox workers run in-process, so devices with queued async
+	// sends get their worker back within a minute after a restart
+	_, err = cron.AddFunc("15 * * * * *", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		started, err := pkgWhatsApp.ResumeOutboxWorkers(ctx)
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to resume outbox workers")
+			return
+		}
+		if started > 0 {
+			log.Print(nil).WithField("started", started).Info("Resumed outbox workers")
+		}
+	})
+	if err != nil {
+		log.Print(nil).WithField("error", err.Error()).Error("Failed to add outbox resume cron job")
+	}
+
+	// Resumable upload cleanup cron — removes uploads older than MEDIA_UPLOAD_TTL and their files
+	_, err = cron.AddFunc("0 */10 * * * *", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		deleted, err := pkgWhatsApp.CleanupExpiredMediaUploads(ctx)
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to clean up expired uploads")
+			return
+		}
+		if deleted > 0 {
+			log.Print(nil).WithField("deleted", deleted).Info("Expired uploads cleaned up")
+		}
+	})
+	if err != nil {
+		log.Print(nil).WithField("error", err.Error()).Error("Failed to add upload cleanup cron job")
+	}
+
+	// Idempotency key cleanup cron — removes keys older than IDEMPOTENCY_KEY_TTL and their stored responses
+	_, err = cron.AddFunc("0 5-59/10 * * * *", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		deleted, err := pkgWhatsApp.CleanupExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to clean up expired idempotency keys")
+			return
+		}
+		if deleted > 0 {
+			log.Print(nil).WithField("deleted", deleted).Info("Expired idempotency keys cleaned up")
+		}
+	})
+	if err != nil {
+		log.Print(nil).WithField("error", err.Error()).Error("Failed to add idempotency key cleanup cron job")
+	}
+
+	// API key usage cleanup cron — removes hourly quota counters of past windows (Postgres backend)
+	_, err = cron.AddFunc("0 20 * * * *", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		deleted, err := pkgWhatsApp.CleanupAPIKeyUsage(ctx)
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to clean up API key usage counters")
+			return
+		}
+		if deleted > 0 {
+			log.Print(nil).WithField("deleted", deleted).Info("API key usage counters cleaned up")
+		}
+	})
+	if err != nil {
+		log.Print(nil).WithField("error", err.Error()).Error("Failed to add API key usage cleanup cron job")
+	}
+
+	// Message store cleanup cron — only registered when a retention period is configured
+	// WHATSAPP_MESSAGE_STORE_RETENTION_DAYS=0 (default) keeps stored messages forever
+	if retentionDays := getMessageStoreRetentionDays(); retentionDays > 0 {
+		retention := time.Duration(retentionDays) * 24 * time.Hour
+		_, err := cron.AddFunc("0 30 4 * * *", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer cancel()
+			deleted, err := pkgWhatsApp.CleanupOldMessages(ctx, retention)
+			if err != nil {
+				log.Print(nil).WithField("error", err.Error()).Error("Failed to cleanup old stored messages")
+				return
+			}
+			if deleted > 0 {
+				log.Print(nil).WithField("deleted", deleted).WithField("retention_days", retentionDays).Info("Message store cleanup completed")
+			}
+		})
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to add message store cleanup cron job")
+		} else {
+			log.Print(nil).WithField("retention_days", retentionDays).Info("Message store cleanup cron enabled")
+		}
+	}
+
+	// Message status cleanup cron — delivery tracking of sent messages is kept for its own retention
+	// period, whether or not stored messages are ever cleaned up. Runs daily at 04:45, 30 days by default
+	statusRetentionDays := 30
+	if raw, ok := os.LookupEnv("WHATSAPP_MESSAGE_STATUS_RETENTION_DAYS"); ok {
+		if v, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && v > 0 {
+			statusRetentionDays = v
+		}
+	}
+	statusRetention := time.Duration(statusRetentionDays) * 24 * time.Hour
+	_, err = cron.AddFunc("0 45 4 * * *", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		deleted, err := pkgWhatsApp.CleanupOldMessageStatus(ctx, statusRetention)
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to cleanup old message status")
+			return
+		}
+		if deleted > 0 {
+			log.Print(nil).WithField("deleted", deleted).WithField("retention_days", statusRetentionDays).Info("Message status cleanup completed")
+		}
+	})
+	if err != nil {
+		log.Print(nil).WithField("error", err.Error()).Error("Failed to add message status cleanup cron job")
+	} else {
+		log.Print(nil).WithField("retention_days", statusRetentionDays).Info("Message status cleanup cron enabled")
+	}
+
+	// Client reference cleanup cron — references only matter while receipts for a message can
+	// still arrive. Runs daily at 04:50, 30 days by default
+	referenceRetentionDays := 30
+	if raw, ok := os.LookupEnv("WHATSAPP_MESSAGE_REFERENCE_RETENTION_DAYS"); ok {
+		if v, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && v > 0 {
+			referenceRetentionDays = v
+		}
+	}
+	referenceRetention := time.Duration(referenceRetentionDays) * 24 * time.Hour
+	_, err = cron.AddFunc("0 50 4 * * *", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		deleted, err := pkgWhatsApp.CleanupOldMessageReferences(ctx, referenceRetention)
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to cleanup old client references")
+			return
+		}
+		if deleted > 0 {
+			log.Print(nil).WithField("deleted", deleted).WithField("retention_days", referenceRetentionDays).Info("Client reference cleanup completed")
+		}
+	})
+	if err != nil {
+		log.Print(nil).WithField("error", err.Error()).Error("Failed to add client reference cleanup cron job")
+	} else {
+		log.Print(nil).WithField("retention_days", referenceRetentionDays).Info("Client reference cleanup cron enabled")
+	}
+
+	// Send job cleanup cron — sent and failed async send jobs are only kept for status lookups.
+	// Runs daily at 04:55, 7 days by default
+	sendJobRetentionDays := 7
+	if raw, ok := os.LookupEnv("OUTBOX_JOB_RETENTION_DAYS"); ok {
+		if v, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && v > 0 {
+			sendJobRetentionDays = v
+		}
+	}
+	sendJobRetention := time.Duration(sendJobRetentionDays) * 24 * time.Hour
+	_, err = cron.AddFunc("0 55 4 * * *", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		deleted, err := pkgWhatsApp.CleanupOldSendJobs(ctx, sendJobRetention)
+		if err != nil {
+			log.Print(nil).WithField("error", err.Error()).Error("Failed to cleanup old send jobs")
+			return
+		}
+		if deleted > 0 {
+			log.Print(nil).WithField("deleted", deleted).WithField("retention_days", sendJobRetentionDays).Info("Send job cleanup completed")
+		}
+	})
+	if err != nil {
+		log.Print(nil).WithField("error", err.Error()).Error("Failed to add send job cleanup cron job")
+	} else {
+		log.Print(nil).WithField("retention_days", sendJobRetentionDays).Info("Send job cleanup cron enabled")
 	}
 
 	cron.Start()
 }
 
+func getMessageStoreRetentionDays() int {
+	raw := strings.TrimSpace(os.Getenv("WHATSAPP_MESSAGE_STORE_RETENTION_DAYS"))
+	if raw == "" {
+		return 0
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 0 {
+		log.Print(nil).Warn("Invalid WHATSAPP_MESSAGE_STORE_RETENTION_DAYS value; keeping stored messages forever")
+		return 0
+	}
+	return days
+}
+
+func isScheduledMessagesCronEnabled() bool {
+	envValue, ok := os.LookupEnv("SCHEDULED_MESSAGES_ENABLED")
+	if !ok {
+		return true
+	}
+	enabled, err := strconv.ParseBool(strings.TrimSpace(envValue))
+	if err != nil {
+		log.Print(nil).Warn("Invalid SCHEDULED_MESSAGES_ENABLED value; defaulting to enabled")
+		return true
+	}
+	return enabled
+}
+
+func getScheduledMessagesCronSpec() string {
+	// robfig/cron with seconds field (6 parts). Default: every 10 seconds
+	spec := strings.TrimSpace(os.Getenv("SCHEDULED_MESSAGES_CRON_SPEC"))
+	if spec == "" {
+		return "*/10 * * * * *"
+	}
+	return spec
+}
+
 func isHealthCheckEnabled() bool {
 	envValue, ok := os.LookupEnv("WHATSAPP_ENABLE_HEALTH_CHECK_CRON")
 	if !ok {
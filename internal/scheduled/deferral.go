@@ -0,0 +1,83 @@
+package scheduled
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// Deferral is how a send endpoint was asked to deliver: through the scheduler at send_at,
+// through the device's outbox when async is set, or right away when neither is
+type Deferral struct {
+	operation string
+	chatJID   string
+	sendAt    *time.Time
+	async     bool
+}
+
+// ParseDeferral validates send_at for a send endpoint. An invalid value is answered with 400,
+// in which case handled is true and err is what the handler returns.
+func ParseDeferral(c *fiber.Ctx, operation string, chatJID string, rawSendAt string, async bool) (d Deferral, handled bool, err error) {
+	sendAt, err := pkgWhatsApp.ParseSendAt(rawSendAt)
+	if err != nil {
+		log.MessageOpCtx(c, operation, chatJID).Warn("Invalid send_at")
+		return Deferral{}, true, router.ResponseBadRequest(c, err.Error())
+	}
+	return Deferral{operation: operation, chatJID: chatJID, sendAt: sendAt, async: async}, false, nil
+}
+
+// Deferred reports whether the send is scheduled or queued rather than sent now, in which case its
+// media has to be buffered
+func (d Deferral) Deferred() bool {
+	return d.sendAt != nil || d.async
+}
+
+// MaybeSchedule hands a deferred send to the scheduler or the outbox and writes the response.
+// handled is false when the send should go out now.
+func (d Deferral) MaybeSchedule(c *fiber.Ctx, msgType string, payload pkgWhatsApp.ScheduledPayload, media []byte) (handled bool, err error) {
+	if !d.Deferred() {
+		return false, nil
+	}
+	deviceID, jid := getDeviceContext(c)
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if d.sendAt != nil {
+		scheduled, err := pkgWhatsApp.ScheduleMessage(ctx, jid, deviceID, d.chatJID, msgType, payload, media, *d.sendAt)
+		if errors.Is(err, pkgWhatsApp.ErrInvalidQueuedMedia) {
+			log.MessageOpCtx(c, d.operation, d.chatJID).WithError(err).Warn("Invalid media for scheduled message")
+			return true, router.ResponseBadRequest(c, err.Error())
+		}
+		if err != nil {
+			log.MessageOpCtx(c, d.operation, d.chatJID).WithError(err).Error("Failed to schedule message")
+			return true, router.ResponseInternalError(c, err.Error())
+		}
+
+		log.MessageOpCtx(c, d.operation, d.chatJID).WithField("scheduled_id", scheduled.ID).WithField("send_at", scheduled.SendAt).Info("Message scheduled successfully")
+
+		return true, router.ResponseCreatedWithData(c, "Success schedule message", scheduled)
+	}
+
+	job, err := pkgWhatsApp.EnqueueSendJob(ctx, jid, deviceID, d.chatJID, msgType, payload, media)
+	if errors.Is(err, pkgWhatsApp.ErrInvalidQueuedMedia) {
+		log.MessageOpCtx(c, d.operation, d.chatJID).WithError(err).Warn("Invalid media for queued message")
+		return true, router.ResponseBadRequest(c, err.Error())
+	}
+	if err != nil {
+		log.MessageOpCtx(c, d.operation, d.chatJID).WithError(err).Error("Failed to queue message")
+		return true, router.ResponseInternalError(c, err.Error())
+	}
+
+	log.MessageOpCtx(c, d.operation, d.chatJID).WithField("job_id", job.ID).Info("Message queued successfully")
+
+	return true, router.ResponseAcceptedWithData(c, "Success queue message", job)
+}
@@ -0,0 +1,180 @@
+package scheduled
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// getDeviceContext extracts device context from auth middleware
+func getDeviceContext(c *fiber.Ctx) (deviceID string, jid string) {
+	deviceID = c.Locals("device_id").(string)
+	jidVal := c.Locals("device_jid")
+	if jidVal != nil {
+		jid = jidVal.(string)
+	}
+	return
+}
+
+func scheduledID(c *fiber.Ctx) (int64, error) {
+	id, err := c.ParamsInt("scheduled_id")
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid scheduled_id")
+	}
+	return int64(id), nil
+}
+
+// ListScheduledMessages lists the device's scheduled messages, optionally filtered by status
+func ListScheduledMessages(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+
+	status := c.Query("status")
+	switch status {
+	case "", pkgWhatsApp.ScheduledPending, pkgWhatsApp.ScheduledSending, pkgWhatsApp.ScheduledSent, pkgWhatsApp.ScheduledFailed, pkgWhatsApp.ScheduledCancelled:
+	default:
+		log.DeviceOpCtx(c, "ListScheduledMessages").WithField("status", status).Warn("Invalid status filter")
+		return router.ResponseBadRequest(c, "status must be one of pending, sending, sent, failed, cancelled")
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	log.DeviceOpCtx(c, "ListScheduledMessages").WithField("status", status).Info("Listing scheduled messages")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	messages, err := pkgWhatsApp.GetScheduledMessages(ctx, deviceID, status, limit, offset)
+	if err != nil {
+		log.DeviceOpCtx(c, "ListScheduledMessages").WithError(err).Error("Failed to list scheduled messages")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "ListScheduledMessages").WithField("count", len(messages)).Info("Scheduled messages listed successfully")
+
+	return router.ResponseSuccessWithData(c, "Success list scheduled messages", map[string]interface{}{
+		"messages": messages,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// GetScheduledMessage returns a single scheduled message
+func GetScheduledMessage(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := scheduledID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "GetScheduledMessage").Warn("Invalid scheduled_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	msg, err := pkgWhatsApp.GetScheduledMessage(ctx, deviceID, id)
+	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrScheduledMessageNotFound) {
+			log.DeviceOpCtx(c, "GetScheduledMessage").WithField("scheduled_id", id).Warn("Scheduled message not found")
+			return router.ResponseNotFound(c, "Scheduled message not found")
+		}
+		log.DeviceOpCtx(c, "GetScheduledMessage").WithField("scheduled_id", id).WithError(err).Error("Failed to get scheduled message")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	return router.ResponseSuccessWithData(c, "Success get scheduled message", msg)
+}
+
+// CancelScheduledMessage cancels a message that has not been sent yet
+func CancelScheduledMessage(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := scheduledID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "CancelScheduledMessage").Warn("Invalid scheduled_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "CancelScheduledMessage").WithField("scheduled_id", id).Info("Cancelling scheduled message")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	msg, err := pkgWhatsApp.CancelScheduledMessage(ctx, deviceID, id)
+	if err != nil {
+		return scheduledErrorResponse(c, "CancelScheduledMessage", id, err)
+	}
+
+	log.DeviceOpCtx(c, "CancelScheduledMessage").WithField("scheduled_id", id).Info("Scheduled message cancelled successfully")
+
+	return router.ResponseSuccessWithData(c, "Success cancel scheduled message", msg)
+}
+
+// RescheduleMessage moves a pending message to a new send_at
+func RescheduleMessage(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := scheduledID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "RescheduleMessage").Warn("Invalid scheduled_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	var req struct {
+		SendAt string `json:"send_at"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		log.DeviceOpCtx(c, "RescheduleMessage").Warn("Failed to parse body request")
+		return router.ResponseBadRequest(c, "Failed parse body request")
+	}
+	sendAt, err := pkgWhatsApp.ParseSendAt(req.SendAt)
+	if err != nil {
+		log.DeviceOpCtx(c, "RescheduleMessage").Warn("Invalid send_at")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	if sendAt == nil {
+		return router.ResponseBadRequest(c, "send_at is required")
+	}
+
+	log.DeviceOpCtx(c, "RescheduleMessage").WithField("scheduled_id", id).WithField("send_at", *sendAt).Info("Rescheduling message")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	msg, err := pkgWhatsApp.RescheduleMessage(ctx, deviceID, id, *sendAt)
+	if err != nil {
+		return scheduledErrorResponse(c, "RescheduleMessage", id, err)
+	}
+
+	log.DeviceOpCtx(c, "RescheduleMessage").WithField("scheduled_id", id).Info("Message rescheduled successfully")
+
+	return router.ResponseSuccessWithData(c, "Success reschedule message", msg)
+}
+
+func scheduledErrorResponse(c *fiber.Ctx, operation string, id int64, err error) error {
+	switch {
+	case errors.Is(err, pkgWhatsApp.ErrScheduledMessageNotFound):
+		log.DeviceOpCtx(c, operation).WithField("scheduled_id", id).Warn("Scheduled message not found")
+		return router.ResponseNotFound(c, "Scheduled message not found")
+	case errors.Is(err, pkgWhatsApp.ErrScheduledMessageNotPending):
+		log.DeviceOpCtx(c, operation).WithField("scheduled_id", id).Warn("Scheduled message is no longer pending")
+		return router.ResponseBadRequest(c, "Scheduled message is no longer pending")
+	}
+	log.DeviceOpCtx(c, operation).WithField("scheduled_id", id).WithError(err).Error("Failed to update scheduled message")
+	return router.ResponseInternalError(c, err.Error())
+}
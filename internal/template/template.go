@@ -0,0 +1,392 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/scheduled"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/validation"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// getDeviceContext extracts device context from auth middleware
+func getDeviceContext(c *fiber.Ctx) (deviceID string, jid string) {
+	deviceID = c.Locals("device_id").(string)
+	jidVal := c.Locals("device_jid")
+	if jidVal != nil {
+		jid = jidVal.(string)
+	}
+	return
+}
+
+type templateRequest struct {
+	Name     *string           `json:"name"`
+	Type     string            `json:"type"`
+	Body     *string           `json:"body"`
+	Defaults map[string]string `json:"defaults"`
+}
+
+type sendTemplateRequest struct {
+	Variables          map[string]string `json:"variables"`
+	ReplyMessageID     string            `json:"reply_to_message_id"`
+	TypingSimulation   *bool             `json:"typing_simulation"`
+	PresenceSimulation *bool             `json:"presence_simulation"`
+	SendAt             string            `json:"send_at"`
+	Async              bool              `json:"async"`
+	ClientReference    string            `json:"client_reference"`
+	Metadata           map[string]string `json:"metadata"`
+}
+
+func templateID(c *fiber.Ctx) (int64, error) {
+	id, err := c.ParamsInt("template_id")
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid template_id")
+	}
+	return int64(id), nil
+}
+
+func templateErrorResponse(c *fiber.Ctx, operation string, id int64, err error) error {
+	var missing *pkgWhatsApp.TemplateMissingVariablesError
+	switch {
+	case errors.Is(err, pkgWhatsApp.ErrTemplateNotFound):
+		log.DeviceOpCtx(c, operation).WithField("template_id", id).Warn("Template not found")
+		return router.ResponseNotFound(c, "Template not found")
+	case errors.Is(err, pkgWhatsApp.ErrTemplateNameTaken):
+		log.DeviceOpCtx(c, operation).WithField("template_id", id).Warn("Template name already exists")
+		return router.ResponseBadRequest(c, err.Error())
+	case errors.Is(err, pkgWhatsApp.ErrTemplateNoMedia):
+		log.DeviceOpCtx(c, operation).WithField("template_id", id).Warn("Template has no media attached")
+		return router.ResponseBadRequest(c, err.Error())
+	case errors.Is(err, pkgWhatsApp.ErrTemplateMediaInvalid):
+		log.DeviceOpCtx(c, operation).WithField("template_id", id).WithError(err).Warn("Invalid template media")
+		return router.ResponseBadRequest(c, err.Error())
+	case errors.As(err, &missing):
+		log.DeviceOpCtx(c, operation).WithField("template_id", id).WithField("missing", missing.Missing).Warn("Missing template variables")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	log.DeviceOpCtx(c, operation).WithField("template_id", id).WithError(err).Error("Template operation failed")
+	return router.ResponseInternalError(c, err.Error())
+}
+
+// CreateTemplate stores a message template with {{variable}} placeholders
+func CreateTemplate(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+
+	var req templateRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.DeviceOpCtx(c, "CreateTemplate").Warn("Failed to parse body request")
+		return router.ResponseBadRequest(c, "Failed parse body request")
+	}
+
+	input := pkgWhatsApp.TemplateInput{
+		Type:     strings.ToLower(strings.TrimSpace(req.Type)),
+		Defaults: req.Defaults,
+	}
+	if req.Name != nil {
+		input.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Body != nil {
+		input.Body = *req.Body
+	}
+	if input.Type == "" {
+		input.Type = pkgWhatsApp.TemplateText
+	}
+	if input.Name == "" {
+		log.DeviceOpCtx(c, "CreateTemplate").Warn("Name is required")
+		return router.ResponseBadRequest(c, "name is required")
+	}
+	if !pkgWhatsApp.IsValidTemplateType(input.Type) {
+		log.DeviceOpCtx(c, "CreateTemplate").WithField("type", input.Type).Warn("Invalid template type")
+		return router.ResponseBadRequest(c, "type must be one of text, image, video, document")
+	}
+	if input.Type == pkgWhatsApp.TemplateText && strings.TrimSpace(input.Body) == "" {
+		log.DeviceOpCtx(c, "CreateTemplate").Warn("Body is required")
+		return router.ResponseBadRequest(c, "body is required for text templates")
+	}
+
+	log.DeviceOpCtx(c, "CreateTemplate").WithField("name", input.Name).WithField("type", input.Type).Info("Creating template")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	tmpl, err := pkgWhatsApp.CreateMessageTemplate(ctx, deviceID, input)
+	if err != nil {
+		return templateErrorResponse(c, "CreateTemplate", 0, err)
+	}
+
+	log.DeviceOpCtx(c, "CreateTemplate").WithField("template_id", tmpl.ID).Info("Template created successfully")
+
+	return router.ResponseCreatedWithData(c, "Success create template", tmpl)
+}
+
+// ListTemplates lists the device's templates
+func ListTemplates(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	templates, err := pkgWhatsApp.GetMessageTemplates(ctx, deviceID, limit, offset)
+	if err != nil {
+		log.DeviceOpCtx(c, "ListTemplates").WithError(err).Error("Failed to list templates")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "ListTemplates").WithField("count", len(templates)).Info("Templates listed successfully")
+
+	return router.ResponseSuccessWithData(c, "Success list templates", map[string]interface{}{
+		"templates": templates,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GetTemplate returns a single template with its placeholder names
+func GetTemplate(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := templateID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "GetTemplate").Warn("Invalid template_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	tmpl, err := pkgWhatsApp.GetMessageTemplate(ctx, deviceID, id)
+	if err != nil {
+		return templateErrorResponse(c, "GetTemplate", id, err)
+	}
+
+	return router.ResponseSuccessWithData(c, "Success get template", tmpl)
+}
+
+// UpdateTemplate changes the name, body or defaults of a template
+func UpdateTemplate(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := templateID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "UpdateTemplate").Warn("Invalid template_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	var req templateRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.DeviceOpCtx(c, "UpdateTemplate").Warn("Failed to parse body request")
+		return router.ResponseBadRequest(c, "Failed parse body request")
+	}
+	if req.Type != "" {
+		return router.ResponseBadRequest(c, "type cannot be changed; create a new template instead")
+	}
+	update := pkgWhatsApp.TemplateUpdate{Body: req.Body, Defaults: req.Defaults}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return router.ResponseBadRequest(c, "name cannot be empty")
+		}
+		update.Name = &name
+	}
+
+	log.DeviceOpCtx(c, "UpdateTemplate").WithField("template_id", id).Info("Updating template")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	tmpl, err := pkgWhatsApp.UpdateMessageTemplate(ctx, deviceID, id, update)
+	if err != nil {
+		return templateErrorResponse(c, "UpdateTemplate", id, err)
+	}
+
+	log.DeviceOpCtx(c, "UpdateTemplate").WithField("template_id", id).Info("Template updated successfully")
+
+	return router.ResponseSuccessWithData(c, "Success update template", tmpl)
+}
+
+// DeleteTemplate removes a template and its attached media
+func DeleteTemplate(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := templateID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "DeleteTemplate").Warn("Invalid template_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := pkgWhatsApp.DeleteMessageTemplate(ctx, deviceID, id); err != nil {
+		return templateErrorResponse(c, "DeleteTemplate", id, err)
+	}
+
+	log.DeviceOpCtx(c, "DeleteTemplate").WithField("template_id", id).Info("Template deleted successfully")
+
+	return router.ResponseSuccess(c, "Success delete template")
+}
+
+// UploadTemplateMedia attaches the media sent with an image, video or document template
+func UploadTemplateMedia(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id, err := templateID(c)
+	if err != nil {
+		log.DeviceOpCtx(c, "UploadTemplateMedia").Warn("Invalid template_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		log.DeviceOpCtx(c, "UploadTemplateMedia").Warn("No file provided")
+		return router.ResponseBadRequest(c, "file is required")
+	}
+	fileName := c.FormValue("filename")
+	if fileName == "" {
+		fileName = fileHeader.Filename
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	tmpl, err := pkgWhatsApp.GetMessageTemplate(ctx, deviceID, id)
+	if err != nil {
+		return templateErrorResponse(c, "UploadTemplateMedia", id, err)
+	}
+	if tmpl.Type == pkgWhatsApp.TemplateText {
+		log.DeviceOpCtx(c, "UploadTemplateMedia").WithField("template_id", id).Warn("Text templates cannot have media")
+		return router.ResponseBadRequest(c, "text templates cannot have media")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.DeviceOpCtx(c, "UploadTemplateMedia").WithError(err).Error("Failed to open file")
+		return router.ResponseInternalError(c, err.Error())
+	}
+	defer file.Close()
+
+	buf := make([]byte, fileHeader.Size)
+	if _, err := io.ReadFull(file, buf); err != nil {
+		log.DeviceOpCtx(c, "UploadTemplateMedia").WithError(err).Error("Failed to read file")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.DeviceOpCtx(c, "UploadTemplateMedia").WithField("template_id", id).WithField("filename", fileName).WithField("size", fileHeader.Size).Info("Attaching template media")
+
+	tmpl, err = pkgWhatsApp.SetMessageTemplateMedia(ctx, deviceID, id, tmpl.Type, buf, fileHeader.Header.Get(fiber.HeaderContentType), fileName)
+	if err != nil {
+		return templateErrorResponse(c, "UploadTemplateMedia", id, err)
+	}
+
+	log.DeviceOpCtx(c, "UploadTemplateMedia").WithField("template_id", id).Info("Template media attached successfully")
+
+	return router.ResponseSuccessWithData(c, "Success upload template media", tmpl)
+}
+
+// SendTemplate renders a template with the given variables and sends it to a chat.
+// Missing variables are rejected before anything is sent.
+func SendTemplate(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+	chatJID := c.Params("chat_jid")
+
+	if err := validation.ValidateChatJID(chatJID); err != nil {
+		log.MessageOpCtx(c, "SendTemplate", chatJID).Warn("Invalid chat_jid")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	id, err := templateID(c)
+	if err != nil {
+		log.MessageOpCtx(c, "SendTemplate", chatJID).Warn("Invalid template_id")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	var req sendTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.MessageOpCtx(c, "SendTemplate", chatJID).Warn("Failed to parse body request")
+		return router.ResponseBadRequest(c, "Failed parse body request")
+	}
+	deferral, handled, err := scheduled.ParseDeferral(c, "SendTemplate", chatJID, req.SendAt, req.Async || c.Query("async") == "true")
+	if handled {
+		return err
+	}
+	if err := pkgWhatsApp.ValidateClientReference(req.ClientReference, req.Metadata); err != nil {
+		log.MessageOpCtx(c, "SendTemplate", chatJID).Warn("Invalid client_reference or metadata")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	rendered, err := pkgWhatsApp.RenderMessageTemplate(ctx, deviceID, id, req.Variables)
+	if err != nil {
+		return templateErrorResponse(c, "SendTemplate", id, err)
+	}
+
+	if deferral.Deferred() {
+		payload := pkgWhatsApp.ScheduledPayload{
+			ReplyToMessageID:   strings.TrimSpace(req.ReplyMessageID),
+			TypingSimulation:   req.TypingSimulation,
+			PresenceSimulation: req.PresenceSimulation,
+			ClientReference:    req.ClientReference,
+			Metadata:           req.Metadata,
+		}
+		if rendered.Type == pkgWhatsApp.TemplateText {
+			payload.Text = rendered.Text
+		} else {
+			payload.Caption = rendered.Text
+			payload.MimeType = rendered.MimeType
+			payload.FileName = rendered.FileName
+		}
+		_, err := deferral.MaybeSchedule(c, rendered.Type, payload, rendered.Media)
+		return err
+	}
+
+	log.MessageOpCtx(c, "SendTemplate", chatJID).WithField("template_id", id).WithField("type", rendered.Type).Info("Sending template message")
+
+	opts := &pkgWhatsApp.SendOptions{
+		TypingSimulation:   req.TypingSimulation,
+		PresenceSimulation: req.PresenceSimulation,
+		ClientReference:    req.ClientReference,
+		Metadata:           req.Metadata,
+	}
+	if replyID := strings.TrimSpace(req.ReplyMessageID); replyID != "" {
+		opts.ReplyTo = &pkgWhatsApp.ReplyTarget{MessageID: replyID}
+	}
+	msgID, err := pkgWhatsApp.SendRenderedTemplate(ctx, jid, deviceID, chatJID, rendered, opts)
+	if err != nil {
+		if errors.Is(err, pkgWhatsApp.ErrStoredMessageNotFound) {
+			log.MessageOpCtx(c, "SendTemplate", chatJID).WithField("reply_to_message_id", req.ReplyMessageID).Warn("Quoted message not found")
+			return router.ResponseNotFound(c, err.Error())
+		}
+		log.MessageOpCtx(c, "SendTemplate", chatJID).WithError(err).Error("Failed to send template message")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.MessageOpCtx(c, "SendTemplate", chatJID).WithField("message_id", msgID).Info("Template message sent successfully")
+
+	return router.ResponseSuccessWithData(c, "Success send template", map[string]interface{}{"message_id": msgID})
+}
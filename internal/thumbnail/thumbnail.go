@@ -0,0 +1,41 @@
+package thumbnail
+
+import (
+	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+// Embedded returns the preview image carried inside the message and its MIME type,
+// or nil when the message has none
+func Embedded(msg *waE2E.Message) ([]byte, string) {
+	switch {
+	case msg.GetImageMessage() != nil:
+		return nonEmpty(msg.GetImageMessage().GetJPEGThumbnail(), "image/jpeg")
+	case msg.GetVideoMessage() != nil:
+		return nonEmpty(msg.GetVideoMessage().GetJPEGThumbnail(), "image/jpeg")
+	case msg.GetStickerMessage() != nil:
+		return nonEmpty(msg.GetStickerMessage().GetPngThumbnail(), "image/png")
+	case msg.GetDocumentMessage() != nil:
+		return nonEmpty(msg.GetDocumentMessage().GetJPEGThumbnail(), "image/jpeg")
+	case msg.GetExtendedTextMessage() != nil:
+		return nonEmpty(msg.GetExtendedTextMessage().GetJPEGThumbnail(), "image/jpeg")
+	}
+	return nil, ""
+}
+
+// Downloadable returns the link preview of the message when its full-size thumbnail is on the CDN.
+// whatsmeow only downloads link preview thumbnails; image and video messages carry the thumbnail
+// CDN fields too, but they cannot be downloaded on their own.
+func Downloadable(msg *waE2E.Message) (*waE2E.ExtendedTextMessage, bool) {
+	link := msg.GetExtendedTextMessage()
+	if link == nil || link.GetThumbnailDirectPath() == "" || len(link.GetMediaKey()) == 0 {
+		return nil, false
+	}
+	return link, true
+}
+
+func nonEmpty(thumbnail []byte, mimeType string) ([]byte, string) {
+	if len(thumbnail) == 0 {
+		return nil, ""
+	}
+	return thumbnail, mimeType
+}
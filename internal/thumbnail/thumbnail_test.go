@@ -0,0 +1,64 @@
+package thumbnail
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestImageWithoutEmbeddedThumbnailHasNone(t *testing.T) {
+	// image messages carry thumbnail CDN fields, but whatsmeow cannot download them
+	msg := &waE2E.Message{ImageMessage: &waE2E.ImageMessage{
+		ThumbnailDirectPath: proto.String("/v/t62.36145-24/thumb"),
+		ThumbnailSHA256:     []byte{1},
+		ThumbnailEncSHA256:  []byte{2},
+		MediaKey:            []byte{3},
+	}}
+	if data, _ := Embedded(msg); data != nil {
+		t.Errorf("Embedded returned %d bytes for an image without JPEGThumbnail", len(data))
+	}
+	if _, ok := Downloadable(msg); ok {
+		t.Error("Downloadable offered the CDN thumbnail of an image message")
+	}
+}
+
+func TestEmbeddedThumbnail(t *testing.T) {
+	jpeg := []byte{0xff, 0xd8, 0xff}
+	png := []byte{0x89, 'P', 'N', 'G'}
+	cases := []struct {
+		name     string
+		msg      *waE2E.Message
+		data     []byte
+		mimeType string
+	}{
+		{"image", &waE2E.Message{ImageMessage: &waE2E.ImageMessage{JPEGThumbnail: jpeg}}, jpeg, "image/jpeg"},
+		{"video", &waE2E.Message{VideoMessage: &waE2E.VideoMessage{JPEGThumbnail: jpeg}}, jpeg, "image/jpeg"},
+		{"sticker", &waE2E.Message{StickerMessage: &waE2E.StickerMessage{PngThumbnail: png}}, png, "image/png"},
+		{"document", &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{JPEGThumbnail: jpeg}}, jpeg, "image/jpeg"},
+		{"link", &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{JPEGThumbnail: jpeg}}, jpeg, "image/jpeg"},
+		{"text", &waE2E.Message{Conversation: proto.String("hi")}, nil, ""},
+		{"nil", nil, nil, ""},
+	}
+	for _, tc := range cases {
+		data, mimeType := Embedded(tc.msg)
+		if string(data) != string(tc.data) || mimeType != tc.mimeType {
+			t.Errorf("%s: got %d bytes of %q, want %d bytes of %q", tc.name, len(data), mimeType, len(tc.data), tc.mimeType)
+		}
+	}
+}
+
+func TestDownloadableLinkPreview(t *testing.T) {
+	link := &waE2E.ExtendedTextMessage{
+		Text:                proto.String("https://example.com"),
+		ThumbnailDirectPath: proto.String("/v/t62.36145-24/thumb"),
+		MediaKey:            []byte{3},
+	}
+	got, ok := Downloadable(&waE2E.Message{ExtendedTextMessage: link})
+	if !ok || got != link {
+		t.Fatal("Downloadable did not offer the link preview thumbnail")
+	}
+	if _, ok := Downloadable(&waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{Text: proto.String("no preview")}}); ok {
+		t.Error("Downloadable offered a link preview without a CDN thumbnail")
+	}
+}
@@ -0,0 +1,162 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// pcmSampleRate is the rate input audio is decoded at; voice notes are mono speech, so 16 kHz
+// (Opus wideband) keeps the decoded PCM small without audible loss
+const pcmSampleRate = 16000
+
+// FFmpeg runs the ffmpeg binary. It transcodes any audio ffmpeg can decode (mp3, wav, aac, m4a,
+// ogg, ...) and extracts video frames.
+type FFmpeg struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (f *FFmpeg) Name() string {
+	return "ffmpeg"
+}
+
+// VoiceNote decodes the input to 16 kHz mono PCM, measures it, and encodes the PCM to OGG/Opus
+func (f *FFmpeg) VoiceNote(ctx context.Context, data []byte, mimeType string) (*VoiceNote, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	in, err := writeTempInput("voice-note-*", data)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in)
+
+	pcm, err := f.run(ctx, nil,
+		"-i", in, "-vn", "-ac", "1", "-ar", fmt.Sprint(pcmSampleRate), "-f", "s16le", "pipe:1")
+	if err != nil {
+		return nil, fmt.Errorf("decode audio: %w", err)
+	}
+	samples := len(pcm) / 2
+	if samples == 0 {
+		return nil, errors.New("audio contains no samples")
+	}
+
+	encoded, err := f.run(ctx, pcm,
+		"-f", "s16le", "-ar", fmt.Sprint(pcmSampleRate), "-ac", "1", "-i", "pipe:0",
+		"-c:a", "libopus", "-b:a", "32k", "-application", "voip", "-f", "ogg", "pipe:1")
+	if err != nil {
+		return nil, fmt.Errorf("encode opus: %w", err)
+	}
+
+	return &VoiceNote{
+		Data:     encoded,
+		MimeType: VoiceNoteMimeType,
+		Seconds:  uint32((samples + pcmSampleRate - 1) / pcmSampleRate),
+		Waveform: pcmWaveform(pcm),
+	}, nil
+}
+
+// Frame grabs one frame as PNG; ffmpeg applies the rotation of the video track itself.
+// Offsets past the end of a short video fall back to the first frame.
+func (f *FFmpeg) Frame(ctx context.Context, data []byte, at time.Duration) (image.Image, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	in, err := writeTempInput("video-*", data)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in)
+
+	for _, offset := range []time.Duration{at, 0} {
+		out, err := f.run(ctx, nil,
+			"-ss", fmt.Sprintf("%.3f", offset.Seconds()), "-i", in,
+			"-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "pipe:1")
+		if err != nil {
+			return nil, fmt.Errorf("extract frame: %w", err)
+		}
+		if len(out) > 0 {
+			return png.Decode(bytes.NewReader(out))
+		}
+		if offset == 0 {
+			break
+		}
+	}
+	return nil, errors.New("video contains no frames")
+}
+
+// writeTempInput writes media to a temp file for ffmpeg. Containers like mp4 and m4a may keep
+// their index at the end, so they cannot be decoded from a pipe.
+func writeTempInput(pattern string, data []byte) (string, error) {
+	in, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = in.Write(data)
+	if closeErr := in.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(in.Name())
+		return "", err
+	}
+	return in.Name(), nil
+}
+
+func (f *FFmpeg) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, f.Path, append([]string{"-hide_banner", "-loglevel", "error", "-nostdin"}, args...)...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// pcmWaveform averages the absolute amplitude of 16-bit little-endian PCM over WaveformSamples buckets
+func pcmWaveform(pcm []byte) []byte {
+	samples := len(pcm) / 2
+	levels := make([]float64, WaveformSamples)
+	for i := range levels {
+		start := i * samples / WaveformSamples
+		end := (i + 1) * samples / WaveformSamples
+		if end <= start {
+			continue
+		}
+		var sum float64
+		for s := start; s < end; s++ {
+			v := float64(int16(binary.LittleEndian.Uint16(pcm[s*2:])))
+			if v < 0 {
+				v = -v
+			}
+			sum += v
+		}
+		levels[i] = sum / float64(end-start)
+	}
+	return normalizeWaveform(levels)
+}
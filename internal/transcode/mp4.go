@@ -0,0 +1,196 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// VideoInfo is what a video message needs to render before it is downloaded
+type VideoInfo struct {
+	Seconds  uint32
+	Width    int // display width, after rotation
+	Height   int // display height, after rotation
+	Rotation int // clockwise degrees from the track matrix
+}
+
+type mp4Box struct {
+	boxType string
+	payload []byte
+}
+
+// mp4Boxes splits data into its ISO BMFF boxes
+func mp4Boxes(data []byte) ([]mp4Box, error) {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		boxType := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0: // box extends to the end of the file
+			size = uint64(len(data))
+		case 1: // 64-bit size follows the type
+			if len(data) < 16 {
+				return nil, errors.New("truncated mp4 box")
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return nil, errors.New("truncated mp4 box")
+		}
+		boxes = append(boxes, mp4Box{boxType, data[header:size]})
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+func findBox(boxes []mp4Box, boxType string) *mp4Box {
+	for i := range boxes {
+		if boxes[i].boxType == boxType {
+			return &boxes[i]
+		}
+	}
+	return nil
+}
+
+// ProbeMP4 reads the duration and the video track dimensions of an MP4/MOV file from its moov box
+func ProbeMP4(data []byte) (*VideoInfo, error) {
+	if len(data) < 8 {
+		return nil, ErrUnsupported
+	}
+	switch string(data[4:8]) {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide":
+	default:
+		return nil, ErrUnsupported
+	}
+	top, err := mp4Boxes(data)
+	if err != nil {
+		return nil, err
+	}
+	moovBox := findBox(top, "moov")
+	if moovBox == nil {
+		if findBox(top, "ftyp") == nil {
+			return nil, ErrUnsupported
+		}
+		return nil, errors.New("mp4 has no moov box")
+	}
+	moov, err := mp4Boxes(moovBox.payload)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &VideoInfo{}
+	if mvhd := findBox(moov, "mvhd"); mvhd != nil {
+		info.Seconds = mvhdSeconds(mvhd.payload)
+	}
+	if info.Seconds == 0 {
+		// fragmented files keep the duration in mvex/mehd
+		if mvex := findBox(moov, "mvex"); mvex != nil {
+			if children, err := mp4Boxes(mvex.payload); err == nil {
+				if mehd := findBox(children, "mehd"); mehd != nil {
+					info.Seconds = mehdSeconds(mehd.payload, moov)
+				}
+			}
+		}
+	}
+
+	for _, box := range moov {
+		if box.boxType != "trak" {
+			continue
+		}
+		trak, err := mp4Boxes(box.payload)
+		if err != nil || !isVideoTrack(trak) {
+			continue
+		}
+		if tkhd := findBox(trak, "tkhd"); tkhd != nil {
+			info.Width, info.Height, info.Rotation = tkhdDimensions(tkhd.payload)
+		}
+		break
+	}
+	if info.Width == 0 || info.Height == 0 {
+		return nil, errors.New("mp4 has no video track")
+	}
+	return info, nil
+}
+
+// mvhdTimescale returns the movie timescale and duration of an mvhd box
+func mvhdTimescale(p []byte) (timescale uint32, duration uint64) {
+	if len(p) >= 32 && p[0] == 1 {
+		return binary.BigEndian.Uint32(p[20:24]), binary.BigEndian.Uint64(p[24:32])
+	}
+	if len(p) >= 20 {
+		return binary.BigEndian.Uint32(p[12:16]), uint64(binary.BigEndian.Uint32(p[16:20]))
+	}
+	return 0, 0
+}
+
+func mvhdSeconds(p []byte) uint32 {
+	timescale, duration := mvhdTimescale(p)
+	return roundUpSeconds(duration, timescale)
+}
+
+func mehdSeconds(p []byte, moov []mp4Box) uint32 {
+	mvhd := findBox(moov, "mvhd")
+	if mvhd == nil {
+		return 0
+	}
+	timescale, _ := mvhdTimescale(mvhd.payload)
+	var duration uint64
+	switch {
+	case len(p) >= 12 && p[0] == 1:
+		duration = binary.BigEndian.Uint64(p[4:12])
+	case len(p) >= 8:
+		duration = uint64(binary.BigEndian.Uint32(p[4:8]))
+	}
+	return roundUpSeconds(duration, timescale)
+}
+
+func roundUpSeconds(duration uint64, timescale uint32) uint32 {
+	if timescale == 0 || duration == 0 || duration == 0xffffffff || duration == 0xffffffffffffffff {
+		return 0
+	}
+	return uint32((duration + uint64(timescale) - 1) / uint64(timescale))
+}
+
+// isVideoTrack checks the handler type of a trak's mdia box
+func isVideoTrack(trak []mp4Box) bool {
+	mdiaBox := findBox(trak, "mdia")
+	if mdiaBox == nil {
+		return false
+	}
+	mdia, err := mp4Boxes(mdiaBox.payload)
+	if err != nil {
+		return false
+	}
+	hdlr := findBox(mdia, "hdlr")
+	return hdlr != nil && len(hdlr.payload) >= 12 && string(hdlr.payload[8:12]) == "vide"
+}
+
+// tkhdDimensions returns the display size of a track and its rotation. Phones record portrait
+// video as landscape frames with a 90 degree matrix, so width and height are swapped for it.
+func tkhdDimensions(p []byte) (width, height, rotation int) {
+	offset := 40 // version 0: matrix after the 32-bit times, ids and duration
+	if len(p) > 0 && p[0] == 1 {
+		offset = 52
+	}
+	if len(p) < offset+44 {
+		return 0, 0, 0
+	}
+	a := int32(binary.BigEndian.Uint32(p[offset : offset+4]))
+	b := int32(binary.BigEndian.Uint32(p[offset+4 : offset+8]))
+	width = int(binary.BigEndian.Uint32(p[offset+36:offset+40]) >> 16)
+	height = int(binary.BigEndian.Uint32(p[offset+40:offset+44]) >> 16)
+
+	switch {
+	case a == 0 && b > 0:
+		rotation = 90
+	case a < 0 && b == 0:
+		rotation = 180
+	case a == 0 && b < 0:
+		rotation = 270
+	}
+	if rotation == 90 || rotation == 270 {
+		width, height = height, width
+	}
+	return width, height, rotation
+}
@@ -0,0 +1,110 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+)
+
+// opusSampleRate is the rate Ogg granule positions of an Opus stream are counted in
+const opusSampleRate = 48000
+
+// GoTranscoder needs no external tools but cannot encode Opus: it only accepts audio that is
+// already OGG/Opus, which it passes through after reading the duration from the stream. The
+// waveform is approximated from Opus packet sizes, which follow loudness closely for speech.
+type GoTranscoder struct{}
+
+func (GoTranscoder) Name() string {
+	return "go"
+}
+
+func (GoTranscoder) VoiceNote(_ context.Context, data []byte, _ string) (*VoiceNote, error) {
+	stream, err := parseOggOpus(data)
+	if err != nil {
+		return nil, err
+	}
+	samples := stream.granule - int64(stream.preSkip)
+	if samples < 0 {
+		samples = 0
+	}
+
+	levels := make([]float64, WaveformSamples)
+	counts := make([]int, WaveformSamples)
+	for i, size := range stream.packetSizes {
+		bucket := i * WaveformSamples / len(stream.packetSizes)
+		levels[bucket] += float64(size)
+		counts[bucket]++
+	}
+	for i := range levels {
+		if counts[i] > 0 {
+			levels[i] /= float64(counts[i])
+		}
+	}
+
+	return &VoiceNote{
+		Data:     data,
+		MimeType: VoiceNoteMimeType,
+		Seconds:  uint32((samples + opusSampleRate - 1) / opusSampleRate),
+		Waveform: normalizeWaveform(levels),
+	}, nil
+}
+
+type oggOpusStream struct {
+	preSkip     uint16
+	granule     int64 // granule position of the last page
+	packetSizes []int // sizes of the audio packets, header packets excluded
+}
+
+// parseOggOpus walks the Ogg pages of a single Opus stream; anything else is ErrUnsupported
+func parseOggOpus(data []byte) (*oggOpusStream, error) {
+	if !bytes.HasPrefix(data, []byte("OggS")) {
+		return nil, ErrUnsupported
+	}
+	stream := &oggOpusStream{}
+	var packet []byte
+	packets := 0
+	for len(data) > 0 {
+		if len(data) < 27 || !bytes.HasPrefix(data, []byte("OggS")) {
+			return nil, errors.New("truncated ogg page")
+		}
+		granule := int64(binary.LittleEndian.Uint64(data[6:14]))
+		segments := int(data[26])
+		if len(data) < 27+segments {
+			return nil, errors.New("truncated ogg page")
+		}
+		table := data[27 : 27+segments]
+		body := data[27+segments:]
+		for _, lacing := range table {
+			if len(body) < int(lacing) {
+				return nil, errors.New("truncated ogg page")
+			}
+			packet = append(packet, body[:lacing]...)
+			body = body[lacing:]
+			if lacing == 255 {
+				continue
+			}
+			switch packets {
+			case 0:
+				if len(packet) < 19 || !bytes.HasPrefix(packet, []byte("OpusHead")) {
+					return nil, ErrUnsupported
+				}
+				stream.preSkip = binary.LittleEndian.Uint16(packet[10:12])
+			case 1:
+				// OpusTags
+			default:
+				stream.packetSizes = append(stream.packetSizes, len(packet))
+			}
+			packets++
+			packet = packet[:0]
+		}
+		if granule > 0 {
+			stream.granule = granule
+		}
+		data = body
+	}
+	if packets < 2 || len(stream.packetSizes) == 0 {
+		return nil, errors.New("ogg stream contains no audio")
+	}
+	return stream, nil
+}
@@ -0,0 +1,266 @@
+package transcode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"net/http"
+	"runtime"
+
+	"github.com/sunshineplan/imgconv"
+	"golang.org/x/sync/errgroup"
+)
+
+// StickerSize is the square canvas WhatsApp renders stickers on
+const StickerSize = 512
+
+// maxStickerFrames bounds animated stickers; longer GIFs are sampled down to this many frames
+const maxStickerFrames = 30
+
+// Size limits WhatsApp clients enforce on stickers; larger stickers are not displayed
+const (
+	MaxStaticStickerBytes   = 100 * 1024
+	MaxAnimatedStickerBytes = 500 * 1024
+)
+
+// ErrStickerTooLarge is returned when a sticker does not fit the WhatsApp size limit. It wraps
+// ErrUnsupported, so callers reject the input like any other sticker they cannot build.
+var ErrStickerTooLarge = fmt.Errorf("%w: sticker exceeds the WhatsApp size limit", ErrUnsupported)
+
+// StickerMetadata is the pack information WhatsApp shows for a sticker, stored in its WebP EXIF
+type StickerMetadata struct {
+	PackName  string   `json:"pack_name,omitempty"`
+	Publisher string   `json:"publisher,omitempty"`
+	Emojis    []string `json:"emojis,omitempty"`
+}
+
+// IsZero reports whether no metadata is set
+func (m StickerMetadata) IsZero() bool {
+	return m.PackName == "" && m.Publisher == "" && len(m.Emojis) == 0
+}
+
+// Sticker is a WebP ready to be sent as a StickerMessage
+type Sticker struct {
+	Data     []byte
+	Width    int
+	Height   int
+	Animated bool
+}
+
+// BuildSticker turns PNG, JPEG or GIF input into a 512x512 WebP sticker, scaling the image to
+// fit and padding it with transparency. Animated GIFs become animated stickers, with fewer frames
+// when the full animation is over MaxAnimatedStickerBytes. WebP input is kept as-is. The metadata,
+// when set, is embedded as sticker EXIF. A sticker that still exceeds its size limit is
+// ErrStickerTooLarge.
+func BuildSticker(data []byte, meta StickerMetadata) (*Sticker, error) {
+	sticker, err := buildSticker(data, meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStickerSize(sticker); err != nil {
+		return nil, err
+	}
+	return sticker, nil
+}
+
+// checkStickerSize enforces MaxStaticStickerBytes or MaxAnimatedStickerBytes
+func checkStickerSize(s *Sticker) error {
+	limit := MaxStaticStickerBytes
+	if s.Animated {
+		limit = MaxAnimatedStickerBytes
+	}
+	if len(s.Data) > limit {
+		return fmt.Errorf("%w (%d KB > %d KB)", ErrStickerTooLarge, (len(s.Data)+1023)/1024, limit/1024)
+	}
+	return nil
+}
+
+func buildSticker(data []byte, meta StickerMetadata) (*Sticker, error) {
+	var exif []byte
+	if !meta.IsZero() {
+		exif = stickerEXIF(meta)
+	}
+
+	switch mimeType := http.DetectContentType(data); mimeType {
+	case "image/webp":
+		chunks, err := parseWebP(data)
+		if err != nil {
+			return nil, err
+		}
+		width, height, animated, err := webpInfo(chunks)
+		if err != nil {
+			return nil, err
+		}
+		if exif != nil {
+			data = setWebPEXIF(chunks, width, height, exif)
+		}
+		return &Sticker{Data: data, Width: width, Height: height, Animated: animated}, nil
+	case "image/gif":
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decode gif: %w", err)
+		}
+		if len(anim.Image) > 1 {
+			return buildAnimatedSticker(anim, exif)
+		}
+		return buildStillSticker(anim.Image[0], exif)
+	case "image/png", "image/jpeg":
+		img, err := imgconv.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decode image: %w", err)
+		}
+		return buildStillSticker(img, exif)
+	default:
+		return nil, fmt.Errorf("%w: stickers can be made from PNG, JPEG, GIF or WebP, not %s", ErrUnsupported, mimeType)
+	}
+}
+
+func buildStillSticker(img image.Image, exif []byte) (*Sticker, error) {
+	encoded, err := encodeWebP(fitSticker(img), exif)
+	if err != nil {
+		return nil, fmt.Errorf("encode webp: %w", err)
+	}
+	return &Sticker{Data: encoded, Width: StickerSize, Height: StickerSize}, nil
+}
+
+// buildAnimatedSticker composites the GIF frames (honoring their disposal methods) and encodes
+// every composited frame as a full 512x512 WebP frame
+func buildAnimatedSticker(anim *gif.GIF, exif []byte) (*Sticker, error) {
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	if bounds.Empty() {
+		bounds = anim.Image[0].Bounds()
+	}
+	canvas := image.NewNRGBA(bounds)
+
+	step := (len(anim.Image) + maxStickerFrames - 1) / maxStickerFrames
+	var frames []webpFrame
+	for i, frame := range anim.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		delay := 10
+		if i < len(anim.Delay) && anim.Delay[i] > 1 {
+			delay = anim.Delay[i]
+		}
+		if i%step == 0 {
+			snapshot := image.NewNRGBA(bounds)
+			copy(snapshot.Pix, canvas.Pix)
+			frames = append(frames, webpFrame{img: snapshot, durationMS: delay * 10})
+		} else {
+			// skipped frames extend the last kept frame so the animation keeps its speed
+			frames[len(frames)-1].durationMS += delay * 10
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	var g errgroup.Group
+	g.SetLimit(runtime.NumCPU())
+	for i := range frames {
+		g.Go(func() error {
+			frames[i].img = fitSticker(frames[i].img)
+			return nil
+		})
+	}
+	g.Wait()
+
+	// Frames are lossless, so an animation over the size limit is thinned out until it fits
+	loopCount := webpLoopCount(anim.LoopCount)
+	for {
+		encoded, err := encodeAnimatedWebP(frames, StickerSize, StickerSize, loopCount, exif)
+		if err != nil {
+			return nil, fmt.Errorf("encode webp: %w", err)
+		}
+		if len(encoded) <= MaxAnimatedStickerBytes || len(frames) <= 2 {
+			return &Sticker{Data: encoded, Width: StickerSize, Height: StickerSize, Animated: true}, nil
+		}
+		frames = halveFrames(frames)
+	}
+}
+
+// halveFrames keeps every other frame; each dropped frame extends the one before it so the
+// animation keeps its speed
+func halveFrames(frames []webpFrame) []webpFrame {
+	kept := make([]webpFrame, 0, (len(frames)+1)/2)
+	for i, f := range frames {
+		if i%2 == 0 {
+			kept = append(kept, f)
+		} else {
+			kept[len(kept)-1].durationMS += f.durationMS
+		}
+	}
+	return kept
+}
+
+// webpLoopCount maps a GIF loop count to the WebP one. A GIF with loop count n > 0 repeats n
+// times after the first play, while WebP counts plays; 0 loops forever in both, and -1 (no
+// NETSCAPE extension) plays once.
+func webpLoopCount(gifLoopCount int) int {
+	switch {
+	case gifLoopCount < 0:
+		return 1
+	case gifLoopCount == 0:
+		return 0
+	}
+	return min(gifLoopCount+1, 0xffff)
+}
+
+// fitSticker scales an image to fit StickerSize and centers it on a transparent square canvas
+func fitSticker(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	width, height := StickerSize, StickerSize
+	if b.Dx() > b.Dy() {
+		height = max(1, b.Dy()*StickerSize/b.Dx())
+	} else if b.Dy() > b.Dx() {
+		width = max(1, b.Dx()*StickerSize/b.Dy())
+	}
+	scaled := imgconv.Resize(img, &imgconv.ResizeOption{Width: width, Height: height})
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, StickerSize, StickerSize))
+	offset := image.Pt((StickerSize-width)/2, (StickerSize-height)/2)
+	draw.Draw(canvas, image.Rectangle{Min: offset, Max: offset.Add(image.Pt(width, height))}, scaled, scaled.Bounds().Min, draw.Src)
+	return canvas
+}
+
+// stickerEXIF builds the EXIF block WhatsApp reads sticker pack info from: a little-endian
+// TIFF header with one IFD entry (tag 0x5741) pointing at a JSON document
+func stickerEXIF(meta StickerMetadata) []byte {
+	// stickers with the same pack name and publisher share a pack id so clients group them
+	sum := sha256.Sum256([]byte(meta.PackName + "\x00" + meta.Publisher))
+	payload, _ := json.Marshal(struct {
+		PackID    string   `json:"sticker-pack-id"`
+		PackName  string   `json:"sticker-pack-name"`
+		Publisher string   `json:"sticker-pack-publisher"`
+		Emojis    []string `json:"emojis,omitempty"`
+	}{hex.EncodeToString(sum[:16]), meta.PackName, meta.Publisher, meta.Emojis})
+
+	header := []byte{
+		0x49, 0x49, 0x2a, 0x00, 0x08, 0x00, 0x00, 0x00, // "II", 42, IFD at offset 8
+		0x01, 0x00, // one entry
+		0x41, 0x57, 0x07, 0x00, // tag 0x5741, type UNDEFINED
+		0x00, 0x00, 0x00, 0x00, // count, set below
+		0x16, 0x00, 0x00, 0x00, // value offset: right after this header
+	}
+	binary.LittleEndian.PutUint32(header[14:18], uint32(len(payload)))
+	return append(header, payload...)
+}
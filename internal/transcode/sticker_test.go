@@ -0,0 +1,204 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func solidImage(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// chunksOf returns the chunks of a WebP with the given fourCC
+func chunksOf(t *testing.T, data []byte, fourCC string) []webpChunk {
+	t.Helper()
+	chunks, err := parseWebP(data)
+	if err != nil {
+		t.Fatalf("parseWebP: %v", err)
+	}
+	var found []webpChunk
+	for _, c := range chunks {
+		if c.fourCC == fourCC {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+func TestBuildStickerFromPNG(t *testing.T) {
+	data := encodePNG(t, solidImage(200, 100, color.NRGBA{R: 255, A: 255}))
+	sticker, err := BuildSticker(data, StickerMetadata{PackName: "Pack", Publisher: "Me", Emojis: []string{"😀"}})
+	if err != nil {
+		t.Fatalf("BuildSticker: %v", err)
+	}
+	if sticker.Width != StickerSize || sticker.Height != StickerSize || sticker.Animated {
+		t.Fatalf("sticker is %dx%d animated=%v, want a still %dx%d", sticker.Width, sticker.Height, sticker.Animated, StickerSize, StickerSize)
+	}
+
+	chunks, err := parseWebP(sticker.Data)
+	if err != nil {
+		t.Fatalf("parseWebP: %v", err)
+	}
+	width, height, animated, err := webpInfo(chunks)
+	if err != nil || width != StickerSize || height != StickerSize || animated {
+		t.Fatalf("webpInfo = %dx%d animated=%v err=%v", width, height, animated, err)
+	}
+	if chunks[0].payload[0]&webpFlagEXIF == 0 {
+		t.Error("VP8X does not flag the EXIF chunk")
+	}
+	exif := chunksOf(t, sticker.Data, "EXIF")
+	if len(exif) != 1 || !strings.Contains(string(exif[0].payload), `"sticker-pack-name":"Pack"`) {
+		t.Fatalf("sticker EXIF does not carry the pack name: %q", exif)
+	}
+}
+
+func TestBuildStickerKeepsWebPAndReplacesEXIF(t *testing.T) {
+	first, err := BuildSticker(encodePNG(t, solidImage(64, 64, color.NRGBA{B: 255, A: 255})), StickerMetadata{PackName: "Old"})
+	if err != nil {
+		t.Fatalf("BuildSticker: %v", err)
+	}
+	second, err := BuildSticker(first.Data, StickerMetadata{PackName: "New"})
+	if err != nil {
+		t.Fatalf("BuildSticker on WebP input: %v", err)
+	}
+	if second.Width != StickerSize || second.Height != StickerSize {
+		t.Fatalf("WebP input resized to %dx%d", second.Width, second.Height)
+	}
+	exif := chunksOf(t, second.Data, "EXIF")
+	if len(exif) != 1 || !strings.Contains(string(exif[0].payload), `"sticker-pack-name":"New"`) {
+		t.Fatalf("EXIF not replaced: %q", exif)
+	}
+	if !bytes.Equal(chunksOf(t, first.Data, "VP8L")[0].payload, chunksOf(t, second.Data, "VP8L")[0].payload) {
+		t.Error("WebP image data was re-encoded")
+	}
+}
+
+func TestBuildAnimatedStickerFromGIF(t *testing.T) {
+	colors := []color.Color{color.White, color.Black, palette.Plan9[100]}
+	anim := &gif.GIF{LoopCount: 2}
+	for i, c := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, 32, 32), palette.Plan9)
+		for p := range frame.Pix {
+			frame.Pix[p] = uint8(frame.Palette.Index(c))
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 5*(i+1))
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+
+	sticker, err := BuildSticker(buf.Bytes(), StickerMetadata{})
+	if err != nil {
+		t.Fatalf("BuildSticker: %v", err)
+	}
+	if !sticker.Animated {
+		t.Fatal("multi-frame GIF did not become an animated sticker")
+	}
+
+	animChunk := chunksOf(t, sticker.Data, "ANIM")
+	if len(animChunk) != 1 {
+		t.Fatalf("found %d ANIM chunks, want 1", len(animChunk))
+	}
+	if loops := binary.LittleEndian.Uint16(animChunk[0].payload[4:]); loops != 3 {
+		t.Errorf("WebP loop count = %d, want 3 for a GIF that repeats twice", loops)
+	}
+
+	frames := chunksOf(t, sticker.Data, "ANMF")
+	if len(frames) != len(colors) {
+		t.Fatalf("found %d frames, want %d", len(frames), len(colors))
+	}
+	for i, f := range frames {
+		p := f.payload
+		duration := int(p[12]) | int(p[13])<<8 | int(p[14])<<16
+		if want := 50 * (i + 1); duration != want {
+			t.Errorf("frame %d lasts %dms, want %dms", i, duration, want)
+		}
+	}
+}
+
+func TestBuildStickerRejectsOversizedStill(t *testing.T) {
+	// random pixels do not compress, so the lossless WebP is far over the static limit
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, StickerSize, StickerSize))
+	rng.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+
+	_, err := BuildSticker(encodePNG(t, img), StickerMetadata{})
+	if !errors.Is(err, ErrStickerTooLarge) {
+		t.Fatalf("BuildSticker error = %v, want ErrStickerTooLarge", err)
+	}
+	if !errors.Is(err, ErrUnsupported) {
+		t.Error("ErrStickerTooLarge does not wrap ErrUnsupported")
+	}
+}
+
+func TestCheckStickerSize(t *testing.T) {
+	cases := []struct {
+		size     int
+		animated bool
+		ok       bool
+	}{
+		{MaxStaticStickerBytes, false, true},
+		{MaxStaticStickerBytes + 1, false, false},
+		{MaxStaticStickerBytes + 1, true, true},
+		{MaxAnimatedStickerBytes, true, true},
+		{MaxAnimatedStickerBytes + 1, true, false},
+	}
+	for _, c := range cases {
+		err := checkStickerSize(&Sticker{Data: make([]byte, c.size), Animated: c.animated})
+		if (err == nil) != c.ok {
+			t.Errorf("checkStickerSize(%d bytes, animated=%v) = %v, want ok=%v", c.size, c.animated, err, c.ok)
+		}
+	}
+}
+
+func TestWebPLoopCount(t *testing.T) {
+	cases := map[int]int{-1: 1, 0: 0, 1: 2, 4: 5, 0xffff: 0xffff}
+	for gifLoops, want := range cases {
+		if got := webpLoopCount(gifLoops); got != want {
+			t.Errorf("webpLoopCount(%d) = %d, want %d", gifLoops, got, want)
+		}
+	}
+}
+
+func TestHalveFramesKeepsDuration(t *testing.T) {
+	frames := []webpFrame{{durationMS: 10}, {durationMS: 20}, {durationMS: 30}, {durationMS: 40}, {durationMS: 50}}
+	halved := halveFrames(frames)
+	want := []int{30, 70, 50}
+	if len(halved) != len(want) {
+		t.Fatalf("halveFrames kept %d frames, want %d", len(halved), len(want))
+	}
+	for i, f := range halved {
+		if f.durationMS != want[i] {
+			t.Errorf("frame %d lasts %dms, want %dms", i, f.durationMS, want[i])
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/env"
+)
+
+// ErrUnsupported is returned for input a transcoder or the sticker builder cannot handle
+var ErrUnsupported = errors.New("media format is not supported")
+
+// VoiceNoteMimeType is the only format WhatsApp clients play as a voice note
+const VoiceNoteMimeType = "audio/ogg; codecs=opus"
+
+// WaveformSamples is the number of waveform bars WhatsApp clients draw for a voice note
+const WaveformSamples = 64
+
+// VoiceNote is audio ready to be sent as a PTT message
+type VoiceNote struct {
+	Data     []byte
+	MimeType string
+	Seconds  uint32
+	Waveform []byte // WaveformSamples values from 0 to 100
+}
+
+// AudioTranscoder converts audio into a voice note
+type AudioTranscoder interface {
+	// Name identifies the transcoder ("ffmpeg", "go")
+	Name() string
+	// VoiceNote converts the input to mono OGG/Opus and measures its duration and waveform
+	VoiceNote(ctx context.Context, data []byte, mimeType string) (*VoiceNote, error)
+}
+
+// FrameExtractor decodes a still frame of a video, used for video thumbnails
+type FrameExtractor interface {
+	// Name identifies the extractor ("ffmpeg")
+	Name() string
+	// Frame returns the frame shown at the given offset, with the track rotation applied
+	Frame(ctx context.Context, data []byte, at time.Duration) (image.Image, error)
+}
+
+// NewAudioFromEnv builds the transcoder selected by AUDIO_TRANSCODER (auto, ffmpeg, go or none).
+// auto uses ffmpeg when it is installed; without it, auto uses the pure-Go transcoder as
+// BestEffort, so audio that is not OGG/Opus is sent unchanged instead of being rejected. go only
+// accepts audio that is already OGG/Opus. none returns a nil transcoder.
+func NewAudioFromEnv() (AudioTranscoder, error) {
+	ffmpegPath := env.GetEnvStringOrDefault("FFMPEG_PATH", "ffmpeg")
+	timeout := env.GetEnvDurationOrDefault("AUDIO_TRANSCODE_TIMEOUT", time.Minute)
+
+	backend := strings.ToLower(env.GetEnvStringOrDefault("AUDIO_TRANSCODER", "auto"))
+	switch backend {
+	case "auto":
+		path, err := exec.LookPath(ffmpegPath)
+		if err != nil {
+			return BestEffort{GoTranscoder{}}, nil
+		}
+		return Chain{&FFmpeg{Path: path, Timeout: timeout}, GoTranscoder{}}, nil
+	case "ffmpeg":
+		path, err := exec.LookPath(ffmpegPath)
+		if err != nil {
+			return nil, fmt.Errorf("ffmpeg not found at %s: %w", ffmpegPath, err)
+		}
+		return &FFmpeg{Path: path, Timeout: timeout}, nil
+	case "go":
+		return GoTranscoder{}, nil
+	case "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown audio transcoder: %s", backend)
+}
+
+// NewFrameExtractorFromEnv builds the extractor selected by VIDEO_FRAME_EXTRACTOR (auto, ffmpeg
+// or none). There is no pure-Go video decoder, so auto returns nil when ffmpeg is not installed.
+func NewFrameExtractorFromEnv() (FrameExtractor, error) {
+	ffmpegPath := env.GetEnvStringOrDefault("FFMPEG_PATH", "ffmpeg")
+	timeout := env.GetEnvDurationOrDefault("VIDEO_FRAME_TIMEOUT", 30*time.Second)
+
+	backend := strings.ToLower(env.GetEnvStringOrDefault("VIDEO_FRAME_EXTRACTOR", "auto"))
+	switch backend {
+	case "auto", "ffmpeg":
+		path, err := exec.LookPath(ffmpegPath)
+		if err != nil {
+			if backend == "auto" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("ffmpeg not found at %s: %w", ffmpegPath, err)
+		}
+		return &FFmpeg{Path: path, Timeout: timeout}, nil
+	case "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown video frame extractor: %s", backend)
+}
+
+// Chain tries each transcoder in order until one succeeds
+type Chain []AudioTranscoder
+
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, t := range c {
+		names[i] = t.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+// VoiceNote returns the first successful conversion. A transcoder reporting ErrUnsupported is
+// skipped silently; otherwise the first real error is returned if nothing succeeds.
+func (c Chain) VoiceNote(ctx context.Context, data []byte, mimeType string) (*VoiceNote, error) {
+	var firstErr error
+	for _, t := range c {
+		note, err := t.VoiceNote(ctx, data, mimeType)
+		if err == nil {
+			return note, nil
+		}
+		if firstErr == nil && !errors.Is(err, ErrUnsupported) {
+			firstErr = fmt.Errorf("%s: %w", t.Name(), err)
+		}
+	}
+	if firstErr == nil {
+		firstErr = ErrUnsupported
+	}
+	return nil, firstErr
+}
+
+// BestEffort wraps a transcoder whose ErrUnsupported is not fatal: the caller sends the audio
+// unchanged instead of rejecting it
+type BestEffort struct {
+	AudioTranscoder
+}
+
+// IsBestEffort reports whether t converts on a best-effort basis, see BestEffort
+func IsBestEffort(t AudioTranscoder) bool {
+	_, ok := t.(BestEffort)
+	return ok
+}
+
+// normalizeWaveform scales bucket levels to the 0-100 range used by AudioMessage.Waveform
+func normalizeWaveform(levels []float64) []byte {
+	waveform := make([]byte, WaveformSamples)
+	peak := 0.0
+	for _, l := range levels {
+		if l > peak {
+			peak = l
+		}
+	}
+	if peak == 0 {
+		return waveform
+	}
+	for i := range waveform {
+		if i < len(levels) {
+			waveform[i] = byte(levels[i] / peak * 100)
+		}
+	}
+	return waveform
+}
@@ -0,0 +1,227 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"runtime"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/sync/errgroup"
+)
+
+// VP8X feature flags
+const (
+	webpFlagAnimation = 0x02
+	webpFlagEXIF      = 0x08
+	webpFlagAlpha     = 0x10
+)
+
+type webpChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+// webpFrame is one lossless frame of an animated WebP
+type webpFrame struct {
+	img        image.Image
+	durationMS int
+}
+
+func appendChunk(buf *bytes.Buffer, c webpChunk) {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(c.payload)))
+	buf.WriteString(c.fourCC)
+	buf.Write(size[:])
+	buf.Write(c.payload)
+	if len(c.payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+func vp8xChunk(flags byte, width, height int) webpChunk {
+	payload := make([]byte, 10)
+	payload[0] = flags
+	putUint24(payload[4:], width-1)
+	putUint24(payload[7:], height-1)
+	return webpChunk{"VP8X", payload}
+}
+
+// riffWebP wraps chunks in the RIFF container
+func riffWebP(chunks []webpChunk) []byte {
+	body := &bytes.Buffer{}
+	body.WriteString("WEBP")
+	for _, c := range chunks {
+		appendChunk(body, c)
+	}
+	out := make([]byte, 8, 8+body.Len())
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(body.Len()))
+	return append(out, body.Bytes()...)
+}
+
+// encodeVP8L encodes an image as a lossless VP8L bitstream and reports whether it uses alpha.
+// An encoder panic is returned as an error rather than taking down the request.
+func encodeVP8L(img image.Image) (stream []byte, hasAlpha bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("webp encoder failed: %v", r)
+		}
+	}()
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, false, err
+	}
+	chunks, err := parseWebP(buf.Bytes())
+	if err != nil {
+		return nil, false, err
+	}
+	vp8l := chunks[0]
+	if vp8l.fourCC != "VP8L" || len(vp8l.payload) < 5 {
+		return nil, false, errors.New("webp encoder did not produce a VP8L bitstream")
+	}
+	return vp8l.payload, vp8l.payload[4]&0x10 != 0, nil
+}
+
+// encodeWebP encodes a still image as lossless WebP in the extended format, with an EXIF chunk when exif is set
+func encodeWebP(img image.Image, exif []byte) ([]byte, error) {
+	stream, hasAlpha, err := encodeVP8L(img)
+	if err != nil {
+		return nil, err
+	}
+	var flags byte
+	if hasAlpha {
+		flags |= webpFlagAlpha
+	}
+	if exif != nil {
+		flags |= webpFlagEXIF
+	}
+	bounds := img.Bounds()
+	chunks := []webpChunk{vp8xChunk(flags, bounds.Dx(), bounds.Dy()), {"VP8L", stream}}
+	if exif != nil {
+		chunks = append(chunks, webpChunk{"EXIF", exif})
+	}
+	return riffWebP(chunks), nil
+}
+
+// encodeAnimatedWebP encodes full-canvas frames as a looping animated WebP
+func encodeAnimatedWebP(frames []webpFrame, width, height int, loopCount int, exif []byte) ([]byte, error) {
+	flags := byte(webpFlagAnimation | webpFlagAlpha)
+	if exif != nil {
+		flags |= webpFlagEXIF
+	}
+	anim := make([]byte, 6) // transparent background color, then the loop count
+	binary.LittleEndian.PutUint16(anim[4:], uint16(loopCount))
+	chunks := []webpChunk{vp8xChunk(flags, width, height), {"ANIM", anim}}
+
+	// frames are encoded in parallel; lossless encoding dominates the build time
+	streams := make([][]byte, len(frames))
+	var g errgroup.Group
+	g.SetLimit(runtime.NumCPU())
+	for i, f := range frames {
+		g.Go(func() error {
+			stream, _, err := encodeVP8L(f.img)
+			streams[i] = stream
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for i, f := range frames {
+		frame := &bytes.Buffer{}
+		header := make([]byte, 16) // frame offset 0,0 and no blending, so every frame replaces the canvas
+		putUint24(header[6:], f.img.Bounds().Dx()-1)
+		putUint24(header[9:], f.img.Bounds().Dy()-1)
+		putUint24(header[12:], f.durationMS)
+		header[15] = 0x02
+		frame.Write(header)
+		appendChunk(frame, webpChunk{"VP8L", streams[i]})
+		chunks = append(chunks, webpChunk{"ANMF", frame.Bytes()})
+	}
+	if exif != nil {
+		chunks = append(chunks, webpChunk{"EXIF", exif})
+	}
+	return riffWebP(chunks), nil
+}
+
+// parseWebP splits a WebP file into its chunks
+func parseWebP(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrUnsupported
+	}
+	var chunks []webpChunk
+	rest := data[12:]
+	for len(rest) >= 8 {
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		if size > len(rest)-8 {
+			return nil, errors.New("truncated webp chunk")
+		}
+		chunks = append(chunks, webpChunk{string(rest[0:4]), rest[8 : 8+size]})
+		rest = rest[8+size:]
+		if size%2 == 1 && len(rest) > 0 {
+			rest = rest[1:]
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, errors.New("webp contains no image")
+	}
+	return chunks, nil
+}
+
+// webpInfo returns the canvas size of a WebP and whether it is animated
+func webpInfo(chunks []webpChunk) (width, height int, animated bool, err error) {
+	c := chunks[0]
+	switch c.fourCC {
+	case "VP8X":
+		if len(c.payload) < 10 {
+			break
+		}
+		p := c.payload
+		width = 1 + (int(p[4]) | int(p[5])<<8 | int(p[6])<<16)
+		height = 1 + (int(p[7]) | int(p[8])<<8 | int(p[9])<<16)
+		return width, height, p[0]&webpFlagAnimation != 0, nil
+	case "VP8L":
+		if len(c.payload) < 5 || c.payload[0] != 0x2f {
+			break
+		}
+		bits := binary.LittleEndian.Uint32(c.payload[1:5])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, false, nil
+	case "VP8 ":
+		if len(c.payload) < 10 {
+			break
+		}
+		return int(binary.LittleEndian.Uint16(c.payload[6:8]) & 0x3fff), int(binary.LittleEndian.Uint16(c.payload[8:10]) & 0x3fff), false, nil
+	}
+	return 0, 0, false, errors.New("invalid webp header")
+}
+
+// setWebPEXIF replaces the EXIF metadata of a WebP, converting simple files to the extended format
+func setWebPEXIF(chunks []webpChunk, width, height int, exif []byte) []byte {
+	var out []webpChunk
+	if chunks[0].fourCC == "VP8X" {
+		vp8x := webpChunk{"VP8X", append([]byte(nil), chunks[0].payload...)}
+		vp8x.payload[0] |= webpFlagEXIF
+		out = append(out, vp8x)
+		chunks = chunks[1:]
+	} else {
+		var flags byte
+		if chunks[0].fourCC == "VP8L" && len(chunks[0].payload) >= 5 && chunks[0].payload[4]&0x10 != 0 {
+			flags |= webpFlagAlpha
+		}
+		out = append(out, vp8xChunk(flags, width, height))
+	}
+	for _, c := range chunks {
+		if c.fourCC != "EXIF" {
+			out = append(out, c)
+		}
+	}
+	return riffWebP(append(out, webpChunk{"EXIF", exif}))
+}
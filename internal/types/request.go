@@ -43,13 +43,19 @@ type RequestStatus struct {
 }
 
 type RequestSendMessage struct {
-	Phone          string
-	Message        string
-	Text           string
-	ReplyMessageID string
-	ViewOnce       bool
-	TypingSimulation   *bool `json:"typing_simulation"`
-	PresenceSimulation *bool `json:"presence_simulation"`
+	Phone              string
+	Message            string
+	Text               string
+	ReplyMessageID     string `json:"reply_to_message_id"`
+	ViewOnce           bool
+	Mentions           []string          `json:"mentions"`    // phone numbers or JIDs; @<number> tokens in group text are detected too
+	MentionAll         bool              `json:"mention_all"` // mention every group participant
+	TypingSimulation   *bool             `json:"typing_simulation"`
+	PresenceSimulation *bool             `json:"presence_simulation"`
+	SendAt             string            `json:"send_at"`          // RFC3339; schedules the message instead of sending it now
+	Async              bool              `json:"async"`            // queue the message in the outbox and return 202 with a job ID
+	ClientReference    string            `json:"client_reference"` // echoed in message.sent and receipt webhooks
+	Metadata           map[string]string `json:"metadata"`
 }
 
 type RequestSendLink struct {
@@ -61,21 +67,33 @@ type RequestSendLink struct {
 }
 
 type RequestSendLocation struct {
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Name      string  `json:"name"`
-	Address   string  `json:"address"`
+	Latitude        float64           `json:"latitude"`
+	Longitude       float64           `json:"longitude"`
+	Name            string            `json:"name"`
+	Address         string            `json:"address"`
+	SendAt          string            `json:"send_at"`
+	Async           bool              `json:"async"`
+	ClientReference string            `json:"client_reference"`
+	Metadata        map[string]string `json:"metadata"`
 }
 
 type RequestSendContact struct {
-	Name  string
-	Phone string
+	Name            string
+	Phone           string
+	SendAt          string            `json:"send_at"`
+	Async           bool              `json:"async"`
+	ClientReference string            `json:"client_reference"`
+	Metadata        map[string]string `json:"metadata"`
 }
 
 type RequestSendPoll struct {
-	Question    string
-	Options     []string
-	MultiAnswer bool
+	Question        string
+	Options         []string
+	MultiAnswer     bool
+	SendAt          string            `json:"send_at"`
+	Async           bool              `json:"async"`
+	ClientReference string            `json:"client_reference"`
+	Metadata        map[string]string `json:"metadata"`
 }
 
 type RequestSendPollVote struct {
@@ -130,8 +148,10 @@ type RequestDelete struct {
 }
 
 type RequestForward struct {
-	MessageID string `json:"message_id"`
-	ToChatJID string `json:"to_chat_jid"`
+	MessageID       string            `json:"message_id"`
+	ToChatJID       string            `json:"to_chat_jid"`
+	ClientReference string            `json:"client_reference"`
+	Metadata        map[string]string `json:"metadata"`
 }
 
 type RequestDownloadMedia struct {
@@ -148,13 +168,16 @@ type RequestContactSync struct {
 }
 
 type RequestReply struct {
-	ChatJID       string
-	Message       string
-	MessageID     string
-	Text          string
-	QuotedMessage *waE2E.Message
-	TypingSimulation   *bool `json:"typing_simulation"`
-	PresenceSimulation *bool `json:"presence_simulation"`
+	ChatJID            string            `json:"chat_jid"`
+	Message            string            `json:"message"`
+	MessageID          string            `json:"message_id"`
+	Text               string            `json:"text"`
+	Participant        string            `json:"participant"` // sender of the quoted message, loaded from the message store when empty
+	QuotedMessage      *waE2E.Message    `json:"quoted_message"`
+	TypingSimulation   *bool             `json:"typing_simulation"`
+	PresenceSimulation *bool             `json:"presence_simulation"`
+	ClientReference    string            `json:"client_reference"`
+	Metadata           map[string]string `json:"metadata"`
 }
 
 type RequestCreateGroup struct {
@@ -367,6 +390,15 @@ type ResponseGetProxy struct {
 	Active   bool   `json:"active"`
 }
 
+type RequestSetMediaAutoDownload struct {
+	Enabled bool `json:"enabled"`
+}
+
+type ResponseGetMediaAutoDownload struct {
+	Enabled        bool   `json:"enabled"`
+	StorageBackend string `json:"storage_backend"`
+}
+
 // ============================================================================
 // Poll Vote Decryption APIs
 // ============================================================================
@@ -447,11 +479,13 @@ type RequestForceActiveReceipts struct {
 // Link Preview Message APIs
 // ============================================================================
 type RequestSendLinkPreview struct {
-	Text        string `json:"text"`
-	URL         string `json:"url"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Thumbnail   string `json:"thumbnail"` // base64 JPEG
+	Text            string            `json:"text"`
+	URL             string            `json:"url"`
+	Title           string            `json:"title"`
+	Description     string            `json:"description"`
+	Thumbnail       string            `json:"thumbnail"` // base64 JPEG
+	ClientReference string            `json:"client_reference"`
+	Metadata        map[string]string `json:"metadata"`
 }
 
 // ============================================================================
@@ -0,0 +1,171 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// getDeviceContext extracts device context from auth middleware
+func getDeviceContext(c *fiber.Ctx) (deviceID string, jid string) {
+	deviceID = c.Locals("device_id").(string)
+	jidVal := c.Locals("device_jid")
+	if jidVal != nil {
+		jid = jidVal.(string)
+	}
+	return
+}
+
+type createUploadRequest struct {
+	MediaType    string `json:"media_type"`
+	UploadLength int64  `json:"upload_length"`
+	FileName     string `json:"filename"`
+}
+
+// setOffsetHeaders exposes the upload progress the way tus clients expect it
+func setOffsetHeaders(c *fiber.Ctx, upload *pkgWhatsApp.MediaUpload) {
+	c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Set(fiber.HeaderCacheControl, "no-store")
+}
+
+func uploadErrorResponse(c *fiber.Ctx, operation string, id string, err error) error {
+	switch {
+	case errors.Is(err, pkgWhatsApp.ErrMediaUploadNotFound):
+		log.DeviceOpCtx(c, operation).WithField("upload_id", id).Warn("Upload not found")
+		return router.ResponseNotFound(c, "Upload not found")
+	case errors.Is(err, pkgWhatsApp.ErrMediaUploadOffsetMismatch), errors.Is(err, pkgWhatsApp.ErrMediaUploadComplete):
+		log.DeviceOpCtx(c, operation).WithField("upload_id", id).Warn(err.Error())
+		return router.ResponseConflict(c, err.Error())
+	case errors.Is(err, pkgWhatsApp.ErrMediaUploadRejected):
+		log.DeviceOpCtx(c, operation).WithField("upload_id", id).WithError(err).Warn("Upload rejected")
+		return router.ResponseBadRequest(c, err.Error())
+	}
+	log.DeviceOpCtx(c, operation).WithField("upload_id", id).WithError(err).Error("Upload operation failed")
+	return router.ResponseInternalError(c, err.Error())
+}
+
+// CreateUpload starts a resumable upload; chunks are then sent with PATCH /uploads/{upload_id}
+func CreateUpload(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+
+	// upload_length may come from the Upload-Length header instead of the body
+	var req createUploadRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			log.DeviceOpCtx(c, "CreateUpload").Warn("Failed to parse body request")
+			return router.ResponseBadRequest(c, "Failed parse body request")
+		}
+	}
+	if req.UploadLength == 0 {
+		if raw := c.Get("Upload-Length"); raw != "" {
+			length, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return router.ResponseBadRequest(c, "Upload-Length must be an integer")
+			}
+			req.UploadLength = length
+		}
+	}
+	req.MediaType = strings.ToLower(strings.TrimSpace(req.MediaType))
+
+	log.DeviceOpCtx(c, "CreateUpload").WithField("media_type", req.MediaType).WithField("upload_length", req.UploadLength).Info("Creating upload")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	upload, err := pkgWhatsApp.CreateMediaUpload(ctx, jid, deviceID, req.MediaType, req.UploadLength, strings.TrimSpace(req.FileName))
+	if err != nil {
+		return uploadErrorResponse(c, "CreateUpload", "", err)
+	}
+
+	log.DeviceOpCtx(c, "CreateUpload").WithField("upload_id", upload.ID).Info("Upload created successfully")
+
+	c.Set(fiber.HeaderLocation, router.BaseURL+"/uploads/"+upload.ID)
+	setOffsetHeaders(c, upload)
+	return router.ResponseCreatedWithData(c, "Success create upload", upload)
+}
+
+// GetUpload returns the state of an upload; HEAD returns only the Upload-Offset and Upload-Length headers
+func GetUpload(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id := c.Params("upload_id")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	upload, err := pkgWhatsApp.GetMediaUpload(ctx, deviceID, id)
+	if err != nil {
+		return uploadErrorResponse(c, "GetUpload", id, err)
+	}
+
+	setOffsetHeaders(c, upload)
+	return router.ResponseSuccessWithData(c, "Success get upload", upload)
+}
+
+// AppendUpload writes the request body at the Upload-Offset header. Intermediate chunks return
+// 204; the last chunk returns the finished upload, which can then be sent by upload_id.
+func AppendUpload(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id := c.Params("upload_id")
+
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		log.DeviceOpCtx(c, "AppendUpload").WithField("upload_id", id).Warn("Invalid Upload-Offset")
+		return router.ResponseBadRequest(c, "Upload-Offset header is required")
+	}
+	body := c.Body()
+	if len(body) == 0 {
+		log.DeviceOpCtx(c, "AppendUpload").WithField("upload_id", id).Warn("Empty chunk")
+		return router.ResponseBadRequest(c, "request body is empty")
+	}
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	upload, err := pkgWhatsApp.AppendMediaUpload(ctx, deviceID, id, offset, bytes.NewReader(body))
+	if err != nil {
+		return uploadErrorResponse(c, "AppendUpload", id, err)
+	}
+
+	setOffsetHeaders(c, upload)
+	if upload.Status == pkgWhatsApp.MediaUploadReceiving {
+		return router.ResponseNoContent(c)
+	}
+
+	log.DeviceOpCtx(c, "AppendUpload").WithField("upload_id", id).WithField("status", upload.Status).Info("Upload completed successfully")
+
+	return router.ResponseSuccessWithData(c, "Success complete upload", upload)
+}
+
+// DeleteUpload discards an upload and its file
+func DeleteUpload(c *fiber.Ctx) error {
+	deviceID, _ := getDeviceContext(c)
+	id := c.Params("upload_id")
+
+	ctx := c.UserContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := pkgWhatsApp.DeleteMediaUpload(ctx, deviceID, id); err != nil {
+		return uploadErrorResponse(c, "DeleteUpload", id, err)
+	}
+
+	log.DeviceOpCtx(c, "DeleteUpload").WithField("upload_id", id).Info("Upload deleted successfully")
+
+	return router.ResponseSuccess(c, "Success delete upload")
+}
@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/env"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// circuitBreaker stops a failing webhook from eating worker time shared by every device.
+// After threshold consecutive failed attempts the circuit opens: queued events move to the
+// dead-letter list and new ones go straight there. In half-open mode a probe is sent after the
+// cooldown and its success closes the circuit again; in disable mode the webhook is deactivated
+// and its events keep going to the dead-letter list until it is re-enabled through the API.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	disable   bool
+}
+
+func loadCircuitBreaker() circuitBreaker {
+	// WEBHOOK_CIRCUIT_THRESHOLD: default 10, 0 turns the breaker off
+	threshold := env.GetEnvIntOrDefault("WEBHOOK_CIRCUIT_THRESHOLD", 10)
+	if threshold < 0 {
+		threshold = 0
+	}
+
+	// WEBHOOK_CIRCUIT_COOLDOWN: default 5m before a half-open probe is sent
+	cooldown := env.GetEnvDurationOrDefault("WEBHOOK_CIRCUIT_COOLDOWN", 5*time.Minute)
+	if cooldown <= 0 {
+		cooldown = 5 * time.Minute
+	}
+
+	// WEBHOOK_CIRCUIT_MODE: half_open (default) or disable
+	mode := strings.ToLower(env.GetEnvStringOrDefault("WEBHOOK_CIRCUIT_MODE", "half_open"))
+
+	return circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		disable:   mode == "disable",
+	}
+}
+
+func (cb circuitBreaker) enabled() bool {
+	return cb.threshold > 0
+}
+
+// shouldOpen reports whether a failed attempt leaves the circuit open: a failed probe reopens a
+// half-open circuit and a closed one opens once the streak reaches the threshold
+func (cb circuitBreaker) shouldOpen(state CircuitState, failures int) bool {
+	switch state {
+	case CircuitHalfOpen:
+		return true
+	case CircuitClosed:
+		return failures >= cb.threshold
+	}
+	return false
+}
+
+// shouldAlert reports whether opening a circuit that was in the previous state raises an alert.
+// Only the closed to open transition does; a failed probe reopening it every cooldown does not.
+func shouldAlert(previous CircuitState) bool {
+	return previous == CircuitClosed
+}
+
+// recordOutcome updates the failure streak after an attempt and opens the circuit when needed
+func (e *Engine) recordOutcome(ctx context.Context, task *queuedDelivery, deliveryErr error) {
+	if !e.circuit.enabled() {
+		return
+	}
+
+	if deliveryErr == nil {
+		previous, err := e.store.RecordSuccess(ctx, task.WebhookID)
+		if err != nil {
+			log.SysErr("wh-circuit", err)
+			return
+		}
+		if previous == CircuitHalfOpen || previous == CircuitOpen {
+			log.Evt("wh", "circuit-closed", task.DeviceID, task.URL)
+		}
+		return
+	}
+
+	failures, state, err := e.store.RecordFailure(ctx, task.WebhookID)
+	if err != nil {
+		log.SysErr("wh-circuit", err)
+		return
+	}
+	if e.circuit.shouldOpen(state, failures) {
+		e.openCircuit(ctx, task, deliveryErr)
+	}
+}
+
+func (e *Engine) openCircuit(ctx context.Context, task *queuedDelivery, deliveryErr error) {
+	wh, previous, err := e.store.OpenCircuit(ctx, task.WebhookID, e.circuit.disable)
+	if err != nil {
+		log.SysErr("wh-circuit", err)
+		return
+	}
+	if wh == nil {
+		return
+	}
+
+	deadLettered, err := e.store.DeadLetterPending(ctx, task.WebhookID)
+	if err != nil {
+		log.SysErr("wh-dead-letter", err)
+	}
+	if !shouldAlert(previous) {
+		log.Evt("wh", "circuit-reopen", wh.DeviceID, wh.URL)
+		return
+	}
+
+	reason := "circuit opened"
+	if e.circuit.disable {
+		reason = "webhook disabled"
+	}
+	alert := WebhookAlert{
+		WebhookID:           wh.ID,
+		DeviceID:            wh.DeviceID,
+		URL:                 wh.URL,
+		Reason:              reason,
+		ConsecutiveFailures: wh.ConsecutiveFailures,
+		DeadLettered:        deadLettered,
+		LastError:           deliveryErr.Error(),
+	}
+	if err := e.store.CreateAlert(ctx, alert); err != nil {
+		log.SysErr("wh-alert", err)
+	}
+	log.EvtErr("wh", "circuit-open", wh.DeviceID, deliveryErr)
+}
+
+// circuitMonitor sends probes to open circuits whose cooldown has elapsed
+func (e *Engine) circuitMonitor() {
+	defer e.wg.Done()
+	interval := e.circuit.cooldown / 4
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			e.sendProbes()
+		}
+	}
+}
+
+func (e *Engine) sendProbes() {
+	webhooks, err := e.store.HalfOpenDueCircuits(e.ctx, e.circuit.cooldown)
+	if err != nil {
+		if e.ctx.Err() == nil {
+			log.SysErr("wh-probe", err)
+		}
+		return
+	}
+	for _, wh := range webhooks {
+		payload, err := json.Marshal(WebhookEvent{
+			EventType: EventWebhookProbe,
+			DeviceID:  wh.DeviceID,
+			Timestamp: time.Now(),
+			Data: map[string]interface{}{
+				"webhook_id":           wh.ID,
+				"consecutive_failures": wh.ConsecutiveFailures,
+			},
+		})
+		if err != nil {
+			log.SysErr("wh-marshal", err)
+			continue
+		}
+		if _, err := e.store.EnqueueDelivery(e.ctx, wh.ID, EventWebhookProbe, payload); err != nil {
+			log.SysErr("wh-probe", err)
+			continue
+		}
+		log.Evt("wh", "circuit-probe", wh.DeviceID, wh.URL)
+	}
+	if len(webhooks) > 0 {
+		e.signal()
+	}
+}
+
+// RequeueDeadLetters sends dead-lettered deliveries again and closes the webhook's circuit
+func (e *Engine) RequeueDeadLetters(ctx context.Context, deviceID string, webhookID int64, deliveryIDs []int64) (int64, error) {
+	count, err := e.store.RequeueDeadLetters(ctx, deviceID, webhookID, deliveryIDs)
+	if err == nil && count > 0 {
+		e.signal()
+	}
+	return count, err
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerShouldOpen(t *testing.T) {
+	cb := circuitBreaker{threshold: 3, cooldown: time.Minute}
+	cases := []struct {
+		state    CircuitState
+		failures int
+		want     bool
+	}{
+		{CircuitClosed, 1, false},
+		{CircuitClosed, 2, false},
+		{CircuitClosed, 3, true},
+		{CircuitClosed, 7, true},
+		{CircuitHalfOpen, 1, true},
+		{CircuitHalfOpen, 12, true},
+		{CircuitOpen, 3, false},
+		{CircuitOpen, 50, false},
+	}
+	for _, c := range cases {
+		if got := cb.shouldOpen(c.state, c.failures); got != c.want {
+			t.Errorf("shouldOpen(%s, %d) = %v, want %v", c.state, c.failures, got, c.want)
+		}
+	}
+}
+
+func TestCircuitBreakerAlertsOnlyWhenLeavingClosed(t *testing.T) {
+	cases := map[CircuitState]bool{
+		CircuitClosed:   true,
+		CircuitHalfOpen: false,
+		CircuitOpen:     false,
+		"":              false,
+	}
+	for previous, want := range cases {
+		if got := shouldAlert(previous); got != want {
+			t.Errorf("shouldAlert(%q) = %v, want %v", previous, got, want)
+		}
+	}
+}
+
+func TestLoadCircuitBreaker(t *testing.T) {
+	t.Setenv("WEBHOOK_CIRCUIT_THRESHOLD", "-4")
+	t.Setenv("WEBHOOK_CIRCUIT_COOLDOWN", "0s")
+	t.Setenv("WEBHOOK_CIRCUIT_MODE", "DISABLE")
+
+	cb := loadCircuitBreaker()
+	if cb.enabled() {
+		t.Errorf("negative threshold should turn the breaker off, got %d", cb.threshold)
+	}
+	if cb.cooldown != 5*time.Minute {
+		t.Errorf("cooldown = %s, want the 5m default", cb.cooldown)
+	}
+	if !cb.disable {
+		t.Error("mode DISABLE was not recognised")
+	}
+
+	t.Setenv("WEBHOOK_CIRCUIT_THRESHOLD", "2")
+	t.Setenv("WEBHOOK_CIRCUIT_MODE", "half_open")
+	cb = loadCircuitBreaker()
+	if !cb.enabled() || cb.threshold != 2 || cb.disable {
+		t.Errorf("unexpected breaker %+v", cb)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,19 +24,36 @@ import (
 type Engine struct {
 	store        *Store
 	httpClient   *http.Client
-	queue        chan *deliveryTask
+	wake         chan struct{}
+	queue        chan pendingDispatch
 	workers      int
 	retryLimit   int
 	maxPerDevice int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	circuit      circuitBreaker
 	enabled      bool
 	wg           sync.WaitGroup
 	ctx          context.Context
 	cancel       context.CancelFunc
 }
 
-type deliveryTask struct {
-	webhook WebhookConfig
-	event   WebhookEvent
+// deliveryLease is how long a claimed delivery stays invisible to other workers;
+// rows of a crashed worker become claimable again once it runs out
+const deliveryLease = 60 * time.Second
+
+// Dispatched events are stored in batches of up to this many, at least every flush interval
+const (
+	dispatchBatchSize     = 100
+	dispatchFlushInterval = 250 * time.Millisecond
+	dispatchWriteTimeout  = 30 * time.Second
+)
+
+// pendingDispatch is an event waiting for the enqueuer to store its deliveries
+type pendingDispatch struct {
+	deviceID string
+	event    WebhookEvent
 }
 
 func NewEngine(store *Store) *Engine {
@@ -57,6 +75,30 @@ func NewEngine(store *Store) *Engine {
 		maxPerDevice = 5
 	}
 
+	// WEBHOOK_RETRY_BACKOFF: default 2s, doubled after every failed attempt
+	backoff := env.GetEnvDurationOrDefault("WEBHOOK_RETRY_BACKOFF", 2*time.Second)
+	if backoff <= 0 {
+		backoff = 2 * time.Second
+	}
+
+	// WEBHOOK_RETRY_MAX_BACKOFF: default 10m
+	maxBackoff := env.GetEnvDurationOrDefault("WEBHOOK_RETRY_MAX_BACKOFF", 10*time.Minute)
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+
+	// WEBHOOK_POLL_INTERVAL: default 1s, how often idle workers look for due retries
+	pollInterval := env.GetEnvDurationOrDefault("WEBHOOK_POLL_INTERVAL", time.Second)
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	// WEBHOOK_QUEUE_SIZE: default 10000 events waiting to be stored
+	queueSize := env.GetEnvIntOrDefault("WEBHOOK_QUEUE_SIZE", 10000)
+	if queueSize < 100 {
+		queueSize = 100
+	}
+
 	// WEBHOOKS_ENABLED: default true
 	enabled := env.GetEnvBoolOrDefault("WEBHOOKS_ENABLED", true)
 
@@ -75,20 +117,31 @@ func NewEngine(store *Store) *Engine {
 	engine := &Engine{
 		store:        store,
 		httpClient:   &http.Client{Timeout: 10 * time.Second, Transport: transport},
-		queue:        make(chan *deliveryTask, 1000),
+		wake:         make(chan struct{}, 1),
+		queue:        make(chan pendingDispatch, queueSize),
 		workers:      workers,
 		retryLimit:   retryLimit,
 		maxPerDevice: maxPerDevice,
+		backoff:      backoff,
+		maxBackoff:   maxBackoff,
+		pollInterval: pollInterval,
+		circuit:      loadCircuitBreaker(),
 		enabled:      enabled,
 		ctx:          ctx,
 		cancel:       cancel,
 	}
 
 	if enabled {
+		engine.wg.Add(1)
+		go engine.enqueuer()
 		for i := 0; i < workers; i++ {
 			engine.wg.Add(1)
 			go engine.worker()
 		}
+		if engine.circuit.enabled() && !engine.circuit.disable {
+			engine.wg.Add(1)
+			go engine.circuitMonitor()
+		}
 	}
 
 	return engine
@@ -98,39 +151,163 @@ func (e *Engine) Store() *Store {
 	return e.store
 }
 
+// Shutdown stores the events still waiting for the enqueuer, stops claiming new deliveries and
+// waits for in-flight ones to finish; everything still queued stays in the database for the next start
 func (e *Engine) Shutdown() {
 	e.cancel()
-	close(e.queue)
 	e.wg.Wait()
 }
 
+// Dispatch hands the event to the enqueuer, which persists one delivery per matching webhook and
+// wakes the workers. It runs on the event handler, so it never waits on the database: when the
+// queue is full the event is dropped with a warning.
 func (e *Engine) Dispatch(ctx context.Context, deviceID string, event WebhookEvent) {
 	if !e.enabled {
 		return
 	}
+	select {
+	case e.queue <- pendingDispatch{deviceID: deviceID, event: event}:
+	case <-ctx.Done():
+	default:
+		log.Evt("wh", "queue-full", deviceID, string(event.EventType), "dropping event")
+	}
+}
 
-	webhooks, err := e.store.GetActiveWebhooks(ctx, deviceID)
-	if err != nil {
-		log.SysErr("wh-fetch", err)
+// enqueuer collects dispatched events until a batch is full or the flush interval passes.
+// On shutdown it stores what is left in the queue before returning.
+func (e *Engine) enqueuer() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(dispatchFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]pendingDispatch, 0, dispatchBatchSize)
+	for {
+		select {
+		case p := <-e.queue:
+			batch = append(batch, p)
+			if len(batch) < dispatchBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-e.ctx.Done():
+			for {
+				select {
+				case p := <-e.queue:
+					batch = append(batch, p)
+					continue
+				default:
+				}
+				break
+			}
+			if len(batch) > 0 {
+				e.flushDispatches(batch)
+			}
+			return
+		}
+		e.flushDispatches(batch)
+		batch = batch[:0]
+	}
+}
+
+// flushDispatches stores the deliveries of a batch of events in one transaction
+func (e *Engine) flushDispatches(batch []pendingDispatch) {
+	ctx, cancel := context.WithTimeout(context.Background(), dispatchWriteTimeout)
+	defer cancel()
+
+	var deliveries []newDelivery
+	counts := make([]int, len(batch))
+	for i, p := range batch {
+		webhooks, err := e.store.GetActiveWebhooks(ctx, p.deviceID)
+		if err != nil {
+			log.SysErr("wh-fetch", err)
+			continue
+		}
+		for _, d := range e.eventDeliveries(webhooks, p.event) {
+			deliveries = append(deliveries, d)
+			counts[i]++
+		}
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+
+	if err := e.store.EnqueueDeliveries(ctx, deliveries); err != nil {
+		for _, p := range batch {
+			log.Evt("wh", "enqueue-failed", p.deviceID, string(p.event.EventType), err.Error())
+		}
 		return
 	}
+	for i, p := range batch {
+		// Log dispatch with count
+		log.WH(string(p.event.EventType), p.deviceID, counts[i])
+	}
+	e.signal()
+}
 
-	dispatched := 0
+// eventDeliveries builds the delivery of the event for every webhook subscribed to it
+func (e *Engine) eventDeliveries(webhooks []WebhookConfig, event WebhookEvent) []newDelivery {
+	var deliveries []newDelivery
+	var payload, rawPayload []byte
+	var err error
 	for _, webhook := range webhooks {
-		if e.shouldDispatch(webhook, event.EventType) {
-			select {
-			case e.queue <- &deliveryTask{webhook: webhook, event: event}:
-				dispatched++
-			default:
-				log.Evt("wh", "queue-full", deviceID, string(event.EventType))
+		if !e.shouldDispatch(webhook, event.EventType) {
+			continue
+		}
+
+		var body []byte
+		if webhook.IncludeRaw && len(event.Raw) > 0 {
+			if rawPayload == nil {
+				if rawPayload, err = marshalEvent(event, true); err != nil {
+					log.SysErr("wh-marshal", err)
+					return nil
+				}
+			}
+			body = rawPayload
+		} else {
+			if payload == nil {
+				if payload, err = marshalEvent(event, false); err != nil {
+					log.SysErr("wh-marshal", err)
+					return nil
+				}
 			}
+			body = payload
 		}
+		deliveries = append(deliveries, newDelivery{webhookID: webhook.ID, eventType: event.EventType, payload: body})
 	}
+	return deliveries
+}
 
-	// Log dispatch with count
-	if dispatched > 0 {
-		log.WH(string(event.EventType), deviceID, dispatched)
+// Replay queues a copy of a stored delivery, whatever its outcome was
+func (e *Engine) Replay(ctx context.Context, deviceID string, webhookID int64, deliveryID int64) (int64, error) {
+	id, err := e.store.ReplayDelivery(ctx, deviceID, webhookID, deliveryID)
+	if err == nil {
+		e.signal()
 	}
+	return id, err
+}
+
+// ReplayFailed queues copies of every failed delivery of a webhook created within [from, to]
+func (e *Engine) ReplayFailed(ctx context.Context, deviceID string, webhookID int64, from, to time.Time) (int64, error) {
+	count, err := e.store.ReplayFailedDeliveries(ctx, deviceID, webhookID, from, to)
+	if err == nil && count > 0 {
+		e.signal()
+	}
+	return count, err
+}
+
+func marshalEvent(event WebhookEvent, includeRaw bool) ([]byte, error) {
+	if includeRaw {
+		data := make(map[string]interface{}, len(event.Data)+1)
+		for k, v := range event.Data {
+			data[k] = v
+		}
+		data["raw_message"] = event.Raw
+		event.Data = data
+	}
+	return json.Marshal(event)
 }
 
 func (e *Engine) shouldDispatch(webhook WebhookConfig, eventType EventType) bool {
@@ -145,80 +322,116 @@ func (e *Engine) shouldDispatch(webhook WebhookConfig, eventType EventType) bool
 	return false
 }
 
+// signal wakes one idle worker without blocking
+func (e *Engine) signal() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
 func (e *Engine) worker() {
 	defer e.wg.Done()
+	timer := time.NewTimer(e.pollInterval)
+	defer timer.Stop()
 	for {
+		if e.ctx.Err() != nil {
+			return
+		}
+
+		task, err := e.store.ClaimDelivery(e.ctx, deliveryLease)
+		if err != nil && e.ctx.Err() == nil {
+			log.SysErr("wh-claim", err)
+		}
+		if task != nil {
+			// More rows may be due, let another worker look while this one delivers
+			e.signal()
+			e.deliver(task)
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(e.pollInterval)
 		select {
 		case <-e.ctx.Done():
 			return
-		case task, ok := <-e.queue:
-			if !ok {
-				return
-			}
-			e.deliver(task)
+		case <-e.wake:
+		case <-timer.C:
 		}
 	}
 }
 
-func (e *Engine) deliver(task *deliveryTask) {
-	if err := e.validateURL(task.webhook.URL); err != nil {
-		log.WHACK(string(task.event.EventType), task.event.DeviceID, task.webhook.ID, false, 0)
-		_ = e.store.LogDelivery(e.ctx, task.webhook.ID, task.event.EventType, DeliveryFailed, 0, err.Error())
+// deliver makes a single attempt and either completes the row or schedules the next attempt.
+// It deliberately ignores e.ctx so a shutdown lets the in-flight request finish
+func (e *Engine) deliver(task *queuedDelivery) {
+	ctx := context.Background()
+
+	if err := e.validateURL(task.URL); err != nil {
+		log.WHACK(string(task.EventType), task.DeviceID, task.WebhookID, false, task.AttemptCount)
+		_ = e.store.UpdateDeliveryStatus(ctx, task.ID, DeliveryFailed, task.AttemptCount, err.Error())
 		return
 	}
 
-	payload, err := json.Marshal(task.event)
-	if err != nil {
-		log.SysErr("wh-marshal", err)
+	err := e.post(ctx, task)
+	if err == nil {
+		_ = e.store.UpdateDeliveryStatus(ctx, task.ID, DeliverySuccess, task.AttemptCount, "")
+		log.WHACK(string(task.EventType), task.DeviceID, task.WebhookID, true, task.AttemptCount)
+		e.recordOutcome(ctx, task, nil)
 		return
 	}
 
-	signature := e.generateSignature(payload, task.webhook.Secret)
-
-	var lastErr error
-	for attempt := 1; attempt <= e.retryLimit; attempt++ {
-		req, err := http.NewRequestWithContext(e.ctx, "POST", task.webhook.URL, bytes.NewReader(payload))
-		if err != nil {
-			lastErr = err
-			continue
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Webhook-Signature", signature)
-		req.Header.Set("X-Hub-Signature-256", signature)
-		req.Header.Set("X-Webhook-Event", string(task.event.EventType))
-		req.Header.Set("User-Agent", "WhatsApp-API-MultiSession/1.0")
+	log.WHACK(string(task.EventType), task.DeviceID, task.WebhookID, false, task.AttemptCount)
+	// Probes are never retried, the circuit monitor sends a new one after the next cooldown
+	if task.AttemptCount >= e.retryLimit || task.EventType == EventWebhookProbe {
+		_ = e.store.UpdateDeliveryStatus(ctx, task.ID, DeliveryFailed, task.AttemptCount, err.Error())
+	} else {
+		_ = e.store.ScheduleRetry(ctx, task.ID, err.Error(), e.retryDelay(task.AttemptCount))
+	}
+	e.recordOutcome(ctx, task, err)
+}
 
-		resp, err := e.httpClient.Do(req)
-		if err != nil {
-			lastErr = err
-			if attempt < e.retryLimit {
-				time.Sleep(time.Duration(attempt*2) * time.Second)
-			}
-			continue
-		}
+func (e *Engine) post(ctx context.Context, task *queuedDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", task.URL, bytes.NewReader(task.Payload))
+	if err != nil {
+		return err
+	}
 
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
+	signature := e.generateSignature(task.Payload, task.Secret)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Signature", signature)
+	req.Header.Set("X-Hub-Signature-256", signature)
+	req.Header.Set("X-Webhook-Event", string(task.EventType))
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(task.ID, 10))
+	req.Header.Set("User-Agent", "WhatsApp-API-MultiSession/1.0")
 
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			_ = e.store.LogDelivery(e.ctx, task.webhook.ID, task.event.EventType, DeliverySuccess, attempt, "")
-			log.WHACK(string(task.event.EventType), task.event.DeviceID, task.webhook.ID, true, attempt)
-			return
-		}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
 
-		lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
-		if attempt < e.retryLimit {
-			time.Sleep(time.Duration(attempt*2) * time.Second)
-		}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
 	}
+	return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+}
 
-	errorMsg := ""
-	if lastErr != nil {
-		errorMsg = lastErr.Error()
+// retryDelay doubles the base backoff for every failed attempt, capped at maxBackoff
+func (e *Engine) retryDelay(attempt int) time.Duration {
+	delay := e.backoff
+	for i := 1; i < attempt && delay < e.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > e.maxBackoff {
+		delay = e.maxBackoff
 	}
-	_ = e.store.LogDelivery(e.ctx, task.webhook.ID, task.event.EventType, DeliveryFailed, e.retryLimit, errorMsg)
-	log.WHACK(string(task.event.EventType), task.event.DeviceID, task.webhook.ID, false, e.retryLimit)
+	return delay
 }
 
 func (e *Engine) generateSignature(payload []byte, secret string) string {
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetryDelayDoublesUpToMax(t *testing.T) {
+	e := &Engine{backoff: 2 * time.Second, maxBackoff: time.Minute}
+	cases := map[int]time.Duration{
+		0:   2 * time.Second,
+		1:   2 * time.Second,
+		2:   4 * time.Second,
+		3:   8 * time.Second,
+		5:   32 * time.Second,
+		6:   time.Minute,
+		10:  time.Minute,
+		500: time.Minute,
+	}
+	for attempt, want := range cases {
+		if got := e.retryDelay(attempt); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestRetryDelayNeverExceedsMax(t *testing.T) {
+	e := &Engine{backoff: 3 * time.Second, maxBackoff: 10 * time.Second}
+	previous := time.Duration(0)
+	for attempt := 1; attempt <= 20; attempt++ {
+		delay := e.retryDelay(attempt)
+		if delay > e.maxBackoff {
+			t.Fatalf("retryDelay(%d) = %s exceeds the %s cap", attempt, delay, e.maxBackoff)
+		}
+		if delay < previous {
+			t.Fatalf("retryDelay(%d) = %s is shorter than the previous attempt's %s", attempt, delay, previous)
+		}
+		previous = delay
+	}
+	if previous != e.maxBackoff {
+		t.Errorf("retryDelay settled at %s, want the %s cap", previous, e.maxBackoff)
+	}
+}
+
+func TestDispatchDropsWhenQueueIsFull(t *testing.T) {
+	e := &Engine{enabled: true, queue: make(chan pendingDispatch, 1)}
+	event := WebhookEvent{EventType: EventMessageReceived, DeviceID: "device-1"}
+
+	done := make(chan struct{})
+	go func() {
+		// nothing drains the queue, so the second event must be dropped rather than wait
+		e.Dispatch(context.Background(), "device-1", event)
+		e.Dispatch(context.Background(), "device-1", event)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Dispatch blocked on a full queue")
+	}
+	if len(e.queue) != 1 {
+		t.Errorf("queue holds %d events, want 1", len(e.queue))
+	}
+}
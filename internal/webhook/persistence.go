@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"sync"
 	"time"
 
@@ -76,7 +77,7 @@ func (s *Store) invalidateActiveCache(deviceID string) {
 
 func (s *Store) GetAllWebhooks(ctx context.Context, deviceID string) ([]WebhookConfig, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, device_id, url, secret, events, active, created_at, updated_at
+		SELECT id, device_id, url, secret, events, active, include_raw, consecutive_failures, circuit_state, circuit_opened_at, created_at, updated_at
 		FROM wa_webhooks
 		WHERE device_id = $1
 	`, deviceID)
@@ -89,28 +90,34 @@ func (s *Store) GetAllWebhooks(ctx context.Context, deviceID string) ([]WebhookC
 	for rows.Next() {
 		var w WebhookConfig
 		var eventsJSON []byte
-		err := rows.Scan(&w.ID, &w.DeviceID, &w.URL, &w.Secret, &eventsJSON, &w.Active, &w.CreatedAt, &w.UpdatedAt)
+		var openedAt sql.NullTime
+		err := rows.Scan(&w.ID, &w.DeviceID, &w.URL, &w.Secret, &eventsJSON, &w.Active, &w.IncludeRaw, &w.ConsecutiveFailures, &w.CircuitState, &openedAt, &w.CreatedAt, &w.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		if err := json.Unmarshal(eventsJSON, &w.Events); err != nil {
 			return nil, err
 		}
+		if openedAt.Valid {
+			w.CircuitOpenedAt = &openedAt.Time
+		}
 		webhooks = append(webhooks, w)
 	}
 	return webhooks, rows.Err()
 }
 
+// GetActiveWebhooks lists the webhooks events are dispatched to. A webhook the circuit breaker
+// disabled stays listed so its events keep landing in the dead-letter list until it is re-enabled.
 func (s *Store) GetActiveWebhooks(ctx context.Context, deviceID string) ([]WebhookConfig, error) {
 	if cached, ok := s.getActiveCache(deviceID); ok {
 		return cached, nil
 	}
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, device_id, url, secret, events, active, created_at, updated_at
+		SELECT id, device_id, url, secret, events, active, include_raw, consecutive_failures, circuit_state, circuit_opened_at, created_at, updated_at
 		FROM wa_webhooks
-		WHERE device_id = $1 AND active = TRUE
-	`, deviceID)
+		WHERE device_id = $1 AND (active = TRUE OR circuit_state = $2)
+	`, deviceID, CircuitOpen)
 	if err != nil {
 		return nil, err
 	}
@@ -120,13 +127,17 @@ func (s *Store) GetActiveWebhooks(ctx context.Context, deviceID string) ([]Webho
 	for rows.Next() {
 		var w WebhookConfig
 		var eventsJSON []byte
-		err := rows.Scan(&w.ID, &w.DeviceID, &w.URL, &w.Secret, &eventsJSON, &w.Active, &w.CreatedAt, &w.UpdatedAt)
+		var openedAt sql.NullTime
+		err := rows.Scan(&w.ID, &w.DeviceID, &w.URL, &w.Secret, &eventsJSON, &w.Active, &w.IncludeRaw, &w.ConsecutiveFailures, &w.CircuitState, &openedAt, &w.CreatedAt, &w.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		if err := json.Unmarshal(eventsJSON, &w.Events); err != nil {
 			return nil, err
 		}
+		if openedAt.Valid {
+			w.CircuitOpenedAt = &openedAt.Time
+		}
 		webhooks = append(webhooks, w)
 	}
 	if err := rows.Err(); err != nil {
@@ -139,21 +150,25 @@ func (s *Store) GetActiveWebhooks(ctx context.Context, deviceID string) ([]Webho
 func (s *Store) GetWebhook(ctx context.Context, webhookID int64, deviceID string) (*WebhookConfig, error) {
 	var w WebhookConfig
 	var eventsJSON []byte
+	var openedAt sql.NullTime
 	err := s.db.QueryRowContext(ctx, `
-		SELECT id, device_id, url, secret, events, active, created_at, updated_at
+		SELECT id, device_id, url, secret, events, active, include_raw, consecutive_failures, circuit_state, circuit_opened_at, created_at, updated_at
 		FROM wa_webhooks
 		WHERE id = $1 AND device_id = $2
-	`, webhookID, deviceID).Scan(&w.ID, &w.DeviceID, &w.URL, &w.Secret, &eventsJSON, &w.Active, &w.CreatedAt, &w.UpdatedAt)
+	`, webhookID, deviceID).Scan(&w.ID, &w.DeviceID, &w.URL, &w.Secret, &eventsJSON, &w.Active, &w.IncludeRaw, &w.ConsecutiveFailures, &w.CircuitState, &openedAt, &w.CreatedAt, &w.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	if err := json.Unmarshal(eventsJSON, &w.Events); err != nil {
 		return nil, err
 	}
+	if openedAt.Valid {
+		w.CircuitOpenedAt = &openedAt.Time
+	}
 	return &w, nil
 }
 
-func (s *Store) CreateWebhook(ctx context.Context, deviceID, url, secret string, events []EventType) (int64, error) {
+func (s *Store) CreateWebhook(ctx context.Context, deviceID, url, secret string, events []EventType, includeRaw bool) (int64, error) {
 	eventsJSON, err := json.Marshal(events)
 	if err != nil {
 		return 0, err
@@ -161,26 +176,32 @@ func (s *Store) CreateWebhook(ctx context.Context, deviceID, url, secret string,
 
 	var id int64
 	err = s.db.QueryRowContext(ctx, `
-		INSERT INTO wa_webhooks (device_id, url, secret, events, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4::jsonb, TRUE, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO wa_webhooks (device_id, url, secret, events, active, include_raw, created_at, updated_at)
+		VALUES ($1, $2, $3, $4::jsonb, TRUE, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING id
-	`, deviceID, url, secret, string(eventsJSON)).Scan(&id)
+	`, deviceID, url, secret, string(eventsJSON), includeRaw).Scan(&id)
 	if err == nil {
 		s.invalidateActiveCache(deviceID)
 	}
 	return id, err
 }
 
-func (s *Store) UpdateWebhook(ctx context.Context, webhookID int64, deviceID, url, secret string, events []EventType, active bool) error {
+// UpdateWebhook replaces a webhook's settings. Switching it on or off, pointing it at another URL or
+// re-enabling it while its circuit is open starts a fresh failure streak with a closed circuit, so an
+// inactive webhook with an open circuit is always one the breaker disabled.
+func (s *Store) UpdateWebhook(ctx context.Context, webhookID int64, deviceID, url, secret string, events []EventType, active bool, includeRaw bool) error {
 	eventsJSON, err := json.Marshal(events)
 	if err != nil {
 		return err
 	}
 	_, err = s.db.ExecContext(ctx, `
 		UPDATE wa_webhooks
-		SET url = $1, secret = $2, events = $3::jsonb, active = $4, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $5 AND device_id = $6
-	`, url, secret, string(eventsJSON), active, webhookID, deviceID)
+		SET url = $1, secret = $2, events = $3::jsonb, active = $4, include_raw = $5, updated_at = CURRENT_TIMESTAMP,
+			consecutive_failures = CASE WHEN active <> $4 OR url <> $1 OR ($4 AND circuit_state <> $8) THEN 0 ELSE consecutive_failures END,
+			circuit_state = CASE WHEN active <> $4 OR url <> $1 OR ($4 AND circuit_state <> $8) THEN $8 ELSE circuit_state END,
+			circuit_opened_at = CASE WHEN active <> $4 OR url <> $1 OR ($4 AND circuit_state <> $8) THEN NULL ELSE circuit_opened_at END
+		WHERE id = $6 AND device_id = $7
+	`, url, secret, string(eventsJSON), active, includeRaw, webhookID, deviceID, CircuitClosed)
 	if err == nil {
 		s.invalidateActiveCache(deviceID)
 	}
@@ -205,18 +226,150 @@ func (s *Store) LogDelivery(ctx context.Context, webhookID int64, eventType Even
 	return err
 }
 
+// UpdateDeliveryStatus records the final outcome of a delivery and takes it out of the queue
 func (s *Store) UpdateDeliveryStatus(ctx context.Context, deliveryID int64, status DeliveryStatus, attemptCount int, lastError string) error {
 	_, err := s.db.ExecContext(ctx, `
 		UPDATE wa_webhook_deliveries
-		SET status = $1, attempt_count = $2, last_error = $3, updated_at = CURRENT_TIMESTAMP
+		SET status = $1, attempt_count = $2, last_error = $3, next_attempt_at = NULL, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $4
 	`, status, attemptCount, lastError, deliveryID)
 	return err
 }
 
+// EnqueueDelivery stores a delivery with its payload so it survives restarts until a worker sends it.
+// Events for a webhook whose circuit is open go straight to the dead-letter list
+func (s *Store) EnqueueDelivery(ctx context.Context, webhookID int64, eventType EventType, payload []byte) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO wa_webhook_deliveries (webhook_id, event_type, status, attempt_count, payload, next_attempt_at, created_at, updated_at)
+		SELECT w.id, $2, CASE WHEN w.circuit_state = $5 THEN $6 ELSE $3 END, 0, $4::jsonb, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM wa_webhooks w
+		WHERE w.id = $1
+		RETURNING id
+	`, webhookID, eventType, DeliveryPending, string(payload), CircuitOpen, DeliveryDeadLetter).Scan(&id)
+	return id, err
+}
+
+// newDelivery is a delivery stored by EnqueueDeliveries
+type newDelivery struct {
+	webhookID int64
+	eventType EventType
+	payload   []byte
+}
+
+// EnqueueDeliveries stores several deliveries in one transaction, like EnqueueDelivery
+func (s *Store) EnqueueDeliveries(ctx context.Context, deliveries []newDelivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO wa_webhook_deliveries (webhook_id, event_type, status, attempt_count, payload, next_attempt_at, created_at, updated_at)
+		SELECT w.id, $2, CASE WHEN w.circuit_state = $5 THEN $6 ELSE $3 END, 0, $4::jsonb, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM wa_webhooks w
+		WHERE w.id = $1
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, d := range deliveries {
+		if _, err := stmt.ExecContext(ctx, d.webhookID, d.eventType, DeliveryPending, string(d.payload), CircuitOpen, DeliveryDeadLetter); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ClaimDelivery takes the oldest due delivery of an active webhook with a closed circuit (or the
+// probe of a half-open one), counts the attempt and hides the row from other workers for the lease.
+// SKIP LOCKED lets several workers and instances poll the same table without blocking each other.
+// Returns nil when nothing is due.
+func (s *Store) ClaimDelivery(ctx context.Context, lease time.Duration) (*queuedDelivery, error) {
+	var d queuedDelivery
+	var payload []byte
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE wa_webhook_deliveries d
+		SET status = $1, attempt_count = d.attempt_count + 1,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2), updated_at = CURRENT_TIMESTAMP
+		FROM wa_webhooks w
+		WHERE w.id = d.webhook_id AND d.id = (
+			SELECT q.id
+			FROM wa_webhook_deliveries q
+			JOIN wa_webhooks qw ON qw.id = q.webhook_id
+			WHERE q.status IN ($3, $4, $1)
+				AND q.next_attempt_at <= CURRENT_TIMESTAMP
+				AND q.payload IS NOT NULL
+				AND qw.active = TRUE
+				AND (qw.circuit_state = $5 OR q.event_type = $6)
+			ORDER BY q.next_attempt_at
+			LIMIT 1
+			FOR UPDATE OF q SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, w.device_id, w.url, w.secret, d.event_type, d.payload, d.attempt_count
+	`, DeliveryProcessing, lease.Seconds(), DeliveryPending, DeliveryRetrying, CircuitClosed, EventWebhookProbe).Scan(
+		&d.ID, &d.WebhookID, &d.DeviceID, &d.URL, &d.Secret, &d.EventType, &payload, &d.AttemptCount,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	return &d, nil
+}
+
+// ScheduleRetry puts a failed attempt back in the queue once the backoff has elapsed
+func (s *Store) ScheduleRetry(ctx context.Context, deliveryID int64, lastError string, delay time.Duration) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE wa_webhook_deliveries
+		SET status = $1, last_error = $2, next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $3), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`, DeliveryRetrying, lastError, delay.Seconds(), deliveryID)
+	return err
+}
+
+// ReplayDelivery queues a fresh copy of a stored delivery of the device's webhook
+func (s *Store) ReplayDelivery(ctx context.Context, deviceID string, webhookID int64, deliveryID int64) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO wa_webhook_deliveries (webhook_id, event_type, status, attempt_count, payload, next_attempt_at, created_at, updated_at)
+		SELECT d.webhook_id, d.event_type, $1, 0, d.payload, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM wa_webhook_deliveries d
+		JOIN wa_webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $2 AND d.webhook_id = $3 AND w.device_id = $4 AND d.payload IS NOT NULL
+		RETURNING id
+	`, DeliveryPending, deliveryID, webhookID, deviceID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrDeliveryNotFound
+	}
+	return id, err
+}
+
+// ReplayFailedDeliveries queues fresh copies of every failed delivery created within [from, to]
+func (s *Store) ReplayFailedDeliveries(ctx context.Context, deviceID string, webhookID int64, from, to time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO wa_webhook_deliveries (webhook_id, event_type, status, attempt_count, payload, next_attempt_at, created_at, updated_at)
+		SELECT d.webhook_id, d.event_type, $1, 0, d.payload, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM wa_webhook_deliveries d
+		JOIN wa_webhooks w ON w.id = d.webhook_id
+		WHERE d.webhook_id = $2 AND w.device_id = $3 AND d.status = $4
+			AND d.created_at >= $5 AND d.created_at <= $6 AND d.payload IS NOT NULL
+		ORDER BY d.created_at
+	`, DeliveryPending, webhookID, deviceID, DeliveryFailed, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (s *Store) GetDeliveryLogs(ctx context.Context, webhookID int64, limit int) ([]DeliveryLog, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, webhook_id, event_type, status, attempt_count, last_error, created_at, updated_at
+		SELECT id, webhook_id, event_type, status, attempt_count, last_error, next_attempt_at, created_at, updated_at
 		FROM wa_webhook_deliveries
 		WHERE webhook_id = $1
 		ORDER BY created_at DESC
@@ -231,27 +384,252 @@ func (s *Store) GetDeliveryLogs(ctx context.Context, webhookID int64, limit int)
 	for rows.Next() {
 		var log DeliveryLog
 		var lastError sql.NullString
-		err := rows.Scan(&log.ID, &log.WebhookID, &log.EventType, &log.Status, &log.AttemptCount, &lastError, &log.CreatedAt, &log.UpdatedAt)
+		var nextAttemptAt sql.NullTime
+		err := rows.Scan(&log.ID, &log.WebhookID, &log.EventType, &log.Status, &log.AttemptCount, &lastError, &nextAttemptAt, &log.CreatedAt, &log.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		if lastError.Valid {
 			log.LastError = lastError.String
 		}
+		if nextAttemptAt.Valid {
+			log.NextAttemptAt = &nextAttemptAt.Time
+		}
 		logs = append(logs, log)
 	}
 	return logs, rows.Err()
 }
 
-// CleanupOldDeliveries removes webhook delivery logs older than the given retention
-// period to prevent unbounded table growth (#5).
+// CleanupOldDeliveries removes finished webhook deliveries (success or failed) older than the given
+// retention period to prevent unbounded table growth (#5). Queued deliveries are never removed, and
+// dead letters have their own retention, see CleanupDeadLetters.
 func (s *Store) CleanupOldDeliveries(ctx context.Context, retention time.Duration) (int64, error) {
 	cutoff := time.Now().Add(-retention)
 	result, err := s.db.ExecContext(ctx, `
-		DELETE FROM wa_webhook_deliveries WHERE created_at < $1
-	`, cutoff)
+		DELETE FROM wa_webhook_deliveries WHERE status IN ($1, $2) AND created_at < $3
+	`, DeliverySuccess, DeliveryFailed, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CleanupDeadLetters removes dead letters parked for longer than the given retention period.
+// Requeued dead letters are queued deliveries again and are not affected.
+func (s *Store) CleanupDeadLetters(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM wa_webhook_deliveries WHERE status = $1 AND updated_at < $2
+	`, DeliveryDeadLetter, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RecordSuccess resets the failure streak of a webhook and closes its circuit.
+// A healthy webhook matches nothing, so the row is neither written nor locked; the previous state is
+// only read for a row that changed, from the statement snapshot taken before the update.
+// It returns the previous circuit state, or an empty state when nothing had to change.
+func (s *Store) RecordSuccess(ctx context.Context, webhookID int64) (CircuitState, error) {
+	var previous CircuitState
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE wa_webhooks
+		SET consecutive_failures = 0, circuit_state = $2, circuit_opened_at = NULL
+		WHERE id = $1 AND (consecutive_failures <> 0 OR circuit_state <> $2)
+		RETURNING (SELECT prev.circuit_state FROM wa_webhooks prev WHERE prev.id = $1)
+	`, webhookID, CircuitClosed).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return previous, err
+}
+
+// RecordFailure extends the failure streak of a webhook and returns it with the current circuit state
+func (s *Store) RecordFailure(ctx context.Context, webhookID int64) (int, CircuitState, error) {
+	var failures int
+	var state CircuitState
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE wa_webhooks SET consecutive_failures = consecutive_failures + 1
+		WHERE id = $1
+		RETURNING consecutive_failures, circuit_state
+	`, webhookID).Scan(&failures, &state)
+	return failures, state, err
+}
+
+// OpenCircuit opens the circuit of a webhook, optionally deactivating it, and returns the state it
+// left so only the worker that moved it away from closed raises the alert. The state is empty when
+// the circuit was already open.
+func (s *Store) OpenCircuit(ctx context.Context, webhookID int64, deactivate bool) (*WebhookConfig, CircuitState, error) {
+	var w WebhookConfig
+	var previous CircuitState
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE wa_webhooks
+		SET circuit_state = $2, circuit_opened_at = CURRENT_TIMESTAMP,
+			active = CASE WHEN $3 THEN FALSE ELSE active END, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND circuit_state <> $2
+		RETURNING id, device_id, url, consecutive_failures,
+			(SELECT prev.circuit_state FROM wa_webhooks prev WHERE prev.id = $1)
+	`, webhookID, CircuitOpen, deactivate).Scan(&w.ID, &w.DeviceID, &w.URL, &w.ConsecutiveFailures, &previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	s.invalidateActiveCache(w.DeviceID)
+	return &w, previous, nil
+}
+
+// HalfOpenDueCircuits moves open circuits of active webhooks whose cooldown has passed to half-open
+// and returns them so the engine can send a probe
+func (s *Store) HalfOpenDueCircuits(ctx context.Context, cooldown time.Duration) ([]WebhookConfig, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE wa_webhooks
+		SET circuit_state = $1
+		WHERE circuit_state = $2 AND active = TRUE
+			AND circuit_opened_at <= CURRENT_TIMESTAMP - make_interval(secs => $3)
+		RETURNING id, device_id, url, consecutive_failures
+	`, CircuitHalfOpen, CircuitOpen, cooldown.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []WebhookConfig
+	for rows.Next() {
+		var w WebhookConfig
+		if err := rows.Scan(&w.ID, &w.DeviceID, &w.URL, &w.ConsecutiveFailures); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeadLetterPending parks every queued delivery of a webhook in the dead-letter list
+func (s *Store) DeadLetterPending(ctx context.Context, webhookID int64) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE wa_webhook_deliveries
+		SET status = $1, next_attempt_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE webhook_id = $2 AND status IN ($3, $4) AND event_type <> $5
+	`, DeliveryDeadLetter, webhookID, DeliveryPending, DeliveryRetrying, EventWebhookProbe)
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
+
+// GetDeadLetters lists dead-lettered deliveries of a webhook, newest first, with their payloads
+func (s *Store) GetDeadLetters(ctx context.Context, webhookID int64, limit int) ([]DeliveryLog, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, webhook_id, event_type, status, attempt_count, last_error, payload, created_at, updated_at
+		FROM wa_webhook_deliveries
+		WHERE webhook_id = $1 AND status = $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, webhookID, DeliveryDeadLetter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []DeliveryLog
+	for rows.Next() {
+		var log DeliveryLog
+		var lastError sql.NullString
+		var payload []byte
+		err := rows.Scan(&log.ID, &log.WebhookID, &log.EventType, &log.Status, &log.AttemptCount, &lastError, &payload, &log.CreatedAt, &log.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if lastError.Valid {
+			log.LastError = lastError.String
+		}
+		log.Payload = payload
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
+
+// RequeueDeadLetters puts dead-lettered deliveries of the device's webhook back in the queue and closes
+// its circuit, since requeueing means the endpoint is considered fixed. An empty ID list requeues all.
+func (s *Store) RequeueDeadLetters(ctx context.Context, deviceID string, webhookID int64, deliveryIDs []int64) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if deliveryIDs == nil {
+		deliveryIDs = []int64{}
+	}
+	ids, err := json.Marshal(deliveryIDs)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE wa_webhook_deliveries d
+		SET status = $1, attempt_count = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		FROM wa_webhooks w
+		WHERE w.id = d.webhook_id AND d.webhook_id = $2 AND w.device_id = $3 AND d.status = $4
+			AND ($5::jsonb = '[]'::jsonb OR d.id IN (SELECT jsonb_array_elements_text($5::jsonb)::bigint))
+	`, DeliveryPending, webhookID, deviceID, DeliveryDeadLetter, string(ids))
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE wa_webhooks
+		SET consecutive_failures = 0, circuit_state = $1, circuit_opened_at = NULL
+		WHERE id = $2 AND device_id = $3
+	`, CircuitClosed, webhookID, deviceID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	s.invalidateActiveCache(deviceID)
+	return count, nil
+}
+
+// CreateAlert records an admin-visible alert about a webhook circuit
+func (s *Store) CreateAlert(ctx context.Context, alert WebhookAlert) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO wa_webhook_alerts (webhook_id, device_id, url, reason, consecutive_failures, dead_lettered, last_error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+	`, alert.WebhookID, alert.DeviceID, alert.URL, alert.Reason, alert.ConsecutiveFailures, alert.DeadLettered, alert.LastError)
+	return err
+}
+
+// GetAlerts lists the most recent webhook alerts across all devices
+func (s *Store) GetAlerts(ctx context.Context, limit int) ([]WebhookAlert, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, webhook_id, device_id, url, reason, consecutive_failures, dead_lettered, last_error, created_at
+		FROM wa_webhook_alerts
+		ORDER BY created_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []WebhookAlert
+	for rows.Next() {
+		var a WebhookAlert
+		var lastError sql.NullString
+		if err := rows.Scan(&a.ID, &a.WebhookID, &a.DeviceID, &a.URL, &a.Reason, &a.ConsecutiveFailures, &a.DeadLettered, &lastError, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastError.Valid {
+			a.LastError = lastError.String
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// The persistence integration tests run against a real Postgres server:
+//
+//	WEBHOOK_TEST_DATABASE_URL=postgres://postgres@localhost:5432/postgres?sslmode=disable \
+//	go test ./internal/webhook -run Cleanup
+//
+// Each test works in a schema of its own that is dropped afterwards.
+// They are skipped when WEBHOOK_TEST_DATABASE_URL is not set.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dsn := os.Getenv("WEBHOOK_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("WEBHOOK_TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// one connection, so search_path applies to every statement
+	db.SetMaxOpenConns(1)
+	schema := fmt.Sprintf("webhook_test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		db.Close()
+	})
+	for _, stmt := range []string{
+		`CREATE SCHEMA ` + schema,
+		`SET search_path TO ` + schema,
+		`CREATE TABLE wa_webhooks (
+			id SERIAL PRIMARY KEY,
+			device_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT,
+			active BOOLEAN DEFAULT TRUE,
+			circuit_state TEXT NOT NULL DEFAULT 'closed'
+		)`,
+		`CREATE TABLE wa_webhook_deliveries (
+			id BIGSERIAL PRIMARY KEY,
+			webhook_id INTEGER NOT NULL REFERENCES wa_webhooks(id) ON DELETE CASCADE,
+			event_type TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempt_count INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			payload JSONB,
+			next_attempt_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`INSERT INTO wa_webhooks (device_id, url) VALUES ('device-1', 'https://example.com/hook')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("prepare schema: %v", err)
+		}
+	}
+	return &Store{db: db, activeCache: make(map[string]activeCacheEntry)}
+}
+
+// insertDelivery stores a delivery of webhook 1 created and last updated age ago
+func insertDelivery(t *testing.T, s *Store, status DeliveryStatus, age time.Duration) {
+	t.Helper()
+	at := time.Now().Add(-age)
+	_, err := s.db.Exec(`
+		INSERT INTO wa_webhook_deliveries (webhook_id, event_type, status, payload, next_attempt_at, created_at, updated_at)
+		VALUES (1, $1, $2, '{}', $3, $3, $3)
+	`, EventMessageReceived, status, at)
+	if err != nil {
+		t.Fatalf("insert %s delivery: %v", status, err)
+	}
+}
+
+func deliveryStatuses(t *testing.T, s *Store) map[DeliveryStatus]int {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM wa_webhook_deliveries GROUP BY status`)
+	if err != nil {
+		t.Fatalf("count deliveries: %v", err)
+	}
+	defer rows.Close()
+	counts := make(map[DeliveryStatus]int)
+	for rows.Next() {
+		var status DeliveryStatus
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			t.Fatalf("count deliveries: %v", err)
+		}
+		counts[status] = n
+	}
+	return counts
+}
+
+func TestCleanupOldDeliveriesKeepsQueuedAndDeadLetters(t *testing.T) {
+	s := newTestStore(t)
+	old := 30 * 24 * time.Hour
+	for _, status := range []DeliveryStatus{DeliveryPending, DeliveryRetrying, DeliveryProcessing, DeliveryDeadLetter, DeliverySuccess, DeliveryFailed} {
+		insertDelivery(t, s, status, old)
+	}
+	insertDelivery(t, s, DeliverySuccess, time.Hour)
+
+	deleted, err := s.CleanupOldDeliveries(context.Background(), 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("CleanupOldDeliveries: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d deliveries, want the 2 old finished ones", deleted)
+	}
+	want := map[DeliveryStatus]int{
+		DeliveryPending:    1,
+		DeliveryRetrying:   1,
+		DeliveryProcessing: 1,
+		DeliveryDeadLetter: 1,
+		DeliverySuccess:    1,
+	}
+	got := deliveryStatuses(t, s)
+	for status, n := range want {
+		if got[status] != n {
+			t.Errorf("%d %s deliveries left, want %d", got[status], status, n)
+		}
+	}
+	if got[DeliveryFailed] != 0 {
+		t.Errorf("%d old failed deliveries left, want 0", got[DeliveryFailed])
+	}
+}
+
+func TestCleanupDeadLettersOnlyRemovesOldDeadLetters(t *testing.T) {
+	s := newTestStore(t)
+	insertDelivery(t, s, DeliveryDeadLetter, 40*24*time.Hour)
+	insertDelivery(t, s, DeliveryDeadLetter, 10*24*time.Hour)
+	insertDelivery(t, s, DeliveryPending, 40*24*time.Hour)
+
+	deleted, err := s.CleanupDeadLetters(context.Background(), 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("CleanupDeadLetters: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted %d dead letters, want 1", deleted)
+	}
+	got := deliveryStatuses(t, s)
+	if got[DeliveryDeadLetter] != 1 || got[DeliveryPending] != 1 {
+		t.Errorf("left %d dead letters and %d pending deliveries, want 1 and 1", got[DeliveryDeadLetter], got[DeliveryPending])
+	}
+}
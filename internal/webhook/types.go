@@ -1,9 +1,14 @@
 package webhook
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 )
 
+// ErrDeliveryNotFound is returned when a delivery does not exist, belongs to another webhook or has no stored payload
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
 type EventType string
 
 const (
@@ -15,6 +20,11 @@ const (
 	EventMessageUndecryptable                  EventType = "message.undecryptable"
 	EventMessageFBReceived                     EventType = "message.fb_received"
 	EventMessageAIRichResponse                 EventType = "message.ai_rich_response"
+	EventMessageScheduledSent                  EventType = "message.scheduled_sent"
+	EventMessageScheduledFailed                EventType = "message.scheduled_failed"
+	EventMessageDeliveryOverdue                EventType = "message.delivery_overdue"
+	EventMessageSendFailed                     EventType = "message.send_failed"
+	EventMessageSent                           EventType = "message.sent"
 	EventConnectionConnected                   EventType = "connection.connected"
 	EventConnectionDisconnected                EventType = "connection.disconnected"
 	EventConnectionLoggedOut                   EventType = "connection.logged_out"
@@ -103,26 +113,43 @@ const (
 	EventMediaRetry        EventType = "media.retry"
 	EventPollVoteDecrypted EventType = "poll.vote_decrypted"
 	EventStatusComment     EventType = "status.comment"
+	// EventWebhookProbe is sent to a half-open webhook to test whether it recovered
+	EventWebhookProbe EventType = "webhook.probe"
 )
 
 type DeliveryStatus string
 
 const (
-	DeliveryPending  DeliveryStatus = "pending"
-	DeliverySuccess  DeliveryStatus = "success"
-	DeliveryFailed   DeliveryStatus = "failed"
-	DeliveryRetrying DeliveryStatus = "retrying"
+	DeliveryPending    DeliveryStatus = "pending"
+	DeliveryProcessing DeliveryStatus = "processing"
+	DeliverySuccess    DeliveryStatus = "success"
+	DeliveryFailed     DeliveryStatus = "failed"
+	DeliveryRetrying   DeliveryStatus = "retrying"
+	// DeliveryDeadLetter marks events parked while the webhook's circuit was open
+	DeliveryDeadLetter DeliveryStatus = "dead_letter"
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
 )
 
 type WebhookConfig struct {
-	ID        int64
-	DeviceID  string
-	URL       string
-	Secret    string
-	Events    []EventType
-	Active    bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID                  int64
+	DeviceID            string
+	URL                 string
+	Secret              string
+	Events              []EventType
+	Active              bool
+	IncludeRaw          bool
+	ConsecutiveFailures int
+	CircuitState        CircuitState
+	CircuitOpenedAt     *time.Time
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
 }
 
 type WebhookEvent struct {
@@ -130,15 +157,45 @@ type WebhookEvent struct {
 	DeviceID  string                 `json:"device_id"`
 	Timestamp time.Time              `json:"timestamp"`
 	Data      map[string]interface{} `json:"data"`
+	// Raw is the protojson encoding of the underlying WhatsApp message, only sent to webhooks with IncludeRaw
+	Raw json.RawMessage `json:"-"`
 }
 
 type DeliveryLog struct {
+	ID            int64
+	WebhookID     int64
+	EventType     EventType
+	Status        DeliveryStatus
+	AttemptCount  int
+	LastError     string
+	NextAttemptAt *time.Time
+	// Payload is only filled when listing dead-lettered deliveries
+	Payload   json.RawMessage `json:",omitempty"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookAlert is raised for admins whenever a webhook's circuit opens
+type WebhookAlert struct {
+	ID                  int64
+	WebhookID           int64
+	DeviceID            string
+	URL                 string
+	Reason              string
+	ConsecutiveFailures int
+	DeadLettered        int64
+	LastError           string
+	CreatedAt           time.Time
+}
+
+// queuedDelivery is a delivery claimed by a worker, joined with its webhook target
+type queuedDelivery struct {
 	ID           int64
 	WebhookID    int64
+	DeviceID     string
+	URL          string
+	Secret       string
 	EventType    EventType
-	Status       DeliveryStatus
+	Payload      []byte
 	AttemptCount int
-	LastError    string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
 }
@@ -3,7 +3,9 @@ package webhooks
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -25,14 +27,16 @@ func getDeviceContext(c *fiber.Ctx) (deviceID string, jid string) {
 }
 
 type createWebhookRequest struct {
-	URL    string              `json:"url"`
-	Events []webhook.EventType `json:"events"`
+	URL        string              `json:"url"`
+	Events     []webhook.EventType `json:"events"`
+	IncludeRaw bool                `json:"include_raw"`
 }
 
 type updateWebhookRequest struct {
-	URL    string              `json:"url"`
-	Events []webhook.EventType `json:"events"`
-	Active bool                `json:"active"`
+	URL        string              `json:"url"`
+	Events     []webhook.EventType `json:"events"`
+	Active     bool                `json:"active"`
+	IncludeRaw *bool               `json:"include_raw"`
 }
 
 func ListWebhooks(c *fiber.Ctx) error {
@@ -114,7 +118,7 @@ func CreateWebhook(c *fiber.Ctx) error {
 		return router.ResponseInternalError(c, "webhook engine not initialized")
 	}
 
-	webhookID, err := engine.Store().CreateWebhook(context.Background(), deviceID, req.URL, secretStr, req.Events)
+	webhookID, err := engine.Store().CreateWebhook(context.Background(), deviceID, req.URL, secretStr, req.Events, req.IncludeRaw)
 	if err != nil {
 		log.WebhookOp(deviceID, jid, "CreateWebhook", 0).WithField("url", req.URL).WithError(err).Error("Failed to create webhook")
 		return router.ResponseInternalError(c, err.Error())
@@ -158,7 +162,12 @@ func UpdateWebhook(c *fiber.Ctx) error {
 		return router.ResponseInternalError(c, err.Error())
 	}
 
-	if err := engine.Store().UpdateWebhook(context.Background(), int64(webhookID), deviceID, req.URL, wh.Secret, req.Events, req.Active); err != nil {
+	includeRaw := wh.IncludeRaw
+	if req.IncludeRaw != nil {
+		includeRaw = *req.IncludeRaw
+	}
+
+	if err := engine.Store().UpdateWebhook(context.Background(), int64(webhookID), deviceID, req.URL, wh.Secret, req.Events, req.Active, includeRaw); err != nil {
 		log.WebhookOp(deviceID, jid, "UpdateWebhook", int64(webhookID)).WithError(err).Error("Failed to update webhook")
 		return router.ResponseInternalError(c, err.Error())
 	}
@@ -269,3 +278,192 @@ func TestWebhook(c *fiber.Ctx) error {
 
 	return router.ResponseSuccess(c, "test webhook dispatched")
 }
+
+type replayDeliveriesRequest struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+func ReplayDelivery(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+	webhookID, err := c.ParamsInt("webhook_id")
+	if err != nil {
+		log.WebhookOp(deviceID, jid, "ReplayDelivery", 0).Warn("Invalid webhook_id parameter")
+		return router.ResponseBadRequest(c, "invalid webhook_id")
+	}
+	deliveryID, err := c.ParamsInt("delivery_id")
+	if err != nil {
+		log.WebhookOp(deviceID, jid, "ReplayDelivery", int64(webhookID)).Warn("Invalid delivery_id parameter")
+		return router.ResponseBadRequest(c, "invalid delivery_id")
+	}
+
+	log.WebhookOp(deviceID, jid, "ReplayDelivery", int64(webhookID)).WithField("delivery_id", deliveryID).Info("Replaying webhook delivery")
+
+	engine := pkgWhatsApp.GetWebhookEngine()
+	if engine == nil {
+		log.WebhookOp(deviceID, jid, "ReplayDelivery", int64(webhookID)).Error("Webhook engine not initialized")
+		return router.ResponseInternalError(c, "webhook engine not initialized")
+	}
+
+	newID, err := engine.Replay(context.Background(), deviceID, int64(webhookID), int64(deliveryID))
+	if err != nil {
+		if errors.Is(err, webhook.ErrDeliveryNotFound) {
+			log.WebhookOp(deviceID, jid, "ReplayDelivery", int64(webhookID)).WithField("delivery_id", deliveryID).Warn("Delivery not found or not replayable")
+			return router.ResponseNotFound(c, err.Error())
+		}
+		log.WebhookOp(deviceID, jid, "ReplayDelivery", int64(webhookID)).WithError(err).Error("Failed to replay delivery")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.WebhookOp(deviceID, jid, "ReplayDelivery", int64(webhookID)).WithField("delivery_id", deliveryID).WithField("replay_id", newID).Info("Webhook delivery queued for replay")
+
+	return router.ResponseSuccessWithData(c, "delivery queued for replay", map[string]interface{}{"delivery_id": newID, "replay_of": deliveryID})
+}
+
+func ReplayFailedDeliveries(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+	webhookID, err := c.ParamsInt("webhook_id")
+	if err != nil {
+		log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", 0).Warn("Invalid webhook_id parameter")
+		return router.ResponseBadRequest(c, "invalid webhook_id")
+	}
+
+	var req replayDeliveriesRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).Warn("Invalid request body")
+		return router.ResponseBadRequest(c, "invalid request body, from and to must be RFC3339 timestamps")
+	}
+	if req.From.IsZero() {
+		log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).Warn("from is required")
+		return router.ResponseBadRequest(c, "from is required")
+	}
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+	if req.To.Before(req.From) {
+		log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).Warn("to is before from")
+		return router.ResponseBadRequest(c, "to must not be before from")
+	}
+
+	log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).WithField("from", req.From).WithField("to", req.To).Info("Replaying failed webhook deliveries")
+
+	engine := pkgWhatsApp.GetWebhookEngine()
+	if engine == nil {
+		log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).Error("Webhook engine not initialized")
+		return router.ResponseInternalError(c, "webhook engine not initialized")
+	}
+
+	if _, err := engine.Store().GetWebhook(context.Background(), int64(webhookID), deviceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).Warn("Webhook not found")
+			return router.ResponseNotFound(c, "webhook not found")
+		}
+		log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).WithError(err).Error("Failed to get webhook")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	count, err := engine.ReplayFailed(context.Background(), deviceID, int64(webhookID), req.From, req.To)
+	if err != nil {
+		log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).WithError(err).Error("Failed to replay deliveries")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.WebhookOp(deviceID, jid, "ReplayFailedDeliveries", int64(webhookID)).WithField("replayed", count).Info("Failed webhook deliveries queued for replay")
+
+	return router.ResponseSuccessWithData(c, "deliveries queued for replay", map[string]interface{}{"replayed": count})
+}
+
+type requeueDeadLettersRequest struct {
+	DeliveryIDs []int64 `json:"delivery_ids"`
+}
+
+func ListDeadLetters(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+	webhookID, err := c.ParamsInt("webhook_id")
+	if err != nil {
+		log.WebhookOp(deviceID, jid, "ListDeadLetters", 0).Warn("Invalid webhook_id parameter")
+		return router.ResponseBadRequest(c, "invalid webhook_id")
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	log.WebhookOp(deviceID, jid, "ListDeadLetters", int64(webhookID)).Info("Listing dead-lettered deliveries")
+
+	engine := pkgWhatsApp.GetWebhookEngine()
+	if engine == nil {
+		log.WebhookOp(deviceID, jid, "ListDeadLetters", int64(webhookID)).Error("Webhook engine not initialized")
+		return router.ResponseInternalError(c, "webhook engine not initialized")
+	}
+
+	wh, err := engine.Store().GetWebhook(context.Background(), int64(webhookID), deviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.WebhookOp(deviceID, jid, "ListDeadLetters", int64(webhookID)).Warn("Webhook not found")
+			return router.ResponseNotFound(c, "webhook not found")
+		}
+		log.WebhookOp(deviceID, jid, "ListDeadLetters", int64(webhookID)).WithError(err).Error("Failed to get webhook")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	deliveries, err := engine.Store().GetDeadLetters(context.Background(), int64(webhookID), limit)
+	if err != nil {
+		log.WebhookOp(deviceID, jid, "ListDeadLetters", int64(webhookID)).WithError(err).Error("Failed to list dead-lettered deliveries")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.WebhookOp(deviceID, jid, "ListDeadLetters", int64(webhookID)).WithField("count", len(deliveries)).Info("Dead-lettered deliveries listed successfully")
+
+	return router.ResponseSuccessWithData(c, "success", map[string]interface{}{
+		"circuit_state":        wh.CircuitState,
+		"consecutive_failures": wh.ConsecutiveFailures,
+		"active":               wh.Active,
+		"deliveries":           deliveries,
+	})
+}
+
+func RequeueDeadLetters(c *fiber.Ctx) error {
+	deviceID, jid := getDeviceContext(c)
+	webhookID, err := c.ParamsInt("webhook_id")
+	if err != nil {
+		log.WebhookOp(deviceID, jid, "RequeueDeadLetters", 0).Warn("Invalid webhook_id parameter")
+		return router.ResponseBadRequest(c, "invalid webhook_id")
+	}
+
+	var req requeueDeadLettersRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			log.WebhookOp(deviceID, jid, "RequeueDeadLetters", int64(webhookID)).Warn("Invalid request body")
+			return router.ResponseBadRequest(c, "invalid request body")
+		}
+	}
+
+	log.WebhookOp(deviceID, jid, "RequeueDeadLetters", int64(webhookID)).WithField("delivery_count", len(req.DeliveryIDs)).Info("Requeueing dead-lettered deliveries")
+
+	engine := pkgWhatsApp.GetWebhookEngine()
+	if engine == nil {
+		log.WebhookOp(deviceID, jid, "RequeueDeadLetters", int64(webhookID)).Error("Webhook engine not initialized")
+		return router.ResponseInternalError(c, "webhook engine not initialized")
+	}
+
+	if _, err := engine.Store().GetWebhook(context.Background(), int64(webhookID), deviceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.WebhookOp(deviceID, jid, "RequeueDeadLetters", int64(webhookID)).Warn("Webhook not found")
+			return router.ResponseNotFound(c, "webhook not found")
+		}
+		log.WebhookOp(deviceID, jid, "RequeueDeadLetters", int64(webhookID)).WithError(err).Error("Failed to get webhook")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	count, err := engine.RequeueDeadLetters(context.Background(), deviceID, int64(webhookID), req.DeliveryIDs)
+	if err != nil {
+		log.WebhookOp(deviceID, jid, "RequeueDeadLetters", int64(webhookID)).WithError(err).Error("Failed to requeue dead-lettered deliveries")
+		return router.ResponseInternalError(c, err.Error())
+	}
+
+	log.WebhookOp(deviceID, jid, "RequeueDeadLetters", int64(webhookID)).WithField("requeued", count).Info("Dead-lettered deliveries requeued successfully")
+
+	return router.ResponseSuccessWithData(c, "dead-lettered deliveries requeued", map[string]interface{}{"requeued": count})
+}
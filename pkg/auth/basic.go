@@ -5,6 +5,7 @@ import (
 	"crypto/subtle"
 	"strings"
 
+	"github.com/fasthttp/websocket"
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
@@ -53,6 +54,12 @@ func APIKeyAuth() fiber.Handler {
 			return router.ResponseUnauthorized(c, "API key is inactive")
 		}
 
+		// Hourly quota shared by the API key and all of its devices
+		q, err := pkgWhatsApp.CheckAPIKeyQuota(ctx, apiKeyRecord.ID, apiKeyRecord.RateLimitPerHour)
+		if !applyAPIKeyQuota(c, q, err) {
+			return nil
+		}
+
 		// Store API key context in locals
 		c.Locals("api_key", apiKeyRecord)
 		c.Locals("api_key_id", apiKeyRecord.ID)
@@ -61,6 +68,21 @@ func APIKeyAuth() fiber.Handler {
 	}
 }
 
+// StreamAuth is DeviceAuth that also accepts the token as ?access_token= on a WebSocket upgrade,
+// since browsers cannot set headers on one. Plain requests must send the Authorization header,
+// keeping the token out of access logs for everything else.
+func StreamAuth() fiber.Handler {
+	deviceAuth := DeviceAuth()
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") == "" && websocket.FastHTTPIsWebSocketUpgrade(c.Context()) {
+			if token := c.Query("access_token"); token != "" {
+				c.Request().Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		return deviceAuth(c)
+	}
+}
+
 // DeviceAuth validates the JWT token from Authorization header
 // Token format: "Bearer <jwt_token>"
 // This is a stateless validation - no database hit for every request
@@ -104,6 +126,12 @@ func DeviceAuth() fiber.Handler {
 			return router.ResponseUnauthorized(c, "Token has been revoked. Please regenerate a new token.")
 		}
 
+		// Hourly quota shared by all devices of the API key
+		q, err := pkgWhatsApp.CheckDeviceAPIKeyQuota(ctx, claims.APIKeyID)
+		if !applyAPIKeyQuota(c, q, err) {
+			return nil
+		}
+
 		// Store device context in locals (from JWT claims - no DB hit)
 		c.Locals("device_id", claims.DeviceID)
 		c.Locals("device_jid", claims.JID)
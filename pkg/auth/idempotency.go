@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/idempotency"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// Idempotency honors the Idempotency-Key header with keys stored in the routing database;
+// see idempotency.Middleware. It must run after DeviceAuth, since keys are scoped to the device.
+func Idempotency() fiber.Handler {
+	return idempotency.Middleware(idempotencyStore{})
+}
+
+// idempotencyStore keeps Idempotency-Key reservations in wa_idempotency_keys
+type idempotencyStore struct{}
+
+func (idempotencyStore) Claim(ctx context.Context, deviceID string, key string, requestHash string) (*idempotency.Response, error) {
+	return pkgWhatsApp.ClaimIdempotencyKey(ctx, deviceID, key, requestHash)
+}
+
+func (idempotencyStore) Complete(ctx context.Context, deviceID string, key string, resp idempotency.Response) error {
+	return pkgWhatsApp.CompleteIdempotencyKey(ctx, deviceID, key, resp)
+}
+
+func (idempotencyStore) Release(ctx context.Context, deviceID string, key string) error {
+	return pkgWhatsApp.ReleaseIdempotencyKey(ctx, deviceID, key)
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+	pkgWhatsApp "github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/whatsapp"
+)
+
+// applyAPIKeyQuota sets the X-RateLimit-* headers of a counted request. It returns false after
+// writing a 429 when the quota is used up, or a 503 when the quota could not be checked.
+func applyAPIKeyQuota(c *fiber.Ctx, q *pkgWhatsApp.APIKeyQuota, err error) bool {
+	if err != nil {
+		_ = router.ResponseServiceUnavailable(c, "Rate limit cannot be checked, please retry later")
+		return false
+	}
+	if q == nil {
+		return true
+	}
+	c.Set("X-RateLimit-Limit", strconv.Itoa(q.Limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(q.Remaining))
+	c.Set("X-RateLimit-Reset", strconv.FormatInt(q.Reset.Unix(), 10))
+	if !q.Exceeded {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(time.Until(q.Reset).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	_ = router.ResponseTooManyRequests(c, "Hourly rate limit of "+strconv.Itoa(q.Limit)+" requests exceeded for this API key")
+	return false
+}
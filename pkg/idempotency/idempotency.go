@@ -0,0 +1,170 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/router"
+)
+
+var (
+	// ErrKeyMismatch is returned when a key is reused for a different request
+	ErrKeyMismatch = errors.New("idempotency key was used for a different request")
+	// ErrKeyInFlight is returned when the original request is still running after the wait timeout
+	ErrKeyInFlight = errors.New("request with this idempotency key is still in progress")
+)
+
+// KeyMaxLength is the longest Idempotency-Key header accepted
+const KeyMaxLength = 255
+
+// Response is the response stored for a key and replayed to retries
+type Response struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Store keeps Idempotency-Key reservations and the responses replayed to retries
+type Store interface {
+	// Claim reserves key for a request identified by requestHash. It returns nil when the caller
+	// now holds the key and must run the request, or the stored response when the key already
+	// completed. While another request holds the key it waits for that request to finish, and
+	// returns ErrKeyInFlight if it does not. A key used with another requestHash is ErrKeyMismatch.
+	Claim(ctx context.Context, deviceID string, key string, requestHash string) (*Response, error)
+	// Complete stores the response of a claimed key so retries replay it
+	Complete(ctx context.Context, deviceID string, key string, resp Response) error
+	// Release forgets a claimed key whose request failed, so a retry runs it again
+	Release(ctx context.Context, deviceID string, key string) error
+}
+
+// Middleware makes a request with an Idempotency-Key header run at most once per device:
+// a retry with the same key gets the first response back, and a duplicate that arrives while
+// the first request is running waits for it. Server errors are not stored, so they can be retried.
+// It must run after DeviceAuth, since keys are scoped to the device.
+func Middleware(store Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := strings.TrimSpace(c.Get("Idempotency-Key"))
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > KeyMaxLength {
+			return router.ResponseBadRequest(c, "Idempotency-Key must be at most 255 characters")
+		}
+		deviceID, _ := c.Locals("device_id").(string)
+
+		ctx := c.UserContext()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		// The same key with another route, query or body is a client bug, not a retry
+		requestHash, err := RequestHash(c)
+		if err != nil {
+			return router.ResponseBadRequest(c, err.Error())
+		}
+
+		stored, err := store.Claim(ctx, deviceID, key, requestHash)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrKeyMismatch):
+				return router.ResponseBadRequest(c, "Idempotency-Key was already used for a different request")
+			case errors.Is(err, ErrKeyInFlight):
+				return router.ResponseConflict(c, "A request with this Idempotency-Key is still in progress")
+			}
+			return router.ResponseInternalError(c, err.Error())
+		}
+		if stored != nil {
+			c.Set("Idempotent-Replayed", "true")
+			if stored.ContentType != "" {
+				c.Set(fiber.HeaderContentType, stored.ContentType)
+			}
+			return c.Status(stored.StatusCode).Send(stored.Body)
+		}
+
+		completed := false
+		defer func() {
+			// handler error or panic: let the retry run the request again
+			if !completed {
+				if err := store.Release(context.Background(), deviceID, key); err != nil {
+					log.Print(c).WithField("error", err.Error()).Error("Failed to release idempotency key")
+				}
+			}
+		}()
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		code := c.Response().StatusCode()
+		if code >= fiber.StatusInternalServerError {
+			return nil
+		}
+		resp := Response{
+			StatusCode:  code,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+		// the request ran, so the key is not released even if storing the response fails;
+		// retries then wait for the lease to expire
+		completed = true
+		if err := store.Complete(context.Background(), deviceID, key, resp); err != nil {
+			log.Print(c).WithField("error", err.Error()).Error("Failed to store idempotent response")
+		}
+		return nil
+	}
+}
+
+// RequestHash fingerprints the request. Multipart bodies are hashed by field and file content,
+// since clients pick a new boundary for every attempt.
+func RequestHash(c *fiber.Ctx) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method() + " " + c.OriginalURL() + "\n"))
+
+	if !strings.HasPrefix(strings.ToLower(c.Get(fiber.HeaderContentType)), fiber.MIMEMultipartForm) {
+		hash.Write(c.Body())
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(form.Value))
+	for name := range form.Value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range form.Value[name] {
+			fmt.Fprintf(hash, "value %q %d\n", name, len(value))
+			hash.Write([]byte(value))
+		}
+	}
+	names = names[:0]
+	for name := range form.File {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, fh := range form.File[name] {
+			fmt.Fprintf(hash, "file %q %q %d\n", name, fh.Filename, fh.Size)
+			f, err := fh.Open()
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(hash, f)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,238 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// memoryStore is a Store that keeps keys in a map; a key still being processed is ErrKeyInFlight
+type memoryStore struct {
+	mu       sync.Mutex
+	entries  map[string]*memoryEntry
+	released int
+}
+
+type memoryEntry struct {
+	requestHash string
+	resp        *Response
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+func (s *memoryStore) Claim(_ context.Context, deviceID string, key string, requestHash string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[deviceID+"/"+key]
+	if !ok {
+		s.entries[deviceID+"/"+key] = &memoryEntry{requestHash: requestHash}
+		return nil, nil
+	}
+	if entry.requestHash != requestHash {
+		return nil, ErrKeyMismatch
+	}
+	if entry.resp == nil {
+		return nil, ErrKeyInFlight
+	}
+	return entry.resp, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, deviceID string, key string, resp Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[deviceID+"/"+key].resp = &resp
+	return nil
+}
+
+func (s *memoryStore) Release(_ context.Context, deviceID string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, deviceID+"/"+key)
+	s.released++
+	return nil
+}
+
+// newTestApp serves POST /send behind the middleware; the handler answers with status and
+// counts its runs. The device comes from the X-Device header, standing in for DeviceAuth.
+func newTestApp(store Store, status *int, runs *int) *fiber.App {
+	app := fiber.New()
+	app.Post("/send", func(c *fiber.Ctx) error {
+		c.Locals("device_id", c.Get("X-Device", "device-1"))
+		return c.Next()
+	}, Middleware(store), func(c *fiber.Ctx) error {
+		*runs++
+		return c.Status(*status).JSON(fiber.Map{"run": *runs})
+	})
+	return app
+}
+
+func send(t *testing.T, app *fiber.App, key string, body string, header ...string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(data)
+}
+
+func TestMiddlewareReplaysStoredResponse(t *testing.T) {
+	status, runs := fiber.StatusCreated, 0
+	app := newTestApp(newMemoryStore(), &status, &runs)
+
+	first, firstBody := send(t, app, "key-1", `{"text":"hi"}`)
+	if first.StatusCode != fiber.StatusCreated || first.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request: HTTP %d, replayed=%q", first.StatusCode, first.Header.Get("Idempotent-Replayed"))
+	}
+
+	status = fiber.StatusOK // a handler run now would answer differently
+	retry, retryBody := send(t, app, "key-1", `{"text":"hi"}`)
+	if runs != 1 {
+		t.Fatalf("handler ran %d times, want 1", runs)
+	}
+	if retry.StatusCode != fiber.StatusCreated || retryBody != firstBody {
+		t.Fatalf("retry got HTTP %d %s, want the first response HTTP 201 %s", retry.StatusCode, retryBody, firstBody)
+	}
+	if retry.Header.Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response is not marked with Idempotent-Replayed")
+	}
+	if ct := retry.Header.Get(fiber.HeaderContentType); ct != first.Header.Get(fiber.HeaderContentType) {
+		t.Errorf("replayed Content-Type %q, want %q", ct, first.Header.Get(fiber.HeaderContentType))
+	}
+
+	// keys are scoped to the device
+	send(t, app, "key-1", `{"text":"hi"}`, "X-Device", "device-2")
+	if runs != 2 {
+		t.Errorf("another device's request with the same key was replayed")
+	}
+}
+
+func TestMiddlewareWithoutKeyAlwaysRuns(t *testing.T) {
+	status, runs := fiber.StatusOK, 0
+	app := newTestApp(newMemoryStore(), &status, &runs)
+	send(t, app, "", `{}`)
+	send(t, app, "", `{}`)
+	if runs != 2 {
+		t.Fatalf("handler ran %d times without a key, want 2", runs)
+	}
+}
+
+func TestMiddlewareRejectsKeyReuse(t *testing.T) {
+	status, runs := fiber.StatusOK, 0
+	app := newTestApp(newMemoryStore(), &status, &runs)
+	send(t, app, "key-1", `{"text":"hi"}`)
+	resp, _ := send(t, app, "key-1", `{"text":"bye"}`)
+	if resp.StatusCode != fiber.StatusBadRequest || runs != 1 {
+		t.Fatalf("reused key with another body: HTTP %d after %d runs, want 400 after 1", resp.StatusCode, runs)
+	}
+}
+
+func TestMiddlewareRejectsLongKey(t *testing.T) {
+	status, runs := fiber.StatusOK, 0
+	app := newTestApp(newMemoryStore(), &status, &runs)
+	resp, _ := send(t, app, strings.Repeat("k", KeyMaxLength+1), `{}`)
+	if resp.StatusCode != fiber.StatusBadRequest || runs != 0 {
+		t.Fatalf("long key: HTTP %d after %d runs, want 400 after 0", resp.StatusCode, runs)
+	}
+}
+
+func TestMiddlewareConflictWhileInFlight(t *testing.T) {
+	store := newMemoryStore()
+	status, runs := fiber.StatusOK, 0
+	app := newTestApp(store, &status, &runs)
+	if _, err := store.Claim(context.Background(), "device-1", "key-1", mustHash(t, `{}`)); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	resp, _ := send(t, app, "key-1", `{}`)
+	if resp.StatusCode != fiber.StatusConflict || runs != 0 {
+		t.Fatalf("in-flight key: HTTP %d after %d runs, want 409 after 0", resp.StatusCode, runs)
+	}
+}
+
+func TestMiddlewareDoesNotStoreServerErrors(t *testing.T) {
+	store := newMemoryStore()
+	status, runs := fiber.StatusInternalServerError, 0
+	app := newTestApp(store, &status, &runs)
+
+	send(t, app, "key-1", `{}`)
+	if store.released != 1 {
+		t.Fatalf("key released %d times after a server error, want 1", store.released)
+	}
+	status = fiber.StatusOK
+	resp, _ := send(t, app, "key-1", `{}`)
+	if resp.StatusCode != fiber.StatusOK || runs != 2 {
+		t.Fatalf("retry after a server error: HTTP %d after %d runs, want 200 after 2", resp.StatusCode, runs)
+	}
+}
+
+// mustHash returns the RequestHash of a JSON POST /send
+func mustHash(t *testing.T, body string) string {
+	t.Helper()
+	var hash string
+	app := fiber.New()
+	app.Post("/send", func(c *fiber.Ctx) error {
+		var err error
+		hash, err = RequestHash(c)
+		return err
+	})
+	req := httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return hash
+}
+
+func TestRequestHashIgnoresMultipartBoundary(t *testing.T) {
+	var hashes []string
+	app := fiber.New()
+	app.Post("/images", func(c *fiber.Ctx) error {
+		hash, err := RequestHash(c)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, hash)
+		return nil
+	})
+	for _, caption := range []string{"hello", "hello", "other"} {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body) // a new random boundary every time
+		w.WriteField("caption", caption)
+		part, _ := w.CreateFormFile("file", "a.jpg")
+		part.Write([]byte("image bytes"))
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/images", &body)
+		req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+		if _, err := app.Test(req); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+	}
+	if len(hashes) != 3 {
+		t.Fatalf("hashed %d requests, want 3", len(hashes))
+	}
+	if hashes[0] != hashes[1] {
+		t.Error("identical multipart requests hash differently")
+	}
+	if hashes[0] == hashes[2] {
+		t.Error("multipart requests with different fields hash the same")
+	}
+}
@@ -23,6 +23,8 @@ func HttpCacheInMemory(ttl int) fiber.Handler {
 			if strings.HasSuffix(p, "/status") ||
 				strings.HasSuffix(p, "/health") ||
 				strings.Contains(p, "/qr") ||
+				strings.Contains(p, "/events/") ||
+				strings.Contains(p, "/uploads/") ||
 				strings.Contains(p, "docs") {
 				return true
 			}
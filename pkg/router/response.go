@@ -107,6 +107,22 @@ func ResponseCreatedWithData(c *fiber.Ctx, message string, data interface{}) err
 	return c.Status(response.Code).JSON(response)
 }
 
+func ResponseAcceptedWithData(c *fiber.Ctx, message string, data interface{}) error {
+	response := Response{
+		Status: true,
+		Code:   http.StatusAccepted,
+		Data:   data,
+	}
+
+	if strings.TrimSpace(message) == "" {
+		message = http.StatusText(response.Code)
+	}
+	response.Message = message
+
+	logSuccess(c, response.Code, response.Message)
+	return c.Status(response.Code).JSON(response)
+}
+
 func ResponseNoContent(c *fiber.Ctx) error {
 	return c.SendStatus(http.StatusNoContent)
 }
@@ -195,3 +211,51 @@ func ResponseBadGateway(c *fiber.Ctx, message string) error {
 	logError(c, response.Code, response.Message)
 	return c.Status(response.Code).JSON(response)
 }
+
+func ResponseConflict(c *fiber.Ctx, message string) error {
+	response := Response{
+		Status: false,
+		Code:   http.StatusConflict,
+	}
+
+	if strings.TrimSpace(message) == "" {
+		message = http.StatusText(response.Code)
+	}
+	response.Message = message
+	response.Error = message
+
+	logError(c, response.Code, response.Message)
+	return c.Status(response.Code).JSON(response)
+}
+
+func ResponseTooManyRequests(c *fiber.Ctx, message string) error {
+	response := Response{
+		Status:  false,
+		Code:    http.StatusTooManyRequests,
+		Message: http.StatusText(http.StatusTooManyRequests),
+	}
+
+	if strings.TrimSpace(message) == "" {
+		message = response.Message
+	}
+	response.Error = message
+
+	logError(c, response.Code, response.Error)
+	return c.Status(response.Code).JSON(response)
+}
+
+func ResponseServiceUnavailable(c *fiber.Ctx, message string) error {
+	response := Response{
+		Status: false,
+		Code:   http.StatusServiceUnavailable,
+	}
+
+	if strings.TrimSpace(message) == "" {
+		message = http.StatusText(response.Code)
+	}
+	response.Message = message
+	response.Error = message
+
+	logError(c, response.Code, response.Message)
+	return c.Status(response.Code).JSON(response)
+}
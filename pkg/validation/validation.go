@@ -2,13 +2,20 @@ package validation
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
+
+	"go.mau.fi/whatsmeow/types"
 )
 
 var (
 	phonePattern = regexp.MustCompile(`^[1-9][0-9]{5,15}$`)
+	// mentionTokenPattern matches @<number> in group text and captions; 6-15 digits covers E.164 numbers and LIDs
+	mentionTokenPattern = regexp.MustCompile(`@(\d{6,15})\b`)
+
+	mentionNumberCleaner = strings.NewReplacer("+", "", " ", "", "-", "", "(", "", ")", "")
 )
 
 // ValidatePhone ensures international format (no leading 0, digits only, length 6-16).
@@ -35,6 +42,78 @@ func ValidateChatJID(chatJID string) error {
 	return nil
 }
 
+// NormalizeRecipientJID turns a phone number or a user, LID or group JID into the canonical JID
+// a message is sent to, e.g. "+62 812 3456" becomes "628123456@s.whatsapp.net".
+func NormalizeRecipientJID(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("recipient cannot be empty")
+	}
+	if !strings.ContainsRune(raw, '@') {
+		phone := strings.Join(strings.Fields(raw), "")
+		if err := ValidatePhone(phone); err != nil {
+			return "", err
+		}
+		return types.NewJID(strings.TrimPrefix(phone, "+"), types.DefaultUserServer).String(), nil
+	}
+	jid, err := types.ParseJID(raw)
+	if err != nil || jid.User == "" {
+		return "", errors.New("recipient must be a phone number or a valid JID")
+	}
+	switch jid.Server {
+	case types.DefaultUserServer:
+		if err := ValidatePhone(jid.User); err != nil {
+			return "", err
+		}
+	case types.HiddenUserServer, types.GroupServer:
+	default:
+		return "", fmt.Errorf("recipient server %q is not supported", jid.Server)
+	}
+	return jid.ToNonAD().String(), nil
+}
+
+// ParseMentionJID accepts a phone number (formatting like "+62 812-..." is ignored) or a user/LID JID
+func ParseMentionJID(raw string) (types.JID, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, "@") {
+		parsed, err := types.ParseJID(raw)
+		if err != nil || parsed.User == "" {
+			return types.EmptyJID, fmt.Errorf("invalid mention %q", raw)
+		}
+		if parsed.Server != types.DefaultUserServer && parsed.Server != types.HiddenUserServer {
+			return types.EmptyJID, fmt.Errorf("mention %q must be a user JID", raw)
+		}
+		return parsed.ToNonAD(), nil
+	}
+	number := mentionNumberCleaner.Replace(raw)
+	if !mentionTokenPattern.MatchString("@"+number) || len(number) > 15 {
+		return types.EmptyJID, fmt.Errorf("invalid mention %q", raw)
+	}
+	return types.NewJID(number, types.DefaultUserServer), nil
+}
+
+// MentionTokens returns the numbers of the @<number> tokens in text, in order of appearance
+func MentionTokens(text string) []string {
+	var numbers []string
+	for _, match := range mentionTokenPattern.FindAllStringSubmatch(text, -1) {
+		numbers = append(numbers, match[1])
+	}
+	return numbers
+}
+
+// ValidateMentions checks the mentions of a send request before anything is sent
+func ValidateMentions(chatJID string, mentions []string, mentionAll bool) error {
+	for _, m := range mentions {
+		if _, err := ParseMentionJID(m); err != nil {
+			return err
+		}
+	}
+	if mentionAll && !strings.HasSuffix(chatJID, "@"+types.GroupServer) {
+		return errors.New("mention_all is only supported for group chats")
+	}
+	return nil
+}
+
 // ValidateURL ensures a non-empty valid URL when provided.
 func ValidateURL(raw string) error {
 	raw = strings.TrimSpace(raw)
@@ -0,0 +1,99 @@
+package validation
+
+import "testing"
+
+func TestNormalizeRecipientJID(t *testing.T) {
+	valid := map[string]string{
+		"628123456789":                   "628123456789@s.whatsapp.net",
+		" +62 812 3456 789 ":             "628123456789@s.whatsapp.net",
+		"628123456789@s.whatsapp.net":    "628123456789@s.whatsapp.net",
+		"628123456789:12@s.whatsapp.net": "628123456789@s.whatsapp.net",
+		"120363025246125486@g.us":        "120363025246125486@g.us",
+		"123456789012345@lid":            "123456789012345@lid",
+	}
+	for raw, want := range valid {
+		got, err := NormalizeRecipientJID(raw)
+		if err != nil {
+			t.Errorf("NormalizeRecipientJID(%q) failed: %v", raw, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("NormalizeRecipientJID(%q) = %q, want %q", raw, got, want)
+		}
+	}
+
+	for _, raw := range []string{"", "   ", "0812345678", "12345", "62812abc", "@s.whatsapp.net", "628123456789@broadcast", "hello@s.whatsapp.net"} {
+		if got, err := NormalizeRecipientJID(raw); err == nil {
+			t.Errorf("NormalizeRecipientJID(%q) = %q, want an error", raw, got)
+		}
+	}
+}
+
+func TestParseMentionJID(t *testing.T) {
+	valid := map[string]string{
+		"628123456789":                  "628123456789@s.whatsapp.net",
+		"+62 812-3456-789":              "628123456789@s.whatsapp.net",
+		"(62) 8123456789":               "628123456789@s.whatsapp.net",
+		"628123456789@s.whatsapp.net":   "628123456789@s.whatsapp.net",
+		"628123456789:7@s.whatsapp.net": "628123456789@s.whatsapp.net",
+		"123456789012345@lid":           "123456789012345@lid",
+	}
+	for raw, want := range valid {
+		got, err := ParseMentionJID(raw)
+		if err != nil {
+			t.Errorf("ParseMentionJID(%q) failed: %v", raw, err)
+			continue
+		}
+		if got.String() != want {
+			t.Errorf("ParseMentionJID(%q) = %q, want %q", raw, got, want)
+		}
+	}
+
+	for _, raw := range []string{"", "12345", "1234567890123456", "62812abc", "@s.whatsapp.net", "120363025246125486@g.us", "status@broadcast"} {
+		if got, err := ParseMentionJID(raw); err == nil {
+			t.Errorf("ParseMentionJID(%q) = %q, want an error", raw, got)
+		}
+	}
+}
+
+func TestMentionTokens(t *testing.T) {
+	cases := map[string][]string{
+		"hi @628123456789, see @628987654321": {"628123456789", "628987654321"},
+		"@123456789012345 joined":             {"123456789012345"},
+		"mail me at a@12345 or @12345":        nil,
+		"@1234567890123456 is too long":       nil,
+		"@62812abc is not a number":           nil,
+		"no mentions here":                    nil,
+	}
+	for text, want := range cases {
+		got := MentionTokens(text)
+		if len(got) != len(want) {
+			t.Errorf("MentionTokens(%q) = %v, want %v", text, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("MentionTokens(%q) = %v, want %v", text, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestValidateMentions(t *testing.T) {
+	group := "120363025246125486@g.us"
+	user := "628123456789@s.whatsapp.net"
+
+	if err := ValidateMentions(group, []string{"628123456789", "123456789012345@lid"}, true); err != nil {
+		t.Errorf("valid group mentions rejected: %v", err)
+	}
+	if err := ValidateMentions(user, []string{"628123456789"}, false); err != nil {
+		t.Errorf("valid direct chat mention rejected: %v", err)
+	}
+	if err := ValidateMentions(user, nil, true); err == nil {
+		t.Error("mention_all accepted for a direct chat")
+	}
+	if err := ValidateMentions(group, []string{"628123456789", "not-a-number"}, false); err == nil {
+		t.Error("invalid mention accepted")
+	}
+}
@@ -0,0 +1,165 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/quota"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// apiKeyQuotaWindow is the fixed window rate_limit_per_hour is counted in
+const apiKeyQuotaWindow = time.Hour
+
+// ErrAPIKeyQuotaUnavailable is returned when a quota cannot be checked and
+// API_KEY_RATE_LIMIT_FAIL_OPEN is off, so the request must be refused
+var ErrAPIKeyQuotaUnavailable = errors.New("API key quota cannot be checked")
+
+var (
+	apiKeyQuotaEnabled  = true
+	apiKeyQuotaFailOpen = false
+	apiKeyQuotaStore    quota.Store
+	// quota checks let through by API_KEY_RATE_LIMIT_FAIL_OPEN since startup
+	apiKeyQuotaFailures atomic.Int64
+
+	// rate_limit_per_hour by API key ID, so device requests do not read api_keys every time
+	apiKeyLimitCache    = make(map[int64]apiKeyLimitCacheEntry)
+	apiKeyLimitCacheMu  sync.RWMutex
+	apiKeyLimitCacheTTL = time.Minute
+)
+
+type apiKeyLimitCacheEntry struct {
+	limit     int
+	expiresAt time.Time
+}
+
+// APIKeyQuota is the state of an API key's hourly quota after counting a request
+type APIKeyQuota = quota.Usage
+
+func loadAPIKeyQuotaConfig() {
+	apiKeyQuotaEnabled = ParseOptionalBool("API_KEY_RATE_LIMIT_ENABLED", true)
+	if !apiKeyQuotaEnabled {
+		log.Sys("cfg", "api_key_rate_limit:disabled")
+		return
+	}
+	apiKeyQuotaFailOpen = ParseOptionalBool("API_KEY_RATE_LIMIT_FAIL_OPEN", false)
+	db, err := openRoutingDB()
+	if err != nil {
+		log.SysErr("api-key-rate-limit", err)
+		os.Exit(1)
+	}
+
+	// A store that cannot be reached would leave every quota unenforced, so startup waits for it
+	// and gives up instead of running without one
+	attempts := ParseOptionalInt("API_KEY_RATE_LIMIT_CONNECT_ATTEMPTS", 5, 1)
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		store, err := quota.NewFromEnv(db)
+		if err == nil {
+			apiKeyQuotaStore = store
+			break
+		}
+		if attempt >= attempts {
+			log.SysErr("api-key-rate-limit", err)
+			os.Exit(1)
+		}
+		log.SysErr("api-key-rate-limit", fmt.Errorf("attempt %d/%d, retrying in %s: %w", attempt, attempts, backoff, err))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Sys("cfg", fmt.Sprintf("api_key_rate_limit:%s fail_open:%t", apiKeyQuotaStore.Name(), apiKeyQuotaFailOpen))
+}
+
+// GetAPIKeyRateLimit returns rate_limit_per_hour of an API key (with caching)
+func GetAPIKeyRateLimit(ctx context.Context, apiKeyID int64) (int, error) {
+	apiKeyLimitCacheMu.RLock()
+	if entry, ok := apiKeyLimitCache[apiKeyID]; ok && time.Now().Before(entry.expiresAt) {
+		apiKeyLimitCacheMu.RUnlock()
+		return entry.limit, nil
+	}
+	apiKeyLimitCacheMu.RUnlock()
+
+	ak, err := GetAPIKeyByID(ctx, apiKeyID)
+	if err != nil {
+		return 0, err
+	}
+
+	apiKeyLimitCacheMu.Lock()
+	apiKeyLimitCache[apiKeyID] = apiKeyLimitCacheEntry{
+		limit:     ak.RateLimitPerHour,
+		expiresAt: time.Now().Add(apiKeyLimitCacheTTL),
+	}
+	apiKeyLimitCacheMu.Unlock()
+
+	return ak.RateLimitPerHour, nil
+}
+
+// InvalidateAPIKeyRateLimitCache removes an API key's limit from cache
+func InvalidateAPIKeyRateLimitCache(apiKeyID int64) {
+	apiKeyLimitCacheMu.Lock()
+	delete(apiKeyLimitCache, apiKeyID)
+	apiKeyLimitCacheMu.Unlock()
+}
+
+// CheckAPIKeyQuota counts one request against the API key's hourly quota. It returns nil when
+// the quota is not enforced: disabled or unlimited (limit <= 0). When the counter store fails the
+// request is refused with ErrAPIKeyQuotaUnavailable, or let through and counted as a failure
+// with API_KEY_RATE_LIMIT_FAIL_OPEN.
+func CheckAPIKeyQuota(ctx context.Context, apiKeyID int64, limit int) (*APIKeyQuota, error) {
+	if !apiKeyQuotaEnabled || apiKeyQuotaStore == nil || apiKeyID == 0 || limit <= 0 {
+		return nil, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	q, err := quota.Count(ctx, apiKeyQuotaStore, apiKeyID, limit, apiKeyQuotaWindow, time.Now())
+	if err != nil {
+		return nil, apiKeyQuotaFailure(apiKeyID, err)
+	}
+	return &q, nil
+}
+
+// CheckDeviceAPIKeyQuota is CheckAPIKeyQuota for a device request, which only carries the API key ID:
+// the limit is looked up first, and a failed lookup follows the same policy as a failed count
+func CheckDeviceAPIKeyQuota(ctx context.Context, apiKeyID int64) (*APIKeyQuota, error) {
+	if !apiKeyQuotaEnabled || apiKeyQuotaStore == nil || apiKeyID == 0 {
+		return nil, nil
+	}
+	limit, err := GetAPIKeyRateLimit(ctx, apiKeyID)
+	if err != nil {
+		return nil, apiKeyQuotaFailure(apiKeyID, err)
+	}
+	return CheckAPIKeyQuota(ctx, apiKeyID, limit)
+}
+
+// APIKeyQuotaFailures is the number of quota checks let through by API_KEY_RATE_LIMIT_FAIL_OPEN since startup
+func APIKeyQuotaFailures() int64 {
+	return apiKeyQuotaFailures.Load()
+}
+
+// apiKeyQuotaFailure logs a quota that could not be checked and applies the fail-open policy
+func apiKeyQuotaFailure(apiKeyID int64, err error) error {
+	log.SysErr("api-key-rate-limit", fmt.Errorf("api key %d: %w", apiKeyID, err))
+	if apiKeyQuotaFailOpen {
+		apiKeyQuotaFailures.Add(1)
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrAPIKeyQuotaUnavailable, err)
+}
+
+// CleanupAPIKeyUsage deletes counters of past windows
+func CleanupAPIKeyUsage(ctx context.Context) (int64, error) {
+	if apiKeyQuotaStore == nil {
+		return 0, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return apiKeyQuotaStore.Cleanup(ctx, time.Now().UTC().Truncate(apiKeyQuotaWindow))
+}
@@ -0,0 +1,103 @@
+package whatsapp
+
+import (
+	"context"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// Queued writes are committed in batches of up to this many, at least every flush interval
+const (
+	asyncWriteBatchSize     = 100
+	asyncWriteFlushInterval = 250 * time.Millisecond
+)
+
+// asyncWriter applies database writes queued from the event handler on one goroutine, in the
+// order they were queued. whatsmeow dispatches events serially, so the handler must not wait on
+// Postgres; readers see a write at most one flush interval late.
+type asyncWriter struct {
+	operation string
+	queue     chan asyncWrite
+}
+
+// asyncWrite is one change applied by the writer goroutine
+type asyncWrite struct {
+	deviceID string
+	apply    func(ctx context.Context, execer messageExecer) error
+}
+
+// newAsyncWriter starts a writer whose queue holds up to size writes; operation names it in logs
+func newAsyncWriter(operation string, size int) *asyncWriter {
+	w := &asyncWriter{operation: operation, queue: make(chan asyncWrite, size)}
+	go w.run()
+	return w
+}
+
+// enqueue hands a write to the writer goroutine. When the queue is full the write is dropped
+// rather than blocking the caller. A nil writer drops everything.
+func (w *asyncWriter) enqueue(deviceID string, apply func(ctx context.Context, execer messageExecer) error) {
+	if w == nil {
+		return
+	}
+	select {
+	case w.queue <- asyncWrite{deviceID: deviceID, apply: apply}:
+	default:
+		log.DeviceOp(deviceID, "", w.operation).Warn("Write queue is full, dropping write")
+	}
+}
+
+// run commits queued writes in batches, in the order they were queued
+func (w *asyncWriter) run() {
+	batch := make([]asyncWrite, 0, asyncWriteBatchSize)
+	ticker := time.NewTicker(asyncWriteFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case write := <-w.queue:
+			batch = append(batch, write)
+			if len(batch) < asyncWriteBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		w.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush applies a batch in one transaction. If any write fails the batch is rolled back and
+// replayed one write at a time, so one bad row does not lose the others.
+func (w *asyncWriter) flush(batch []asyncWrite) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), messageStoreWriteTimeout*2)
+	defer cancel()
+
+	if tx, err := db.BeginTx(ctx, nil); err == nil {
+		failed := false
+		for _, write := range batch {
+			if err := write.apply(ctx, tx); err != nil {
+				failed = true
+				break
+			}
+		}
+		if !failed && tx.Commit() == nil {
+			return
+		}
+		_ = tx.Rollback()
+	}
+
+	for _, write := range batch {
+		writeCtx, writeCancel := context.WithTimeout(context.Background(), messageStoreWriteTimeout)
+		if err := write.apply(writeCtx, db); err != nil {
+			log.DeviceOp(write.deviceID, "", w.operation).WithError(err).Warn("Failed to apply queued write")
+		}
+		writeCancel()
+	}
+}
@@ -0,0 +1,713 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+var (
+	// ErrCampaignNotFound is returned when a campaign does not exist for the device
+	ErrCampaignNotFound = errors.New("campaign not found")
+	// ErrCampaignStateConflict is returned when a pause/resume/cancel does not apply to the campaign's current status
+	ErrCampaignStateConflict = errors.New("campaign cannot change to the requested status")
+)
+
+// Campaign statuses
+const (
+	CampaignRunning   = "running"
+	CampaignPaused    = "paused"
+	CampaignCancelled = "cancelled"
+	CampaignCompleted = "completed"
+)
+
+// Campaign recipient statuses; delivered/read are set from receipts after a recipient was sent
+const (
+	RecipientQueued    = "queued"
+	RecipientSending   = "sending"
+	RecipientSent      = "sent"
+	RecipientDelivered = "delivered"
+	RecipientRead      = "read"
+	RecipientFailed    = "failed"
+)
+
+const (
+	// A recipient stays in "sending" for at most this long before a resumed worker retries it
+	campaignSendLease = 5 * time.Minute
+	// How long a worker waits before checking again whether its device reconnected
+	campaignDeviceWait = 15 * time.Second
+)
+
+var (
+	campaignDefaultInterval = 3 * time.Second
+	campaignMinInterval     = time.Second
+	campaignMaxRecipients   = 10000
+
+	campaignWorkersMu sync.Mutex
+	campaignWorkers   = make(map[int64]*campaignWorker)
+)
+
+// campaignWorker is the in-process goroutine sending one campaign
+type campaignWorker struct {
+	cancel context.CancelFunc
+}
+
+func loadCampaignConfig() {
+	campaignDefaultInterval = ParseOptionalDuration("CAMPAIGN_DEFAULT_INTERVAL", 3*time.Second)
+	campaignMinInterval = ParseOptionalDuration("CAMPAIGN_MIN_INTERVAL", time.Second)
+	campaignMaxRecipients = ParseOptionalInt("CAMPAIGN_MAX_RECIPIENTS", 10000, 1)
+	if campaignDefaultInterval < campaignMinInterval {
+		campaignDefaultInterval = campaignMinInterval
+	}
+}
+
+// CampaignMaxRecipients is the largest recipient list accepted for one campaign
+func CampaignMaxRecipients() int {
+	return campaignMaxRecipients
+}
+
+// CampaignProgress counts the campaign's recipients by status
+type CampaignProgress struct {
+	Total     int `json:"total"`
+	Queued    int `json:"queued"`
+	Sending   int `json:"sending"`
+	Sent      int `json:"sent"`
+	Delivered int `json:"delivered"`
+	Read      int `json:"read"`
+	Failed    int `json:"failed"`
+}
+
+// Campaign is one message sent to a list of recipients by a background worker
+type Campaign struct {
+	ID                 int64            `json:"id"`
+	DeviceID           string           `json:"device_id"`
+	Name               string           `json:"name"`
+	Message            string           `json:"message"`
+	Status             string           `json:"status"`
+	IntervalMS         int64            `json:"interval_ms"`
+	TypingSimulation   *bool            `json:"typing_simulation,omitempty"`
+	PresenceSimulation *bool            `json:"presence_simulation,omitempty"`
+	Progress           CampaignProgress `json:"progress"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+	CompletedAt        *time.Time       `json:"completed_at,omitempty"`
+}
+
+// CampaignRecipient is the delivery state of one campaign recipient
+type CampaignRecipient struct {
+	ID          int64      `json:"id"`
+	ChatJID     string     `json:"chat_jid"`
+	Status      string     `json:"status"`
+	MessageID   string     `json:"message_id,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+// CampaignInput describes a campaign to create
+type CampaignInput struct {
+	Name               string
+	Message            string
+	Recipients         []string
+	Interval           time.Duration
+	TypingSimulation   *bool
+	PresenceSimulation *bool
+}
+
+func ensureCampaignSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_campaigns (
+		id BIGSERIAL PRIMARY KEY,
+		device_id TEXT NOT NULL,
+		device_jid TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'running',
+		interval_ms BIGINT NOT NULL,
+		typing_simulation BOOLEAN,
+		presence_simulation BOOLEAN,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		completed_at TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_campaigns_device ON wa_campaigns (device_id, created_at)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS wa_campaign_recipients (
+		id BIGSERIAL PRIMARY KEY,
+		campaign_id BIGINT NOT NULL REFERENCES wa_campaigns(id) ON DELETE CASCADE,
+		chat_jid TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'queued',
+		message_id TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		locked_until TIMESTAMP,
+		sent_at TIMESTAMP,
+		delivered_at TIMESTAMP,
+		read_at TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_campaign_recipients_campaign ON wa_campaign_recipients (campaign_id, status, id)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_campaign_recipients_message ON wa_campaign_recipients (message_id) WHERE message_id <> ''`)
+	return err
+}
+
+// CreateCampaign stores the campaign with all recipients queued and starts its worker
+func CreateCampaign(ctx context.Context, jid string, deviceID string, input CampaignInput) (*Campaign, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	interval := input.Interval
+	if interval <= 0 {
+		interval = campaignDefaultInterval
+	}
+	if interval < campaignMinInterval {
+		interval = campaignMinInterval
+	}
+	recipients, err := json.Marshal(input.Recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO wa_campaigns (device_id, device_jid, name, message, status, interval_ms, typing_simulation, presence_simulation)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, deviceID, jid, input.Name, input.Message, CampaignRunning, interval.Milliseconds(), nullableBool(input.TypingSimulation), nullableBool(input.PresenceSimulation)).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO wa_campaign_recipients (campaign_id, chat_jid, status)
+		SELECT $1, r.chat_jid, $3
+		FROM jsonb_array_elements_text($2::jsonb) WITH ORDINALITY AS r(chat_jid, position)
+		ORDER BY r.position
+	`, id, string(recipients), RecipientQueued)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	startCampaignWorker(id)
+	return GetCampaign(ctx, deviceID, id)
+}
+
+func nullableBool(b *bool) interface{} {
+	if b == nil {
+		return nil
+	}
+	return *b
+}
+
+const campaignColumns = `c.id, c.device_id, c.name, c.message, c.status, c.interval_ms, c.typing_simulation, c.presence_simulation, c.created_at, c.updated_at, c.completed_at,
+	COUNT(r.id),
+	COUNT(r.id) FILTER (WHERE r.status = 'queued'),
+	COUNT(r.id) FILTER (WHERE r.status = 'sending'),
+	COUNT(r.id) FILTER (WHERE r.status = 'sent'),
+	COUNT(r.id) FILTER (WHERE r.status = 'delivered'),
+	COUNT(r.id) FILTER (WHERE r.status = 'read'),
+	COUNT(r.id) FILTER (WHERE r.status = 'failed')`
+
+func scanCampaign(row scheduledRowScanner) (*Campaign, error) {
+	var c Campaign
+	var typing, presence sql.NullBool
+	var completedAt sql.NullTime
+	p := &c.Progress
+	if err := row.Scan(&c.ID, &c.DeviceID, &c.Name, &c.Message, &c.Status, &c.IntervalMS, &typing, &presence, &c.CreatedAt, &c.UpdatedAt, &completedAt,
+		&p.Total, &p.Queued, &p.Sending, &p.Sent, &p.Delivered, &p.Read, &p.Failed); err != nil {
+		return nil, err
+	}
+	if typing.Valid {
+		c.TypingSimulation = &typing.Bool
+	}
+	if presence.Valid {
+		c.PresenceSimulation = &presence.Bool
+	}
+	if completedAt.Valid {
+		c.CompletedAt = &completedAt.Time
+	}
+	return &c, nil
+}
+
+// GetCampaign returns the campaign with its progress counters
+func GetCampaign(ctx context.Context, deviceID string, id int64) (*Campaign, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	c, err := scanCampaign(db.QueryRowContext(ctx, `
+		SELECT `+campaignColumns+`
+		FROM wa_campaigns c
+		LEFT JOIN wa_campaign_recipients r ON r.campaign_id = c.id
+		WHERE c.device_id = $1 AND c.id = $2
+		GROUP BY c.id
+	`, deviceID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCampaignNotFound
+	}
+	return c, err
+}
+
+// GetCampaigns lists the device's campaigns, newest first
+func GetCampaigns(ctx context.Context, deviceID string, limit int, offset int) ([]Campaign, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+campaignColumns+`
+		FROM wa_campaigns c
+		LEFT JOIN wa_campaign_recipients r ON r.campaign_id = c.id
+		WHERE c.device_id = $1
+		GROUP BY c.id
+		ORDER BY c.id DESC
+		LIMIT $2 OFFSET $3
+	`, deviceID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campaigns := []Campaign{}
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, *c)
+	}
+	return campaigns, rows.Err()
+}
+
+// GetCampaignRecipients pages through the campaign's recipients in list order.
+// A limit of 0 returns every recipient (used by the CSV export).
+func GetCampaignRecipients(ctx context.Context, deviceID string, id int64, status string, limit int, offset int) ([]CampaignRecipient, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := GetCampaign(ctx, deviceID, id); err != nil {
+		return nil, err
+	}
+	var rowLimit interface{}
+	if limit > 0 {
+		rowLimit = limit
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, chat_jid, status, message_id, error, sent_at, delivered_at, read_at
+		FROM wa_campaign_recipients
+		WHERE campaign_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id
+		LIMIT $3 OFFSET $4
+	`, id, status, rowLimit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients := []CampaignRecipient{}
+	for rows.Next() {
+		var r CampaignRecipient
+		var sentAt, deliveredAt, readAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.ChatJID, &r.Status, &r.MessageID, &r.Error, &sentAt, &deliveredAt, &readAt); err != nil {
+			return nil, err
+		}
+		if sentAt.Valid {
+			r.SentAt = &sentAt.Time
+		}
+		if deliveredAt.Valid {
+			r.DeliveredAt = &deliveredAt.Time
+		}
+		if readAt.Valid {
+			r.ReadAt = &readAt.Time
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, rows.Err()
+}
+
+// PauseCampaign stops sending after the current recipient; queued recipients stay queued
+func PauseCampaign(ctx context.Context, deviceID string, id int64) (*Campaign, error) {
+	if err := setCampaignStatus(ctx, deviceID, id, CampaignPaused, CampaignRunning); err != nil {
+		return nil, err
+	}
+	stopCampaignWorker(id)
+	return GetCampaign(ctx, deviceID, id)
+}
+
+// ResumeCampaign continues a paused campaign
+func ResumeCampaign(ctx context.Context, deviceID string, id int64) (*Campaign, error) {
+	if err := setCampaignStatus(ctx, deviceID, id, CampaignRunning, CampaignPaused); err != nil {
+		return nil, err
+	}
+	startCampaignWorker(id)
+	return GetCampaign(ctx, deviceID, id)
+}
+
+// CancelCampaign stops the campaign for good; recipients not sent yet are marked failed
+func CancelCampaign(ctx context.Context, deviceID string, id int64) (*Campaign, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := setCampaignStatus(ctx, deviceID, id, CampaignCancelled, CampaignRunning, CampaignPaused); err != nil {
+		return nil, err
+	}
+	stopCampaignWorker(id)
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE wa_campaign_recipients
+		SET status = $2, error = 'campaign cancelled', locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE campaign_id = $1 AND status = $3
+	`, id, RecipientFailed, RecipientQueued)
+	if err != nil {
+		return nil, err
+	}
+	return GetCampaign(ctx, deviceID, id)
+}
+
+func setCampaignStatus(ctx context.Context, deviceID string, id int64, status string, from ...string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return err
+	}
+	allowed, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	result, err := db.ExecContext(ctx, `
+		UPDATE wa_campaigns
+		SET status = $3, updated_at = CURRENT_TIMESTAMP,
+			completed_at = CASE WHEN $3 = 'cancelled' THEN CURRENT_TIMESTAMP ELSE completed_at END
+		WHERE device_id = $1 AND id = $2 AND status IN (SELECT jsonb_array_elements_text($4::jsonb))
+	`, deviceID, id, status, string(allowed))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		if _, err := GetCampaign(ctx, deviceID, id); err != nil {
+			return err
+		}
+		return ErrCampaignStateConflict
+	}
+	return nil
+}
+
+// ResumeRunningCampaigns starts workers for running campaigns that have none in this process,
+// which picks campaigns back up after a restart
+func ResumeRunningCampaigns(ctx context.Context) (int, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT id FROM wa_campaigns WHERE status = $1`, CampaignRunning)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	started := 0
+	for _, id := range ids {
+		if startCampaignWorker(id) {
+			started++
+		}
+	}
+	return started, nil
+}
+
+func startCampaignWorker(id int64) bool {
+	campaignWorkersMu.Lock()
+	defer campaignWorkersMu.Unlock()
+	if _, ok := campaignWorkers[id]; ok {
+		return false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &campaignWorker{cancel: cancel}
+	campaignWorkers[id] = w
+	go func() {
+		defer func() {
+			campaignWorkersMu.Lock()
+			if campaignWorkers[id] == w {
+				delete(campaignWorkers, id)
+			}
+			campaignWorkersMu.Unlock()
+			cancel()
+		}()
+		runCampaign(ctx, id)
+	}()
+	return true
+}
+
+func stopCampaignWorker(id int64) {
+	campaignWorkersMu.Lock()
+	w, ok := campaignWorkers[id]
+	delete(campaignWorkers, id)
+	campaignWorkersMu.Unlock()
+	if ok {
+		w.cancel()
+	}
+}
+
+type campaignTask struct {
+	deviceID  string
+	deviceJID string
+	message   string
+	status    string
+	interval  time.Duration
+	opts      *SendOptions
+}
+
+func loadCampaignTask(ctx context.Context, db *sql.DB, id int64) (*campaignTask, error) {
+	var t campaignTask
+	var intervalMS int64
+	var typing, presence sql.NullBool
+	err := db.QueryRowContext(ctx, `
+		SELECT device_id, device_jid, message, status, interval_ms, typing_simulation, presence_simulation
+		FROM wa_campaigns WHERE id = $1
+	`, id).Scan(&t.deviceID, &t.deviceJID, &t.message, &t.status, &intervalMS, &typing, &presence)
+	if err != nil {
+		return nil, err
+	}
+	t.interval = time.Duration(intervalMS) * time.Millisecond
+	t.opts = &SendOptions{}
+	if typing.Valid {
+		t.opts.TypingSimulation = &typing.Bool
+	}
+	if presence.Valid {
+		t.opts.PresenceSimulation = &presence.Bool
+	}
+	return &t, nil
+}
+
+// runCampaign sends to the campaign's queued recipients one at a time, waiting the campaign interval
+// (plus up to 50% jitter) between sends. It returns when the campaign is paused, cancelled or done.
+func runCampaign(ctx context.Context, id int64) {
+	db, err := openRoutingDB()
+	if err != nil {
+		log.SysErr("campaign-db", err)
+		return
+	}
+
+	for ctx.Err() == nil {
+		task, err := loadCampaignTask(ctx, db, id)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+				log.EvtErr("campaign", "load", "", err)
+			}
+			return
+		}
+		if task.status != CampaignRunning {
+			return
+		}
+
+		if err := ensureClientOK(getClientByDeviceID(task.deviceID)); err != nil {
+			if !sleepCtx(ctx, campaignDeviceWait) {
+				return
+			}
+			continue
+		}
+
+		recipientID, chatJID, err := claimCampaignRecipient(ctx, db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			if finishCampaignIfDone(db, id, task.deviceID) {
+				return
+			}
+			// Recipients still leased by another worker; check again later
+			if !sleepCtx(ctx, task.interval) {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			log.EvtErr("campaign", "claim", task.deviceID, err)
+			if !sleepCtx(ctx, campaignDeviceWait) {
+				return
+			}
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		msgID, sendErr := WhatsAppSendText(sendCtx, getClientJID(task.deviceJID, task.deviceID), task.deviceID, chatJID, task.message, task.opts)
+		cancel()
+		recordCampaignSend(db, recipientID, msgID, sendErr)
+		if sendErr != nil {
+			log.MessageOp(task.deviceID, "", "CampaignSend", chatJID).WithField("campaign_id", id).WithError(sendErr).Warn("Campaign message failed")
+		}
+
+		if !sleepCtx(ctx, jitterDuration(task.interval, task.interval*3/2)) {
+			return
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func claimCampaignRecipient(ctx context.Context, db *sql.DB, campaignID int64) (int64, string, error) {
+	now := time.Now().UTC()
+	var id int64
+	var chatJID string
+	err := db.QueryRowContext(ctx, `
+		UPDATE wa_campaign_recipients
+		SET status = $2, locked_until = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM wa_campaign_recipients
+			WHERE campaign_id = $1 AND (status = $4 OR (status = $2 AND locked_until < $5))
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, chat_jid
+	`, campaignID, RecipientSending, now.Add(campaignSendLease), RecipientQueued, now).Scan(&id, &chatJID)
+	return id, chatJID, err
+}
+
+func recordCampaignSend(db *sql.DB, recipientID int64, msgID string, sendErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var err error
+	if sendErr != nil {
+		_, err = db.ExecContext(ctx, `
+			UPDATE wa_campaign_recipients
+			SET status = $2, error = $3, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, recipientID, RecipientFailed, sendErr.Error())
+	} else {
+		_, err = db.ExecContext(ctx, `
+			UPDATE wa_campaign_recipients
+			SET status = $2, message_id = $3, sent_at = $4, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, recipientID, RecipientSent, msgID, time.Now().UTC())
+	}
+	if err != nil {
+		log.SysErr("campaign-record", err)
+	}
+}
+
+// finishCampaignIfDone marks the campaign completed once no recipient is queued or being sent
+func finishCampaignIfDone(db *sql.DB, id int64, deviceID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result, err := db.ExecContext(ctx, `
+		UPDATE wa_campaigns
+		SET status = $2, completed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $3 AND NOT EXISTS (
+			SELECT 1 FROM wa_campaign_recipients WHERE campaign_id = $1 AND status IN ($4, $5)
+		)
+	`, id, CampaignCompleted, CampaignRunning, RecipientQueued, RecipientSending)
+	if err != nil {
+		log.EvtErr("campaign", "complete", deviceID, err)
+		return false
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.EvtOK("campaign", "completed", deviceID)
+		return true
+	}
+	return false
+}
+
+// trackCampaignReceipt queues moving sent campaign recipients to delivered/read when receipts arrive
+func trackCampaignReceipt(deviceID string, evt *events.Receipt) {
+	var set, status string
+	var from []string
+	switch evt.Type {
+	case types.ReceiptTypeDelivered:
+		set = "delivered_at = COALESCE(r.delivered_at, $2)"
+		status, from = RecipientDelivered, []string{RecipientSent}
+	case types.ReceiptTypeRead, types.ReceiptTypePlayed:
+		// a read receipt can arrive without a delivery receipt before it
+		set = "delivered_at = COALESCE(r.delivered_at, $2), read_at = COALESCE(r.read_at, $2)"
+		status, from = RecipientRead, []string{RecipientSent, RecipientDelivered}
+	default:
+		return
+	}
+	if len(evt.MessageIDs) == 0 {
+		return
+	}
+	ids, err := json.Marshal(evt.MessageIDs)
+	if err != nil {
+		return
+	}
+	allowed, err := json.Marshal(from)
+	if err != nil {
+		return
+	}
+	ts := evt.Timestamp.UTC()
+	if evt.Timestamp.IsZero() {
+		ts = time.Now().UTC()
+	}
+	receiptWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+		_, err := execer.ExecContext(ctx, `
+			UPDATE wa_campaign_recipients r
+			SET status = $1, `+set+`, updated_at = CURRENT_TIMESTAMP
+			FROM wa_campaigns c
+			WHERE c.id = r.campaign_id AND c.device_id = $3
+				AND r.message_id IN (SELECT jsonb_array_elements_text($4::jsonb))
+				AND r.status IN (SELECT jsonb_array_elements_text($5::jsonb))
+		`, status, ts, deviceID, string(ids), string(allowed))
+		return err
+	})
+}
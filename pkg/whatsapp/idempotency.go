@@ -0,0 +1,221 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/idempotency"
+)
+
+var (
+	// ErrIdempotencyKeyMismatch is returned when a key is reused for a different request
+	ErrIdempotencyKeyMismatch = idempotency.ErrKeyMismatch
+	// ErrIdempotencyKeyInFlight is returned when the original request is still running after the wait timeout
+	ErrIdempotencyKeyInFlight = idempotency.ErrKeyInFlight
+)
+
+// IdempotencyKeyMaxLength is the longest Idempotency-Key header accepted
+const IdempotencyKeyMaxLength = idempotency.KeyMaxLength
+
+const (
+	// A key stays "processing" for at most this long, so a crashed request does not block its retries forever
+	idempotencyLease = 10 * time.Minute
+	// How often a duplicate re-checks a key held by another instance
+	idempotencyPollInterval = 500 * time.Millisecond
+)
+
+var (
+	idempotencyKeyTTL      = 24 * time.Hour
+	idempotencyWaitTimeout = 2 * time.Minute
+
+	// Requests holding a key in this process; duplicates wait on the channel instead of polling
+	idempotencyInFlightMu sync.Mutex
+	idempotencyInFlight   = make(map[string]chan struct{})
+)
+
+func loadIdempotencyConfig() {
+	idempotencyKeyTTL = ParseOptionalDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	idempotencyWaitTimeout = ParseOptionalDuration("IDEMPOTENCY_WAIT_TIMEOUT", 2*time.Minute)
+}
+
+// IdempotentResponse is the response stored for a key and replayed to retries
+type IdempotentResponse = idempotency.Response
+
+func ensureIdempotencySchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_idempotency_keys (
+		device_id TEXT NOT NULL,
+		idem_key TEXT NOT NULL,
+		request_hash TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'processing',
+		response_code INTEGER NOT NULL DEFAULT 0,
+		content_type TEXT NOT NULL DEFAULT '',
+		response_body BYTEA,
+		locked_until TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (device_id, idem_key)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_idempotency_keys_expires ON wa_idempotency_keys (expires_at)`)
+	return err
+}
+
+func idempotencyInFlightKey(deviceID string, key string) string {
+	return deviceID + "\x00" + key
+}
+
+// ClaimIdempotencyKey reserves key for a request identified by requestHash. It returns nil when
+// the caller now holds the key and must run the request, or the stored response when the key
+// already completed. While another request holds the key it waits for that request to finish.
+func ClaimIdempotencyKey(ctx context.Context, deviceID string, key string, requestHash string) (*IdempotentResponse, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(idempotencyWaitTimeout)
+	for {
+		now := time.Now().UTC()
+		// Expired keys are reused; a key stuck in processing past its lease is taken over by a retry of the same request
+		var claimed string
+		err := db.QueryRowContext(ctx, `
+			INSERT INTO wa_idempotency_keys (device_id, idem_key, request_hash, status, locked_until, expires_at, created_at)
+			VALUES ($1, $2, $3, 'processing', $4, $5, $6)
+			ON CONFLICT (device_id, idem_key) DO UPDATE SET
+				request_hash = EXCLUDED.request_hash,
+				status = 'processing',
+				response_code = 0,
+				content_type = '',
+				response_body = NULL,
+				locked_until = EXCLUDED.locked_until,
+				expires_at = EXCLUDED.expires_at,
+				created_at = EXCLUDED.created_at
+			WHERE wa_idempotency_keys.expires_at <= $6
+				OR (wa_idempotency_keys.status = 'processing'
+					AND wa_idempotency_keys.locked_until <= $6
+					AND wa_idempotency_keys.request_hash = EXCLUDED.request_hash)
+			RETURNING idem_key
+		`, deviceID, key, requestHash, now.Add(idempotencyLease), now.Add(idempotencyKeyTTL), now).Scan(&claimed)
+		if err == nil {
+			idempotencyInFlightMu.Lock()
+			idempotencyInFlight[idempotencyInFlightKey(deviceID, key)] = make(chan struct{})
+			idempotencyInFlightMu.Unlock()
+			return nil, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		var (
+			storedHash string
+			status     string
+			resp       IdempotentResponse
+		)
+		err = db.QueryRowContext(ctx, `
+			SELECT request_hash, status, response_code, content_type, COALESCE(response_body, ''::bytea)
+			FROM wa_idempotency_keys
+			WHERE device_id = $1 AND idem_key = $2
+		`, deviceID, key).Scan(&storedHash, &status, &resp.StatusCode, &resp.ContentType, &resp.Body)
+		if errors.Is(err, sql.ErrNoRows) {
+			// released between the insert and the read
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if storedHash != requestHash {
+			return nil, ErrIdempotencyKeyMismatch
+		}
+		if status == "completed" {
+			return &resp, nil
+		}
+
+		// The original request is still running, here or on another instance
+		if time.Now().After(deadline) {
+			return nil, ErrIdempotencyKeyInFlight
+		}
+		idempotencyInFlightMu.Lock()
+		done := idempotencyInFlight[idempotencyInFlightKey(deviceID, key)]
+		idempotencyInFlightMu.Unlock()
+		timer := time.NewTimer(idempotencyPollInterval)
+		select {
+		case <-done:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// CompleteIdempotencyKey stores the response of a claimed key so retries replay it until the key expires
+func CompleteIdempotencyKey(ctx context.Context, deviceID string, key string, resp IdempotentResponse) error {
+	defer finishIdempotencyKey(deviceID, key)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE wa_idempotency_keys
+		SET status = 'completed', response_code = $3, content_type = $4, response_body = $5
+		WHERE device_id = $1 AND idem_key = $2
+	`, deviceID, key, resp.StatusCode, resp.ContentType, resp.Body)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a claimed key whose request failed, so a retry runs it again
+func ReleaseIdempotencyKey(ctx context.Context, deviceID string, key string) error {
+	defer finishIdempotencyKey(deviceID, key)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `DELETE FROM wa_idempotency_keys WHERE device_id = $1 AND idem_key = $2 AND status = 'processing'`, deviceID, key)
+	return err
+}
+
+// finishIdempotencyKey wakes duplicates waiting on the key in this process
+func finishIdempotencyKey(deviceID string, key string) {
+	idempotencyInFlightMu.Lock()
+	defer idempotencyInFlightMu.Unlock()
+	inFlightKey := idempotencyInFlightKey(deviceID, key)
+	if done, ok := idempotencyInFlight[inFlightKey]; ok {
+		close(done)
+		delete(idempotencyInFlight, inFlightKey)
+	}
+}
+
+// CleanupExpiredIdempotencyKeys deletes keys past IDEMPOTENCY_KEY_TTL
+func CleanupExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM wa_idempotency_keys
+		WHERE expires_at <= $1 AND (status = 'completed' OR locked_until <= $1)
+	`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,220 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/blobstore"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhook"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// ErrMediaStorageNotConfigured is returned when auto-download is enabled without a working storage backend
+var ErrMediaStorageNotConfigured = errors.New("Media storage backend is not configured")
+
+var (
+	// Built on first use, so no directory is created or endpoint configured until a device archives media
+	mediaStorage   blobstore.Store
+	mediaStorageMu sync.Mutex
+
+	mediaStorageURLExpiry  = time.Hour
+	mediaArchiveMaxBytes   uint64
+	mediaArchiveSlots      chan struct{}
+	mediaAutoDownloadCache sync.Map // deviceID -> bool
+)
+
+func loadMediaArchiveConfig() {
+	mediaStorageURLExpiry = ParseOptionalDuration("WHATSAPP_MEDIA_STORAGE_URL_EXPIRY", time.Hour)
+	mediaArchiveMaxBytes = uint64(ParseOptionalInt("WHATSAPP_MEDIA_AUTO_DOWNLOAD_MAX_MB", 100, 1)) << 20
+	mediaArchiveSlots = make(chan struct{}, ParseOptionalInt("WHATSAPP_MEDIA_AUTO_DOWNLOAD_CONCURRENCY", 4, 1))
+}
+
+// getMediaStorage returns the storage backend, building it from the environment the first time
+func getMediaStorage() (blobstore.Store, error) {
+	mediaStorageMu.Lock()
+	defer mediaStorageMu.Unlock()
+	if mediaStorage != nil {
+		return mediaStorage, nil
+	}
+	store, err := blobstore.NewFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	mediaStorage = store
+	log.Sys("cfg", fmt.Sprintf("media_storage:%s url_expiry:%s", store.Name(), mediaStorageURLExpiry))
+	return store, nil
+}
+
+// GetMediaStorage returns the configured media storage backend, or nil when unavailable
+func GetMediaStorage() blobstore.Store {
+	store, err := getMediaStorage()
+	if err != nil {
+		log.SysErr("media-storage", err)
+		return nil
+	}
+	return store
+}
+
+func isMediaAutoDownloadEnabled(ctx context.Context, deviceID string) bool {
+	if cached, ok := mediaAutoDownloadCache.Load(deviceID); ok {
+		return cached.(bool)
+	}
+	enabled, err := GetDeviceMediaAutoDownload(ctx, deviceID)
+	if err != nil {
+		return false
+	}
+	mediaAutoDownloadCache.Store(deviceID, enabled)
+	return enabled
+}
+
+// WhatsAppSetMediaAutoDownload toggles archiving of incoming media for a device
+func WhatsAppSetMediaAutoDownload(ctx context.Context, deviceID string, enabled bool) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if enabled {
+		if _, err := getMediaStorage(); err != nil {
+			log.SysErr("media-storage", err)
+			return fmt.Errorf("%w: %v", ErrMediaStorageNotConfigured, err)
+		}
+	}
+	if err := SetDeviceMediaAutoDownload(ctx, deviceID, enabled); err != nil {
+		return err
+	}
+	mediaAutoDownloadCache.Store(deviceID, enabled)
+	return nil
+}
+
+// WhatsAppGetMediaAutoDownload reports whether incoming media is archived for a device and to which backend
+func WhatsAppGetMediaAutoDownload(ctx context.Context, deviceID string) (bool, string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	enabled, err := GetDeviceMediaAutoDownload(ctx, deviceID)
+	if err != nil {
+		return false, "", err
+	}
+	backend := ""
+	if enabled {
+		if store, err := getMediaStorage(); err == nil {
+			backend = store.Name()
+		}
+	}
+	return enabled, backend, nil
+}
+
+// mediaStorageKey lays archived media out per device and day
+func mediaStorageKey(deviceID string, e *events.Message, mimetype string) string {
+	ts := e.Info.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return fmt.Sprintf("%s/%s/%s", deviceID, ts.UTC().Format("2006/01/02"), mediaFileName(e.Info.ID, "", mimetype))
+}
+
+// archiveIncomingMedia downloads the media of a received message into the storage backend
+// and fires media.downloaded; it runs in the background and never blocks event handling
+func archiveIncomingMedia(jid string, deviceID string, currentJID string, e *events.Message) {
+	if e == nil || e.Message == nil {
+		return
+	}
+	media := downloadableFromMessage(e.Message)
+	content := extractMessageContent(e.Message)
+	if media == nil || content.Media == nil {
+		return
+	}
+	if !isMediaAutoDownloadEnabled(context.Background(), deviceID) {
+		return
+	}
+	store, err := getMediaStorage()
+	if err != nil {
+		log.MessageOp(deviceID, jid, "ArchiveMedia", e.Info.Chat.String()).WithField("message_id", e.Info.ID).WithError(err).Error("Media storage backend is not available")
+		return
+	}
+	if content.Media.FileLength > mediaArchiveMaxBytes {
+		log.MessageOp(deviceID, jid, "ArchiveMedia", e.Info.Chat.String()).WithField("message_id", e.Info.ID).WithField("size", content.Media.FileLength).Warn("Skipping media archive, file exceeds size limit")
+		return
+	}
+
+	go func() {
+		mediaArchiveSlots <- struct{}{}
+		defer func() { <-mediaArchiveSlots }()
+
+		client, err := currentClient(jid, deviceID)
+		if err != nil || client == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		data, err := client.Download(ctx, media)
+		if err != nil {
+			log.MessageOp(deviceID, jid, "ArchiveMedia", e.Info.Chat.String()).WithField("message_id", e.Info.ID).WithError(err).Warn("Failed to download media for archive")
+			return
+		}
+
+		mimetype := content.Media.Mimetype
+		if mimetype == "" {
+			mimetype = "application/octet-stream"
+		}
+		key := mediaStorageKey(deviceID, e, mimetype)
+		if err := store.Put(ctx, key, data, mimetype); err != nil {
+			log.MessageOp(deviceID, jid, "ArchiveMedia", e.Info.Chat.String()).WithField("message_id", e.Info.ID).WithError(err).Error("Failed to write media to storage")
+			return
+		}
+		signedURL, err := store.SignedURL(ctx, key, mediaStorageURLExpiry)
+		if err != nil {
+			log.MessageOp(deviceID, jid, "ArchiveMedia", e.Info.Chat.String()).WithField("message_id", e.Info.ID).WithError(err).Warn("Failed to sign media URL")
+		}
+
+		dispatchWebhook(deviceID, webhook.EventMediaDownloaded, map[string]interface{}{
+			"jid":             currentJID,
+			"message_id":      e.Info.ID,
+			"chat":            e.Info.Chat.String(),
+			"media_type":      content.Type,
+			"mimetype":        mimetype,
+			"file_name":       content.Media.FileName,
+			"size":            len(data),
+			"sha256":          hex.EncodeToString(media.GetFileSHA256()),
+			"storage_backend": store.Name(),
+			"storage_key":     key,
+			"url":             signedURL,
+			"url_expires_at":  time.Now().Add(mediaStorageURLExpiry).Unix(),
+		})
+		log.MessageOp(deviceID, jid, "ArchiveMedia", e.Info.Chat.String()).WithField("message_id", e.Info.ID).WithField("storage_key", key).Debug("Media archived")
+	}()
+}
+
+// GetDeviceMediaAutoDownload reads the per-device auto-download toggle
+func GetDeviceMediaAutoDownload(ctx context.Context, deviceID string) (bool, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return false, err
+	}
+	var enabled sql.NullBool
+	err = db.QueryRowContext(ctx, `SELECT media_auto_download FROM devices WHERE device_id = $1`, deviceID).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, errors.New("device not found")
+	}
+	if err != nil {
+		return false, err
+	}
+	return enabled.Valid && enabled.Bool, nil
+}
+
+// SetDeviceMediaAutoDownload stores the per-device auto-download toggle
+func SetDeviceMediaAutoDownload(ctx context.Context, deviceID string, enabled bool) error {
+	db, err := openRoutingDB()
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `UPDATE devices SET media_auto_download = $2 WHERE device_id = $1`, deviceID, enabled)
+	return err
+}
@@ -0,0 +1,270 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waMmsRetry"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+var (
+	// ErrMessageHasNoMedia is returned when a stored message carries no downloadable media
+	ErrMessageHasNoMedia = errors.New("Message does not contain downloadable media")
+	// ErrNoThumbnail is returned when a message has neither an embedded nor a downloadable thumbnail
+	ErrNoThumbnail = errors.New("no thumbnail available for this message")
+	// ErrMediaUnavailable is returned when the media expired on the CDN and the sender's phone could not re-upload it
+	ErrMediaUnavailable = errors.New("Media is no longer available")
+)
+
+const mediaRetryTimeout = 30 * time.Second
+
+// DownloadedMedia is decrypted media content with the metadata needed to serve it.
+// Body reads the content from a temporary file that is removed when Body is closed.
+type DownloadedMedia struct {
+	Body     io.ReadCloser
+	Size     int64
+	Mimetype string
+	FileName string
+}
+
+// tempMediaFile is a downloaded media file that deletes itself on Close
+type tempMediaFile struct {
+	*os.File
+}
+
+func newTempMediaFile() (*tempMediaFile, error) {
+	f, err := os.CreateTemp("", "wa-media-*")
+	if err != nil {
+		return nil, err
+	}
+	return &tempMediaFile{File: f}, nil
+}
+
+func (f *tempMediaFile) Close() error {
+	err := f.File.Close()
+	_ = os.Remove(f.Name())
+	return err
+}
+
+// mediaRetryWaiters routes media retry notifications to the download waiting for them
+var mediaRetryWaiters = struct {
+	sync.Mutex
+	m map[string]chan *events.MediaRetry
+}{m: make(map[string]chan *events.MediaRetry)}
+
+// updateStoredMediaMessage persists refreshed media paths so later downloads skip the retry
+func updateStoredMediaMessage(ctx context.Context, deviceID string, chatJID string, messageID string, msg *waE2E.Message) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return
+	}
+	raw, err := proto.Marshal(msg)
+	if err != nil {
+		return
+	}
+	var mediaJSON interface{}
+	if media := extractMessageContent(msg).Media; media != nil {
+		if encoded, err := json.Marshal(media); err == nil {
+			mediaJSON = string(encoded)
+		}
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE wa_messages SET raw_message = $1, media = COALESCE($2::jsonb, media), updated_at = NOW()
+		WHERE device_id = $3 AND chat_jid = $4 AND message_id = $5
+	`, raw, mediaJSON, deviceID, chatJID, messageID)
+	if err != nil {
+		log.MessageOp(deviceID, "", "UpdateStoredMedia", chatJID).WithError(err).Warn("Failed to update stored media path")
+	}
+}
+
+func mediaRetryKey(deviceID string, messageID string) string {
+	return deviceID + "|" + messageID
+}
+
+func registerMediaRetry(deviceID string, messageID string) (chan *events.MediaRetry, func()) {
+	key := mediaRetryKey(deviceID, messageID)
+	ch := make(chan *events.MediaRetry, 1)
+	mediaRetryWaiters.Lock()
+	mediaRetryWaiters.m[key] = ch
+	mediaRetryWaiters.Unlock()
+	return ch, func() {
+		mediaRetryWaiters.Lock()
+		if mediaRetryWaiters.m[key] == ch {
+			delete(mediaRetryWaiters.m, key)
+		}
+		mediaRetryWaiters.Unlock()
+	}
+}
+
+// deliverMediaRetry hands a media retry notification to a pending download, if any
+func deliverMediaRetry(deviceID string, evt *events.MediaRetry) {
+	mediaRetryWaiters.Lock()
+	ch, ok := mediaRetryWaiters.m[mediaRetryKey(deviceID, evt.MessageID)]
+	mediaRetryWaiters.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case ch <- evt:
+	default:
+	}
+}
+
+// setMediaDirectPath points a media message at a re-uploaded file
+func setMediaDirectPath(media whatsmeow.DownloadableMessage, directPath string) {
+	switch m := media.(type) {
+	case *waE2E.ImageMessage:
+		m.DirectPath, m.URL = proto.String(directPath), nil
+	case *waE2E.VideoMessage:
+		m.DirectPath, m.URL = proto.String(directPath), nil
+	case *waE2E.AudioMessage:
+		m.DirectPath, m.URL = proto.String(directPath), nil
+	case *waE2E.DocumentMessage:
+		m.DirectPath, m.URL = proto.String(directPath), nil
+	case *waE2E.StickerMessage:
+		m.DirectPath, m.URL = proto.String(directPath), nil
+	}
+}
+
+func isExpiredMediaError(err error) bool {
+	return errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404) || errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410)
+}
+
+// retryMediaDownload asks the sender's phone to re-upload expired media and downloads it again into file
+func retryMediaDownload(ctx context.Context, client *whatsmeow.Client, jid string, deviceID string, messageID string, stored *storedRawMessage, media whatsmeow.DownloadableMessage, file whatsmeow.File) error {
+	chat, err := types.ParseJID(stored.ChatJID)
+	if err != nil {
+		return fmt.Errorf("invalid stored chat JID: %w", err)
+	}
+	sender, err := types.ParseJID(stored.SenderJID)
+	if err != nil {
+		return fmt.Errorf("invalid stored sender JID: %w", err)
+	}
+
+	retries, unregister := registerMediaRetry(deviceID, messageID)
+	defer unregister()
+
+	info := &types.MessageInfo{
+		ID: messageID,
+		MessageSource: types.MessageSource{
+			Chat:     chat,
+			Sender:   sender,
+			IsFromMe: stored.IsFromMe,
+			IsGroup:  stored.IsGroup,
+		},
+	}
+	if err := client.SendMediaRetryReceipt(ctx, info, media.GetMediaKey()); err != nil {
+		return fmt.Errorf("Failed to request media re-upload: %w", err)
+	}
+	log.MessageOp(deviceID, "", "MediaRetry", stored.ChatJID).WithField("message_id", messageID).Info("Requested media re-upload")
+
+	var evt *events.MediaRetry
+	select {
+	case evt = <-retries:
+	case <-time.After(mediaRetryTimeout):
+		return fmt.Errorf("%w: timed out waiting for re-upload", ErrMediaUnavailable)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	notif, err := whatsmeow.DecryptMediaRetryNotification(evt, media.GetMediaKey())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMediaUnavailable, err)
+	}
+	if notif.GetResult() != waMmsRetry.MediaRetryNotification_SUCCESS || notif.GetDirectPath() == "" {
+		return fmt.Errorf("%w: re-upload result %s", ErrMediaUnavailable, notif.GetResult().String())
+	}
+
+	setMediaDirectPath(media, notif.GetDirectPath())
+	updateStoredMediaMessage(ctx, deviceID, stored.ChatJID, messageID, stored.Message)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	return WhatsAppDownloadMediaToFile(ctx, jid, deviceID, media, file)
+}
+
+// mediaFileName picks the file name to serve media under, deriving an extension from the mimetype when needed
+func mediaFileName(messageID string, fileName string, mimetype string) string {
+	if fileName != "" {
+		return fileName
+	}
+	base := strings.SplitN(mimetype, ";", 2)[0]
+	if exts, err := mime.ExtensionsByType(base); err == nil && len(exts) > 0 {
+		return messageID + exts[0]
+	}
+	return messageID
+}
+
+// WhatsAppDownloadStoredMedia downloads and decrypts the media of a stored message,
+// requesting a re-upload from the sender's phone when the CDN copy has expired
+func WhatsAppDownloadStoredMedia(ctx context.Context, jid string, deviceID string, messageID string) (*DownloadedMedia, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	client, err := currentClient(jid, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if err = WhatsAppIsClientOK(jid, deviceID); err != nil {
+		return nil, err
+	}
+
+	stored, err := loadStoredRawMessage(ctx, deviceID, "", messageID)
+	if err != nil {
+		return nil, err
+	}
+	media := downloadableFromMessage(stored.Message)
+	content := extractMessageContent(stored.Message)
+	if media == nil || content.Media == nil {
+		return nil, ErrMessageHasNoMedia
+	}
+
+	file, err := newTempMediaFile()
+	if err != nil {
+		return nil, err
+	}
+	err = WhatsAppDownloadMediaToFile(ctx, jid, deviceID, media, file)
+	if err != nil && isExpiredMediaError(err) {
+		err = retryMediaDownload(ctx, client, jid, deviceID, messageID, stored, media, file)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = file.Stat()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	mimetype := content.Media.Mimetype
+	if mimetype == "" {
+		mimetype = "application/octet-stream"
+	}
+	return &DownloadedMedia{
+		Body:     file,
+		Size:     info.Size(),
+		Mimetype: mimetype,
+		FileName: mediaFileName(messageID, content.Media.FileName, mimetype),
+	}, nil
+}
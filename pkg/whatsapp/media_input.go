@@ -0,0 +1,251 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Media kinds accepted by FetchMediaURL and DecodeMediaBase64; each maps to the size limit
+// and MIME allowlist of the matching WhatsAppSend* function
+const (
+	MediaKindImage    = "image"
+	MediaKindVideo    = "video"
+	MediaKindAudio    = "audio"
+	MediaKindDocument = "document"
+	MediaKindSticker  = "sticker"
+)
+
+var (
+	// ErrMediaFetchFailed is returned when media_url could not be downloaded from the remote server
+	ErrMediaFetchFailed = errors.New("failed to fetch media_url")
+	// ErrMediaURLNotAllowed is returned when media_url points to a private or local network address
+	ErrMediaURLNotAllowed = errors.New("private/local network URLs are not allowed")
+)
+
+// carrierGradeNAT is the shared address space of RFC 6598 (100.64.0.0/10), which net.IP.IsPrivate does not cover
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+const (
+	mediaFetchMaxRedirects = 5
+	mediaFetchDialTimeout  = 10 * time.Second
+)
+
+var (
+	mediaFetchTimeout = 30 * time.Second
+
+	// mediaFetchClient re-checks every resolved address at dial time, so a public hostname
+	// that resolves (or redirects) to a private address is refused as well
+	mediaFetchClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: mediaFetchDialTimeout,
+				Control: blockPrivateDial,
+			}).DialContext,
+			TLSHandshakeTimeout:   mediaFetchDialTimeout,
+			ResponseHeaderTimeout: mediaFetchDialTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= mediaFetchMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			return ValidateMediaURL(req.URL.String())
+		},
+	}
+)
+
+func loadMediaInputConfig() {
+	mediaFetchTimeout = ParseOptionalDuration("MEDIA_URL_FETCH_TIMEOUT", 30*time.Second)
+}
+
+// MediaInput is media taken from a send request, before it goes through the send pipeline.
+// For an upload_id or media_id, Data is only loaded when the bytes are needed (e.g. for send_at).
+type MediaInput struct {
+	Data     []byte
+	MimeType string
+	FileName string
+	Source   string
+	UploadID string
+	MediaID  int64
+	Size     int64
+}
+
+// Stored reports whether the media is already on the server and is sent with WhatsAppSendStoredMedia
+func (m *MediaInput) Stored() bool {
+	return m.UploadID != "" || m.MediaID != 0
+}
+
+func mediaKindLimits(kind string) (int64, map[string]bool) {
+	switch kind {
+	case MediaKindImage:
+		return maxImageBytes, allowedImageMimes
+	case MediaKindVideo:
+		return maxVideoBytes, allowedVideoMimes
+	case MediaKindAudio:
+		return maxAudioBytes, allowedAudioMimes
+	case MediaKindSticker:
+		return maxImageBytes, stickerSourceMimes
+	}
+	return maxDocumentBytes, allowedDocumentMimes
+}
+
+// isBlockedMediaIP reports whether media_url may not reach ip: loopback, private, link-local,
+// carrier-grade NAT and unspecified addresses are refused
+func isBlockedMediaIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || carrierGradeNAT.Contains(ip)
+}
+
+func blockPrivateDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isBlockedMediaIP(ip) {
+		return ErrMediaURLNotAllowed
+	}
+	return nil
+}
+
+// ValidateMediaURL applies the same rules as webhook URLs: HTTPS only and no private or local hosts
+func ValidateMediaURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid media_url: %w", err)
+	}
+	if u.Scheme != "https" {
+		return errors.New("only HTTPS URLs are allowed for media_url")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return errors.New("invalid media_url: missing host")
+	}
+	if host == "localhost" || host == "0.0.0.0" {
+		return ErrMediaURLNotAllowed
+	}
+	if ip := net.ParseIP(host); ip != nil && isBlockedMediaIP(ip) {
+		return ErrMediaURLNotAllowed
+	}
+	return nil
+}
+
+func checkMediaMime(kind string, data []byte, hinted string, allowed map[string]bool) (string, error) {
+	mimeType := detectMime(data, hinted)
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mediaType
+	}
+	if !allowed[mimeType] {
+		return "", fmt.Errorf("%s MIME type %s is not allowed", kind, mimeType)
+	}
+	return mimeType, nil
+}
+
+// FetchMediaURL downloads media for a send request. The download is bounded by the size
+// limit of the media kind and MEDIA_URL_FETCH_TIMEOUT, and the MIME type must be allowed.
+func FetchMediaURL(ctx context.Context, rawURL string, kind string) (*MediaInput, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ValidateMediaURL(rawURL); err != nil {
+		return nil, err
+	}
+	limit, allowed := mediaKindLimits(kind)
+
+	ctx, cancel := context.WithTimeout(ctx, mediaFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media_url: %w", err)
+	}
+	resp, err := mediaFetchClient.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrMediaURLNotAllowed) {
+			return nil, ErrMediaURLNotAllowed
+		}
+		return nil, fmt.Errorf("%w: %v", ErrMediaFetchFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%w: remote server returned %d", ErrMediaFetchFailed, resp.StatusCode)
+	}
+	if err := enforceSizeLimit(kind, resp.ContentLength, limit); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMediaFetchFailed, err)
+	}
+	if err := enforceSizeLimit(kind, int64(len(data)), limit); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty response body", ErrMediaFetchFailed)
+	}
+	mimeType, err := checkMediaMime(kind, data, resp.Header.Get("Content-Type"), allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := path.Base(resp.Request.URL.Path)
+	if fileName == "/" || fileName == "." {
+		fileName = ""
+	}
+	return &MediaInput{Data: data, MimeType: mimeType, FileName: fileName, Source: "url", Size: int64(len(data))}, nil
+}
+
+// DecodeMediaBase64 decodes media sent inline as plain base64 or as a data URI
+// (data:image/png;base64,...). The same size limits and MIME allowlists apply.
+func DecodeMediaBase64(raw string, kind string) (*MediaInput, error) {
+	raw = strings.TrimSpace(raw)
+	hinted := ""
+	if strings.HasPrefix(raw, "data:") {
+		header, payload, ok := strings.Cut(raw, ",")
+		if !ok || !strings.HasSuffix(header, ";base64") {
+			return nil, errors.New("media_base64 data URI must be base64 encoded")
+		}
+		hinted = strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+		raw = payload
+	}
+	raw = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, raw)
+
+	limit, allowed := mediaKindLimits(kind)
+	// Reject oversized payloads before decoding them
+	decodedLen := int64(base64.StdEncoding.DecodedLen(len(raw))) - int64(strings.Count(raw[max(0, len(raw)-2):], "="))
+	if err := enforceSizeLimit(kind, decodedLen, limit); err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.New("media_base64 is not valid base64")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("media_base64 is empty")
+	}
+	if err := enforceSizeLimit(kind, int64(len(data)), limit); err != nil {
+		return nil, err
+	}
+	mimeType, err := checkMediaMime(kind, data, hinted, allowed)
+	if err != nil {
+		return nil, err
+	}
+	return &MediaInput{Data: data, MimeType: mimeType, Source: "base64", Size: int64(len(data))}, nil
+}
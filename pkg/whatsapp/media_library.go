@@ -0,0 +1,409 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/transcode"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+var (
+	// ErrLibraryMediaNotFound is returned when a media_id does not exist for the device
+	ErrLibraryMediaNotFound = errors.New("media not found")
+	// ErrLibraryMediaInvalid is returned when media added to the library fails validation
+	ErrLibraryMediaInvalid = errors.New("invalid media")
+)
+
+// WhatsApp removes uploaded media from its CDN after a while, so a stored upload is
+// only reused for this long before the file is uploaded again
+var mediaLibraryUploadTTL = 7 * 24 * time.Hour
+
+func loadMediaLibraryConfig() {
+	mediaLibraryUploadTTL = ParseOptionalDuration("MEDIA_LIBRARY_UPLOAD_TTL", 7*24*time.Hour)
+}
+
+// LibraryMedia is a file kept per device so it can be sent many times by media_id. The
+// WhatsApp upload (and image thumbnail) is stored with it and reused until MEDIA_LIBRARY_UPLOAD_TTL.
+type LibraryMedia struct {
+	ID              int64      `json:"media_id"`
+	DeviceID        string     `json:"device_id"`
+	MediaType       string     `json:"media_type"`
+	MimeType        string     `json:"mimetype"`
+	FileName        string     `json:"filename,omitempty"`
+	Size            int64      `json:"size"`
+	SHA256          string     `json:"sha256"`
+	HasThumbnail    bool       `json:"has_thumbnail"`
+	UploadedAt      *time.Time `json:"uploaded_at,omitempty"`
+	UploadExpiresAt *time.Time `json:"upload_expires_at,omitempty"`
+	Deduplicated    bool       `json:"deduplicated,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	thumbnail       []byte
+	uploaded        *storedMediaUpload
+	thumbUploaded   *storedMediaUpload
+}
+
+func ensureMediaLibrarySchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_media_library (
+		id BIGSERIAL PRIMARY KEY,
+		device_id TEXT NOT NULL,
+		media_type TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		file_name TEXT NOT NULL DEFAULT '',
+		size BIGINT NOT NULL,
+		sha256 TEXT NOT NULL,
+		data BYTEA NOT NULL,
+		thumbnail BYTEA,
+		wa_upload JSONB,
+		wa_thumbnail JSONB,
+		uploaded_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (device_id, media_type, sha256)
+	)`)
+	return err
+}
+
+const libraryMediaColumns = `id, device_id, media_type, mime_type, file_name, size, sha256, thumbnail, wa_upload, wa_thumbnail, uploaded_at, created_at, updated_at`
+
+func scanLibraryMedia(row scheduledRowScanner) (*LibraryMedia, error) {
+	var m LibraryMedia
+	var waUpload, waThumbnail []byte
+	var uploadedAt sql.NullTime
+	if err := row.Scan(&m.ID, &m.DeviceID, &m.MediaType, &m.MimeType, &m.FileName, &m.Size, &m.SHA256, &m.thumbnail, &waUpload, &waThumbnail, &uploadedAt, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return nil, err
+	}
+	m.HasThumbnail = len(m.thumbnail) > 0
+	if uploadedAt.Valid {
+		m.setUploadedAt(uploadedAt.Time)
+	}
+	if len(waUpload) > 0 {
+		m.uploaded = &storedMediaUpload{}
+		if err := json.Unmarshal(waUpload, m.uploaded); err != nil {
+			return nil, fmt.Errorf("failed to decode media upload: %w", err)
+		}
+	}
+	if len(waThumbnail) > 0 {
+		m.thumbUploaded = &storedMediaUpload{}
+		if err := json.Unmarshal(waThumbnail, m.thumbUploaded); err != nil {
+			return nil, fmt.Errorf("failed to decode thumbnail upload: %w", err)
+		}
+	}
+	return &m, nil
+}
+
+func (m *LibraryMedia) setUploadedAt(t time.Time) {
+	expires := t.Add(mediaLibraryUploadTTL)
+	m.UploadedAt = &t
+	m.UploadExpiresAt = &expires
+}
+
+// needsUpload reports whether the stored WhatsApp upload is missing or may have left the CDN
+func (m *LibraryMedia) needsUpload() bool {
+	return m.uploaded == nil || m.UploadExpiresAt == nil || !time.Now().UTC().Before(*m.UploadExpiresAt)
+}
+
+// AddLibraryMedia stores media in the device's library and uploads it to WhatsApp. Content is
+// deduplicated by SHA-256: adding the same bytes again returns the existing entry with
+// Deduplicated set. If the upload fails the entry is kept and uploaded on its first send.
+func AddLibraryMedia(ctx context.Context, jid string, deviceID string, kind string, data []byte, mimeType string, fileName string) (*LibraryMedia, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !IsValidMediaKind(kind) {
+		return nil, fmt.Errorf("%w: media_type must be one of image, video, audio, document, sticker", ErrLibraryMediaInvalid)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: media is empty", ErrLibraryMediaInvalid)
+	}
+	limit, allowed := mediaKindLimits(kind)
+	if err := enforceSizeLimit(kind, int64(len(data)), limit); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLibraryMediaInvalid, err)
+	}
+	mimeType, err := checkMediaMime(kind, data, mimeType, allowed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLibraryMediaInvalid, err)
+	}
+	if kind == MediaKindSticker {
+		// Stickers are stored as the WebP that is sent, so sends can reuse the upload
+		sticker, err := transcode.BuildSticker(data, transcode.StickerMetadata{})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrLibraryMediaInvalid, err)
+		}
+		data, mimeType = sticker.Data, "image/webp"
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if existing, err := findLibraryMedia(ctx, db, deviceID, kind, hash); err != nil || existing != nil {
+		return existing, err
+	}
+
+	var thumbnail []byte
+	if kind == MediaKindImage {
+		if thumbnail, err = jpegThumbnail(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrLibraryMediaInvalid, err)
+		}
+	}
+
+	m := &LibraryMedia{
+		DeviceID:     deviceID,
+		MediaType:    kind,
+		MimeType:     mimeType,
+		FileName:     fileName,
+		Size:         int64(len(data)),
+		SHA256:       hash,
+		HasThumbnail: len(thumbnail) > 0,
+		thumbnail:    thumbnail,
+	}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO wa_media_library (device_id, media_type, mime_type, file_name, size, sha256, data, thumbnail)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (device_id, media_type, sha256) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`, deviceID, kind, mimeType, fileName, m.Size, hash, data, thumbnail).Scan(&m.ID, &m.CreatedAt, &m.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Added concurrently by another request
+		return findLibraryMedia(ctx, db, deviceID, kind, hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := currentClient(jid, deviceID)
+	if err == nil {
+		err = ensureClientOK(client)
+	}
+	if err == nil {
+		err = uploadLibraryMedia(ctx, client, db, m, data)
+	}
+	if err != nil {
+		log.DeviceOp(deviceID, jid, "AddLibraryMedia").WithField("media_id", m.ID).WithError(err).Warn("Library media stored, WhatsApp upload deferred to first send")
+	}
+	return m, nil
+}
+
+func findLibraryMedia(ctx context.Context, db *sql.DB, deviceID string, kind string, hash string) (*LibraryMedia, error) {
+	m, err := scanLibraryMedia(db.QueryRowContext(ctx, `
+		SELECT `+libraryMediaColumns+`
+		FROM wa_media_library
+		WHERE device_id = $1 AND media_type = $2 AND sha256 = $3
+	`, deviceID, kind, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Deduplicated = true
+	return m, nil
+}
+
+// uploadLibraryMedia uploads the file (and thumbnail) to WhatsApp and stores the result; data is
+// loaded from the database when nil
+func uploadLibraryMedia(ctx context.Context, client *whatsmeow.Client, db *sql.DB, m *LibraryMedia, data []byte) error {
+	if data == nil {
+		if err := db.QueryRowContext(ctx, `SELECT data FROM wa_media_library WHERE id = $1`, m.ID).Scan(&data); err != nil {
+			return err
+		}
+	}
+	resp, err := client.Upload(ctx, data, whatsmeowMediaType(m.MediaType))
+	if err != nil {
+		return errors.New("Error While Uploading Media to WhatsApp Server")
+	}
+	uploaded := newStoredMediaUpload(resp)
+	raw, err := json.Marshal(uploaded)
+	if err != nil {
+		return err
+	}
+
+	var thumbUploaded *storedMediaUpload
+	var thumbRaw sql.NullString
+	if len(m.thumbnail) > 0 {
+		resp, err := client.Upload(ctx, m.thumbnail, whatsmeow.MediaLinkThumbnail)
+		if err != nil {
+			return errors.New("Error while Uploading Image Thumbnail to WhatsApp Server")
+		}
+		thumbUploaded = newStoredMediaUpload(resp)
+		b, err := json.Marshal(thumbUploaded)
+		if err != nil {
+			return err
+		}
+		thumbRaw = sql.NullString{String: string(b), Valid: true}
+	}
+
+	now := time.Now().UTC()
+	_, err = db.ExecContext(ctx, `
+		UPDATE wa_media_library
+		SET wa_upload = $2::jsonb, wa_thumbnail = $3::jsonb, uploaded_at = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, m.ID, string(raw), thumbRaw, now)
+	if err != nil {
+		return err
+	}
+	m.uploaded = uploaded
+	m.thumbUploaded = thumbUploaded
+	m.setUploadedAt(now)
+	return nil
+}
+
+// GetLibraryMediaList lists the device's library, newest first, optionally filtered by media type
+func GetLibraryMediaList(ctx context.Context, deviceID string, kind string, limit int, offset int) ([]LibraryMedia, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+libraryMediaColumns+`
+		FROM wa_media_library
+		WHERE device_id = $1 AND ($2 = '' OR media_type = $2)
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4
+	`, deviceID, kind, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []LibraryMedia{}
+	for rows.Next() {
+		m, err := scanLibraryMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, *m)
+	}
+	return media, rows.Err()
+}
+
+// GetLibraryMedia returns one library entry of the device, without its bytes
+func GetLibraryMedia(ctx context.Context, deviceID string, id int64) (*LibraryMedia, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	m, err := scanLibraryMedia(db.QueryRowContext(ctx, `
+		SELECT `+libraryMediaColumns+`
+		FROM wa_media_library
+		WHERE device_id = $1 AND id = $2
+	`, deviceID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLibraryMediaNotFound
+	}
+	return m, err
+}
+
+// DeleteLibraryMedia removes a library entry; messages already sent are not affected
+func DeleteLibraryMedia(ctx context.Context, deviceID string, id int64) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx, `DELETE FROM wa_media_library WHERE device_id = $1 AND id = $2`, deviceID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLibraryMediaNotFound
+	}
+	return nil
+}
+
+// LibraryMediaInput resolves the media_id of a send request. The entry must be of the same media
+// type as the endpoint; the bytes are only read when withData is set.
+func LibraryMediaInput(ctx context.Context, deviceID string, id int64, kind string, withData bool) (*MediaInput, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	m, err := GetLibraryMedia(ctx, deviceID, id)
+	if err != nil {
+		return nil, err
+	}
+	if m.MediaType != kind {
+		return nil, fmt.Errorf("media_id is a %s, not %s", m.MediaType, kind)
+	}
+	input := &MediaInput{MimeType: m.MimeType, FileName: m.FileName, Source: "library", MediaID: m.ID, Size: m.Size}
+	if withData {
+		db, err := openRoutingDB()
+		if err != nil {
+			return nil, err
+		}
+		if err := db.QueryRowContext(ctx, `SELECT data FROM wa_media_library WHERE id = $1`, m.ID).Scan(&input.Data); err != nil {
+			return nil, err
+		}
+	}
+	return input, nil
+}
+
+// WhatsAppSendLibraryMedia sends a library entry, reusing its WhatsApp upload while it is
+// younger than MEDIA_LIBRARY_UPLOAD_TTL and uploading it again otherwise
+func WhatsAppSendLibraryMedia(ctx context.Context, jid string, deviceID string, rjid string, id int64, sendOpts StoredMediaOptions, opts *SendOptions) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	client, err := currentClient(jid, deviceID)
+	if err != nil {
+		return "", err
+	}
+	if err = ensureClientOK(client); err != nil {
+		return "", err
+	}
+	m, err := GetLibraryMedia(ctx, deviceID, id)
+	if err != nil {
+		return "", err
+	}
+	remoteJID, err := WhatsAppCheckJID(ctx, jid, deviceID, rjid)
+	if err != nil {
+		return "", err
+	}
+	if err := waitRateLimit(ctx, deviceID); err != nil {
+		return "", err
+	}
+	cleanup := beginPresenceSimulation(ctx, jid, deviceID, remoteJID, m.MediaType == MediaKindAudio, opts)
+	defer cleanup()
+
+	if m.needsUpload() {
+		db, err := openRoutingDB()
+		if err != nil {
+			return "", err
+		}
+		if err := uploadLibraryMedia(ctx, client, db, m, nil); err != nil {
+			return "", err
+		}
+	}
+	fileName := sendOpts.FileName
+	if fileName == "" {
+		fileName = m.FileName
+	}
+	msgContent := uploadedMediaMessage(m.MediaType, m.MimeType, fileName, m.uploaded, m.thumbnail, m.thumbUploaded, sendOpts)
+
+	msgExtra := whatsmeow.SendRequestExtra{ID: client.GenerateMessageID()}
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
+	if err != nil {
+		return "", err
+	}
+	return msgExtra.ID, nil
+}
@@ -0,0 +1,637 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sunshineplan/imgconv"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrMediaUploadNotFound is returned when an upload does not exist for the device or has expired
+	ErrMediaUploadNotFound = errors.New("upload not found")
+	// ErrMediaUploadOffsetMismatch is returned when a chunk does not start at the current upload offset
+	ErrMediaUploadOffsetMismatch = errors.New("upload offset does not match")
+	// ErrMediaUploadComplete is returned when appending to an upload that already has all its bytes
+	ErrMediaUploadComplete = errors.New("upload is already complete")
+	// ErrMediaUploadIncomplete is returned when sending an upload that is still receiving chunks
+	ErrMediaUploadIncomplete = errors.New("upload is not complete")
+	// ErrMediaUploadRejected is returned when a chunk or the finished file fails validation
+	ErrMediaUploadRejected = errors.New("upload rejected")
+)
+
+// Media upload statuses
+const (
+	// MediaUploadReceiving means chunks are still being appended
+	MediaUploadReceiving = "receiving"
+	// MediaUploadComplete means every byte is on disk but the WhatsApp upload has not succeeded yet
+	MediaUploadComplete = "complete"
+	// MediaUploadReady means the file is uploaded to WhatsApp and can be sent without re-uploading
+	MediaUploadReady = "ready"
+)
+
+var (
+	mediaUploadDir = "./data/uploads"
+	mediaUploadTTL = 24 * time.Hour
+	// One append at a time per upload; the offset check in the database covers the rest
+	mediaUploadLocks sync.Map
+)
+
+func loadMediaUploadConfig() {
+	if dir := strings.TrimSpace(os.Getenv("MEDIA_UPLOAD_DIR")); dir != "" {
+		mediaUploadDir = dir
+	}
+	mediaUploadTTL = ParseOptionalDuration("MEDIA_UPLOAD_TTL", 24*time.Hour)
+}
+
+// MediaUpload is a resumable upload; the file is appended on disk chunk by chunk and
+// then streamed to WhatsApp, so the returned upload_id can be sent like a file
+type MediaUpload struct {
+	ID        string    `json:"upload_id"`
+	DeviceID  string    `json:"device_id"`
+	MediaType string    `json:"media_type"`
+	FileName  string    `json:"filename,omitempty"`
+	MimeType  string    `json:"mimetype,omitempty"`
+	Length    int64     `json:"upload_length"`
+	Offset    int64     `json:"upload_offset"`
+	Status    string    `json:"status"`
+	LastError string    `json:"last_error,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	deviceJID string
+	uploaded  *storedMediaUpload
+}
+
+// storedMediaUpload keeps the fields of whatsmeow.UploadResponse needed to build a media message
+type storedMediaUpload struct {
+	URL           string `json:"url"`
+	DirectPath    string `json:"direct_path"`
+	MediaKey      []byte `json:"media_key"`
+	FileEncSHA256 []byte `json:"file_enc_sha256"`
+	FileSHA256    []byte `json:"file_sha256"`
+	FileLength    uint64 `json:"file_length"`
+}
+
+func newStoredMediaUpload(resp whatsmeow.UploadResponse) *storedMediaUpload {
+	return &storedMediaUpload{
+		URL:           resp.URL,
+		DirectPath:    resp.DirectPath,
+		MediaKey:      resp.MediaKey,
+		FileEncSHA256: resp.FileEncSHA256,
+		FileSHA256:    resp.FileSHA256,
+		FileLength:    resp.FileLength,
+	}
+}
+
+func ensureMediaUploadSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_media_uploads (
+		id TEXT PRIMARY KEY,
+		device_id TEXT NOT NULL,
+		device_jid TEXT NOT NULL DEFAULT '',
+		media_type TEXT NOT NULL,
+		file_name TEXT NOT NULL DEFAULT '',
+		mime_type TEXT NOT NULL DEFAULT '',
+		upload_length BIGINT NOT NULL,
+		upload_offset BIGINT NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'receiving',
+		wa_upload JSONB,
+		last_error TEXT NOT NULL DEFAULT '',
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_media_uploads_expires ON wa_media_uploads (expires_at)`)
+	return err
+}
+
+// IsValidMediaKind reports whether kind is one of the MediaKind* constants
+func IsValidMediaKind(kind string) bool {
+	switch kind {
+	case MediaKindImage, MediaKindVideo, MediaKindAudio, MediaKindDocument, MediaKindSticker:
+		return true
+	}
+	return false
+}
+
+func mediaUploadPath(id string) string {
+	return filepath.Join(mediaUploadDir, id+".part")
+}
+
+func lockMediaUpload(id string) func() {
+	mu, _ := mediaUploadLocks.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// CreateMediaUpload starts a resumable upload of length bytes. The length is checked
+// against the size limit of the media type up front.
+func CreateMediaUpload(ctx context.Context, jid string, deviceID string, kind string, length int64, fileName string) (*MediaUpload, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !IsValidMediaKind(kind) {
+		return nil, fmt.Errorf("%w: media_type must be one of image, video, audio, document, sticker", ErrMediaUploadRejected)
+	}
+	if length <= 0 {
+		return nil, fmt.Errorf("%w: upload_length must be greater than 0", ErrMediaUploadRejected)
+	}
+	limit, _ := mediaKindLimits(kind)
+	if err := enforceSizeLimit(kind, length, limit); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMediaUploadRejected, err)
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(mediaUploadDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	id := uuid.NewString()
+	f, err := os.Create(mediaUploadPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	_ = f.Close()
+
+	u := &MediaUpload{
+		ID:        id,
+		DeviceID:  deviceID,
+		MediaType: kind,
+		FileName:  fileName,
+		Length:    length,
+		Status:    MediaUploadReceiving,
+		ExpiresAt: time.Now().UTC().Add(mediaUploadTTL),
+	}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO wa_media_uploads (id, device_id, device_jid, media_type, file_name, upload_length, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at, updated_at
+	`, id, deviceID, jid, kind, fileName, length, MediaUploadReceiving, u.ExpiresAt).Scan(&u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		_ = os.Remove(mediaUploadPath(id))
+		return nil, err
+	}
+	return u, nil
+}
+
+const mediaUploadColumns = `id, device_id, device_jid, media_type, file_name, mime_type, upload_length, upload_offset, status, wa_upload, last_error, expires_at, created_at, updated_at`
+
+func scanMediaUpload(row scheduledRowScanner) (*MediaUpload, error) {
+	var u MediaUpload
+	var waUpload []byte
+	if err := row.Scan(&u.ID, &u.DeviceID, &u.deviceJID, &u.MediaType, &u.FileName, &u.MimeType, &u.Length, &u.Offset, &u.Status, &waUpload, &u.LastError, &u.ExpiresAt, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if len(waUpload) > 0 {
+		u.uploaded = &storedMediaUpload{}
+		if err := json.Unmarshal(waUpload, u.uploaded); err != nil {
+			return nil, fmt.Errorf("failed to decode media upload: %w", err)
+		}
+	}
+	return &u, nil
+}
+
+// GetMediaUpload returns an upload of the device; expired uploads are reported as not found
+func GetMediaUpload(ctx context.Context, deviceID string, id string) (*MediaUpload, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrMediaUploadNotFound
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	u, err := scanMediaUpload(db.QueryRowContext(ctx, `
+		SELECT `+mediaUploadColumns+`
+		FROM wa_media_uploads
+		WHERE device_id = $1 AND id = $2 AND expires_at > $3
+	`, deviceID, id, time.Now().UTC()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMediaUploadNotFound
+	}
+	return u, err
+}
+
+// AppendMediaUpload writes a chunk at offset, which must equal the current upload offset.
+// The chunk that completes the upload also checks the MIME type and uploads the file to WhatsApp;
+// if that upload fails the bytes are kept and it is retried when the upload is sent.
+func AppendMediaUpload(ctx context.Context, deviceID string, id string, offset int64, chunk io.Reader) (*MediaUpload, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	unlock := lockMediaUpload(id)
+	defer unlock()
+
+	u, err := GetMediaUpload(ctx, deviceID, id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Status != MediaUploadReceiving {
+		return nil, ErrMediaUploadComplete
+	}
+	if offset != u.Offset {
+		return nil, ErrMediaUploadOffsetMismatch
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(mediaUploadPath(id), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	remaining := u.Length - u.Offset
+	written, err := f.Seek(u.Offset, io.SeekStart)
+	if err == nil {
+		written, err = io.Copy(f, io.LimitReader(chunk, remaining+1))
+	}
+	if err == nil && written > remaining {
+		err = fmt.Errorf("%w: chunk exceeds upload_length (%d bytes remaining)", ErrMediaUploadRejected, remaining)
+	}
+	if err != nil {
+		// Drop the partial chunk so the stored offset stays valid for a retry
+		_ = f.Truncate(u.Offset)
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write upload file: %w", err)
+	}
+
+	u.Offset += written
+	if u.Offset == u.Length {
+		mimeType, err := detectUploadMime(u)
+		if err != nil {
+			// The file can never be sent, so there is nothing to resume
+			_ = DeleteMediaUpload(ctx, deviceID, id)
+			return nil, err
+		}
+		u.MimeType = mimeType
+		u.Status = MediaUploadComplete
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE wa_media_uploads
+		SET upload_offset = $3, status = $4, mime_type = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE device_id = $1 AND id = $2
+	`, deviceID, id, u.Offset, u.Status, u.MimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Status == MediaUploadComplete {
+		if err := uploadMediaToWhatsApp(ctx, db, u); err != nil {
+			u.LastError = err.Error()
+		}
+	}
+	return u, nil
+}
+
+func detectUploadMime(u *MediaUpload) (string, error) {
+	f, err := os.Open(mediaUploadPath(u.ID))
+	if err != nil {
+		return "", fmt.Errorf("failed to open upload file: %w", err)
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("failed to read upload file: %w", err)
+	}
+	_, allowed := mediaKindLimits(u.MediaType)
+	if u.MediaType == MediaKindSticker {
+		// uploads go to WhatsApp as they are, so they cannot be converted from PNG/JPEG/GIF
+		allowed = allowedStickerMimes
+	}
+	mimeType, err := checkMediaMime(u.MediaType, head[:n], "", allowed)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMediaUploadRejected, err)
+	}
+	return mimeType, nil
+}
+
+func whatsmeowMediaType(kind string) whatsmeow.MediaType {
+	switch kind {
+	case MediaKindVideo:
+		return whatsmeow.MediaVideo
+	case MediaKindAudio:
+		return whatsmeow.MediaAudio
+	case MediaKindDocument:
+		return whatsmeow.MediaDocument
+	}
+	return whatsmeow.MediaImage
+}
+
+// uploadMediaToWhatsApp streams the file from disk through WhatsAppUploadReader and stores the result
+func uploadMediaToWhatsApp(ctx context.Context, db *sql.DB, u *MediaUpload) error {
+	if u.uploaded != nil {
+		return nil
+	}
+	f, err := os.Open(mediaUploadPath(u.ID))
+	if err != nil {
+		return fmt.Errorf("failed to open upload file: %w", err)
+	}
+	defer f.Close()
+
+	resp, err := WhatsAppUploadReader(ctx, u.deviceJID, u.DeviceID, f, nil, whatsmeowMediaType(u.MediaType))
+	if err != nil {
+		_, _ = db.Exec(`UPDATE wa_media_uploads SET last_error = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, u.ID, err.Error())
+		return fmt.Errorf("failed to upload media to WhatsApp: %w", err)
+	}
+	uploaded := newStoredMediaUpload(*resp)
+	raw, err := json.Marshal(uploaded)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		UPDATE wa_media_uploads
+		SET wa_upload = $2::jsonb, status = $3, last_error = '', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, u.ID, string(raw), MediaUploadReady)
+	if err != nil {
+		return err
+	}
+	u.uploaded = uploaded
+	u.Status = MediaUploadReady
+	u.LastError = ""
+	return nil
+}
+
+// MediaUploadInput resolves the upload_id of a send request. The upload must be complete and of
+// the same media type as the endpoint; the bytes are only read when withData is set.
+func MediaUploadInput(ctx context.Context, deviceID string, id string, kind string, withData bool) (*MediaInput, error) {
+	u, err := GetMediaUpload(ctx, deviceID, id)
+	if err != nil {
+		return nil, err
+	}
+	if u.MediaType != kind {
+		return nil, fmt.Errorf("upload_id is a %s upload, not %s", u.MediaType, kind)
+	}
+	if u.Status == MediaUploadReceiving {
+		return nil, ErrMediaUploadIncomplete
+	}
+	input := &MediaInput{MimeType: u.MimeType, FileName: u.FileName, Source: "upload", UploadID: u.ID, Size: u.Length}
+	if withData {
+		input.Data, err = os.ReadFile(mediaUploadPath(u.ID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upload file: %w", err)
+		}
+	}
+	return input, nil
+}
+
+// DeleteMediaUpload removes an upload and its file
+func DeleteMediaUpload(ctx context.Context, deviceID string, id string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrMediaUploadNotFound
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx, `DELETE FROM wa_media_uploads WHERE device_id = $1 AND id = $2`, deviceID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMediaUploadNotFound
+	}
+	_ = os.Remove(mediaUploadPath(id))
+	mediaUploadLocks.Delete(id)
+	return nil
+}
+
+// CleanupExpiredMediaUploads deletes uploads past MEDIA_UPLOAD_TTL together with their files
+func CleanupExpiredMediaUploads(ctx context.Context) (int, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := db.QueryContext(ctx, `DELETE FROM wa_media_uploads WHERE expires_at <= $1 RETURNING id`, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	deleted := 0
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return deleted, err
+		}
+		_ = os.Remove(mediaUploadPath(id))
+		mediaUploadLocks.Delete(id)
+		deleted++
+	}
+	return deleted, rows.Err()
+}
+
+// StoredMediaOptions holds the per-message fields of a send that uses an upload_id or media_id
+type StoredMediaOptions struct {
+	Caption   string
+	FileName  string
+	ViewOnce  bool
+	VoiceNote bool
+}
+
+// WhatsAppSendStoredMedia sends media referenced by upload_id or media_id, as resolved by
+// MediaUploadInput or LibraryMediaInput, reusing what was already uploaded to WhatsApp
+func WhatsAppSendStoredMedia(ctx context.Context, jid string, deviceID string, rjid string, media *MediaInput, sendOpts StoredMediaOptions, opts *SendOptions) (string, error) {
+	if media.MediaID != 0 {
+		return WhatsAppSendLibraryMedia(ctx, jid, deviceID, rjid, media.MediaID, sendOpts, opts)
+	}
+	return WhatsAppSendMediaUpload(ctx, jid, deviceID, rjid, media.UploadID, sendOpts, opts)
+}
+
+// WhatsAppSendMediaUpload sends a completed upload without reading it into memory. The file
+// was already streamed to WhatsApp when the last chunk arrived; if that failed it is retried here.
+func WhatsAppSendMediaUpload(ctx context.Context, jid string, deviceID string, rjid string, uploadID string, sendOpts StoredMediaOptions, opts *SendOptions) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	client, err := currentClient(jid, deviceID)
+	if err != nil {
+		return "", err
+	}
+	if err = ensureClientOK(client); err != nil {
+		return "", err
+	}
+	u, err := GetMediaUpload(ctx, deviceID, uploadID)
+	if err != nil {
+		return "", err
+	}
+	if u.Status == MediaUploadReceiving {
+		return "", ErrMediaUploadIncomplete
+	}
+	remoteJID, err := WhatsAppCheckJID(ctx, jid, deviceID, rjid)
+	if err != nil {
+		return "", err
+	}
+	if err := waitRateLimit(ctx, deviceID); err != nil {
+		return "", err
+	}
+	cleanup := beginPresenceSimulation(ctx, jid, deviceID, remoteJID, u.MediaType == MediaKindAudio, opts)
+	defer cleanup()
+
+	db, err := openRoutingDB()
+	if err != nil {
+		return "", err
+	}
+	if err := uploadMediaToWhatsApp(ctx, db, u); err != nil {
+		return "", err
+	}
+
+	var thumb []byte
+	var thumbUploaded *storedMediaUpload
+	if u.MediaType == MediaKindImage {
+		if thumb, err = mediaUploadThumbnail(u.ID); err != nil {
+			return "", err
+		}
+		resp, err := client.Upload(ctx, thumb, whatsmeow.MediaLinkThumbnail)
+		if err != nil {
+			return "", errors.New("Error while Uploading Image Thumbnail to WhatsApp Server")
+		}
+		thumbUploaded = newStoredMediaUpload(resp)
+	}
+	fileName := sendOpts.FileName
+	if fileName == "" {
+		fileName = u.FileName
+	}
+	msgContent := uploadedMediaMessage(u.MediaType, u.MimeType, fileName, u.uploaded, thumb, thumbUploaded, sendOpts)
+
+	msgExtra := whatsmeow.SendRequestExtra{ID: client.GenerateMessageID()}
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
+	if err != nil {
+		return "", err
+	}
+	return msgExtra.ID, nil
+}
+
+// uploadedMediaMessage builds the media message for a file that is already on the WhatsApp servers
+func uploadedMediaMessage(kind string, mimeType string, fileName string, up *storedMediaUpload, thumb []byte, thumbUp *storedMediaUpload, sendOpts StoredMediaOptions) *waE2E.Message {
+	switch kind {
+	case MediaKindImage:
+		img := &waE2E.ImageMessage{
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			Mimetype:      proto.String(mimeType),
+			Caption:       proto.String(sendOpts.Caption),
+			FileLength:    proto.Uint64(up.FileLength),
+			FileSHA256:    up.FileSHA256,
+			FileEncSHA256: up.FileEncSHA256,
+			MediaKey:      up.MediaKey,
+			JPEGThumbnail: thumb,
+			ViewOnce:      proto.Bool(sendOpts.ViewOnce),
+		}
+		if thumbUp != nil {
+			img.ThumbnailDirectPath = proto.String(thumbUp.DirectPath)
+			img.ThumbnailSHA256 = thumbUp.FileSHA256
+			img.ThumbnailEncSHA256 = thumbUp.FileEncSHA256
+		}
+		return &waE2E.Message{ImageMessage: img}
+	case MediaKindVideo:
+		return &waE2E.Message{VideoMessage: &waE2E.VideoMessage{
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			Mimetype:      proto.String(mimeType),
+			Caption:       proto.String(sendOpts.Caption),
+			FileLength:    proto.Uint64(up.FileLength),
+			FileSHA256:    up.FileSHA256,
+			FileEncSHA256: up.FileEncSHA256,
+			MediaKey:      up.MediaKey,
+			ViewOnce:      proto.Bool(sendOpts.ViewOnce),
+		}}
+	case MediaKindAudio:
+		return &waE2E.Message{AudioMessage: &waE2E.AudioMessage{
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			Mimetype:      proto.String(mimeType),
+			FileLength:    proto.Uint64(up.FileLength),
+			FileSHA256:    up.FileSHA256,
+			FileEncSHA256: up.FileEncSHA256,
+			MediaKey:      up.MediaKey,
+			PTT:           proto.Bool(sendOpts.VoiceNote),
+		}}
+	case MediaKindSticker:
+		return &waE2E.Message{StickerMessage: &waE2E.StickerMessage{
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			Mimetype:      proto.String(mimeType),
+			FileLength:    proto.Uint64(up.FileLength),
+			FileSHA256:    up.FileSHA256,
+			FileEncSHA256: up.FileEncSHA256,
+			MediaKey:      up.MediaKey,
+		}}
+	}
+	if fileName == "" {
+		fileName = "document"
+	}
+	return &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{
+		URL:           proto.String(up.URL),
+		DirectPath:    proto.String(up.DirectPath),
+		Mimetype:      proto.String(mimeType),
+		Caption:       proto.String(sendOpts.Caption),
+		FileName:      proto.String(fileName),
+		FileLength:    proto.Uint64(up.FileLength),
+		FileSHA256:    up.FileSHA256,
+		FileEncSHA256: up.FileEncSHA256,
+		MediaKey:      up.MediaKey,
+	}}
+}
+
+// mediaUploadThumbnail builds the thumbnail of an image upload from its file on disk
+func mediaUploadThumbnail(id string) ([]byte, error) {
+	f, err := os.Open(mediaUploadPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	defer f.Close()
+	return jpegThumbnail(f)
+}
+
+// jpegThumbnail builds the 72px JPEG preview WhatsApp shows before an image is downloaded
+func jpegThumbnail(r io.Reader) ([]byte, error) {
+	img, err := imgconv.Decode(r)
+	if err != nil {
+		return nil, errors.New("Error While Decoding Thumbnail Image Stream")
+	}
+	return encodeJPEGThumbnail(img)
+}
+
+// encodeJPEGThumbnail scales a decoded image or video frame to the 72px JPEG preview
+func encodeJPEGThumbnail(img image.Image) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := imgconv.Write(buf, imgconv.Resize(img, &imgconv.ResizeOption{Width: 72}), &imgconv.FormatOption{Format: imgconv.JPEG})
+	if err != nil {
+		return nil, errors.New("Error While Encoding Thumbnail Image Stream")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,127 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/validation"
+)
+
+// applyMentions fills ContextInfo.MentionedJID from SendOptions.Mentions, the @<number> tokens of
+// group text and captions when SendOptions.MentionTokens is set, and, with MentionAll, every group
+// participant except this device. Participants are only notified when their number is not in the
+// text; phones highlight the @<number> tokens that are. In groups each mention is resolved to the
+// JID the group addresses the participant by, phone number or LID.
+func applyMentions(ctx context.Context, client *whatsmeow.Client, to types.JID, msg *waE2E.Message, opts *SendOptions) error {
+	if opts == nil {
+		return nil
+	}
+	isGroup := to.Server == types.GroupServer
+	if opts.MentionAll && !isGroup {
+		return errors.New("mention_all is only supported for group chats")
+	}
+
+	var candidates []types.JID
+	for _, raw := range opts.Mentions {
+		j, err := validation.ParseMentionJID(raw)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, j)
+	}
+	if isGroup && opts.MentionTokens {
+		content := extractMessageContent(msg)
+		text := content.Text
+		if text == "" {
+			text = content.Caption
+		}
+		for _, number := range validation.MentionTokens(text) {
+			candidates = append(candidates, types.NewJID(number, types.DefaultUserServer))
+		}
+	}
+	if len(candidates) == 0 && !opts.MentionAll {
+		return nil
+	}
+
+	var info *types.GroupInfo
+	if isGroup {
+		var err error
+		if info, err = client.GetGroupInfo(ctx, to); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]struct{})
+	mentioned := []string{}
+	add := func(j types.JID) {
+		s := j.ToNonAD().String()
+		if _, ok := seen[s]; ok {
+			return
+		}
+		seen[s] = struct{}{}
+		mentioned = append(mentioned, s)
+	}
+	for _, j := range candidates {
+		add(resolveMentionJID(ctx, client, info, j))
+	}
+	if opts.MentionAll {
+		var ownPN, ownLID types.JID
+		if client.Store != nil {
+			ownPN = client.Store.GetJID().ToNonAD()
+			ownLID = client.Store.GetLID().ToNonAD()
+		}
+		for _, p := range info.Participants {
+			if isOwnParticipant(p, ownPN, ownLID) {
+				continue
+			}
+			add(p.JID)
+		}
+	}
+
+	if len(mentioned) == 0 {
+		return nil
+	}
+	ci := ensureContextInfo(msg)
+	if ci == nil {
+		return nil
+	}
+	ci.MentionedJID = mentioned
+	return nil
+}
+
+// resolveMentionJID maps a mention to the JID a group addresses the participant by. A number or
+// LID of a participant becomes the participant's JID; otherwise a phone number mentioned in a
+// LID-addressed group is looked up in the device's LID store. Outside groups j is kept as is.
+func resolveMentionJID(ctx context.Context, client *whatsmeow.Client, info *types.GroupInfo, j types.JID) types.JID {
+	if info == nil {
+		return j
+	}
+	for _, p := range info.Participants {
+		if p.JID.User == j.User || p.PhoneNumber.User == j.User || p.LID.User == j.User {
+			return p.JID
+		}
+	}
+	if info.AddressingMode == types.AddressingModeLID && j.Server == types.DefaultUserServer && client.Store != nil && client.Store.LIDs != nil {
+		if lid, err := client.Store.LIDs.GetLIDForPN(ctx, j); err == nil && !lid.IsEmpty() {
+			return lid
+		}
+	}
+	return j
+}
+
+// isOwnParticipant reports whether a group participant is this device, by phone number or LID
+func isOwnParticipant(p types.GroupParticipant, ownPN types.JID, ownLID types.JID) bool {
+	for _, j := range []types.JID{p.JID, p.PhoneNumber, p.LID} {
+		if j.IsEmpty() {
+			continue
+		}
+		if j = j.ToNonAD(); j == ownPN || j == ownLID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhook"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// Limits for the client_reference and metadata a send can carry
+const (
+	ClientReferenceMaxLength      = 255
+	MessageMetadataMaxKeys        = 20
+	MessageMetadataMaxKeyLength   = 64
+	MessageMetadataMaxValueLength = 500
+)
+
+// messageReferenceCacheTTL is how long a reference stays in memory after its send. Most receipts
+// arrive well within it; later ones read the reference back from wa_message_references.
+const messageReferenceCacheTTL = time.Hour
+
+// messageReference is the caller's own identifiers for a sent message, echoed in its webhooks
+type messageReference struct {
+	ClientReference string
+	Metadata        map[string]string
+}
+
+// referenceCache keeps recent references in memory, and remembers which devices have any stored
+// reference at all, so receipts of devices that never set one do not query the database
+type referenceCache struct {
+	mu        sync.Mutex
+	entries   map[string]cachedReference
+	devices   map[string]bool
+	lastSweep time.Time
+}
+
+type cachedReference struct {
+	ref     messageReference
+	expires time.Time
+}
+
+var messageReferences = &referenceCache{
+	entries: make(map[string]cachedReference),
+	devices: make(map[string]bool),
+}
+
+func referenceCacheKey(deviceID string, msgID string) string {
+	return deviceID + "/" + msgID
+}
+
+func (rc *referenceCache) put(deviceID string, msgID string, ref messageReference, now time.Time) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.devices[deviceID] = true
+	rc.entries[referenceCacheKey(deviceID, msgID)] = cachedReference{ref: ref, expires: now.Add(messageReferenceCacheTTL)}
+	if now.Sub(rc.lastSweep) >= time.Minute {
+		rc.lastSweep = now
+		for key, entry := range rc.entries {
+			if now.After(entry.expires) {
+				delete(rc.entries, key)
+			}
+		}
+	}
+}
+
+func (rc *referenceCache) remove(deviceID string, msgID string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.entries, referenceCacheKey(deviceID, msgID))
+}
+
+// get returns the cached references of the messages and the IDs it does not hold
+func (rc *referenceCache) get(deviceID string, msgIDs []string, now time.Time) (map[string]messageReference, []string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	refs := make(map[string]messageReference)
+	var misses []string
+	for _, msgID := range msgIDs {
+		entry, ok := rc.entries[referenceCacheKey(deviceID, msgID)]
+		if ok && !now.After(entry.expires) {
+			refs[msgID] = entry.ref
+			continue
+		}
+		misses = append(misses, msgID)
+	}
+	return refs, misses
+}
+
+// deviceHasReferences reports whether the device has stored references, and whether that is known yet
+func (rc *referenceCache) deviceHasReferences(deviceID string) (has bool, known bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	has, known = rc.devices[deviceID]
+	return has, known
+}
+
+func (rc *referenceCache) setDeviceHasReferences(deviceID string, has bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.devices[deviceID] {
+		rc.devices[deviceID] = has
+	}
+}
+
+// ValidateClientReference checks the client_reference and metadata of a send request
+func ValidateClientReference(clientReference string, metadata map[string]string) error {
+	if len(clientReference) > ClientReferenceMaxLength {
+		return fmt.Errorf("client_reference must be at most %d characters", ClientReferenceMaxLength)
+	}
+	if len(metadata) > MessageMetadataMaxKeys {
+		return fmt.Errorf("metadata can have at most %d keys", MessageMetadataMaxKeys)
+	}
+	for k, v := range metadata {
+		if k == "" || len(k) > MessageMetadataMaxKeyLength {
+			return fmt.Errorf("metadata keys must be 1-%d characters", MessageMetadataMaxKeyLength)
+		}
+		if len(v) > MessageMetadataMaxValueLength {
+			return fmt.Errorf("metadata value of %q must be at most %d characters", k, MessageMetadataMaxValueLength)
+		}
+	}
+	return nil
+}
+
+func ensureMessageReferenceSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_message_references (
+		device_id TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		client_reference TEXT NOT NULL DEFAULT '',
+		metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (device_id, message_id)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_message_references_created ON wa_message_references (created_at)`)
+	return err
+}
+
+// sendReference returns the reference carried by a send, or nil when it has none
+func sendReference(opts *SendOptions) *messageReference {
+	if opts == nil || (opts.ClientReference == "" && len(opts.Metadata) == 0) {
+		return nil
+	}
+	return &messageReference{ClientReference: opts.ClientReference, Metadata: opts.Metadata}
+}
+
+// recordMessageReference keeps the reference in memory before the message goes out, so receipts
+// that arrive right after the send already find it, and queues it for wa_message_references
+func recordMessageReference(deviceID string, to types.JID, msgID string, ref *messageReference) {
+	if ref == nil || msgID == "" {
+		return
+	}
+	now := time.Now().UTC()
+	messageReferences.put(deviceID, msgID, *ref, now)
+	metadata, err := json.Marshal(ref.Metadata)
+	if err != nil || ref.Metadata == nil {
+		metadata = []byte("{}")
+	}
+	receiptWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+		_, err := execer.ExecContext(ctx, `
+			INSERT INTO wa_message_references (device_id, message_id, chat_jid, client_reference, metadata, created_at)
+			VALUES ($1, $2, $3, $4, $5::jsonb, $6)
+			ON CONFLICT (device_id, message_id) DO UPDATE SET client_reference = EXCLUDED.client_reference, metadata = EXCLUDED.metadata
+		`, deviceID, msgID, to.String(), ref.ClientReference, string(metadata), now)
+		return err
+	})
+}
+
+// forgetMessageReference drops the reference of a send that failed
+func forgetMessageReference(deviceID string, msgID string, ref *messageReference) {
+	if ref == nil || msgID == "" {
+		return
+	}
+	messageReferences.remove(deviceID, msgID)
+	receiptWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+		_, err := execer.ExecContext(ctx, `DELETE FROM wa_message_references WHERE device_id = $1 AND message_id = $2`, deviceID, msgID)
+		return err
+	})
+}
+
+// lookupMessageReferences returns the references of the given messages, keyed by message ID.
+// Recent ones come from memory; the database is only asked about the rest, and only for devices
+// that have stored references.
+func lookupMessageReferences(deviceID string, msgIDs []string) map[string]messageReference {
+	if len(msgIDs) == 0 {
+		return nil
+	}
+	refs, misses := messageReferences.get(deviceID, msgIDs, time.Now())
+	if len(misses) == 0 {
+		return refs
+	}
+	has, known := messageReferences.deviceHasReferences(deviceID)
+	if known && !has {
+		return refs
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return refs
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), messageStoreWriteTimeout)
+	defer cancel()
+
+	if !known {
+		err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM wa_message_references WHERE device_id = $1)`, deviceID).Scan(&has)
+		if err != nil {
+			log.EvtErr("receipt", "reference", deviceID, err)
+			return refs
+		}
+		messageReferences.setDeviceHasReferences(deviceID, has)
+		if !has {
+			return refs
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT message_id, client_reference, metadata
+		FROM wa_message_references
+		WHERE device_id = $1 AND message_id = ANY($2)
+	`, deviceID, pq.Array(misses))
+	if err != nil {
+		log.EvtErr("receipt", "reference", deviceID, err)
+		return refs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			msgID    string
+			ref      messageReference
+			metadata []byte
+		)
+		if err := rows.Scan(&msgID, &ref.ClientReference, &metadata); err != nil {
+			return refs
+		}
+		_ = json.Unmarshal(metadata, &ref.Metadata)
+		refs[msgID] = ref
+	}
+	return refs
+}
+
+// addTo sets client_reference and metadata on webhook data when they were given
+func (r messageReference) addTo(data map[string]interface{}) {
+	if r.ClientReference != "" {
+		data["client_reference"] = r.ClientReference
+	}
+	if len(r.Metadata) > 0 {
+		data["metadata"] = r.Metadata
+	}
+}
+
+// dispatchMessageSent raises message.sent for a message the server accepted
+func dispatchMessageSent(deviceID string, to types.JID, resp whatsmeow.SendResponse, ref *messageReference) {
+	data := map[string]interface{}{
+		"message_id": resp.ID,
+		"chat":       to.String(),
+		"timestamp":  resp.Timestamp.Unix(),
+	}
+	if ref != nil {
+		ref.addTo(data)
+	}
+	dispatchWebhook(deviceID, webhook.EventMessageSent, data)
+}
+
+// CleanupOldMessageReferences deletes the client references of messages sent before the retention period
+func CleanupOldMessageReferences(ctx context.Context, retention time.Duration) (int64, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	result, err := db.ExecContext(ctx, `DELETE FROM wa_message_references WHERE created_at < $1`, time.Now().Add(-retention).UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,441 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhook"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// ErrMessageStatusNotFound is returned when no outbound status is tracked for a message
+var ErrMessageStatusNotFound = errors.New("message status not found")
+
+// Outbound message statuses, in lifecycle order; failed is set when the send errors or the
+// server rejects the message
+const (
+	MessageStatusSent      = "sent"
+	MessageStatusServerAck = "server_ack"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+	MessageStatusPlayed    = "played"
+	MessageStatusFailed    = "failed"
+)
+
+// messageStatusRank orders statuses so a late delivery receipt never moves a read message back.
+// failed ranks lowest: a receipt arriving after a failure means the message did get through.
+const messageStatusRank = `array_position(ARRAY['failed','sent','server_ack','delivered','read','played'], %s)`
+
+// messageStatusAlertBatch bounds how many overdue messages one SLA run alerts on
+const messageStatusAlertBatch = 500
+
+var (
+	messageStatusEnabled = true
+	messageDeliverySLA   time.Duration
+)
+
+// MessageStatus is the lifecycle of a message sent through the API
+type MessageStatus struct {
+	MessageID   string                   `json:"message_id"`
+	ChatJID     string                   `json:"chat_jid"`
+	IsGroup     bool                     `json:"is_group"`
+	Status      string                   `json:"status"`
+	Error       string                   `json:"error,omitempty"`
+	SentAt      time.Time                `json:"sent_at"`
+	ServerAckAt *time.Time               `json:"server_ack_at,omitempty"`
+	DeliveredAt *time.Time               `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time               `json:"read_at,omitempty"`
+	PlayedAt    *time.Time               `json:"played_at,omitempty"`
+	FailedAt    *time.Time               `json:"failed_at,omitempty"`
+	Recipients  []MessageRecipientStatus `json:"recipients,omitempty"`
+	Timeline    []MessageStatusEvent     `json:"timeline"`
+}
+
+// MessageRecipientStatus is the state of a group message for one participant
+type MessageRecipientStatus struct {
+	JID         string     `json:"jid"`
+	Status      string     `json:"status"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	PlayedAt    *time.Time `json:"played_at,omitempty"`
+}
+
+// MessageStatusEvent is one status change; Recipient is set for per-participant changes of group messages
+type MessageStatusEvent struct {
+	Status    string    `json:"status"`
+	Recipient string    `json:"recipient,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+func loadMessageStatusConfig() {
+	messageStatusEnabled = ParseOptionalBool("WHATSAPP_MESSAGE_STATUS_ENABLED", true)
+	messageDeliverySLA = ParseOptionalDuration("WHATSAPP_MESSAGE_DELIVERY_SLA", 0)
+	if !messageStatusEnabled {
+		messageDeliverySLA = 0
+	}
+	log.Sys("cfg", fmt.Sprintf("message_status:%t delivery_sla:%s", messageStatusEnabled, messageDeliverySLA))
+}
+
+// MessageDeliverySLA is how long a sent message may stay undelivered before an alert is raised; 0 disables alerts
+func MessageDeliverySLA() time.Duration {
+	return messageDeliverySLA
+}
+
+func ensureMessageStatusSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_message_status (
+		device_id TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		is_group BOOLEAN NOT NULL DEFAULT FALSE,
+		status TEXT NOT NULL,
+		error TEXT,
+		sent_at TIMESTAMP NOT NULL,
+		server_ack_at TIMESTAMP,
+		delivered_at TIMESTAMP,
+		read_at TIMESTAMP,
+		played_at TIMESTAMP,
+		failed_at TIMESTAMP,
+		sla_alerted_at TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (device_id, message_id)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_message_status_undelivered ON wa_message_status (sent_at)
+		WHERE status IN ('sent', 'server_ack') AND sla_alerted_at IS NULL`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS wa_message_status_recipients (
+		device_id TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		recipient_jid TEXT NOT NULL,
+		status TEXT NOT NULL,
+		delivered_at TIMESTAMP,
+		read_at TIMESTAMP,
+		played_at TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (device_id, message_id, recipient_jid)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS wa_message_status_events (
+		id BIGSERIAL PRIMARY KEY,
+		device_id TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		recipient_jid TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		occurred_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_message_status_events_msg ON wa_message_status_events (device_id, message_id, occurred_at, id)`)
+	return err
+}
+
+// recordMessageSending tracks a message as sent right before it goes out, so receipts racing the
+// server ack always find its row
+func recordMessageSending(deviceID string, to types.JID, msgID string, sentAt time.Time) {
+	if !messageStatusEnabled || msgID == "" {
+		return
+	}
+	receiptWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+		_, err := execer.ExecContext(ctx, `
+			WITH inserted AS (
+				INSERT INTO wa_message_status (device_id, message_id, chat_jid, is_group, status, sent_at)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (device_id, message_id) DO NOTHING
+				RETURNING device_id, message_id, status, sent_at
+			)
+			INSERT INTO wa_message_status_events (device_id, message_id, status, occurred_at)
+			SELECT device_id, message_id, status, sent_at FROM inserted
+		`, deviceID, msgID, to.String(), to.Server == types.GroupServer, MessageStatusSent, sentAt.UTC())
+		return err
+	})
+}
+
+// recordMessageSendResult moves a tracked message to server_ack, or to failed with the send error
+func recordMessageSendResult(deviceID string, msgID string, ackAt time.Time, sendErr error) {
+	if !messageStatusEnabled || msgID == "" {
+		return
+	}
+	status, column, errText := MessageStatusServerAck, "server_ack_at", sql.NullString{}
+	if sendErr != nil {
+		status, column = MessageStatusFailed, "failed_at"
+		errText = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+	if ackAt.IsZero() {
+		ackAt = time.Now()
+	}
+	receiptWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+		_, err := execer.ExecContext(ctx, `
+			WITH updated AS (
+				UPDATE wa_message_status
+				SET status = $3, `+column+` = $4, error = COALESCE($5, error), updated_at = CURRENT_TIMESTAMP
+				WHERE device_id = $1 AND message_id = $2 AND status = $6
+				RETURNING device_id, message_id
+			)
+			INSERT INTO wa_message_status_events (device_id, message_id, status, occurred_at)
+			SELECT device_id, message_id, $3, $4 FROM updated
+		`, deviceID, msgID, status, ackAt.UTC(), errText, MessageStatusSent)
+		return err
+	})
+}
+
+// trackMessageReceipt queues the update of tracked messages from a receipt. Group messages also
+// keep the state of every participant that sent a receipt.
+func trackMessageReceipt(deviceID string, evt *events.Receipt) {
+	if !messageStatusEnabled || len(evt.MessageIDs) == 0 {
+		return
+	}
+	var status, column string
+	switch evt.Type {
+	case types.ReceiptTypeDelivered:
+		status, column = MessageStatusDelivered, "delivered_at"
+	case types.ReceiptTypeRead:
+		status, column = MessageStatusRead, "read_at"
+	case types.ReceiptTypePlayed:
+		status, column = MessageStatusPlayed, "played_at"
+	case types.ReceiptTypeServerError:
+		status, column = MessageStatusFailed, "failed_at"
+	default:
+		// self receipts come from our own devices, retry/sender receipts are protocol traffic
+		return
+	}
+	ids, err := json.Marshal(evt.MessageIDs)
+	if err != nil {
+		return
+	}
+	ts := evt.Timestamp.UTC()
+	if evt.Timestamp.IsZero() {
+		ts = time.Now().UTC()
+	}
+
+	// a read or played receipt implies the message was delivered
+	set := column + " = COALESCE(s." + column + ", $3)"
+	if status == MessageStatusRead || status == MessageStatusPlayed {
+		set += ", delivered_at = COALESCE(s.delivered_at, $3)"
+	}
+	advance := fmt.Sprintf(messageStatusRank, "s.status") + " < " + fmt.Sprintf(messageStatusRank, "$2::text")
+	if status == MessageStatusFailed {
+		// the server rejecting a message only counts before anyone received it
+		advance = "s.status IN ('sent', 'server_ack')"
+		set += ", error = COALESCE(s.error, 'rejected by server')"
+	}
+	statusQuery := `
+		WITH updated AS (
+			UPDATE wa_message_status s
+			SET status = $2, ` + set + `, updated_at = CURRENT_TIMESTAMP
+			WHERE s.device_id = $1 AND s.message_id IN (SELECT jsonb_array_elements_text($4::jsonb)) AND ` + advance + `
+			RETURNING s.device_id, s.message_id
+		)
+		INSERT INTO wa_message_status_events (device_id, message_id, status, occurred_at)
+		SELECT device_id, message_id, $2, $3 FROM updated
+	`
+
+	recipientQuery := ""
+	if evt.IsGroup && !evt.Sender.IsEmpty() && status != MessageStatusFailed {
+		insertColumns, insertValues := column, "$3"
+		recipientSet := column + " = COALESCE(r." + column + ", $3)"
+		if status == MessageStatusRead || status == MessageStatusPlayed {
+			insertColumns, insertValues = column+", delivered_at", "$3, $3"
+			recipientSet += ", delivered_at = COALESCE(r.delivered_at, $3)"
+		}
+		recipientQuery = `
+		WITH upserted AS (
+			INSERT INTO wa_message_status_recipients AS r (device_id, message_id, recipient_jid, status, ` + insertColumns + `)
+			SELECT s.device_id, s.message_id, $5, $2, ` + insertValues + `
+			FROM wa_message_status s
+			WHERE s.device_id = $1 AND s.message_id IN (SELECT jsonb_array_elements_text($4::jsonb)) AND s.is_group
+			ON CONFLICT (device_id, message_id, recipient_jid) DO UPDATE
+			SET status = $2, ` + recipientSet + `, updated_at = CURRENT_TIMESTAMP
+			WHERE ` + fmt.Sprintf(messageStatusRank, "r.status") + ` < ` + fmt.Sprintf(messageStatusRank, "$2::text") + `
+			RETURNING r.device_id, r.message_id
+		)
+		INSERT INTO wa_message_status_events (device_id, message_id, recipient_jid, status, occurred_at)
+		SELECT device_id, message_id, $5, $2, $3 FROM upserted
+	`
+	}
+	sender := evt.Sender.ToNonAD().String()
+
+	receiptWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+		if _, err := execer.ExecContext(ctx, statusQuery, deviceID, status, ts, string(ids)); err != nil {
+			return err
+		}
+		if recipientQuery == "" {
+			return nil
+		}
+		_, err := execer.ExecContext(ctx, recipientQuery, deviceID, status, ts, string(ids), sender)
+		return err
+	})
+}
+
+// GetMessageStatus returns the current status, per-participant state and timeline of a sent message
+func GetMessageStatus(ctx context.Context, deviceID string, messageID string) (*MessageStatus, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	m := MessageStatus{MessageID: messageID}
+	var errText sql.NullString
+	var serverAckAt, deliveredAt, readAt, playedAt, failedAt sql.NullTime
+	err = db.QueryRowContext(ctx, `
+		SELECT chat_jid, is_group, status, error, sent_at, server_ack_at, delivered_at, read_at, played_at, failed_at
+		FROM wa_message_status
+		WHERE device_id = $1 AND message_id = $2
+	`, deviceID, messageID).Scan(&m.ChatJID, &m.IsGroup, &m.Status, &errText, &m.SentAt, &serverAckAt, &deliveredAt, &readAt, &playedAt, &failedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageStatusNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Error = errText.String
+	m.ServerAckAt = nullTimePtr(serverAckAt)
+	m.DeliveredAt = nullTimePtr(deliveredAt)
+	m.ReadAt = nullTimePtr(readAt)
+	m.PlayedAt = nullTimePtr(playedAt)
+	m.FailedAt = nullTimePtr(failedAt)
+
+	if m.IsGroup {
+		rows, err := db.QueryContext(ctx, `
+			SELECT recipient_jid, status, delivered_at, read_at, played_at
+			FROM wa_message_status_recipients
+			WHERE device_id = $1 AND message_id = $2
+			ORDER BY recipient_jid
+		`, deviceID, messageID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r MessageRecipientStatus
+			var rDelivered, rRead, rPlayed sql.NullTime
+			if err := rows.Scan(&r.JID, &r.Status, &rDelivered, &rRead, &rPlayed); err != nil {
+				return nil, err
+			}
+			r.DeliveredAt = nullTimePtr(rDelivered)
+			r.ReadAt = nullTimePtr(rRead)
+			r.PlayedAt = nullTimePtr(rPlayed)
+			m.Recipients = append(m.Recipients, r)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT status, recipient_jid, occurred_at
+		FROM wa_message_status_events
+		WHERE device_id = $1 AND message_id = $2
+		ORDER BY occurred_at, id
+	`, deviceID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m.Timeline = []MessageStatusEvent{}
+	for rows.Next() {
+		var e MessageStatusEvent
+		if err := rows.Scan(&e.Status, &e.Recipient, &e.At); err != nil {
+			return nil, err
+		}
+		m.Timeline = append(m.Timeline, e)
+	}
+	return &m, rows.Err()
+}
+
+// RunMessageDeliverySLA raises message.delivery_overdue once for every message that is still not
+// delivered after the configured SLA
+func RunMessageDeliverySLA(ctx context.Context) (int, error) {
+	if messageDeliverySLA <= 0 {
+		return 0, nil
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	rows, err := db.QueryContext(ctx, `
+		UPDATE wa_message_status
+		SET sla_alerted_at = $1
+		WHERE (device_id, message_id) IN (
+			SELECT device_id, message_id FROM wa_message_status
+			WHERE status IN ($2, $3) AND sla_alerted_at IS NULL AND sent_at < $4
+			ORDER BY sent_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING device_id, message_id, chat_jid, status, sent_at
+	`, now, MessageStatusSent, MessageStatusServerAck, now.Add(-messageDeliverySLA), messageStatusAlertBatch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	alerted := 0
+	for rows.Next() {
+		var deviceID, messageID, chatJID, status string
+		var sentAt time.Time
+		if err := rows.Scan(&deviceID, &messageID, &chatJID, &status, &sentAt); err != nil {
+			return alerted, err
+		}
+		dispatchWebhook(deviceID, webhook.EventMessageDeliveryOverdue, map[string]interface{}{
+			"message_id":  messageID,
+			"chat":        chatJID,
+			"status":      status,
+			"sent_at":     sentAt.UTC().Format(time.RFC3339),
+			"sla_seconds": int64(messageDeliverySLA.Seconds()),
+		})
+		alerted++
+	}
+	return alerted, rows.Err()
+}
+
+// CleanupOldMessageStatus deletes the tracked status, participant states and timeline of messages
+// sent before the retention period
+func CleanupOldMessageStatus(ctx context.Context, retention time.Duration) (int64, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-retention).UTC()
+	_, err = db.ExecContext(ctx, `
+		DELETE FROM wa_message_status_recipients r
+		USING wa_message_status s
+		WHERE s.device_id = r.device_id AND s.message_id = r.message_id AND s.sent_at < $1
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	_, err = db.ExecContext(ctx, `
+		DELETE FROM wa_message_status_events e
+		USING wa_message_status s
+		WHERE s.device_id = e.device_id AND s.message_id = e.message_id AND s.sent_at < $1
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	result, err := db.ExecContext(ctx, `DELETE FROM wa_message_status WHERE sent_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,627 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+var (
+	// ErrStoredMessageNotFound is returned when a message is not present in the message store
+	ErrStoredMessageNotFound = errors.New("message not found in message store")
+	// ErrMessageStoreDisabled is returned by operations that need WHATSAPP_MESSAGE_STORE_ENABLED
+	ErrMessageStoreDisabled = errors.New("message store is disabled")
+)
+
+const (
+	messageStoreWriteTimeout = 5 * time.Second
+	messageStoreMaxPageSize  = 200
+)
+
+var (
+	messageStoreEnabled = true
+
+	// Writes from the event handler; whatsmeow dispatches events serially, so they must not wait on Postgres
+	messageStoreWriter *asyncWriter
+)
+
+// StoredMessage represents a chat message persisted in the message store
+type StoredMessage struct {
+	MessageID string       `json:"message_id"`
+	ChatJID   string       `json:"chat_jid"`
+	SenderJID string       `json:"sender_jid"`
+	PushName  string       `json:"push_name,omitempty"`
+	IsFromMe  bool         `json:"is_from_me"`
+	IsGroup   bool         `json:"is_group"`
+	Type      string       `json:"type"`
+	Text      string       `json:"text,omitempty"`
+	Caption   string       `json:"caption,omitempty"`
+	Media     *StoredMedia `json:"media,omitempty"`
+	IsEdited  bool         `json:"is_edited"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+// StoredMedia holds media metadata extracted from a stored message
+type StoredMedia struct {
+	Mimetype    string `json:"mimetype,omitempty"`
+	FileName    string `json:"file_name,omitempty"`
+	FileLength  uint64 `json:"file_length,omitempty"`
+	FileSHA256  string `json:"file_sha256,omitempty"` // base64
+	DirectPath  string `json:"direct_path,omitempty"`
+	Width       uint32 `json:"width,omitempty"`
+	Height      uint32 `json:"height,omitempty"`
+	Seconds     uint32 `json:"seconds,omitempty"`
+	IsVoiceNote bool   `json:"is_voice_note,omitempty"`
+	IsAnimated  bool   `json:"is_animated,omitempty"`
+}
+
+type messageContent struct {
+	Type    string
+	Text    string
+	Caption string
+	Media   *StoredMedia
+}
+
+func loadMessageStoreConfig() {
+	messageStoreEnabled = ParseOptionalBool("WHATSAPP_MESSAGE_STORE_ENABLED", true)
+	queueSize := ParseOptionalInt("WHATSAPP_MESSAGE_STORE_QUEUE_SIZE", 10000, 100)
+	log.Sys("cfg", fmt.Sprintf("message_store:%t queue_size:%d", messageStoreEnabled, queueSize))
+	if messageStoreEnabled {
+		messageStoreWriter = newAsyncWriter("StoreMessage", queueSize)
+	}
+}
+
+func ensureMessageStoreSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_messages (
+		device_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		sender_jid TEXT NOT NULL DEFAULT '',
+		push_name TEXT,
+		is_from_me BOOLEAN NOT NULL DEFAULT FALSE,
+		is_group BOOLEAN NOT NULL DEFAULT FALSE,
+		message_type TEXT NOT NULL,
+		text TEXT,
+		caption TEXT,
+		media JSONB,
+		raw_message BYTEA,
+		is_edited BOOLEAN NOT NULL DEFAULT FALSE,
+		message_timestamp TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (device_id, chat_jid, message_id)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_messages_chat_ts ON wa_messages(device_id, chat_jid, message_timestamp DESC, message_id DESC)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_messages_device_msg ON wa_messages(device_id, message_id)`)
+	return err
+}
+
+// extractMessageContent classifies a message and pulls out its text and media metadata.
+// An empty Type means the message carries no user-visible content (protocol messages, reactions, votes).
+func extractMessageContent(msg *waE2E.Message) messageContent {
+	if msg == nil {
+		return messageContent{}
+	}
+	switch {
+	case msg.GetConversation() != "":
+		return messageContent{Type: "text", Text: msg.GetConversation()}
+	case msg.GetExtendedTextMessage() != nil:
+		return messageContent{Type: "text", Text: msg.GetExtendedTextMessage().GetText()}
+	case msg.GetImageMessage() != nil:
+		m := msg.GetImageMessage()
+		return messageContent{Type: "image", Caption: m.GetCaption(), Media: &StoredMedia{
+			Mimetype:   m.GetMimetype(),
+			FileLength: m.GetFileLength(),
+			FileSHA256: encodeMediaHash(m.GetFileSHA256()),
+			DirectPath: m.GetDirectPath(),
+			Width:      m.GetWidth(),
+			Height:     m.GetHeight(),
+		}}
+	case msg.GetVideoMessage() != nil:
+		m := msg.GetVideoMessage()
+		return messageContent{Type: "video", Caption: m.GetCaption(), Media: &StoredMedia{
+			Mimetype:   m.GetMimetype(),
+			FileLength: m.GetFileLength(),
+			FileSHA256: encodeMediaHash(m.GetFileSHA256()),
+			DirectPath: m.GetDirectPath(),
+			Width:      m.GetWidth(),
+			Height:     m.GetHeight(),
+			Seconds:    m.GetSeconds(),
+			IsAnimated: m.GetGifPlayback(),
+		}}
+	case msg.GetAudioMessage() != nil:
+		m := msg.GetAudioMessage()
+		return messageContent{Type: "audio", Media: &StoredMedia{
+			Mimetype:    m.GetMimetype(),
+			FileLength:  m.GetFileLength(),
+			FileSHA256:  encodeMediaHash(m.GetFileSHA256()),
+			DirectPath:  m.GetDirectPath(),
+			Seconds:     m.GetSeconds(),
+			IsVoiceNote: m.GetPTT(),
+		}}
+	case msg.GetDocumentMessage() != nil:
+		m := msg.GetDocumentMessage()
+		return messageContent{Type: "document", Caption: m.GetCaption(), Media: &StoredMedia{
+			Mimetype:   m.GetMimetype(),
+			FileName:   m.GetFileName(),
+			FileLength: m.GetFileLength(),
+			FileSHA256: encodeMediaHash(m.GetFileSHA256()),
+			DirectPath: m.GetDirectPath(),
+		}}
+	case msg.GetStickerMessage() != nil:
+		m := msg.GetStickerMessage()
+		return messageContent{Type: "sticker", Media: &StoredMedia{
+			Mimetype:   m.GetMimetype(),
+			FileLength: m.GetFileLength(),
+			FileSHA256: encodeMediaHash(m.GetFileSHA256()),
+			DirectPath: m.GetDirectPath(),
+			Width:      m.GetWidth(),
+			Height:     m.GetHeight(),
+			IsAnimated: m.GetIsAnimated(),
+		}}
+	case msg.GetLocationMessage() != nil:
+		m := msg.GetLocationMessage()
+		return messageContent{Type: "location", Text: firstNonEmpty(m.GetName(), m.GetAddress())}
+	case msg.GetLiveLocationMessage() != nil:
+		return messageContent{Type: "live_location", Caption: msg.GetLiveLocationMessage().GetCaption()}
+	case msg.GetContactMessage() != nil:
+		return messageContent{Type: "contact", Text: msg.GetContactMessage().GetDisplayName()}
+	case msg.GetContactsArrayMessage() != nil:
+		return messageContent{Type: "contacts", Text: msg.GetContactsArrayMessage().GetDisplayName()}
+	case pollCreationFromMessage(msg) != nil:
+		return messageContent{Type: "poll", Text: pollCreationFromMessage(msg).GetName()}
+	case msg.GetProtocolMessage() != nil, msg.GetReactionMessage() != nil,
+		msg.GetPollUpdateMessage() != nil, msg.GetKeepInChatMessage() != nil:
+		return messageContent{}
+	case msg.GetSenderKeyDistributionMessage() != nil:
+		return messageContent{}
+	}
+	return messageContent{Type: "unknown"}
+}
+
+func encodeMediaHash(hash []byte) string {
+	if len(hash) == 0 {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(hash)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// canonicalChatJID maps hidden-user (LID) 1:1 chats to their phone number JID so that
+// history lookups by phone number find messages regardless of the addressing mode used.
+func canonicalChatJID(ctx context.Context, client *whatsmeow.Client, info types.MessageInfo) types.JID {
+	chat := info.Chat.ToNonAD()
+	if chat.Server != types.HiddenUserServer {
+		return chat
+	}
+	if info.IsFromMe && info.RecipientAlt.Server == types.DefaultUserServer {
+		return info.RecipientAlt.ToNonAD()
+	}
+	if !info.IsFromMe && info.SenderAlt.Server == types.DefaultUserServer {
+		return info.SenderAlt.ToNonAD()
+	}
+	if client != nil && client.Store != nil && client.Store.LIDs != nil {
+		if pn, err := client.Store.LIDs.GetPNForLID(ctx, chat); err == nil && !pn.IsEmpty() {
+			return pn.ToNonAD()
+		}
+	}
+	return chat
+}
+
+type storedMessageRow struct {
+	deviceID  string
+	chatJID   string
+	messageID string
+	senderJID string
+	pushName  string
+	isFromMe  bool
+	isGroup   bool
+	content   messageContent
+	raw       []byte
+	timestamp time.Time
+}
+
+type messageExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func upsertStoredMessage(ctx context.Context, execer messageExecer, row storedMessageRow) error {
+	var media interface{}
+	if row.content.Media != nil {
+		encoded, err := json.Marshal(row.content.Media)
+		if err != nil {
+			return err
+		}
+		media = string(encoded)
+	}
+	ts := row.timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	_, err := execer.ExecContext(ctx, `
+		INSERT INTO wa_messages (device_id, chat_jid, message_id, sender_jid, push_name, is_from_me, is_group, message_type, text, caption, media, raw_message, message_timestamp)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), $11::jsonb, $12, $13)
+		ON CONFLICT (device_id, chat_jid, message_id) DO UPDATE
+		SET sender_jid = EXCLUDED.sender_jid,
+		    push_name = COALESCE(EXCLUDED.push_name, wa_messages.push_name),
+		    message_type = EXCLUDED.message_type,
+		    text = EXCLUDED.text,
+		    caption = EXCLUDED.caption,
+		    media = EXCLUDED.media,
+		    raw_message = EXCLUDED.raw_message,
+		    updated_at = NOW()
+	`, row.deviceID, row.chatJID, row.messageID, row.senderJID, row.pushName, row.isFromMe, row.isGroup,
+		row.content.Type, row.content.Text, row.content.Caption, media, row.raw, ts.UTC())
+	return err
+}
+
+func buildStoredMessageRow(ctx context.Context, deviceID string, client *whatsmeow.Client, info types.MessageInfo, msg *waE2E.Message) (storedMessageRow, bool) {
+	content := extractMessageContent(msg)
+	if content.Type == "" || info.ID == "" {
+		return storedMessageRow{}, false
+	}
+	raw, err := proto.Marshal(msg)
+	if err != nil {
+		raw = nil
+	}
+	return storedMessageRow{
+		deviceID:  deviceID,
+		chatJID:   canonicalChatJID(ctx, client, info).String(),
+		messageID: info.ID,
+		senderJID: info.Sender.ToNonAD().String(),
+		pushName:  info.PushName,
+		isFromMe:  info.IsFromMe,
+		isGroup:   info.IsGroup,
+		content:   content,
+		raw:       raw,
+		timestamp: info.Timestamp,
+	}, true
+}
+
+// storeMessageEvent queues an incoming (or own-device echoed) message for the message store.
+// Edits and revokes update the original row instead of creating a new one.
+func storeMessageEvent(deviceID string, client *whatsmeow.Client, evt *events.Message) {
+	if !messageStoreEnabled || evt == nil || evt.Message == nil {
+		return
+	}
+
+	if protocol := evt.Message.GetProtocolMessage(); protocol != nil {
+		targetID := protocol.GetKey().GetID()
+		if targetID == "" {
+			return
+		}
+		info := evt.Info
+		switch protocol.GetType() {
+		case waE2E.ProtocolMessage_REVOKE:
+			messageStoreWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+				_, err := execer.ExecContext(ctx, `
+					UPDATE wa_messages
+					SET message_type = 'revoked', text = NULL, caption = NULL, media = NULL, raw_message = NULL, updated_at = NOW()
+					WHERE device_id = $1 AND chat_jid = $2 AND message_id = $3
+				`, deviceID, canonicalChatJID(ctx, client, info).String(), targetID)
+				return err
+			})
+		case waE2E.ProtocolMessage_MESSAGE_EDIT:
+			edited := extractMessageContent(protocol.GetEditedMessage())
+			if edited.Type == "" {
+				return
+			}
+			messageStoreWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+				_, err := execer.ExecContext(ctx, `
+					UPDATE wa_messages
+					SET text = COALESCE(NULLIF($4, ''), text), caption = COALESCE(NULLIF($5, ''), caption), is_edited = TRUE, updated_at = NOW()
+					WHERE device_id = $1 AND chat_jid = $2 AND message_id = $3
+				`, deviceID, canonicalChatJID(ctx, client, info).String(), targetID, edited.Text, edited.Caption)
+				return err
+			})
+		}
+		return
+	}
+
+	info, msg := evt.Info, evt.Message
+	messageStoreWriter.enqueue(deviceID, func(ctx context.Context, execer messageExecer) error {
+		row, ok := buildStoredMessageRow(ctx, deviceID, client, info, msg)
+		if !ok {
+			return nil
+		}
+		return upsertStoredMessage(ctx, execer, row)
+	})
+}
+
+// storeHistorySync persists every message of a history sync blob in a single transaction.
+func storeHistorySync(deviceID string, client *whatsmeow.Client, data *waHistorySync.HistorySync) {
+	if !messageStoreEnabled || data == nil || client == nil || len(data.GetConversations()) == 0 {
+		return
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.DeviceOp(deviceID, "", "StoreHistorySync").WithError(err).Warn("Failed to begin message store transaction")
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stored := 0
+	for _, conv := range data.GetConversations() {
+		chatJID, err := types.ParseJID(firstNonEmpty(conv.GetPnJID(), conv.GetID()))
+		if err != nil {
+			continue
+		}
+		for _, histMsg := range conv.GetMessages() {
+			webMsg := histMsg.GetMessage()
+			if webMsg == nil || webMsg.GetMessage() == nil {
+				continue
+			}
+			evt, err := client.ParseWebMessage(chatJID, webMsg)
+			if err != nil {
+				continue
+			}
+			row, ok := buildStoredMessageRow(ctx, deviceID, client, evt.Info, evt.Message)
+			if !ok {
+				continue
+			}
+			if err := upsertStoredMessage(ctx, tx, row); err != nil {
+				log.DeviceOp(deviceID, "", "StoreHistorySync").WithError(err).Warn("Failed to store history message")
+				return
+			}
+			stored++
+			if poll := pollCreationFromMessage(evt.Message); poll != nil {
+				storePollCreation(deviceID, row.chatJID, evt.Info.Sender.ToNonAD().String(), evt.Info.ID, poll, evt.Info.Timestamp)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.DeviceOp(deviceID, "", "StoreHistorySync").WithError(err).Warn("Failed to commit history sync messages")
+		return
+	}
+	if stored > 0 {
+		log.DeviceOp(deviceID, "", "StoreHistorySync").WithField("stored", stored).Debug("History sync messages stored")
+	}
+}
+
+// storeSentMessage persists a message sent through this API.
+func storeSentMessage(deviceID string, client *whatsmeow.Client, to types.JID, msgID string, msg *waE2E.Message, ts time.Time) {
+	if !messageStoreEnabled || client == nil || client.Store == nil {
+		return
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), messageStoreWriteTimeout)
+	defer cancel()
+
+	info := types.MessageInfo{
+		MessageSource: types.MessageSource{
+			Chat:     to,
+			Sender:   client.Store.GetJID(),
+			IsFromMe: true,
+			IsGroup:  to.Server == types.GroupServer,
+		},
+		ID:        msgID,
+		PushName:  client.Store.PushName,
+		Timestamp: ts,
+	}
+	row, ok := buildStoredMessageRow(ctx, deviceID, client, info, msg)
+	if !ok {
+		return
+	}
+	if err := upsertStoredMessage(ctx, db, row); err != nil {
+		log.MessageOp(deviceID, "", "StoreMessage", row.chatJID).WithError(err).Warn("Failed to store sent message")
+	}
+	if poll := pollCreationFromMessage(msg); poll != nil {
+		storePollCreation(deviceID, row.chatJID, info.Sender.ToNonAD().String(), msgID, poll, ts)
+	}
+}
+
+// sendAndStoreMessage applies per-send options (quoted replies), sends the message
+// and records it in the message store on success. Its delivery status is tracked from
+// the moment it is sent.
+func sendAndStoreMessage(ctx context.Context, client *whatsmeow.Client, deviceID string, to types.JID, msg *waE2E.Message, extra whatsmeow.SendRequestExtra, opts *SendOptions) (whatsmeow.SendResponse, error) {
+	if opts != nil && opts.ReplyTo != nil {
+		replyContext, err := resolveReplyContext(ctx, deviceID, opts.ReplyTo)
+		if err != nil {
+			return whatsmeow.SendResponse{}, err
+		}
+		applyReplyContext(msg, replyContext)
+	}
+	if err := applyMentions(ctx, client, to, msg, opts); err != nil {
+		return whatsmeow.SendResponse{}, err
+	}
+	if extra.ID == "" {
+		extra.ID = client.GenerateMessageID()
+	}
+	ref := sendReference(opts)
+	recordMessageSending(deviceID, to, extra.ID, time.Now())
+	recordMessageReference(deviceID, to, extra.ID, ref)
+	resp, err := client.SendMessage(ctx, to, msg, extra)
+	recordMessageSendResult(deviceID, extra.ID, resp.Timestamp, err)
+	if err != nil {
+		forgetMessageReference(deviceID, extra.ID, ref)
+		return resp, err
+	}
+	storeSentMessage(deviceID, client, to, resp.ID, msg, resp.Timestamp)
+	dispatchMessageSent(deviceID, to, resp, ref)
+	return resp, nil
+}
+
+// GetStoredMessages pages through stored messages of a chat, newest first.
+// before/after are message IDs used as cursors; when both are empty the latest messages are returned.
+func GetStoredMessages(ctx context.Context, deviceID string, chatJID string, limit int, before string, after string) ([]StoredMessage, bool, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, false, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > messageStoreMaxPageSize {
+		limit = messageStoreMaxPageSize
+	}
+
+	cursorID := before
+	comparison := "<"
+	order := "DESC"
+	if before == "" && after != "" {
+		cursorID = after
+		comparison = ">"
+		order = "ASC"
+	}
+
+	args := []interface{}{deviceID, chatJID, limit + 1}
+	where := ""
+	if cursorID != "" {
+		var cursorTS time.Time
+		err = db.QueryRowContext(ctx, `SELECT message_timestamp FROM wa_messages WHERE device_id = $1 AND chat_jid = $2 AND message_id = $3`,
+			deviceID, chatJID, cursorID).Scan(&cursorTS)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, ErrStoredMessageNotFound
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		args = append(args, cursorTS, cursorID)
+		where = fmt.Sprintf(" AND (message_timestamp, message_id) %s ($4, $5)", comparison)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT message_id, chat_jid, sender_jid, push_name, is_from_me, is_group, message_type, text, caption, media, is_edited, message_timestamp
+		FROM wa_messages
+		WHERE device_id = $1 AND chat_jid = $2`+where+`
+		ORDER BY message_timestamp `+order+`, message_id `+order+`
+		LIMIT $3
+	`, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	messages := make([]StoredMessage, 0, limit)
+	for rows.Next() {
+		var m StoredMessage
+		var pushName, text, caption sql.NullString
+		var media []byte
+		var ts time.Time
+		if err := rows.Scan(&m.MessageID, &m.ChatJID, &m.SenderJID, &pushName, &m.IsFromMe, &m.IsGroup, &m.Type, &text, &caption, &media, &m.IsEdited, &ts); err != nil {
+			return nil, false, err
+		}
+		m.PushName = pushName.String
+		m.Text = text.String
+		m.Caption = caption.String
+		if len(media) > 0 {
+			var sm StoredMedia
+			if json.Unmarshal(media, &sm) == nil {
+				m.Media = &sm
+			}
+		}
+		m.Timestamp = ts.Unix()
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	if order == "ASC" {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, hasMore, nil
+}
+
+// storedRawMessage is a stored message with its original protobuf
+type storedRawMessage struct {
+	ChatJID   string
+	SenderJID string
+	IsFromMe  bool
+	IsGroup   bool
+	Message   *waE2E.Message
+}
+
+// loadStoredRawMessage loads the latest stored copy of a message that still has its protobuf.
+// An empty chatJID matches the message in any chat.
+func loadStoredRawMessage(ctx context.Context, deviceID string, chatJID string, messageID string) (*storedRawMessage, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	var stored storedRawMessage
+	var raw []byte
+	err = db.QueryRowContext(ctx, `
+		SELECT chat_jid, sender_jid, is_from_me, is_group, raw_message FROM wa_messages
+		WHERE device_id = $1 AND message_id = $2 AND ($3 = '' OR chat_jid = $3) AND raw_message IS NOT NULL
+		ORDER BY message_timestamp DESC
+		LIMIT 1
+	`, deviceID, messageID, chatJID).Scan(&stored.ChatJID, &stored.SenderJID, &stored.IsFromMe, &stored.IsGroup, &raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrStoredMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored.Message = &waE2E.Message{}
+	if err := proto.Unmarshal(raw, stored.Message); err != nil {
+		return nil, fmt.Errorf("failed to decode stored message: %w", err)
+	}
+	return &stored, nil
+}
+
+// GetStoredRawMessage loads the original message protobuf of a stored message by its ID
+func GetStoredRawMessage(ctx context.Context, deviceID string, messageID string) (*waE2E.Message, error) {
+	stored, err := loadStoredRawMessage(ctx, deviceID, "", messageID)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Message, nil
+}
+
+// CleanupOldMessages deletes stored messages older than the retention period
+func CleanupOldMessages(ctx context.Context, retention time.Duration) (int64, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-retention).UTC()
+	result, err := db.ExecContext(ctx, `DELETE FROM wa_messages WHERE message_timestamp < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,515 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/transcode"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhook"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// ErrSendJobNotFound is returned when a send job does not exist for the device
+var ErrSendJobNotFound = errors.New("send job not found")
+
+// Send job statuses
+const (
+	JobQueued  = "queued"
+	JobSending = "sending"
+	JobSent    = "sent"
+	JobFailed  = "failed"
+)
+
+const (
+	// A job stays in "sending" for at most this long before a worker retries it
+	outboxSendLease = 5 * time.Minute
+	// How long a worker waits before checking again whether its device reconnected
+	outboxDeviceWait = 15 * time.Second
+)
+
+var (
+	outboxMaxAttempts  = 5
+	outboxRetryBackoff = 10 * time.Second
+	outboxMaxDelay     = 24 * time.Hour
+
+	outboxWorkersMu sync.Mutex
+	outboxWorkers   = make(map[string]*outboxWorker)
+)
+
+// outboxWorker is the in-process goroutine draining one device's outbox
+type outboxWorker struct {
+	wake chan struct{}
+}
+
+func loadOutboxConfig() {
+	outboxMaxAttempts = ParseOptionalInt("OUTBOX_MAX_ATTEMPTS", 5, 1)
+	outboxRetryBackoff = ParseOptionalDuration("OUTBOX_RETRY_BACKOFF", 10*time.Second)
+	outboxMaxDelay = ParseOptionalDuration("OUTBOX_MAX_DELAY", 24*time.Hour)
+}
+
+// SendJob is a send accepted with async=true. Jobs of a device are sent one at a time in the
+// order they were queued; media bytes are dropped once the job leaves the queue.
+type SendJob struct {
+	ID        int64            `json:"job_id"`
+	DeviceID  string           `json:"device_id"`
+	ChatJID   string           `json:"chat_jid"`
+	Type      string           `json:"type"`
+	Payload   ScheduledPayload `json:"payload"`
+	MediaSize int              `json:"media_size,omitempty"`
+	Status    string           `json:"status"`
+	Attempts  int              `json:"attempts"`
+	MessageID string           `json:"message_id,omitempty"`
+	LastError string           `json:"last_error,omitempty"`
+	SentAt    *time.Time       `json:"sent_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	media     []byte
+	deviceJID string
+}
+
+func ensureOutboxSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_send_jobs (
+		id BIGSERIAL PRIMARY KEY,
+		device_id TEXT NOT NULL,
+		device_jid TEXT NOT NULL DEFAULT '',
+		chat_jid TEXT NOT NULL,
+		message_type TEXT NOT NULL,
+		payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+		media BYTEA,
+		media_size INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'queued',
+		next_attempt_at TIMESTAMP NOT NULL,
+		locked_until TIMESTAMP,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		message_id TEXT NOT NULL DEFAULT '',
+		last_error TEXT NOT NULL DEFAULT '',
+		sent_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_send_jobs_pending ON wa_send_jobs (device_id, id) WHERE status IN ('queued', 'sending')`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_send_jobs_finished ON wa_send_jobs (updated_at) WHERE status IN ('sent', 'failed')`)
+	return err
+}
+
+// CleanupOldSendJobs deletes sent and failed jobs, with any media they still hold, that finished
+// before the retention period. Queued jobs are never deleted.
+func CleanupOldSendJobs(ctx context.Context, retention time.Duration) (int64, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-retention).UTC()
+	result, err := db.ExecContext(ctx, `DELETE FROM wa_send_jobs WHERE status IN ($1, $2) AND updated_at < $3`, JobSent, JobFailed, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// EnqueueSendJob stores a send in the device's outbox and wakes its worker. Media that could
+// never be sent is refused with ErrInvalidQueuedMedia instead of failing in the worker.
+func EnqueueSendJob(ctx context.Context, jid string, deviceID string, chatJID string, msgType string, payload ScheduledPayload, media []byte) (*SendJob, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := validateQueuedMedia(msgType, media, payload.MimeType); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQueuedMedia, err)
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var mediaValue interface{}
+	if len(media) > 0 {
+		mediaValue = media
+	}
+
+	now := time.Now().UTC()
+	job := &SendJob{
+		DeviceID:  deviceID,
+		ChatJID:   chatJID,
+		Type:      msgType,
+		Payload:   payload,
+		MediaSize: len(media),
+		Status:    JobQueued,
+	}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO wa_send_jobs (device_id, device_jid, chat_jid, message_type, payload, media, media_size, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7, $8, $9, $9)
+		RETURNING id, created_at, updated_at
+	`, deviceID, jid, chatJID, msgType, string(rawPayload), mediaValue, len(media), JobQueued, now).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	startOutboxWorker(deviceID)
+	return job, nil
+}
+
+const sendJobColumns = `id, device_id, chat_jid, message_type, payload, media_size, status, attempts, message_id, last_error, sent_at, created_at, updated_at`
+
+// GetSendJob returns one send job of the device
+func GetSendJob(ctx context.Context, deviceID string, id int64) (*SendJob, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	var job SendJob
+	var payload []byte
+	var sentAt sql.NullTime
+	err = db.QueryRowContext(ctx, `
+		SELECT `+sendJobColumns+`
+		FROM wa_send_jobs
+		WHERE device_id = $1 AND id = $2
+	`, deviceID, id).Scan(&job.ID, &job.DeviceID, &job.ChatJID, &job.Type, &payload, &job.MediaSize, &job.Status, &job.Attempts, &job.MessageID, &job.LastError, &sentAt, &job.CreatedAt, &job.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSendJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &job.Payload); err != nil {
+			return nil, fmt.Errorf("failed to decode job payload: %w", err)
+		}
+	}
+	if sentAt.Valid {
+		job.SentAt = &sentAt.Time
+	}
+	return &job, nil
+}
+
+// ResumeOutboxWorkers starts a worker for every device with queued jobs. Workers run in-process,
+// so this picks up jobs queued before a restart.
+func ResumeOutboxWorkers(ctx context.Context) (int, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT device_id FROM wa_send_jobs WHERE status IN ($1, $2)`, JobQueued, JobSending)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var deviceIDs []string
+	for rows.Next() {
+		var deviceID string
+		if err := rows.Scan(&deviceID); err != nil {
+			return 0, err
+		}
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	started := 0
+	for _, deviceID := range deviceIDs {
+		if startOutboxWorker(deviceID) {
+			started++
+		}
+	}
+	return started, nil
+}
+
+// startOutboxWorker starts the device's worker, or wakes it when it is already running
+func startOutboxWorker(deviceID string) bool {
+	outboxWorkersMu.Lock()
+	defer outboxWorkersMu.Unlock()
+	if w, ok := outboxWorkers[deviceID]; ok {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+		return false
+	}
+	w := &outboxWorker{wake: make(chan struct{}, 1)}
+	outboxWorkers[deviceID] = w
+	go runOutbox(deviceID, w)
+	return true
+}
+
+// stopIfIdle removes the worker once its outbox is empty. A wake-up sent after the outbox was
+// found empty means a job was queued in between, so the worker keeps going.
+func (w *outboxWorker) stopIfIdle(deviceID string) bool {
+	outboxWorkersMu.Lock()
+	defer outboxWorkersMu.Unlock()
+	select {
+	case <-w.wake:
+		return false
+	default:
+	}
+	if outboxWorkers[deviceID] == w {
+		delete(outboxWorkers, deviceID)
+	}
+	return true
+}
+
+// sleep waits for d or until the worker is woken by a new job
+func (w *outboxWorker) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-w.wake:
+	}
+}
+
+// outboxHead is the oldest job of a device that is not sent or failed yet
+type outboxHead struct {
+	id            int64
+	status        string
+	nextAttemptAt time.Time
+	lockedUntil   sql.NullTime
+	createdAt     time.Time
+}
+
+// runOutbox sends the device's jobs strictly in order: a job waiting for a retry holds back the
+// jobs queued after it. While the device is disconnected jobs wait, up to OUTBOX_MAX_DELAY.
+func runOutbox(deviceID string, w *outboxWorker) {
+	db, err := openRoutingDB()
+	if err != nil {
+		log.SysErr("outbox-db", err)
+		w.stopIfIdle(deviceID)
+		return
+	}
+
+	for {
+		head, err := loadOutboxHead(db, deviceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			if w.stopIfIdle(deviceID) {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			log.EvtErr("outbox", "load", deviceID, err)
+			w.sleep(outboxDeviceWait)
+			continue
+		}
+
+		now := time.Now().UTC()
+		if head.status == JobSending && head.lockedUntil.Valid && head.lockedUntil.Time.After(now) {
+			// another instance is sending it
+			w.sleep(outboxDeviceWait)
+			continue
+		}
+		if head.nextAttemptAt.After(now) {
+			w.sleep(head.nextAttemptAt.Sub(now))
+			continue
+		}
+		if err := ensureClientOK(getClientByDeviceID(deviceID)); err != nil {
+			if now.Sub(head.createdAt) > outboxMaxDelay {
+				job, claimErr := claimSendJob(db, head.id)
+				if claimErr != nil {
+					w.sleep(outboxDeviceWait)
+					continue
+				}
+				failSendJob(db, job, fmt.Errorf("device unavailable for %s: %w", outboxMaxDelay, err))
+				continue
+			}
+			w.sleep(outboxDeviceWait)
+			continue
+		}
+
+		job, err := claimSendJob(db, head.id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			log.EvtErr("outbox", "claim", deviceID, err)
+			w.sleep(outboxDeviceWait)
+			continue
+		}
+		processSendJob(db, job)
+	}
+}
+
+func loadOutboxHead(db *sql.DB, deviceID string) (*outboxHead, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var head outboxHead
+	err := db.QueryRowContext(ctx, `
+		SELECT id, status, next_attempt_at, locked_until, created_at
+		FROM wa_send_jobs
+		WHERE device_id = $1 AND status IN ($2, $3)
+		ORDER BY id
+		LIMIT 1
+	`, deviceID, JobQueued, JobSending).Scan(&head.id, &head.status, &head.nextAttemptAt, &head.lockedUntil, &head.createdAt)
+	if err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+// claimSendJob leases a job so other instances skip it. Jobs whose lease expired mid-send are claimed again.
+func claimSendJob(db *sql.DB, id int64) (*SendJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	now := time.Now().UTC()
+	var job SendJob
+	var payload []byte
+	err := db.QueryRowContext(ctx, `
+		UPDATE wa_send_jobs
+		SET status = $2, locked_until = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (status = $4 OR (status = $2 AND locked_until < $5))
+		RETURNING id, device_id, device_jid, chat_jid, message_type, payload, media, attempts, created_at
+	`, id, JobSending, now.Add(outboxSendLease), JobQueued, now).Scan(
+		&job.ID, &job.DeviceID, &job.deviceJID, &job.ChatJID, &job.Type, &payload, &job.media, &job.Attempts, &job.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(payload, &job.Payload); err != nil {
+		return nil, fmt.Errorf("failed to decode job payload: %w", err)
+	}
+	return &job, nil
+}
+
+// permanentSendError is a send failure that every retry would repeat
+type permanentSendError struct {
+	err error
+}
+
+func (e permanentSendError) Error() string { return e.err.Error() }
+func (e permanentSendError) Unwrap() error { return e.err }
+
+// isPermanentSendError reports whether a job can fail right away instead of being retried: its
+// input is invalid, its media cannot be sent, or the recipient or quoted message does not exist
+func isPermanentSendError(err error) bool {
+	var permanent permanentSendError
+	return errors.As(err, &permanent) ||
+		errors.Is(err, ErrJIDNotRegistered) ||
+		errors.Is(err, ErrStoredMessageNotFound) ||
+		errors.Is(err, transcode.ErrUnsupported)
+}
+
+// validateSendJob checks what no retry can fix before a job is sent: the chat JID, the message
+// type, and the size and MIME type of its media
+func validateSendJob(job *SendJob) error {
+	if err := validatePersonalPhoneInput(job.ChatJID); err != nil {
+		return permanentSendError{err}
+	}
+	if strings.ContainsRune(job.ChatJID, '@') {
+		if parsed, err := types.ParseJID(job.ChatJID); err != nil || parsed.User == "" {
+			return permanentSendError{fmt.Errorf("invalid chat JID %q", job.ChatJID)}
+		}
+	}
+	if err := validateQueuedMedia(job.Type, job.media, job.Payload.MimeType); err != nil {
+		return permanentSendError{err}
+	}
+	return nil
+}
+
+// validateQueuedMedia checks the media of a scheduled or async send against the size limit and
+// MIME allowlist of its type. Types without media pass.
+func validateQueuedMedia(msgType string, media []byte, mimeType string) error {
+	switch msgType {
+	case ScheduledText, ScheduledLocation, ScheduledContact, ScheduledPoll:
+		return nil
+	case ScheduledImage, ScheduledVideo, ScheduledAudio, ScheduledDocument, ScheduledSticker:
+	default:
+		return fmt.Errorf("unsupported message type: %s", msgType)
+	}
+	if len(media) == 0 {
+		return fmt.Errorf("%s payload cannot be empty", msgType)
+	}
+	limit, allowed := mediaKindLimits(msgType)
+	if err := enforceSizeLimit(msgType, int64(len(media)), limit); err != nil {
+		return err
+	}
+	_, err := checkMediaMime(msgType, media, mimeType, allowed)
+	return err
+}
+
+// processSendJob sends a claimed job and records the outcome. Permanent errors fail the job on
+// the first attempt; anything else is retried up to OUTBOX_MAX_ATTEMPTS.
+func processSendJob(db *sql.DB, job *SendJob) {
+	var msgID string
+	err := validateSendJob(job)
+	if err == nil {
+		sendCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		msgID, err = sendQueuedMessage(sendCtx, job.deviceJID, job.DeviceID, job.ChatJID, job.Type, job.Payload, job.media)
+		cancel()
+	}
+	job.Attempts++
+	if err != nil {
+		if job.Attempts >= outboxMaxAttempts || isPermanentSendError(err) {
+			failSendJob(db, job, err)
+			return
+		}
+		retrySendJob(db, job, err)
+		return
+	}
+
+	ctx, cancelWrite := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelWrite()
+	_, err = db.ExecContext(ctx, `
+		UPDATE wa_send_jobs
+		SET status = $2, message_id = $3, sent_at = $4, attempts = $5, last_error = '', media = NULL, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, job.ID, JobSent, msgID, time.Now().UTC(), job.Attempts)
+	if err != nil {
+		log.MessageOp(job.DeviceID, "", "OutboxSend", job.ChatJID).WithError(err).Error("Failed to mark send job as sent")
+	}
+	log.MessageOp(job.DeviceID, "", "OutboxSend", job.ChatJID).WithField("job_id", job.ID).WithField("message_id", msgID).Info("Queued message sent")
+}
+
+func retrySendJob(db *sql.DB, job *SendJob, sendErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	next := time.Now().UTC().Add(outboxRetryBackoff * time.Duration(job.Attempts))
+	_, err := db.ExecContext(ctx, `
+		UPDATE wa_send_jobs
+		SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, job.ID, JobQueued, job.Attempts, sendErr.Error(), next)
+	if err != nil {
+		log.SysErr("outbox-retry", err)
+	}
+	log.MessageOp(job.DeviceID, "", "OutboxSend", job.ChatJID).WithError(sendErr).WithField("job_id", job.ID).WithField("attempts", job.Attempts).Warn("Queued message send failed, will retry")
+}
+
+func failSendJob(db *sql.DB, job *SendJob, sendErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := db.ExecContext(ctx, `
+		UPDATE wa_send_jobs
+		SET status = $2, attempts = $3, last_error = $4, media = NULL, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, job.ID, JobFailed, job.Attempts, sendErr.Error())
+	if err != nil {
+		log.SysErr("outbox-fail", err)
+	}
+	log.MessageOp(job.DeviceID, "", "OutboxSend", job.ChatJID).WithError(sendErr).WithField("job_id", job.ID).WithField("attempts", job.Attempts).Error("Queued message failed")
+
+	dispatchWebhook(job.DeviceID, webhook.EventMessageSendFailed, map[string]interface{}{
+		"job_id":    job.ID,
+		"chat":      job.ChatJID,
+		"type":      job.Type,
+		"attempts":  job.Attempts,
+		"queued_at": job.CreatedAt.UTC().Format(time.RFC3339),
+		"error":     sendErr.Error(),
+	})
+}
@@ -0,0 +1,277 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// ErrPollNotFound is returned when poll results are requested for a poll that was never stored
+var ErrPollNotFound = errors.New("poll not found")
+
+// PollOptionResult holds the tally of a single poll option
+type PollOptionResult struct {
+	Name      string   `json:"name"`
+	Hash      string   `json:"hash"` // hex encoded SHA-256 of the option name
+	VoteCount int      `json:"vote_count"`
+	Voters    []string `json:"voters"`
+}
+
+// PollResults is the aggregated state of a poll, counting only the latest vote of each voter
+type PollResults struct {
+	PollID          string             `json:"poll_id"`
+	ChatJID         string             `json:"chat_jid"`
+	CreatorJID      string             `json:"creator_jid"`
+	Question        string             `json:"question"`
+	SelectableCount int                `json:"selectable_count"`
+	MultiAnswer     bool               `json:"multi_answer"`
+	Options         []PollOptionResult `json:"options"`
+	TotalVoters     int                `json:"total_voters"`
+	TotalVotes      int                `json:"total_votes"`
+	CreatedAt       int64              `json:"created_at"`
+}
+
+type storedPollOption struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+func ensurePollStoreSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_polls (
+		device_id TEXT NOT NULL,
+		poll_id TEXT NOT NULL,
+		chat_jid TEXT NOT NULL,
+		creator_jid TEXT NOT NULL DEFAULT '',
+		question TEXT NOT NULL,
+		selectable_count INTEGER NOT NULL DEFAULT 0,
+		options JSONB NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (device_id, poll_id)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS wa_poll_votes (
+		device_id TEXT NOT NULL,
+		poll_id TEXT NOT NULL,
+		voter_jid TEXT NOT NULL,
+		selected_hashes JSONB NOT NULL DEFAULT '[]'::jsonb,
+		vote_timestamp TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (device_id, poll_id, voter_jid)
+	)`)
+	return err
+}
+
+// pollCreationFromMessage returns the poll creation content regardless of the poll message version
+func pollCreationFromMessage(msg *waE2E.Message) *waE2E.PollCreationMessage {
+	if msg == nil {
+		return nil
+	}
+	switch {
+	case msg.GetPollCreationMessage() != nil:
+		return msg.GetPollCreationMessage()
+	case msg.GetPollCreationMessageV2() != nil:
+		return msg.GetPollCreationMessageV2()
+	case msg.GetPollCreationMessageV3() != nil:
+		return msg.GetPollCreationMessageV3()
+	case msg.GetPollCreationMessageV5() != nil:
+		return msg.GetPollCreationMessageV5()
+	}
+	return nil
+}
+
+// preferPhoneJID maps a hidden-user (LID) JID to its phone number JID when the mapping is known,
+// so the same person is keyed identically regardless of addressing mode.
+func preferPhoneJID(ctx context.Context, client *whatsmeow.Client, jid types.JID, alt types.JID) types.JID {
+	jid = jid.ToNonAD()
+	if jid.Server != types.HiddenUserServer {
+		return jid
+	}
+	if alt.Server == types.DefaultUserServer {
+		return alt.ToNonAD()
+	}
+	if client != nil && client.Store != nil && client.Store.LIDs != nil {
+		if pn, err := client.Store.LIDs.GetPNForLID(ctx, jid); err == nil && !pn.IsEmpty() {
+			return pn.ToNonAD()
+		}
+	}
+	return jid
+}
+
+// storePollCreation records a poll and the SHA-256 hashes of its options
+func storePollCreation(deviceID string, chatJID string, creatorJID string, pollID string, poll *waE2E.PollCreationMessage, createdAt time.Time) {
+	if !messageStoreEnabled || poll == nil || pollID == "" {
+		return
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), messageStoreWriteTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(poll.GetOptions()))
+	for _, opt := range poll.GetOptions() {
+		names = append(names, opt.GetOptionName())
+	}
+	hashes := whatsmeow.HashPollOptions(names)
+	options := make([]storedPollOption, len(names))
+	for i, name := range names {
+		options[i] = storedPollOption{Name: name, Hash: hex.EncodeToString(hashes[i])}
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return
+	}
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO wa_polls (device_id, poll_id, chat_jid, creator_jid, question, selectable_count, options, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8)
+		ON CONFLICT (device_id, poll_id) DO NOTHING
+	`, deviceID, pollID, chatJID, creatorJID, poll.GetName(), int(poll.GetSelectableOptionsCount()), string(encoded), createdAt.UTC())
+	if err != nil {
+		log.Print(nil).WithField("device_id", deviceID).WithField("poll_id", pollID).WithError(err).Warn("Failed to store poll")
+	}
+}
+
+// storePollVote records a voter's selection; an older vote never overwrites a newer one
+func storePollVote(deviceID string, pollID string, voterJID string, selectedHashes [][]byte, votedAt time.Time) {
+	if !messageStoreEnabled || pollID == "" || voterJID == "" {
+		return
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), messageStoreWriteTimeout)
+	defer cancel()
+
+	selected := make([]string, 0, len(selectedHashes))
+	for _, h := range selectedHashes {
+		selected = append(selected, hex.EncodeToString(h))
+	}
+	encoded, err := json.Marshal(selected)
+	if err != nil {
+		return
+	}
+	if votedAt.IsZero() {
+		votedAt = time.Now()
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO wa_poll_votes (device_id, poll_id, voter_jid, selected_hashes, vote_timestamp, updated_at)
+		VALUES ($1, $2, $3, $4::jsonb, $5, NOW())
+		ON CONFLICT (device_id, poll_id, voter_jid) DO UPDATE
+		SET selected_hashes = EXCLUDED.selected_hashes,
+		    vote_timestamp = EXCLUDED.vote_timestamp,
+		    updated_at = NOW()
+		WHERE wa_poll_votes.vote_timestamp <= EXCLUDED.vote_timestamp
+	`, deviceID, pollID, voterJID, string(encoded), votedAt.UTC())
+	if err != nil {
+		log.Print(nil).WithField("device_id", deviceID).WithField("poll_id", pollID).WithError(err).Warn("Failed to store poll vote")
+	}
+}
+
+// getStoredPollCreator returns the sender of a stored poll, needed to encrypt votes for it
+func getStoredPollCreator(ctx context.Context, deviceID string, pollID string) (types.JID, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return types.EmptyJID, err
+	}
+	var creator string
+	err = db.QueryRowContext(ctx, `SELECT creator_jid FROM wa_polls WHERE device_id = $1 AND poll_id = $2`, deviceID, pollID).Scan(&creator)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.EmptyJID, ErrPollNotFound
+	}
+	if err != nil {
+		return types.EmptyJID, err
+	}
+	return types.ParseJID(creator)
+}
+
+// GetPollResults aggregates the stored votes of a poll
+func GetPollResults(ctx context.Context, deviceID string, pollID string) (*PollResults, error) {
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+
+	results := &PollResults{PollID: pollID}
+	var optionsRaw []byte
+	var createdAt time.Time
+	err = db.QueryRowContext(ctx, `
+		SELECT chat_jid, creator_jid, question, selectable_count, options, created_at
+		FROM wa_polls WHERE device_id = $1 AND poll_id = $2
+	`, deviceID, pollID).Scan(&results.ChatJID, &results.CreatorJID, &results.Question, &results.SelectableCount, &optionsRaw, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	results.CreatedAt = createdAt.Unix()
+
+	var options []storedPollOption
+	if err := json.Unmarshal(optionsRaw, &options); err != nil {
+		return nil, err
+	}
+	// selectable_count of 0 means "any number of options", 1 means single choice
+	results.MultiAnswer = results.SelectableCount != 1
+	results.Options = make([]PollOptionResult, len(options))
+	optionIndex := make(map[string]int, len(options))
+	for i, opt := range options {
+		results.Options[i] = PollOptionResult{Name: opt.Name, Hash: opt.Hash, Voters: []string{}}
+		optionIndex[opt.Hash] = i
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT voter_jid, selected_hashes FROM wa_poll_votes
+		WHERE device_id = $1 AND poll_id = $2
+		ORDER BY vote_timestamp ASC
+	`, deviceID, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var voter string
+		var selectedRaw []byte
+		if err := rows.Scan(&voter, &selectedRaw); err != nil {
+			return nil, err
+		}
+		var selected []string
+		if err := json.Unmarshal(selectedRaw, &selected); err != nil {
+			continue
+		}
+		counted := false
+		seen := make(map[int]bool, len(selected))
+		for _, hash := range selected {
+			idx, ok := optionIndex[hash]
+			if !ok || seen[idx] {
+				continue
+			}
+			seen[idx] = true
+			results.Options[idx].VoteCount++
+			results.Options[idx].Voters = append(results.Options[idx].Voters, voter)
+			results.TotalVotes++
+			counted = true
+		}
+		// An empty selection means the voter retracted their vote
+		if counted {
+			results.TotalVoters++
+		}
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,25 @@
+package whatsapp
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types/events"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// receiptWriter carries the delivery tracking writes of sends and receipts. Sharing one ordered
+// queue means a receipt is never applied before the row written when its message was sent.
+var receiptWriter *asyncWriter
+
+func loadReceiptWriterConfig() {
+	queueSize := ParseOptionalInt("WHATSAPP_RECEIPT_QUEUE_SIZE", 10000, 100)
+	receiptWriter = newAsyncWriter("TrackReceipt", queueSize)
+	log.Sys("cfg", fmt.Sprintf("receipt_queue_size:%d", queueSize))
+}
+
+// trackReceipt queues the bookkeeping a receipt triggers; nothing here waits on the database
+func trackReceipt(deviceID string, evt *events.Receipt) {
+	trackCampaignReceipt(deviceID, evt)
+	trackMessageReceipt(deviceID, evt)
+}
@@ -0,0 +1,134 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// ReplyTarget identifies the message a send should quote.
+// When Message is nil the quoted content and participant are loaded from the message store.
+type ReplyTarget struct {
+	MessageID   string
+	Participant string
+	Message     *waE2E.Message
+}
+
+// WhatsAppGetReplyChat returns the chat a stored message belongs to, so replies can omit chat_jid
+func WhatsAppGetReplyChat(ctx context.Context, deviceID string, messageID string) (string, error) {
+	q, err := loadStoredRawMessage(ctx, deviceID, "", messageID)
+	if err != nil {
+		return "", err
+	}
+	return q.ChatJID, nil
+}
+
+// resolveReplyContext builds the ContextInfo that turns a message into a quoted reply
+func resolveReplyContext(ctx context.Context, deviceID string, target *ReplyTarget) (*waE2E.ContextInfo, error) {
+	if target == nil || target.MessageID == "" {
+		return nil, nil
+	}
+	quoted := target.Message
+	participant := target.Participant
+	if quoted == nil || participant == "" {
+		stored, err := loadStoredRawMessage(ctx, deviceID, "", target.MessageID)
+		switch {
+		case err == nil:
+			if quoted == nil {
+				quoted = stored.Message
+			}
+			if participant == "" {
+				participant = stored.SenderJID
+			}
+		case errors.Is(err, ErrStoredMessageNotFound) && quoted != nil:
+			// Caller supplied the quoted content itself; nothing more to load
+		default:
+			return nil, err
+		}
+	}
+	contextInfo := &waE2E.ContextInfo{
+		StanzaID:      proto.String(target.MessageID),
+		QuotedMessage: stripQuotedContext(quoted),
+	}
+	if participant != "" {
+		if parsed, err := types.ParseJID(participant); err == nil {
+			contextInfo.Participant = proto.String(parsed.ToNonAD().String())
+		}
+	}
+	return contextInfo, nil
+}
+
+// stripQuotedContext drops nested context info (quotes of quotes, forwarding data) from quoted content
+func stripQuotedContext(msg *waE2E.Message) *waE2E.Message {
+	if msg == nil {
+		return nil
+	}
+	quoted := proto.Clone(msg).(*waE2E.Message)
+	quoted.MessageContextInfo = nil
+	if slot := contextInfoSlot(quoted); slot != nil {
+		*slot = nil
+	}
+	return quoted
+}
+
+// contextInfoSlot points at the ContextInfo field of the message's primary content
+func contextInfoSlot(msg *waE2E.Message) **waE2E.ContextInfo {
+	switch {
+	case msg.ExtendedTextMessage != nil:
+		return &msg.ExtendedTextMessage.ContextInfo
+	case msg.ImageMessage != nil:
+		return &msg.ImageMessage.ContextInfo
+	case msg.VideoMessage != nil:
+		return &msg.VideoMessage.ContextInfo
+	case msg.AudioMessage != nil:
+		return &msg.AudioMessage.ContextInfo
+	case msg.DocumentMessage != nil:
+		return &msg.DocumentMessage.ContextInfo
+	case msg.StickerMessage != nil:
+		return &msg.StickerMessage.ContextInfo
+	case msg.LocationMessage != nil:
+		return &msg.LocationMessage.ContextInfo
+	case msg.ContactMessage != nil:
+		return &msg.ContactMessage.ContextInfo
+	case msg.ContactsArrayMessage != nil:
+		return &msg.ContactsArrayMessage.ContextInfo
+	}
+	return nil
+}
+
+// ensureContextInfo returns the ContextInfo of an outgoing message, allocating it when missing.
+// Plain conversation text is promoted to an ExtendedTextMessage since Conversation cannot carry context info.
+func ensureContextInfo(msg *waE2E.Message) *waE2E.ContextInfo {
+	if msg == nil {
+		return nil
+	}
+	if msg.Conversation != nil {
+		msg.ExtendedTextMessage = &waE2E.ExtendedTextMessage{Text: msg.Conversation}
+		msg.Conversation = nil
+	}
+	slot := contextInfoSlot(msg)
+	if slot == nil {
+		return nil
+	}
+	if *slot == nil {
+		*slot = &waE2E.ContextInfo{}
+	}
+	return *slot
+}
+
+// applyReplyContext attaches quote information to an outgoing message
+func applyReplyContext(msg *waE2E.Message, replyContext *waE2E.ContextInfo) {
+	if replyContext == nil {
+		return
+	}
+	ci := ensureContextInfo(msg)
+	if ci == nil {
+		return
+	}
+	ci.StanzaID = replyContext.StanzaID
+	ci.Participant = replyContext.Participant
+	ci.QuotedMessage = replyContext.QuotedMessage
+}
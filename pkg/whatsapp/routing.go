@@ -155,6 +155,10 @@ func openRoutingDB() (*sql.DB, error) {
 			secret TEXT NOT NULL,
 			events JSONB NOT NULL DEFAULT '["message.received","connection.connected","connection.disconnected"]'::jsonb,
 			active BOOLEAN NOT NULL DEFAULT TRUE,
+			include_raw BOOLEAN NOT NULL DEFAULT FALSE,
+			consecutive_failures INTEGER NOT NULL DEFAULT 0,
+			circuit_state TEXT NOT NULL DEFAULT 'closed',
+			circuit_opened_at TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`)
@@ -169,6 +173,8 @@ func openRoutingDB() (*sql.DB, error) {
 			status TEXT NOT NULL,
 			attempt_count INTEGER NOT NULL DEFAULT 0,
 			last_error TEXT,
+			payload JSONB,
+			next_attempt_at TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`)
@@ -176,6 +182,37 @@ func openRoutingDB() (*sql.DB, error) {
 			routingErr = err
 			return
 		}
+		_, err = db.Exec(`CREATE TABLE IF NOT EXISTS wa_webhook_alerts (
+			id BIGSERIAL PRIMARY KEY,
+			webhook_id INTEGER NOT NULL,
+			device_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			consecutive_failures INTEGER NOT NULL DEFAULT 0,
+			dead_lettered BIGINT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+		if err != nil {
+			routingErr = err
+			return
+		}
+		_, err = db.Exec(`CREATE TABLE IF NOT EXISTS wa_event_log (
+			id BIGSERIAL PRIMARY KEY,
+			device_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+		if err != nil {
+			routingErr = err
+			return
+		}
+		_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_event_log_device ON wa_event_log(device_id, id)`)
+		if err != nil {
+			routingErr = err
+			return
+		}
 		_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_webhooks_device ON wa_webhooks(device_id)`)
 		if err != nil {
 			routingErr = err
@@ -291,6 +328,67 @@ func openRoutingDB() (*sql.DB, error) {
 			return
 		}
 
+		// Per-device toggle for archiving incoming media to the storage backend
+		_, err = db.Exec(`ALTER TABLE devices ADD COLUMN IF NOT EXISTS media_auto_download BOOLEAN DEFAULT FALSE`)
+		if err != nil {
+			routingErr = err
+			return
+		}
+
+		// Per-device message store backing chat history
+		if err := ensureMessageStoreSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		// Lifecycle of messages sent through the API, advanced by receipts
+		if err := ensureMessageStatusSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		// client_reference and metadata of sends, echoed in their webhooks
+		if err := ensureMessageReferenceSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		if err := ensurePollStoreSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		// Sends queued with send_at, picked up by the scheduler cron
+		if err := ensureScheduledMessageSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		// Sends accepted with async=true, drained in order by per-device workers
+		if err := ensureOutboxSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		// Idempotency-Key reservations and the responses replayed to retries
+		if err := ensureIdempotencySchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		// Broadcast campaigns and their per-recipient delivery state
+		if err := ensureCampaignSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		if err := ensureMessageTemplateSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		// Resumable media uploads referenced by upload_id
+		if err := ensureMediaUploadSchema(db); err != nil {
+			routingErr = err
+			return
+		}
+		// Per-device media library sent by media_id
+		if err := ensureMediaLibrarySchema(db); err != nil {
+			routingErr = err
+			return
+		}
+
 		routingDB = db
 	})
 	return routingDB, routingErr
@@ -341,6 +439,26 @@ func ensureWebhookSchema(db *sql.DB) error {
 			return err
 		}
 	}
+	if ok, _ := columnExists(db, "wa_webhooks", "include_raw"); !ok {
+		if _, err := db.Exec(`ALTER TABLE wa_webhooks ADD COLUMN include_raw BOOLEAN NOT NULL DEFAULT FALSE`); err != nil {
+			return err
+		}
+	}
+	if ok, _ := columnExists(db, "wa_webhooks", "consecutive_failures"); !ok {
+		if _, err := db.Exec(`ALTER TABLE wa_webhooks ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0`); err != nil {
+			return err
+		}
+	}
+	if ok, _ := columnExists(db, "wa_webhooks", "circuit_state"); !ok {
+		if _, err := db.Exec(`ALTER TABLE wa_webhooks ADD COLUMN circuit_state TEXT NOT NULL DEFAULT 'closed'`); err != nil {
+			return err
+		}
+	}
+	if ok, _ := columnExists(db, "wa_webhooks", "circuit_opened_at"); !ok {
+		if _, err := db.Exec(`ALTER TABLE wa_webhooks ADD COLUMN circuit_opened_at TIMESTAMP`); err != nil {
+			return err
+		}
+	}
 
 	if ok, _ := columnExists(db, "wa_webhook_deliveries", "attempts"); ok {
 		if ok2, _ := columnExists(db, "wa_webhook_deliveries", "attempt_count"); !ok2 {
@@ -371,6 +489,20 @@ func ensureWebhookSchema(db *sql.DB) error {
 			return err
 		}
 	}
+	if ok, _ := columnExists(db, "wa_webhook_deliveries", "payload"); !ok {
+		if _, err := db.Exec(`ALTER TABLE wa_webhook_deliveries ADD COLUMN payload JSONB`); err != nil {
+			return err
+		}
+	}
+	if ok, _ := columnExists(db, "wa_webhook_deliveries", "next_attempt_at"); !ok {
+		if _, err := db.Exec(`ALTER TABLE wa_webhook_deliveries ADD COLUMN next_attempt_at TIMESTAMP`); err != nil {
+			return err
+		}
+	}
+	// Partial index keeps queue polling cheap while finished deliveries pile up
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_wa_webhook_deliveries_queue ON wa_webhook_deliveries(next_attempt_at) WHERE status IN ('pending', 'retrying', 'processing')`); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -781,6 +913,7 @@ func UpdateAPIKey(ctx context.Context, id int64, customerName, customerEmail, cu
 		SET customer_name = $2, customer_email = $3, customer_phone = $4, max_devices = $5, rate_limit_per_hour = $6, is_active = $7, updated_at = NOW()
 		WHERE id = $1
 	`, id, customerName, customerEmail, customerPhone, maxDevices, rateLimitPerHour, isActive)
+	InvalidateAPIKeyRateLimitCache(id)
 	return err
 }
 
@@ -799,6 +932,7 @@ func DeleteAPIKey(ctx context.Context, id int64) error {
 	}
 
 	_, err = db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	InvalidateAPIKeyRateLimitCache(id)
 	return err
 }
 
@@ -1341,6 +1475,9 @@ type AdminStats struct {
 	LoggedOutDevices   int `json:"logged_out_devices"`
 	TotalWebhooks      int `json:"total_webhooks"`
 	ActiveWebhooks     int `json:"active_webhooks"`
+
+	// Quota checks let through by API_KEY_RATE_LIMIT_FAIL_OPEN since startup
+	APIKeyQuotaFailures int64 `json:"api_key_quota_failures"`
 }
 
 // ListAllDevices retrieves all devices across all API keys with customer info
@@ -1429,6 +1566,7 @@ func GetAdminStats(ctx context.Context) (*AdminStats, error) {
 	if err != nil {
 		return nil, err
 	}
+	stats.APIKeyQuotaFailures = APIKeyQuotaFailures()
 
 	return stats, nil
 }
@@ -1451,6 +1589,10 @@ func GetWebhookStats(ctx context.Context) (map[string]interface{}, error) {
 	_ = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM wa_webhook_deliveries WHERE status = 'success'`).Scan(&successDeliveries)
 	_ = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM wa_webhook_deliveries WHERE status = 'failed'`).Scan(&failedDeliveries)
 
+	var openCircuits, deadLettered int
+	_ = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM wa_webhooks WHERE circuit_state <> 'closed'`).Scan(&openCircuits)
+	_ = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM wa_webhook_deliveries WHERE status = 'dead_letter'`).Scan(&deadLettered)
+
 	var successRate float64
 	if totalDeliveries > 0 {
 		successRate = float64(successDeliveries) / float64(totalDeliveries) * 100
@@ -1462,6 +1604,8 @@ func GetWebhookStats(ctx context.Context) (map[string]interface{}, error) {
 	stats["success_deliveries"] = successDeliveries
 	stats["failed_deliveries"] = failedDeliveries
 	stats["success_rate"] = successRate
+	stats["open_circuits"] = openCircuits
+	stats["dead_letter_deliveries"] = deadLettered
 
 	return stats, nil
 }
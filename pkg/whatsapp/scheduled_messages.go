@@ -0,0 +1,615 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/transcode"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhook"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+var (
+	// ErrScheduledMessageNotFound is returned when a scheduled message does not exist for the device
+	ErrScheduledMessageNotFound = errors.New("scheduled message not found")
+	// ErrScheduledMessageNotPending is returned when cancelling or rescheduling a message that already left the queue
+	ErrScheduledMessageNotPending = errors.New("scheduled message is no longer pending")
+	// ErrInvalidQueuedMedia is returned when the media of a scheduled or async send could never be sent
+	ErrInvalidQueuedMedia = errors.New("invalid media")
+)
+
+// Scheduled message types; each one maps to the WhatsAppSend* function used at send time
+const (
+	ScheduledText     = "text"
+	ScheduledImage    = "image"
+	ScheduledVideo    = "video"
+	ScheduledAudio    = "audio"
+	ScheduledDocument = "document"
+	ScheduledSticker  = "sticker"
+	ScheduledLocation = "location"
+	ScheduledContact  = "contact"
+	ScheduledPoll     = "poll"
+)
+
+// Scheduled message statuses
+const (
+	ScheduledPending   = "pending"
+	ScheduledSending   = "sending"
+	ScheduledSent      = "sent"
+	ScheduledFailed    = "failed"
+	ScheduledCancelled = "cancelled"
+)
+
+const (
+	// A row stays in "sending" for at most this long; after that another tick may pick it up again
+	scheduledSendLease = 5 * time.Minute
+	// How long a message waits before checking again whether its device reconnected
+	scheduledDeviceWait = 30 * time.Second
+)
+
+var (
+	scheduledMaxAttempts  = 3
+	scheduledRetryBackoff = time.Minute
+	scheduledMaxDelay     = 24 * time.Hour
+	scheduledBatchSize    = 50
+	scheduledConcurrency  = 4
+	scheduledRunMu        sync.Mutex
+)
+
+func loadScheduledMessageConfig() {
+	scheduledMaxAttempts = ParseOptionalInt("SCHEDULED_MESSAGE_MAX_ATTEMPTS", 3, 1)
+	scheduledRetryBackoff = ParseOptionalDuration("SCHEDULED_MESSAGE_RETRY_BACKOFF", time.Minute)
+	scheduledMaxDelay = ParseOptionalDuration("SCHEDULED_MESSAGE_MAX_DELAY", 24*time.Hour)
+	scheduledBatchSize = ParseOptionalInt("SCHEDULED_MESSAGE_BATCH_SIZE", 50, 1)
+	scheduledConcurrency = ParseOptionalInt("SCHEDULED_MESSAGE_CONCURRENCY", 4, 1)
+}
+
+// ScheduledPayload holds the send parameters of a scheduled message; only the fields of its type are set
+type ScheduledPayload struct {
+	Text               string                     `json:"text,omitempty"`
+	Caption            string                     `json:"caption,omitempty"`
+	MimeType           string                     `json:"mimetype,omitempty"`
+	FileName           string                     `json:"filename,omitempty"`
+	ViewOnce           bool                       `json:"view_once,omitempty"`
+	VoiceNote          bool                       `json:"voice_note,omitempty"`
+	Latitude           float64                    `json:"latitude,omitempty"`
+	Longitude          float64                    `json:"longitude,omitempty"`
+	Name               string                     `json:"name,omitempty"`
+	Address            string                     `json:"address,omitempty"`
+	Phone              string                     `json:"phone,omitempty"`
+	Question           string                     `json:"question,omitempty"`
+	Options            []string                   `json:"options,omitempty"`
+	MultiAnswer        bool                       `json:"multi_answer,omitempty"`
+	ReplyToMessageID   string                     `json:"reply_to_message_id,omitempty"`
+	Mentions           []string                   `json:"mentions,omitempty"`
+	MentionAll         bool                       `json:"mention_all,omitempty"`
+	MentionTokens      bool                       `json:"mention_tokens,omitempty"`
+	StickerPack        *transcode.StickerMetadata `json:"sticker_pack,omitempty"`
+	TypingSimulation   *bool                      `json:"typing_simulation,omitempty"`
+	PresenceSimulation *bool                      `json:"presence_simulation,omitempty"`
+	ClientReference    string                     `json:"client_reference,omitempty"`
+	Metadata           map[string]string          `json:"metadata,omitempty"`
+}
+
+// ScheduledMessage is a send queued for a later time. Media bytes are kept in the database
+// until the message leaves the queue and are never returned by the API.
+type ScheduledMessage struct {
+	ID        int64            `json:"id"`
+	DeviceID  string           `json:"device_id"`
+	ChatJID   string           `json:"chat_jid"`
+	Type      string           `json:"type"`
+	Payload   ScheduledPayload `json:"payload"`
+	MediaSize int              `json:"media_size,omitempty"`
+	Status    string           `json:"status"`
+	SendAt    time.Time        `json:"send_at"`
+	Attempts  int              `json:"attempts"`
+	MessageID string           `json:"message_id,omitempty"`
+	LastError string           `json:"last_error,omitempty"`
+	SentAt    *time.Time       `json:"sent_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	media     []byte
+	deviceJID string
+}
+
+func ensureScheduledMessageSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS scheduled_messages (
+		id BIGSERIAL PRIMARY KEY,
+		device_id TEXT NOT NULL,
+		device_jid TEXT NOT NULL DEFAULT '',
+		chat_jid TEXT NOT NULL,
+		message_type TEXT NOT NULL,
+		payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+		media BYTEA,
+		media_size INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'pending',
+		send_at TIMESTAMP NOT NULL,
+		next_attempt_at TIMESTAMP NOT NULL,
+		locked_until TIMESTAMP,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		message_id TEXT NOT NULL DEFAULT '',
+		last_error TEXT NOT NULL DEFAULT '',
+		sent_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages (next_attempt_at) WHERE status IN ('pending', 'sending')`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_scheduled_messages_device ON scheduled_messages (device_id, send_at)`)
+	return err
+}
+
+// ParseSendAt parses the optional send_at field of a send request (RFC3339).
+// It returns nil when the field is empty, meaning the message is sent immediately.
+func ParseSendAt(raw string) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	sendAt, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, errors.New("send_at must be an RFC3339 timestamp")
+	}
+	if !sendAt.After(time.Now()) {
+		return nil, errors.New("send_at must be in the future")
+	}
+	return &sendAt, nil
+}
+
+// ScheduleMessage stores a send to be performed by the scheduler at sendAt. Media is checked
+// against the limits of its type first, so a payload that can never be sent is refused now.
+func ScheduleMessage(ctx context.Context, jid string, deviceID string, chatJID string, msgType string, payload ScheduledPayload, media []byte, sendAt time.Time) (*ScheduledMessage, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := validateQueuedMedia(msgType, media, payload.MimeType); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQueuedMedia, err)
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var mediaValue interface{}
+	if len(media) > 0 {
+		mediaValue = media
+	}
+	sendAt = sendAt.UTC()
+
+	msg := &ScheduledMessage{
+		DeviceID:  deviceID,
+		ChatJID:   chatJID,
+		Type:      msgType,
+		Payload:   payload,
+		MediaSize: len(media),
+		Status:    ScheduledPending,
+		SendAt:    sendAt,
+	}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO scheduled_messages (device_id, device_jid, chat_jid, message_type, payload, media, media_size, status, send_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7, $8, $9, $9)
+		RETURNING id, created_at, updated_at
+	`, deviceID, jid, chatJID, msgType, string(rawPayload), mediaValue, len(media), ScheduledPending, sendAt).Scan(&msg.ID, &msg.CreatedAt, &msg.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+const scheduledMessageColumns = `id, device_id, chat_jid, message_type, payload, media_size, status, send_at, attempts, message_id, last_error, sent_at, created_at, updated_at`
+
+type scheduledRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScheduledMessage(row scheduledRowScanner) (*ScheduledMessage, error) {
+	var msg ScheduledMessage
+	var payload []byte
+	var sentAt sql.NullTime
+	if err := row.Scan(&msg.ID, &msg.DeviceID, &msg.ChatJID, &msg.Type, &payload, &msg.MediaSize, &msg.Status, &msg.SendAt, &msg.Attempts, &msg.MessageID, &msg.LastError, &sentAt, &msg.CreatedAt, &msg.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &msg.Payload); err != nil {
+			return nil, fmt.Errorf("failed to decode scheduled payload: %w", err)
+		}
+	}
+	if sentAt.Valid {
+		msg.SentAt = &sentAt.Time
+	}
+	return &msg, nil
+}
+
+// GetScheduledMessages lists the device's scheduled messages by send time, optionally filtered by status
+func GetScheduledMessages(ctx context.Context, deviceID string, status string, limit int, offset int) ([]ScheduledMessage, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+scheduledMessageColumns+`
+		FROM scheduled_messages
+		WHERE device_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY send_at, id
+		LIMIT $3 OFFSET $4
+	`, deviceID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []ScheduledMessage{}
+	for rows.Next() {
+		msg, err := scanScheduledMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *msg)
+	}
+	return messages, rows.Err()
+}
+
+// GetScheduledMessage returns one scheduled message of the device
+func GetScheduledMessage(ctx context.Context, deviceID string, id int64) (*ScheduledMessage, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := scanScheduledMessage(db.QueryRowContext(ctx, `
+		SELECT `+scheduledMessageColumns+`
+		FROM scheduled_messages
+		WHERE device_id = $1 AND id = $2
+	`, deviceID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScheduledMessageNotFound
+	}
+	return msg, err
+}
+
+// CancelScheduledMessage cancels a pending message and drops its media
+func CancelScheduledMessage(ctx context.Context, deviceID string, id int64) (*ScheduledMessage, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := scanScheduledMessage(db.QueryRowContext(ctx, `
+		UPDATE scheduled_messages
+		SET status = $3, media = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE device_id = $1 AND id = $2 AND status = $4
+		RETURNING `+scheduledMessageColumns, deviceID, id, ScheduledCancelled, ScheduledPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, scheduledMessageMissingOrBusy(ctx, deviceID, id)
+	}
+	return msg, err
+}
+
+// RescheduleMessage moves a pending message to a new send time and resets its attempts
+func RescheduleMessage(ctx context.Context, deviceID string, id int64, sendAt time.Time) (*ScheduledMessage, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	sendAt = sendAt.UTC()
+	msg, err := scanScheduledMessage(db.QueryRowContext(ctx, `
+		UPDATE scheduled_messages
+		SET send_at = $3, next_attempt_at = $3, attempts = 0, last_error = '', updated_at = CURRENT_TIMESTAMP
+		WHERE device_id = $1 AND id = $2 AND status = $4
+		RETURNING `+scheduledMessageColumns, deviceID, id, sendAt, ScheduledPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, scheduledMessageMissingOrBusy(ctx, deviceID, id)
+	}
+	return msg, err
+}
+
+func scheduledMessageMissingOrBusy(ctx context.Context, deviceID string, id int64) error {
+	if _, err := GetScheduledMessage(ctx, deviceID, id); err != nil {
+		return err
+	}
+	return ErrScheduledMessageNotPending
+}
+
+// dueScheduledDevices lists up to limit devices that have messages due, the longest-waiting first
+func dueScheduledDevices(ctx context.Context, db *sql.DB, limit int) ([]string, error) {
+	now := time.Now().UTC()
+	rows, err := db.QueryContext(ctx, `
+		SELECT device_id FROM scheduled_messages
+		WHERE next_attempt_at <= $1
+			AND (status = $2 OR (status = $3 AND locked_until < $1))
+		GROUP BY device_id
+		ORDER BY MIN(next_attempt_at)
+		LIMIT $4
+	`, now, ScheduledPending, ScheduledSending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []string
+	for rows.Next() {
+		var deviceID string
+		if err := rows.Scan(&deviceID); err != nil {
+			return nil, err
+		}
+		devices = append(devices, deviceID)
+	}
+	return devices, rows.Err()
+}
+
+// claimScheduledMessage takes the device's next due message and leases it, so concurrent ticks
+// (or other instances) skip it. Rows whose lease expired mid-send are picked up again.
+func claimScheduledMessage(ctx context.Context, db *sql.DB, deviceID string) (*ScheduledMessage, error) {
+	var msg ScheduledMessage
+	var payload []byte
+	// send_at/next_attempt_at are written as UTC from Go, so "now" is passed the same way
+	now := time.Now().UTC()
+	err := db.QueryRowContext(ctx, `
+		UPDATE scheduled_messages
+		SET status = $1, locked_until = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM scheduled_messages
+			WHERE device_id = $5 AND next_attempt_at <= $3
+				AND (status = $4 OR (status = $1 AND locked_until < $3))
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, device_id, device_jid, chat_jid, message_type, payload, media, send_at, attempts
+	`, ScheduledSending, now.Add(scheduledSendLease), now, ScheduledPending, deviceID).Scan(
+		&msg.ID, &msg.DeviceID, &msg.deviceJID, &msg.ChatJID, &msg.Type, &payload, &msg.media, &msg.SendAt, &msg.Attempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(payload, &msg.Payload); err != nil {
+		return nil, fmt.Errorf("failed to decode scheduled payload: %w", err)
+	}
+	return &msg, nil
+}
+
+// RunDueScheduledMessages sends every message whose time has come. It is called from the
+// scheduler cron; a run that is still busy when the next tick fires makes that tick a no-op.
+// Up to SCHEDULED_MESSAGE_CONCURRENCY devices are worked on at once, so a slow device does not
+// hold back the others, while the messages of one device still go out one after another.
+func RunDueScheduledMessages(ctx context.Context) (sent int, failed int, err error) {
+	if !scheduledRunMu.TryLock() {
+		return 0, 0, nil
+	}
+	defer scheduledRunMu.Unlock()
+
+	db, err := openRoutingDB()
+	if err != nil {
+		return 0, 0, err
+	}
+	devices, err := dueScheduledDevices(ctx, db, scheduledBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var budget, sentCount, failedCount atomic.Int64
+	budget.Store(int64(scheduledBatchSize))
+	var errMu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, scheduledConcurrency)
+
+	for _, deviceID := range devices {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(deviceID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if deviceErr := runDueScheduledMessagesOfDevice(ctx, db, deviceID, &budget, &sentCount, &failedCount); deviceErr != nil {
+				errMu.Lock()
+				if err == nil {
+					err = deviceErr
+				}
+				errMu.Unlock()
+			}
+		}(deviceID)
+	}
+	wg.Wait()
+	return int(sentCount.Load()), int(failedCount.Load()), err
+}
+
+// runDueScheduledMessagesOfDevice sends the device's due messages in order until none is left
+// or the run has used up its SCHEDULED_MESSAGE_BATCH_SIZE
+func runDueScheduledMessagesOfDevice(ctx context.Context, db *sql.DB, deviceID string, budget *atomic.Int64, sent *atomic.Int64, failed *atomic.Int64) error {
+	for budget.Add(-1) >= 0 {
+		if ctx.Err() != nil {
+			return nil
+		}
+		msg, err := claimScheduledMessage(ctx, db, deviceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch processScheduledMessage(ctx, db, msg) {
+		case ScheduledSent:
+			sent.Add(1)
+		case ScheduledFailed:
+			failed.Add(1)
+		}
+	}
+	return nil
+}
+
+// processScheduledMessage sends a claimed message and records the outcome. While the device is
+// disconnected the message is put back untouched until it is more than SCHEDULED_MESSAGE_MAX_DELAY late.
+func processScheduledMessage(ctx context.Context, db *sql.DB, msg *ScheduledMessage) string {
+	if err := ensureClientOK(getClientByDeviceID(msg.DeviceID)); err != nil {
+		if time.Since(msg.SendAt) <= scheduledMaxDelay {
+			releaseScheduledMessage(db, msg.ID)
+			return ScheduledPending
+		}
+		failScheduledMessage(db, msg, fmt.Errorf("device unavailable for %s: %w", scheduledMaxDelay, err))
+		return ScheduledFailed
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	msgID, err := sendQueuedMessage(sendCtx, msg.deviceJID, msg.DeviceID, msg.ChatJID, msg.Type, msg.Payload, msg.media)
+	cancel()
+	if err != nil {
+		msg.Attempts++
+		if msg.Attempts >= scheduledMaxAttempts {
+			failScheduledMessage(db, msg, err)
+			return ScheduledFailed
+		}
+		retryScheduledMessage(db, msg, err)
+		return ScheduledPending
+	}
+
+	msg.Attempts++
+	msg.MessageID = msgID
+	sentAt := time.Now().UTC()
+	msg.SentAt = &sentAt
+	writeCtx, writeCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer writeCancel()
+	_, err = db.ExecContext(writeCtx, `
+		UPDATE scheduled_messages
+		SET status = $2, message_id = $3, sent_at = $4, attempts = $5, last_error = '', media = NULL, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, msg.ID, ScheduledSent, msgID, sentAt, msg.Attempts)
+	if err != nil {
+		log.MessageOp(msg.DeviceID, "", "ScheduledSend", msg.ChatJID).WithError(err).Error("Failed to mark scheduled message as sent")
+	}
+	log.MessageOp(msg.DeviceID, "", "ScheduledSend", msg.ChatJID).WithField("scheduled_id", msg.ID).WithField("message_id", msgID).Info("Scheduled message sent")
+
+	dispatchWebhook(msg.DeviceID, webhook.EventMessageScheduledSent, scheduledWebhookData(msg, nil))
+	return ScheduledSent
+}
+
+// sendQueuedMessage performs a send stored by the scheduler or the outbox through the regular
+// WhatsAppSend* function of its type
+func sendQueuedMessage(ctx context.Context, deviceJID string, deviceID string, chatJID string, msgType string, p ScheduledPayload, media []byte) (string, error) {
+	jid := getClientJID(deviceJID, deviceID)
+	opts := &SendOptions{
+		TypingSimulation:   p.TypingSimulation,
+		PresenceSimulation: p.PresenceSimulation,
+		Mentions:           p.Mentions,
+		MentionAll:         p.MentionAll,
+		MentionTokens:      p.MentionTokens,
+		ClientReference:    p.ClientReference,
+		Metadata:           p.Metadata,
+	}
+	if p.ReplyToMessageID != "" {
+		opts.ReplyTo = &ReplyTarget{MessageID: p.ReplyToMessageID}
+	}
+
+	switch msgType {
+	case ScheduledText:
+		return WhatsAppSendText(ctx, jid, deviceID, chatJID, p.Text, opts)
+	case ScheduledImage:
+		return WhatsAppSendImage(ctx, jid, deviceID, chatJID, media, p.MimeType, p.Caption, p.ViewOnce, opts)
+	case ScheduledVideo:
+		return WhatsAppSendVideo(ctx, jid, deviceID, chatJID, media, p.MimeType, p.Caption, p.ViewOnce, opts)
+	case ScheduledAudio:
+		return WhatsAppSendAudio(ctx, jid, deviceID, chatJID, media, p.MimeType, p.VoiceNote, opts)
+	case ScheduledDocument:
+		return WhatsAppSendDocument(ctx, jid, deviceID, chatJID, media, p.MimeType, p.FileName, p.Caption, opts)
+	case ScheduledSticker:
+		var pack transcode.StickerMetadata
+		if p.StickerPack != nil {
+			pack = *p.StickerPack
+		}
+		return WhatsAppSendSticker(ctx, jid, deviceID, chatJID, media, pack, opts)
+	case ScheduledLocation:
+		return WhatsAppSendLocation(ctx, jid, deviceID, chatJID, p.Latitude, p.Longitude, p.Name, p.Address, opts)
+	case ScheduledContact:
+		return WhatsAppSendContact(ctx, jid, deviceID, chatJID, p.Name, p.Phone, opts)
+	case ScheduledPoll:
+		return WhatsAppCreatePoll(ctx, jid, deviceID, chatJID, p.Question, p.Options, p.MultiAnswer, opts)
+	}
+	return "", fmt.Errorf("unsupported message type: %s", msgType)
+}
+
+func releaseScheduledMessage(db *sql.DB, id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := db.ExecContext(ctx, `
+		UPDATE scheduled_messages SET status = $2, next_attempt_at = $4, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $3
+	`, id, ScheduledPending, ScheduledSending, time.Now().UTC().Add(scheduledDeviceWait))
+	if err != nil {
+		log.SysErr("scheduled-release", err)
+	}
+}
+
+func retryScheduledMessage(db *sql.DB, msg *ScheduledMessage, sendErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	next := time.Now().UTC().Add(scheduledRetryBackoff * time.Duration(msg.Attempts))
+	_, err := db.ExecContext(ctx, `
+		UPDATE scheduled_messages
+		SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, msg.ID, ScheduledPending, msg.Attempts, sendErr.Error(), next)
+	if err != nil {
+		log.SysErr("scheduled-retry", err)
+	}
+	log.MessageOp(msg.DeviceID, "", "ScheduledSend", msg.ChatJID).WithError(sendErr).WithField("scheduled_id", msg.ID).WithField("attempts", msg.Attempts).Warn("Scheduled message send failed, will retry")
+}
+
+func failScheduledMessage(db *sql.DB, msg *ScheduledMessage, sendErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := db.ExecContext(ctx, `
+		UPDATE scheduled_messages
+		SET status = $2, attempts = $3, last_error = $4, media = NULL, locked_until = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, msg.ID, ScheduledFailed, msg.Attempts, sendErr.Error())
+	if err != nil {
+		log.SysErr("scheduled-fail", err)
+	}
+	log.MessageOp(msg.DeviceID, "", "ScheduledSend", msg.ChatJID).WithError(sendErr).WithField("scheduled_id", msg.ID).WithField("attempts", msg.Attempts).Error("Scheduled message failed")
+
+	dispatchWebhook(msg.DeviceID, webhook.EventMessageScheduledFailed, scheduledWebhookData(msg, sendErr))
+}
+
+func scheduledWebhookData(msg *ScheduledMessage, sendErr error) map[string]interface{} {
+	data := map[string]interface{}{
+		"scheduled_id": msg.ID,
+		"chat":         msg.ChatJID,
+		"type":         msg.Type,
+		"send_at":      msg.SendAt.UTC().Format(time.RFC3339),
+		"attempts":     msg.Attempts,
+	}
+	if msg.MessageID != "" {
+		data["message_id"] = msg.MessageID
+	}
+	if msg.SentAt != nil {
+		data["sent_at"] = msg.SentAt.Format(time.RFC3339)
+	}
+	if sendErr != nil {
+		data["error"] = sendErr.Error()
+	}
+	return data
+}
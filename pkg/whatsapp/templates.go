@@ -0,0 +1,410 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTemplateNotFound is returned when a template does not exist for the device
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrTemplateNameTaken is returned when the device already has a template with that name
+	ErrTemplateNameTaken = errors.New("template name already exists")
+	// ErrTemplateNoMedia is returned when sending a media template that has no media attached
+	ErrTemplateNoMedia = errors.New("template has no media attached")
+	// ErrTemplateMediaInvalid is returned when media attached to a template fails the send limits of its type
+	ErrTemplateMediaInvalid = errors.New("invalid template media")
+)
+
+// Template types; media templates use the body as caption
+const (
+	TemplateText     = "text"
+	TemplateImage    = "image"
+	TemplateVideo    = "video"
+	TemplateDocument = "document"
+)
+
+// templateVariablePattern matches {{name}} placeholders; whitespace inside the braces is ignored
+var templateVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// TemplateMissingVariablesError lists the placeholders that had neither a value nor a default
+type TemplateMissingVariablesError struct {
+	Missing []string
+}
+
+func (e *TemplateMissingVariablesError) Error() string {
+	return "missing template variables: " + strings.Join(e.Missing, ", ")
+}
+
+// MessageTemplate is a stored message shape with {{variable}} placeholders.
+// Attached media is kept in the database and never returned by the API.
+type MessageTemplate struct {
+	ID            int64             `json:"id"`
+	DeviceID      string            `json:"device_id"`
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	Body          string            `json:"body"`
+	Defaults      map[string]string `json:"defaults"`
+	Variables     []string          `json:"variables"`
+	MediaMimeType string            `json:"media_mimetype,omitempty"`
+	MediaFileName string            `json:"media_filename,omitempty"`
+	MediaSize     int               `json:"media_size,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// TemplateInput holds the fields of a new template
+type TemplateInput struct {
+	Name     string
+	Type     string
+	Body     string
+	Defaults map[string]string
+}
+
+// TemplateUpdate holds the fields of a template update; nil fields are left unchanged
+type TemplateUpdate struct {
+	Name     *string
+	Body     *string
+	Defaults map[string]string
+}
+
+// RenderedTemplate is a template with all placeholders filled in, ready to send
+type RenderedTemplate struct {
+	TemplateID int64
+	Type       string
+	Text       string
+	Media      []byte
+	MimeType   string
+	FileName   string
+}
+
+func ensureMessageTemplateSchema(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_message_templates (
+		id BIGSERIAL PRIMARY KEY,
+		device_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		template_type TEXT NOT NULL DEFAULT 'text',
+		body TEXT NOT NULL DEFAULT '',
+		defaults JSONB NOT NULL DEFAULT '{}'::jsonb,
+		media BYTEA,
+		media_mimetype TEXT NOT NULL DEFAULT '',
+		media_filename TEXT NOT NULL DEFAULT '',
+		media_size INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (device_id, name)
+	)`)
+	return err
+}
+
+// IsValidTemplateType reports whether t is a supported template type
+func IsValidTemplateType(t string) bool {
+	switch t {
+	case TemplateText, TemplateImage, TemplateVideo, TemplateDocument:
+		return true
+	}
+	return false
+}
+
+// ExtractTemplateVariables returns the placeholder names of body in order of first use
+func ExtractTemplateVariables(body string) []string {
+	seen := make(map[string]struct{})
+	names := []string{}
+	for _, m := range templateVariablePattern.FindAllStringSubmatch(body, -1) {
+		if _, ok := seen[m[1]]; ok {
+			continue
+		}
+		seen[m[1]] = struct{}{}
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// RenderTemplate fills the placeholders of body from vars, falling back to defaults.
+// Every missing variable is reported at once in a *TemplateMissingVariablesError.
+func RenderTemplate(body string, defaults map[string]string, vars map[string]string) (string, error) {
+	var missing []string
+	for _, name := range ExtractTemplateVariables(body) {
+		if _, ok := vars[name]; ok {
+			continue
+		}
+		if _, ok := defaults[name]; ok {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", &TemplateMissingVariablesError{Missing: missing}
+	}
+	return templateVariablePattern.ReplaceAllStringFunc(body, func(placeholder string) string {
+		name := templateVariablePattern.FindStringSubmatch(placeholder)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return defaults[name]
+	}), nil
+}
+
+const messageTemplateColumns = `id, device_id, name, template_type, body, defaults, media_mimetype, media_filename, media_size, created_at, updated_at`
+
+func scanMessageTemplate(row scheduledRowScanner) (*MessageTemplate, error) {
+	var t MessageTemplate
+	var defaults []byte
+	if err := row.Scan(&t.ID, &t.DeviceID, &t.Name, &t.Type, &t.Body, &defaults, &t.MediaMimeType, &t.MediaFileName, &t.MediaSize, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	t.Defaults = map[string]string{}
+	if len(defaults) > 0 {
+		if err := json.Unmarshal(defaults, &t.Defaults); err != nil {
+			return nil, fmt.Errorf("failed to decode template defaults: %w", err)
+		}
+	}
+	t.Variables = ExtractTemplateVariables(t.Body)
+	return &t, nil
+}
+
+func encodeTemplateDefaults(defaults map[string]string) (string, error) {
+	if defaults == nil {
+		defaults = map[string]string{}
+	}
+	raw, err := json.Marshal(defaults)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// CreateMessageTemplate stores a new template for the device
+func CreateMessageTemplate(ctx context.Context, deviceID string, input TemplateInput) (*MessageTemplate, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := encodeTemplateDefaults(input.Defaults)
+	if err != nil {
+		return nil, err
+	}
+	t, err := scanMessageTemplate(db.QueryRowContext(ctx, `
+		INSERT INTO wa_message_templates (device_id, name, template_type, body, defaults)
+		VALUES ($1, $2, $3, $4, $5::jsonb)
+		ON CONFLICT (device_id, name) DO NOTHING
+		RETURNING `+messageTemplateColumns, deviceID, input.Name, input.Type, input.Body, defaults))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTemplateNameTaken
+	}
+	return t, err
+}
+
+// GetMessageTemplates lists the device's templates by name
+func GetMessageTemplates(ctx context.Context, deviceID string, limit int, offset int) ([]MessageTemplate, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+messageTemplateColumns+`
+		FROM wa_message_templates
+		WHERE device_id = $1
+		ORDER BY name
+		LIMIT $2 OFFSET $3
+	`, deviceID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []MessageTemplate{}
+	for rows.Next() {
+		t, err := scanMessageTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+// GetMessageTemplate returns one template of the device
+func GetMessageTemplate(ctx context.Context, deviceID string, id int64) (*MessageTemplate, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	t, err := scanMessageTemplate(db.QueryRowContext(ctx, `
+		SELECT `+messageTemplateColumns+`
+		FROM wa_message_templates
+		WHERE device_id = $1 AND id = $2
+	`, deviceID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return t, err
+}
+
+// UpdateMessageTemplate changes the name, body or defaults of a template
+func UpdateMessageTemplate(ctx context.Context, deviceID string, id int64, update TemplateUpdate) (*MessageTemplate, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	current, err := GetMessageTemplate(ctx, deviceID, id)
+	if err != nil {
+		return nil, err
+	}
+	name, body, defaults := current.Name, current.Body, current.Defaults
+	if update.Name != nil {
+		name = *update.Name
+	}
+	if update.Body != nil {
+		body = *update.Body
+	}
+	if update.Defaults != nil {
+		defaults = update.Defaults
+	}
+	if name != current.Name {
+		var exists bool
+		err = db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM wa_message_templates WHERE device_id = $1 AND name = $2 AND id <> $3)`, deviceID, name, id).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrTemplateNameTaken
+		}
+	}
+	rawDefaults, err := encodeTemplateDefaults(defaults)
+	if err != nil {
+		return nil, err
+	}
+	t, err := scanMessageTemplate(db.QueryRowContext(ctx, `
+		UPDATE wa_message_templates
+		SET name = $3, body = $4, defaults = $5::jsonb, updated_at = CURRENT_TIMESTAMP
+		WHERE device_id = $1 AND id = $2
+		RETURNING `+messageTemplateColumns, deviceID, id, name, body, rawDefaults))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return t, err
+}
+
+// SetMessageTemplateMedia attaches (or replaces) the media sent with a media template
+func SetMessageTemplateMedia(ctx context.Context, deviceID string, id int64, templateType string, media []byte, mimeType string, fileName string) (*MessageTemplate, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(media) == 0 {
+		return nil, fmt.Errorf("%w: media is empty", ErrTemplateMediaInvalid)
+	}
+	// Template media is checked against the same size and MIME limits as a direct send of its type
+	limit, allowed := mediaKindLimits(templateType)
+	if err := enforceSizeLimit(templateType, int64(len(media)), limit); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTemplateMediaInvalid, err)
+	}
+	mimeType, err := checkMediaMime(templateType, media, mimeType, allowed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTemplateMediaInvalid, err)
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	t, err := scanMessageTemplate(db.QueryRowContext(ctx, `
+		UPDATE wa_message_templates
+		SET media = $3, media_mimetype = $4, media_filename = $5, media_size = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE device_id = $1 AND id = $2
+		RETURNING `+messageTemplateColumns, deviceID, id, media, mimeType, fileName, len(media)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	return t, err
+}
+
+// DeleteMessageTemplate removes a template and its media
+func DeleteMessageTemplate(ctx context.Context, deviceID string, id int64) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx, `DELETE FROM wa_message_templates WHERE device_id = $1 AND id = $2`, deviceID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// RenderMessageTemplate loads a template and fills in its placeholders. Nothing is sent;
+// a missing variable or media fails here so callers can reject the request up front.
+func RenderMessageTemplate(ctx context.Context, deviceID string, id int64, vars map[string]string) (*RenderedTemplate, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := openRoutingDB()
+	if err != nil {
+		return nil, err
+	}
+	t, err := GetMessageTemplate(ctx, deviceID, id)
+	if err != nil {
+		return nil, err
+	}
+	var media []byte
+	if t.Type != TemplateText {
+		if err := db.QueryRowContext(ctx, `SELECT media FROM wa_message_templates WHERE device_id = $1 AND id = $2`, deviceID, id).Scan(&media); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if len(media) == 0 {
+			return nil, ErrTemplateNoMedia
+		}
+	}
+	text, err := RenderTemplate(t.Body, t.Defaults, vars)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderedTemplate{
+		TemplateID: t.ID,
+		Type:       t.Type,
+		Text:       text,
+		Media:      media,
+		MimeType:   t.MediaMimeType,
+		FileName:   t.MediaFileName,
+	}, nil
+}
+
+// SendRenderedTemplate sends a rendered template through the regular send functions
+func SendRenderedTemplate(ctx context.Context, jid string, deviceID string, chatJID string, r *RenderedTemplate, opts *SendOptions) (string, error) {
+	switch r.Type {
+	case TemplateText:
+		return WhatsAppSendText(ctx, jid, deviceID, chatJID, r.Text, opts)
+	case TemplateImage:
+		return WhatsAppSendImage(ctx, jid, deviceID, chatJID, r.Media, r.MimeType, r.Text, false, opts)
+	case TemplateVideo:
+		return WhatsAppSendVideo(ctx, jid, deviceID, chatJID, r.Media, r.MimeType, r.Text, false, opts)
+	case TemplateDocument:
+		return WhatsAppSendDocument(ctx, jid, deviceID, chatJID, r.Media, r.MimeType, r.FileName, r.Text, opts)
+	}
+	return "", fmt.Errorf("unsupported template type: %s", r.Type)
+}
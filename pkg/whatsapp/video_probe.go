@@ -0,0 +1,62 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/transcode"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// videoFrameExtractor grabs the frame used as a video thumbnail; nil when ffmpeg is unavailable
+var videoFrameExtractor transcode.FrameExtractor
+
+func loadVideoProbeConfig() {
+	e, err := transcode.NewFrameExtractorFromEnv()
+	if err != nil {
+		log.SysErr("video-frame-extractor", err)
+		return
+	}
+	videoFrameExtractor = e
+	if e == nil {
+		log.Sys("cfg", "video_frame_extractor:none")
+		return
+	}
+	log.Sys("cfg", fmt.Sprintf("video_frame_extractor:%s", e.Name()))
+}
+
+// probeVideo reads the duration and display size of an MP4/MOV video and builds its 72px JPEG
+// thumbnail. Both are best-effort: a video that cannot be probed is still sent, just without them.
+func probeVideo(ctx context.Context, deviceID string, video []byte) (*transcode.VideoInfo, []byte) {
+	info, err := transcode.ProbeMP4(video)
+	if err != nil && !errors.Is(err, transcode.ErrUnsupported) {
+		log.DeviceOp(deviceID, "", "ProbeVideo").WithError(err).Warn("Failed to read video metadata")
+	}
+	if videoFrameExtractor == nil {
+		return info, nil
+	}
+
+	// One second in skips the black lead-in frame most recordings start with
+	at := time.Second
+	if info != nil && info.Seconds <= 1 {
+		at = 0
+	}
+	frame, err := videoFrameExtractor.Frame(ctx, video, at)
+	if err != nil {
+		log.DeviceOp(deviceID, "", "ProbeVideo").WithError(err).Warn("Failed to extract video thumbnail")
+		return info, nil
+	}
+	thumbnail, err := encodeJPEGThumbnail(frame)
+	if err != nil {
+		log.DeviceOp(deviceID, "", "ProbeVideo").WithError(err).Warn("Failed to encode video thumbnail")
+		return info, nil
+	}
+	if info == nil {
+		// Not an MP4 the parser understands; ffmpeg still tells us the frame size
+		b := frame.Bounds()
+		info = &transcode.VideoInfo{Width: b.Dx(), Height: b.Dy()}
+	}
+	return info, thumbnail
+}
@@ -0,0 +1,53 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/transcode"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
+)
+
+// audioTranscoder converts voice notes to OGG/Opus; nil when AUDIO_TRANSCODER=none
+var audioTranscoder transcode.AudioTranscoder
+
+func loadVoiceNoteConfig() {
+	t, err := transcode.NewAudioFromEnv()
+	if err != nil {
+		log.SysErr("audio-transcoder", err)
+		return
+	}
+	audioTranscoder = t
+	if t == nil {
+		log.Sys("cfg", "audio_transcoder:none")
+		return
+	}
+	log.Sys("cfg", fmt.Sprintf("audio_transcoder:%s", t.Name()))
+}
+
+// prepareVoiceNote converts audio to an OGG/Opus voice note with its duration and waveform.
+// It returns nil without a configured transcoder, or when a best-effort transcoder cannot convert
+// the format, in which case the audio is sent unchanged.
+func prepareVoiceNote(ctx context.Context, deviceID string, audio []byte, mimeType string) (*transcode.VoiceNote, error) {
+	if audioTranscoder == nil {
+		return nil, nil
+	}
+	note, err := audioTranscoder.VoiceNote(ctx, audio, mimeType)
+	if err != nil && errors.Is(err, transcode.ErrUnsupported) && transcode.IsBestEffort(audioTranscoder) {
+		log.DeviceOp(deviceID, "", "PrepareVoiceNote").
+			WithField("input_mimetype", mimeType).
+			Warn("ffmpeg is not installed and the audio is not OGG/Opus, sending it unchanged")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to convert %s to a voice note with the %s transcoder: %w", mimeType, audioTranscoder.Name(), err)
+	}
+	log.DeviceOp(deviceID, "", "PrepareVoiceNote").
+		WithField("input_mimetype", mimeType).
+		WithField("input_size", len(audio)).
+		WithField("size", len(note.Data)).
+		WithField("seconds", note.Seconds).
+		Debug("Voice note converted")
+	return note, nil
+}
@@ -0,0 +1,181 @@
+package whatsapp
+
+import (
+	"encoding/json"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// WebhookMessageContent is the normalized content block attached to message.received payloads
+type WebhookMessageContent struct {
+	Type     string                `json:"type"`
+	Text     string                `json:"text,omitempty"`
+	Caption  string                `json:"caption,omitempty"`
+	Quoted   *WebhookQuotedMessage `json:"quoted,omitempty"`
+	Mentions []string              `json:"mentions,omitempty"`
+	Location *WebhookLocation      `json:"location,omitempty"`
+	Contacts []WebhookContactCard  `json:"contacts,omitempty"`
+	Poll     *WebhookPoll          `json:"poll,omitempty"`
+	Media    *WebhookMedia         `json:"media,omitempty"`
+}
+
+// WebhookQuotedMessage references the message a received message replies to
+type WebhookQuotedMessage struct {
+	MessageID   string `json:"message_id"`
+	Participant string `json:"participant,omitempty"`
+	Chat        string `json:"chat,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Text        string `json:"text,omitempty"`
+}
+
+type WebhookLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+	URL       string  `json:"url,omitempty"`
+	IsLive    bool    `json:"is_live"`
+}
+
+type WebhookContactCard struct {
+	DisplayName string `json:"display_name"`
+	VCard       string `json:"vcard"`
+}
+
+type WebhookPoll struct {
+	Name            string   `json:"name"`
+	Options         []string `json:"options"`
+	SelectableCount uint32   `json:"selectable_count"`
+}
+
+// WebhookMedia extends the stored media metadata with the keys needed to download the file
+type WebhookMedia struct {
+	*StoredMedia
+	URL           string `json:"url,omitempty"`
+	MediaKey      string `json:"media_key,omitempty"`       // base64
+	FileEncSHA256 string `json:"file_enc_sha256,omitempty"` // base64
+}
+
+// downloadableFromMessage returns the media part of a message that can be passed to client.Download
+func downloadableFromMessage(msg *waE2E.Message) whatsmeow.DownloadableMessage {
+	switch {
+	case msg == nil:
+		return nil
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage()
+	}
+	return nil
+}
+
+// buildWebhookMessageContent normalizes a message into the content block sent to webhooks
+func buildWebhookMessageContent(msg *waE2E.Message) *WebhookMessageContent {
+	extracted := extractMessageContent(msg)
+	if extracted.Type == "" {
+		return nil
+	}
+	content := &WebhookMessageContent{
+		Type:    extracted.Type,
+		Text:    extracted.Text,
+		Caption: extracted.Caption,
+	}
+
+	if slot := contextInfoSlot(msg); slot != nil && *slot != nil {
+		ci := *slot
+		content.Mentions = ci.GetMentionedJID()
+		if ci.GetStanzaID() != "" {
+			quoted := extractMessageContent(ci.GetQuotedMessage())
+			content.Quoted = &WebhookQuotedMessage{
+				MessageID:   ci.GetStanzaID(),
+				Participant: ci.GetParticipant(),
+				Chat:        ci.GetRemoteJID(),
+				Type:        quoted.Type,
+				Text:        firstNonEmpty(quoted.Text, quoted.Caption),
+			}
+		}
+	}
+
+	switch {
+	case msg.GetLocationMessage() != nil:
+		m := msg.GetLocationMessage()
+		content.Location = &WebhookLocation{
+			Latitude:  m.GetDegreesLatitude(),
+			Longitude: m.GetDegreesLongitude(),
+			Name:      m.GetName(),
+			Address:   m.GetAddress(),
+			URL:       m.GetURL(),
+			IsLive:    m.GetIsLive(),
+		}
+	case msg.GetLiveLocationMessage() != nil:
+		m := msg.GetLiveLocationMessage()
+		content.Location = &WebhookLocation{
+			Latitude:  m.GetDegreesLatitude(),
+			Longitude: m.GetDegreesLongitude(),
+			IsLive:    true,
+		}
+	case msg.GetContactMessage() != nil:
+		m := msg.GetContactMessage()
+		content.Contacts = []WebhookContactCard{{DisplayName: m.GetDisplayName(), VCard: m.GetVcard()}}
+	case msg.GetContactsArrayMessage() != nil:
+		for _, m := range msg.GetContactsArrayMessage().GetContacts() {
+			content.Contacts = append(content.Contacts, WebhookContactCard{DisplayName: m.GetDisplayName(), VCard: m.GetVcard()})
+		}
+	case pollCreationFromMessage(msg) != nil:
+		poll := pollCreationFromMessage(msg)
+		content.Poll = &WebhookPoll{Name: poll.GetName(), SelectableCount: poll.GetSelectableOptionsCount(), Options: []string{}}
+		for _, opt := range poll.GetOptions() {
+			content.Poll.Options = append(content.Poll.Options, opt.GetOptionName())
+		}
+	}
+
+	if extracted.Media != nil {
+		content.Media = &WebhookMedia{StoredMedia: extracted.Media}
+		if media := downloadableFromMessage(msg); media != nil {
+			content.Media.MediaKey = encodeMediaHash(media.GetMediaKey())
+			content.Media.FileEncSHA256 = encodeMediaHash(media.GetFileEncSHA256())
+			if withURL, ok := media.(interface{ GetURL() string }); ok {
+				content.Media.URL = withURL.GetURL()
+			}
+		}
+	}
+	return content
+}
+
+func buildMessageReceivedWebhookPayload(e *events.Message) map[string]interface{} {
+	payload := map[string]interface{}{
+		"message_id": e.Info.ID,
+		"from":       e.Info.Sender.String(),
+		"chat":       e.Info.Chat.String(),
+		"timestamp":  e.Info.Timestamp.Unix(),
+		"is_from_me": e.Info.IsFromMe,
+		"is_group":   e.Info.IsGroup,
+		"push_name":  e.Info.PushName,
+	}
+	if content := buildWebhookMessageContent(e.Message); content != nil {
+		payload["content"] = content
+	}
+	return payload
+}
+
+// rawMessageJSON encodes a message as protojson for webhooks that opted into include_raw
+func rawMessageJSON(msg *waE2E.Message) json.RawMessage {
+	if msg == nil {
+		return nil
+	}
+	options := protojson.MarshalOptions{UseProtoNames: true}
+	raw, err := options.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
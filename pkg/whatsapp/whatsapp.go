@@ -42,6 +42,9 @@ import (
 	"golang.org/x/sync/singleflight"
 	"golang.org/x/time/rate"
 
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/eventstream"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/thumbnail"
+	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/transcode"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/internal/webhook"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/env"
 	"github.com/gdbrns/go-whatsapp-multi-session-rest-api/pkg/log"
@@ -81,12 +84,14 @@ var (
 	WhatsAppClientProxyURL   string
 	ErrInvalidGroupID        = errors.New("WhatsApp Group ID is Not Group Server")
 	ErrParticipantMustBeUser = errors.New("WhatsApp Participant ID must be a Personal JID")
+	ErrJIDNotRegistered      = errors.New("WhatsApp Personal ID is Not Registered")
 	datastoreDriver          string
 	datastoreDSN             string
 	keysDatastoreDriver      string
 	keysDatastoreDSN         string
 	WhatsAppKeysDatastore    *sqlstore.Container
 	webhookEngine            *webhook.Engine
+	eventHub                 *eventstream.Hub
 	groupListCacheMu         sync.RWMutex
 	groupListCache           = make(map[groupListCacheKey]groupListCacheEntry)
 	groupListCacheTTL        = 5 * time.Minute // Extended TTL for multi-device efficiency
@@ -209,6 +214,14 @@ var (
 	allowedStickerMimes = map[string]bool{
 		"image/webp": true,
 	}
+
+	// stickerSourceMimes are accepted on sticker sends and converted to WebP by transcode.BuildSticker
+	stickerSourceMimes = map[string]bool{
+		"image/webp": true,
+		"image/png":  true,
+		"image/jpeg": true,
+		"image/gif":  true,
+	}
 )
 
 func init() {
@@ -274,6 +287,7 @@ func init() {
 	}
 	webhookStore := webhook.NewStore(db)
 	webhookEngine = webhook.NewEngine(webhookStore)
+	eventHub = eventstream.NewHub(db)
 
 	log.Sys("db-ready")
 
@@ -281,6 +295,20 @@ func init() {
 	loadBehaviorConfig()
 	loadIsOnConfig()
 	loadRateLimitConfig()
+	loadMessageStoreConfig()
+	loadReceiptWriterConfig()
+	loadMessageStatusConfig()
+	loadMediaArchiveConfig()
+	loadScheduledMessageConfig()
+	loadOutboxConfig()
+	loadIdempotencyConfig()
+	loadAPIKeyQuotaConfig()
+	loadCampaignConfig()
+	loadMediaInputConfig()
+	loadMediaUploadConfig()
+	loadMediaLibraryConfig()
+	loadVoiceNoteConfig()
+	loadVideoProbeConfig()
 }
 
 func configureGroupListCache() {
@@ -435,6 +463,12 @@ func jitterDuration(min, max time.Duration) time.Duration {
 type SendOptions struct {
 	TypingSimulation   *bool
 	PresenceSimulation *bool
+	ReplyTo            *ReplyTarget
+	Mentions           []string          // phone numbers or JIDs to @mention
+	MentionAll         bool              // mention every participant of the group
+	MentionTokens      bool              // also mention the @<number> tokens of group text and captions
+	ClientReference    string            // caller's own ID, echoed in message.sent and receipt webhooks
+	Metadata           map[string]string // small caller-defined map, echoed like ClientReference
 }
 
 func rateLimiterForDevice(deviceID string) *rate.Limiter {
@@ -1105,6 +1139,9 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 			})
 		case *events.Message:
 			go autoMarkMessageAsRead(currentJID, deviceID, e)
+			if client, err := currentClient(jid, deviceID); err == nil {
+				storeMessageEvent(deviceID, client, e)
+			}
 			if e.Info.Chat == types.StatusBroadcastJID {
 				dispatchWebhook(deviceID, webhook.EventStatusPosted, map[string]interface{}{
 					"jid":        currentJID,
@@ -1138,13 +1175,7 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 				}
 			} else {
 				// Regular message received
-				dispatchWebhook(deviceID, webhook.EventMessageReceived, map[string]interface{}{
-					"message_id": e.Info.ID,
-					"from":       e.Info.Sender.String(),
-					"chat":       e.Info.Chat.String(),
-					"timestamp":  e.Info.Timestamp.Unix(),
-					"is_from_me": e.Info.IsFromMe,
-				})
+				dispatchWebhookWithRaw(deviceID, webhook.EventMessageReceived, buildMessageReceivedWebhookPayload(e), rawMessageJSON(e.Message))
 				if e.NewsletterMeta != nil {
 					dispatchWebhook(deviceID, webhook.EventNewsletterMessageReceived, map[string]interface{}{
 						"jid":        currentJID,
@@ -1159,7 +1190,10 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 						dispatchWebhook(deviceID, webhook.EventMessageAIRichResponse, buildAIRichResponseWebhookPayload(currentJID, e, richResponse))
 						log.Msg("ai_rich_response", deviceID, e.Info.ID, e.Info.Chat.String())
 					}
-					if poll := e.Message.GetPollCreationMessage(); poll != nil {
+					if poll := pollCreationFromMessage(e.Message); poll != nil {
+						if client, err := currentClient(jid, deviceID); err == nil && client != nil {
+							storePollCreation(deviceID, canonicalChatJID(context.Background(), client, e.Info).String(), e.Info.Sender.ToNonAD().String(), e.Info.ID, poll, e.Info.Timestamp)
+						}
 						dispatchWebhook(deviceID, webhook.EventPollCreated, map[string]interface{}{
 							"jid":                currentJID,
 							"message_id":         e.Info.ID,
@@ -1185,6 +1219,13 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 						client, err := currentClient(jid, deviceID)
 						if err == nil && client != nil {
 							if pollVote, decErr := client.DecryptPollVote(context.Background(), e); decErr == nil {
+								pollUpdate := e.Message.GetPollUpdateMessage()
+								votedAt := e.Info.Timestamp
+								if ms := pollUpdate.GetSenderTimestampMS(); ms > 0 {
+									votedAt = time.UnixMilli(ms)
+								}
+								voter := preferPhoneJID(context.Background(), client, e.Info.Sender, e.Info.SenderAlt)
+								storePollVote(deviceID, pollUpdate.GetPollCreationMessageKey().GetID(), voter.String(), pollVote.GetSelectedOptions(), votedAt)
 								dispatchWebhook(deviceID, webhook.EventPollVoteDecrypted, map[string]interface{}{
 									"jid":              currentJID,
 									"message_id":       e.Info.ID,
@@ -1234,6 +1275,7 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 							"media_type": "sticker",
 						})
 					}
+					archiveIncomingMedia(jid, deviceID, currentJID, e)
 				}
 			}
 		case *events.Receipt:
@@ -1243,13 +1285,19 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 			} else if e.Type == types.ReceiptTypePlayed {
 				eventType = webhook.EventMessagePlayed
 			}
+			trackReceipt(deviceID, e)
+			refs := lookupMessageReferences(deviceID, e.MessageIDs)
 			for _, msgID := range e.MessageIDs {
-				dispatchWebhook(deviceID, eventType, map[string]interface{}{
+				data := map[string]interface{}{
 					"message_id": msgID,
 					"chat":       e.Chat.String(),
 					"sender":     e.Sender.String(),
 					"timestamp":  e.Timestamp.Unix(),
-				})
+				}
+				if ref, ok := refs[msgID]; ok {
+					ref.addTo(data)
+				}
+				dispatchWebhook(deviceID, eventType, data)
 				if e.Chat == types.StatusBroadcastJID && eventType == webhook.EventMessageRead {
 					dispatchWebhook(deviceID, webhook.EventStatusViewed, map[string]interface{}{
 						"jid":        currentJID,
@@ -1363,6 +1411,9 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 			})
 		// History sync events
 		case *events.HistorySync:
+			if client, err := currentClient(jid, deviceID); err == nil {
+				go storeHistorySync(deviceID, client, e.Data)
+			}
 			dispatchWebhook(deviceID, webhook.EventHistorySync, map[string]interface{}{
 				"jid":                currentJID,
 				"sync_type":          e.Data.GetSyncType().String(),
@@ -1468,6 +1519,7 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 				"messages":       e.Messages,
 			})
 		case *events.MediaRetry:
+			deliverMediaRetry(deviceID, e)
 			dispatchWebhook(deviceID, webhook.EventMediaRetry, map[string]interface{}{
 				"jid":        currentJID,
 				"message_id": e.MessageID,
@@ -1697,15 +1749,28 @@ func handleWhatsAppEvents(jid string, deviceID string) func(interface{}) {
 }
 
 func dispatchWebhook(deviceID string, eventType webhook.EventType, data map[string]interface{}) {
-	if webhookEngine == nil {
-		return
-	}
-	webhookEngine.Dispatch(context.Background(), deviceID, webhook.WebhookEvent{
+	dispatchWebhookWithRaw(deviceID, eventType, data, nil)
+}
+
+// dispatchWebhookWithRaw dispatches an event whose raw message is only delivered to webhooks with include_raw
+// and appends it to the event log behind the SSE/WebSocket streams
+func dispatchWebhookWithRaw(deviceID string, eventType webhook.EventType, data map[string]interface{}, raw []byte) {
+	event := webhook.WebhookEvent{
 		EventType: eventType,
 		DeviceID:  deviceID,
 		Timestamp: time.Now(),
 		Data:      data,
-	})
+		Raw:       raw,
+	}
+	if eventHub.Enabled() {
+		if err := eventHub.Publish(context.Background(), event); err != nil {
+			log.EvtErr("stream", "publish", deviceID, err)
+		}
+	}
+	if webhookEngine == nil {
+		return
+	}
+	webhookEngine.Dispatch(context.Background(), deviceID, event)
 }
 
 func sendAvailablePresence(jid string, deviceID string) {
@@ -1773,6 +1838,10 @@ func GetWebhookEngine() *webhook.Engine {
 	return webhookEngine
 }
 
+func GetEventHub() *eventstream.Hub {
+	return eventHub
+}
+
 func WhatsAppGenerateQR(ctx context.Context, qrChan <-chan whatsmeow.QRChannelItem) (string, int, bool, error) {
 	for {
 		select {
@@ -2089,7 +2158,7 @@ func WhatsAppCheckJID(ctx context.Context, jid string, deviceID string, id strin
 	if remoteJID.Server != types.GroupServer {
 		resolved := WhatsAppGetJID(ctx, jid, deviceID, id)
 		if resolved.IsEmpty() {
-			return types.EmptyJID, errors.New("WhatsApp Personal ID is Not Registered")
+			return types.EmptyJID, ErrJIDNotRegistered
 		}
 		remoteJID = resolved
 	}
@@ -2721,7 +2790,7 @@ func WhatsAppSendText(ctx context.Context, jid string, deviceID string, rjid str
 	}
 	cleanup := beginPresenceSimulation(ctx, jid, deviceID, remoteJID, false, opts)
 	defer cleanup()
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
@@ -2773,7 +2842,7 @@ func WhatsAppSendDocument(ctx context.Context, jid string, deviceID string, rjid
 			MediaKey:      documentUploaded.MediaKey,
 		},
 	}
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
@@ -2889,7 +2958,7 @@ func WhatsAppSendImage(ctx context.Context, jid string, deviceID string, rjid st
 			ViewOnce:            proto.Bool(isViewOnce),
 		},
 	}
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
@@ -3527,7 +3596,7 @@ func WhatsAppUploadNewsletterPhoto(ctx context.Context, jid string, deviceID str
 
 // Poll functions
 
-func WhatsAppCreatePoll(ctx context.Context, jid string, deviceID string, rjid string, question string, options []string, multiAnswer bool) (string, error) {
+func WhatsAppCreatePoll(ctx context.Context, jid string, deviceID string, rjid string, question string, options []string, multiAnswer bool, opts *SendOptions) (string, error) {
 	client, err := currentClient(jid, deviceID)
 	if err != nil {
 		return "", err
@@ -3557,7 +3626,7 @@ func WhatsAppCreatePoll(ctx context.Context, jid string, deviceID string, rjid s
 	}
 	pollMsg := client.BuildPollCreation(question, options, selectableCount)
 	msgExtra := whatsmeow.SendRequestExtra{ID: client.GenerateMessageID()}
-	_, err = client.SendMessage(ctx, remoteJID, pollMsg, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, pollMsg, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
@@ -3583,22 +3652,27 @@ func WhatsAppVotePoll(ctx context.Context, jid string, deviceID string, rjid str
 		return err
 	}
 	// Build the poll vote message
-	// Note: BuildPollVote requires the original poll message info
-	// This is a simplified implementation that may need adjustment based on actual poll message storage
+	// BuildPollVote needs the original poll sender to derive the vote encryption key,
+	// which is known when the poll was stored; otherwise the poll is assumed to be our own
 	pollMsgInfo := &types.MessageInfo{
 		ID: pollMsgID,
 		MessageSource: types.MessageSource{
 			Chat: remoteJID,
 		},
 	}
+	if creator, err := getStoredPollCreator(ctx, deviceID, pollMsgID); err == nil {
+		pollMsgInfo.Sender = creator
+	}
 	pollVoteMsg, err := client.BuildPollVote(ctx, pollMsgInfo, selectedOptions)
 	if err != nil {
 		return fmt.Errorf("failed to build poll vote: %w", err)
 	}
-	_, err = client.SendMessage(ctx, remoteJID, pollVoteMsg)
+	resp, err := client.SendMessage(ctx, remoteJID, pollVoteMsg)
 	if err != nil {
 		return err
 	}
+	ownJID := client.Store.GetJID().ToNonAD()
+	storePollVote(deviceID, pollMsgID, ownJID.String(), whatsmeow.HashPollOptions(selectedOptions), resp.Timestamp)
 	return nil
 }
 
@@ -3620,6 +3694,7 @@ func WhatsAppSendVideo(ctx context.Context, jid string, deviceID string, rjid st
 	if !allowedVideoMimes[videoMime] {
 		return "", fmt.Errorf("Video MIME type %s is not allowed", videoMime)
 	}
+	info, thumb := probeVideo(ctx, deviceID, videoBytes)
 	remoteJID, err := WhatsAppCheckJID(ctx, jid, deviceID, rjid)
 	if err != nil {
 		return "", err
@@ -3634,20 +3709,30 @@ func WhatsAppSendVideo(ctx context.Context, jid string, deviceID string, rjid st
 		return "", errors.New("Error While Uploading Video to WhatsApp Server")
 	}
 	msgExtra := whatsmeow.SendRequestExtra{ID: client.GenerateMessageID()}
-	msgContent := &waE2E.Message{
-		VideoMessage: &waE2E.VideoMessage{
-			URL:           proto.String(videoUploaded.URL),
-			DirectPath:    proto.String(videoUploaded.DirectPath),
-			Mimetype:      proto.String(videoMime),
-			Caption:       proto.String(videoCaption),
-			FileLength:    proto.Uint64(videoUploaded.FileLength),
-			FileSHA256:    videoUploaded.FileSHA256,
-			FileEncSHA256: videoUploaded.FileEncSHA256,
-			MediaKey:      videoUploaded.MediaKey,
-			ViewOnce:      proto.Bool(isViewOnce),
-		},
+	videoMsg := &waE2E.VideoMessage{
+		URL:           proto.String(videoUploaded.URL),
+		DirectPath:    proto.String(videoUploaded.DirectPath),
+		Mimetype:      proto.String(videoMime),
+		Caption:       proto.String(videoCaption),
+		FileLength:    proto.Uint64(videoUploaded.FileLength),
+		FileSHA256:    videoUploaded.FileSHA256,
+		FileEncSHA256: videoUploaded.FileEncSHA256,
+		MediaKey:      videoUploaded.MediaKey,
+		ViewOnce:      proto.Bool(isViewOnce),
+	}
+	if info != nil {
+		videoMsg.Width = proto.Uint32(uint32(info.Width))
+		videoMsg.Height = proto.Uint32(uint32(info.Height))
+		if info.Seconds > 0 {
+			videoMsg.Seconds = proto.Uint32(info.Seconds)
+		}
+	}
+	// Only the embedded preview is sent: a CDN thumbnail would be decrypted with the video's media key
+	if thumb != nil {
+		videoMsg.JPEGThumbnail = thumb
 	}
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	msgContent := &waE2E.Message{VideoMessage: videoMsg}
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
@@ -3672,6 +3757,19 @@ func WhatsAppSendAudio(ctx context.Context, jid string, deviceID string, rjid st
 	if !allowedAudioMimes[audioMime] {
 		return "", fmt.Errorf("Audio MIME type %s is not allowed", audioMime)
 	}
+	// Voice notes only play as such when they are OGG/Opus with a duration and waveform
+	var seconds *uint32
+	var waveform []byte
+	if isVoiceNote {
+		note, err := prepareVoiceNote(ctx, deviceID, audioBytes, audioMime)
+		if err != nil {
+			return "", err
+		}
+		if note != nil {
+			audioBytes, audioMime = note.Data, note.MimeType
+			seconds, waveform = proto.Uint32(note.Seconds), note.Waveform
+		}
+	}
 	remoteJID, err := WhatsAppCheckJID(ctx, jid, deviceID, rjid)
 	if err != nil {
 		return "", err
@@ -3696,16 +3794,18 @@ func WhatsAppSendAudio(ctx context.Context, jid string, deviceID string, rjid st
 			FileEncSHA256: audioUploaded.FileEncSHA256,
 			MediaKey:      audioUploaded.MediaKey,
 			PTT:           proto.Bool(isVoiceNote),
+			Seconds:       seconds,
+			Waveform:      waveform,
 		},
 	}
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
 	return msgExtra.ID, nil
 }
 
-func WhatsAppSendSticker(ctx context.Context, jid string, deviceID string, rjid string, stickerBytes []byte, opts *SendOptions) (string, error) {
+func WhatsAppSendSticker(ctx context.Context, jid string, deviceID string, rjid string, stickerBytes []byte, pack transcode.StickerMetadata, opts *SendOptions) (string, error) {
 	client, err := currentClient(jid, deviceID)
 	if err != nil {
 		return "", err
@@ -3719,10 +3819,11 @@ func WhatsAppSendSticker(ctx context.Context, jid string, deviceID string, rjid
 	if err := enforceSizeLimit("sticker", int64(len(stickerBytes)), maxImageBytes); err != nil {
 		return "", err
 	}
-	stickerMime := detectMime(stickerBytes, "image/webp")
-	if !allowedStickerMimes[stickerMime] {
-		return "", fmt.Errorf("Sticker MIME type %s is not allowed. Stickers must be in WebP format", stickerMime)
+	sticker, err := transcode.BuildSticker(stickerBytes, pack)
+	if err != nil {
+		return "", err
 	}
+	stickerMime := "image/webp"
 	remoteJID, err := WhatsAppCheckJID(ctx, jid, deviceID, rjid)
 	if err != nil {
 		return "", err
@@ -3732,7 +3833,7 @@ func WhatsAppSendSticker(ctx context.Context, jid string, deviceID string, rjid
 	}
 	cleanup := beginPresenceSimulation(ctx, jid, deviceID, remoteJID, false, opts)
 	defer cleanup()
-	stickerUploaded, err := client.Upload(ctx, stickerBytes, whatsmeow.MediaImage)
+	stickerUploaded, err := client.Upload(ctx, sticker.Data, whatsmeow.MediaImage)
 	if err != nil {
 		return "", errors.New("Error While Uploading Sticker to WhatsApp Server")
 	}
@@ -3746,9 +3847,12 @@ func WhatsAppSendSticker(ctx context.Context, jid string, deviceID string, rjid
 			FileSHA256:    stickerUploaded.FileSHA256,
 			FileEncSHA256: stickerUploaded.FileEncSHA256,
 			MediaKey:      stickerUploaded.MediaKey,
+			Width:         proto.Uint32(uint32(sticker.Width)),
+			Height:        proto.Uint32(uint32(sticker.Height)),
+			IsAnimated:    proto.Bool(sticker.Animated),
 		},
 	}
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
@@ -3787,7 +3891,7 @@ func WhatsAppSendLocation(ctx context.Context, jid string, deviceID string, rjid
 			Address:          proto.String(address),
 		},
 	}
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
@@ -3827,7 +3931,7 @@ func WhatsAppSendContact(ctx context.Context, jid string, deviceID string, rjid
 			Vcard:       proto.String(vcard),
 		},
 	}
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}
@@ -4145,7 +4249,7 @@ func WhatsAppGroupGetSubGroups(ctx context.Context, jid string, deviceID string,
 	return client.GetSubGroups(ctx, communityJID)
 }
 
-func WhatsAppMessageForward(ctx context.Context, jid string, deviceID string, messageContent *waE2E.Message, toChatID string) (string, error) {
+func WhatsAppMessageForward(ctx context.Context, jid string, deviceID string, messageContent *waE2E.Message, toChatID string, opts *SendOptions) (string, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -4182,6 +4286,8 @@ func WhatsAppMessageForward(ctx context.Context, jid string, deviceID string, me
 		forwardedContent.StickerMessage = messageContent.StickerMessage
 	} else if messageContent.ContactMessage != nil {
 		forwardedContent.ContactMessage = messageContent.ContactMessage
+	} else if messageContent.ContactsArrayMessage != nil {
+		forwardedContent.ContactsArrayMessage = messageContent.ContactsArrayMessage
 	} else if messageContent.LocationMessage != nil {
 		forwardedContent.LocationMessage = messageContent.LocationMessage
 	} else if messageContent.ExtendedTextMessage != nil {
@@ -4207,6 +4313,14 @@ func WhatsAppMessageForward(ctx context.Context, jid string, deviceID string, me
 		if fs := messageContent.VideoMessage.ContextInfo.ForwardingScore; fs != nil && *fs > 0 {
 			originalForwardingScore = *fs
 		}
+	} else if messageContent.AudioMessage != nil && messageContent.AudioMessage.ContextInfo != nil {
+		if fs := messageContent.AudioMessage.ContextInfo.ForwardingScore; fs != nil && *fs > 0 {
+			originalForwardingScore = *fs
+		}
+	} else if messageContent.DocumentMessage != nil && messageContent.DocumentMessage.ContextInfo != nil {
+		if fs := messageContent.DocumentMessage.ContextInfo.ForwardingScore; fs != nil && *fs > 0 {
+			originalForwardingScore = *fs
+		}
 	}
 
 	if originalForwardingScore > 0 {
@@ -4236,6 +4350,8 @@ func WhatsAppMessageForward(ctx context.Context, jid string, deviceID string, me
 		forwardedContent.StickerMessage.ContextInfo = contextInfo
 	} else if forwardedContent.ContactMessage != nil {
 		forwardedContent.ContactMessage.ContextInfo = contextInfo
+	} else if forwardedContent.ContactsArrayMessage != nil {
+		forwardedContent.ContactsArrayMessage.ContextInfo = contextInfo
 	} else if forwardedContent.LocationMessage != nil {
 		forwardedContent.LocationMessage.ContextInfo = contextInfo
 	} else if forwardedContent.ExtendedTextMessage != nil {
@@ -4245,7 +4361,7 @@ func WhatsAppMessageForward(ctx context.Context, jid string, deviceID string, me
 	}
 
 	msgExtra := whatsmeow.SendRequestExtra{ID: client.GenerateMessageID()}
-	resp, err := client.SendMessage(ctx, toJID, forwardedContent, msgExtra)
+	resp, err := sendAndStoreMessage(ctx, client, deviceID, toJID, forwardedContent, msgExtra, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to send forwarded message: %w", err)
 	}
@@ -4848,13 +4964,21 @@ func WhatsAppGetUserDevices(ctx context.Context, jid string, deviceID string, us
 	return client.GetUserDevicesContext(ctx, []types.JID{userJID})
 }
 
-func WhatsAppGetChatHistory(jid string, deviceID string, chatJID types.JID, limit int, before string, after string) (interface{}, error) {
+func WhatsAppGetChatHistory(ctx context.Context, jid string, deviceID string, chatJID types.JID, limit int, before string, after string) (interface{}, error) {
+	if !messageStoreEnabled {
+		return nil, ErrMessageStoreDisabled
+	}
+	messages, hasMore, err := GetStoredMessages(ctx, deviceID, chatJID.String(), limit, before, after)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{
 		"chat_jid": chatJID.String(),
 		"limit":    limit,
 		"before":   before,
 		"after":    after,
-		"messages": []interface{}{},
+		"has_more": hasMore,
+		"messages": messages,
 	}, nil
 }
 
@@ -4999,12 +5123,56 @@ func WhatsAppDeleteMessage(ctx context.Context, jid string, deviceID string, cha
 	return err
 }
 
-func WhatsAppForwardMessage(jid string, deviceID string, messageID string, toChatJID types.JID) (string, error) {
-	return "", errors.New("forward message requires stored message data")
+// WhatsAppForwardMessage forwards a previously stored message by its ID.
+// Media is forwarded with its original media key and direct path, so nothing is uploaded again.
+func WhatsAppForwardMessage(ctx context.Context, jid string, deviceID string, messageID string, toChatJID types.JID, opts *SendOptions) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !messageStoreEnabled {
+		return "", ErrMessageStoreDisabled
+	}
+	original, err := GetStoredRawMessage(ctx, deviceID, messageID)
+	if err != nil {
+		return "", err
+	}
+	if original.GetImageMessage().GetViewOnce() || original.GetVideoMessage().GetViewOnce() || original.GetAudioMessage().GetViewOnce() {
+		return "", errors.New("View once messages cannot be forwarded")
+	}
+	return WhatsAppMessageForward(ctx, jid, deviceID, original, toChatJID.String(), opts)
 }
 
-func WhatsAppGetMessageThumbnail(jid string, deviceID string, messageID string) ([]byte, string, error) {
-	return nil, "image/jpeg", errors.New("thumbnail download requires stored message data")
+// WhatsAppGetMessageThumbnail returns the thumbnail of a stored message, preferring the embedded preview
+// and falling back to downloading the full-size link preview thumbnail from the CDN
+func WhatsAppGetMessageThumbnail(ctx context.Context, jid string, deviceID string, messageID string) ([]byte, string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	client, err := currentClient(jid, deviceID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = WhatsAppIsClientOK(jid, deviceID); err != nil {
+		return nil, "", err
+	}
+	msg, err := GetStoredRawMessage(ctx, deviceID, messageID)
+	if err != nil {
+		return nil, "", err
+	}
+	if data, mimeType, err := WhatsAppDownloadThumbnail(ctx, jid, deviceID, msg); err == nil {
+		return data, mimeType, nil
+	}
+	if link, ok := thumbnail.Downloadable(msg); ok {
+		data, err := client.DownloadThumbnail(ctx, link)
+		if errors.Is(err, whatsmeow.ErrUnknownMediaType) || errors.Is(err, whatsmeow.ErrNoURLPresent) {
+			return nil, "", ErrNoThumbnail
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return data, "image/jpeg", nil
+	}
+	return nil, "", ErrNoThumbnail
 }
 
 func WhatsAppGroupInfo(ctx context.Context, jid string, deviceID string, groupJID types.JID) (*types.GroupInfo, error) {
@@ -5120,6 +5288,22 @@ func WhatsAppDownloadMedia(ctx context.Context, jid string, deviceID string, msg
 	return client.Download(ctx, msg)
 }
 
+// WhatsAppDownloadMediaToFile downloads media from a message straight into file, without holding it in memory
+func WhatsAppDownloadMediaToFile(ctx context.Context, jid string, deviceID string, msg whatsmeow.DownloadableMessage, file whatsmeow.File) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	client, err := currentClient(jid, deviceID)
+	if err != nil {
+		return err
+	}
+	if err = WhatsAppIsClientOK(jid, deviceID); err != nil {
+		return err
+	}
+
+	return client.DownloadToFile(ctx, msg, file)
+}
+
 // WhatsAppDownloadMediaWithURL downloads media using a direct URL (for thumbnails, profile pics, etc.)
 func WhatsAppDownloadMediaWithURL(ctx context.Context, jid string, deviceID string, directPath string, encFileHash []byte, fileHash []byte, mediaKey []byte, fileLength int, mediaType whatsmeow.MediaType) ([]byte, error) {
 	if ctx == nil {
@@ -5138,28 +5322,11 @@ func WhatsAppDownloadMediaWithURL(ctx context.Context, jid string, deviceID stri
 
 // WhatsAppDownloadThumbnail downloads a thumbnail from a message
 func WhatsAppDownloadThumbnail(_ context.Context, _ string, _ string, msg *waE2E.Message) ([]byte, string, error) {
-	var thumbnail []byte
-	var mimeType string = "image/jpeg"
-
-	// Check various message types for thumbnails
-	if img := msg.GetImageMessage(); img != nil {
-		thumbnail = img.GetJPEGThumbnail()
-	} else if vid := msg.GetVideoMessage(); vid != nil {
-		thumbnail = vid.GetJPEGThumbnail()
-	} else if sticker := msg.GetStickerMessage(); sticker != nil {
-		thumbnail = sticker.GetPngThumbnail()
-		mimeType = "image/png"
-	} else if doc := msg.GetDocumentMessage(); doc != nil {
-		thumbnail = doc.GetJPEGThumbnail()
-	} else if link := msg.GetExtendedTextMessage(); link != nil {
-		thumbnail = link.GetJPEGThumbnail()
+	data, mimeType := thumbnail.Embedded(msg)
+	if data == nil {
+		return nil, "", ErrNoThumbnail
 	}
-
-	if len(thumbnail) == 0 {
-		return nil, "", errors.New("no thumbnail available for this message")
-	}
-
-	return thumbnail, mimeType, nil
+	return data, mimeType, nil
 }
 
 // WhatsAppSetProfilePhoto sets the current user's profile photo
@@ -5943,7 +6110,7 @@ func WhatsAppSendTextWithLinkPreview(ctx context.Context, jid string, deviceID s
 		ExtendedTextMessage: extendedText,
 	}
 
-	_, err = client.SendMessage(ctx, remoteJID, msgContent, msgExtra)
+	_, err = sendAndStoreMessage(ctx, client, deviceID, remoteJID, msgContent, msgExtra, opts)
 	if err != nil {
 		return "", err
 	}